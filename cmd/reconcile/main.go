@@ -0,0 +1,118 @@
+// Command reconcile compares Paystack's charges and transfers for a date range with our
+// payment intents, transaction log and withdrawals, and prints the discrepancies found.
+//
+//	go run ./cmd/reconcile -from 2026-10-01 -to 2026-10-18 [-fix] [-format json]
+//
+// It exits with status 1 when discrepancies remain unresolved.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+)
+
+func main() {
+	fromFlag := flag.String("from", "", "start of the range (YYYY-MM-DD or RFC3339); defaults to 24 hours ago")
+	toFlag := flag.String("to", "", "end of the range, exclusive (YYYY-MM-DD or RFC3339); defaults to now")
+	fix := flag.Bool("fix", false, "replay missed Paystack events for the safe cases (missing credits, unsettled transfers)")
+	format := flag.String("format", "text", "report format: text or json")
+	flag.Parse()
+
+	to := time.Now()
+	if *toFlag != "" {
+		to = parseTime("to", *toFlag)
+	}
+	from := to.Add(-24 * time.Hour)
+	if *fromFlag != "" {
+		from = parseTime("from", *fromFlag)
+	}
+	if !from.Before(to) {
+		log.Fatalf("FATAL: -from (%s) must be before -to (%s)", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+	if *format != "text" && *format != "json" {
+		log.Fatalf("FATAL: Unknown -format %q; use text or json", *format)
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("FATAL: Failed to load configuration: %v", err)
+	}
+	supabaseService, err := services.NewSupabaseService(cfg)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to initialize Supabase service: %v", err)
+	}
+	paystackService, err := services.NewPaystackService(cfg)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to initialize Paystack service: %v", err)
+	}
+
+	report, err := paystackService.Reconcile(from, to, *fix, supabaseService)
+	if err != nil {
+		log.Fatalf("FATAL: Reconciliation failed: %v", err)
+	}
+
+	if *format == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			log.Fatalf("FATAL: Failed to write report: %v", err)
+		}
+	} else {
+		printReport(report)
+	}
+
+	if report.Unresolved() > 0 {
+		os.Exit(1)
+	}
+}
+
+// parseTime parses a flag given as a date (midnight UTC) or an RFC3339 timestamp.
+func parseTime(name, value string) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.Fatalf("FATAL: Invalid -%s %q; use YYYY-MM-DD or RFC3339", name, value)
+	}
+	return t
+}
+
+// printReport writes the report as plain text, one line per discrepancy.
+func printReport(report *models.ReconciliationReport) {
+	fmt.Printf("Reconciliation %s to %s (auto-fix: %t)\n", report.From.Format(time.RFC3339), report.To.Format(time.RFC3339), report.AutoFix)
+	fmt.Printf("Paystack: %d charge(s), %d transfer(s). Ours: %d payment intent(s), %d withdrawal(s).\n",
+		report.PaystackTransactions, report.PaystackTransfers, report.PaymentIntents, report.Withdrawals)
+	fmt.Printf("%d discrepancy(ies), %d fixed, %d unresolved\n", len(report.Discrepancies), report.Fixed, report.Unresolved())
+
+	for _, d := range report.Discrepancies {
+		state := "open"
+		switch {
+		case d.Fixed:
+			state = "fixed"
+		case d.FixError != "":
+			state = "fix failed: " + d.FixError
+		case d.Fixable:
+			state = "fixable with -fix"
+		}
+		fmt.Printf("  %-26s %-32s paystack=%s/%s ours=%s/%s  %s [%s]\n",
+			d.Kind, d.Reference, d.PaystackStatus, formatAmount(d.PaystackAmount),
+			d.RecordedStatus, formatAmount(d.RecordedAmount), d.Detail, state)
+	}
+}
+
+// formatAmount prints an optional amount in kobo, or "-" when absent.
+func formatAmount(amount *int64) string {
+	if amount == nil {
+		return "-"
+	}
+	return fmt.Sprint(*amount)
+}
@@ -0,0 +1,90 @@
+// Command statement exports a user's transaction statement for a date range as CSV,
+// JSON Lines or OFX, with opening and closing balances, running balances and totals per
+// operation. The statement is streamed to the output as it is read.
+//
+//	go run ./cmd/statement -user <user_id> -from 2026-09-01 -to 2026-09-30 [-format ofx] [-out statement.ofx]
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+)
+
+func main() {
+	userID := flag.String("user", "", "ID of the user whose statement to export (required)")
+	fromFlag := flag.String("from", "", "start of the statement (YYYY-MM-DD or RFC3339); defaults to 30 days before -to")
+	toFlag := flag.String("to", "", "end of the statement: exclusive RFC3339 time, or its last day (YYYY-MM-DD); defaults to now")
+	format := flag.String("format", models.StatementFormatCSV, "statement format: csv, jsonl or ofx")
+	outPath := flag.String("out", "", "file to write the statement to; defaults to standard output")
+	flag.Parse()
+
+	if *userID == "" {
+		log.Fatal("FATAL: -user is required")
+	}
+	switch *format {
+	case models.StatementFormatCSV, models.StatementFormatJSONL, models.StatementFormatOFX:
+	default:
+		log.Fatalf("FATAL: Unknown -format %q; use csv, jsonl or ofx", *format)
+	}
+
+	to := time.Now()
+	if *toFlag != "" {
+		to = parseTime("to", *toFlag, true)
+	}
+	from := to.AddDate(0, 0, -30)
+	if *fromFlag != "" {
+		from = parseTime("from", *fromFlag, false)
+	}
+	if !from.Before(to) {
+		log.Fatalf("FATAL: -from (%s) must be before -to (%s)", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Fatalf("FATAL: Failed to load configuration: %v", err)
+	}
+	supabaseService, err := services.NewSupabaseService(cfg)
+	if err != nil {
+		log.Fatalf("FATAL: Failed to initialize Supabase service: %v", err)
+	}
+
+	var out io.Writer = os.Stdout
+	if *outPath != "" {
+		file, err := os.Create(*outPath)
+		if err != nil {
+			log.Fatalf("FATAL: Failed to create %s: %v", *outPath, err)
+		}
+		defer file.Close()
+		out = file
+	}
+
+	if err := supabaseService.WriteStatement(out, *format, *userID, from, to); err != nil {
+		log.Fatalf("FATAL: Failed to export statement: %v", err)
+	}
+	if *outPath != "" {
+		log.Printf("INFO: Statement for UserID %s from %s to %s written to %s", *userID, from.Format(time.RFC3339), to.Format(time.RFC3339), *outPath)
+	}
+}
+
+// parseTime parses a flag given as an RFC3339 time or a date (midnight UTC). With
+// endOfDay, a date means the end of that day, so the statement includes its last day.
+func parseTime(name, value string, endOfDay bool) time.Time {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		log.Fatalf("FATAL: Invalid -%s %q; use YYYY-MM-DD or RFC3339", name, value)
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
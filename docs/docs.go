@@ -24,14 +24,87 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/databytes/purchase": {
+        "/admin/databyte-rates": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the versions of the datacredit to databyte rate, latest effective first, including those scheduled for the future. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Databyte Rates",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "default": false,
+                        "description": "Also list cancelled versions",
+                        "name": "include_cancelled",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of versions to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Rate versions",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.DatabyteRate"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing rates",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Purchase databytes using the authenticated user's datacredit balance.",
+                "description": "Schedule a new version of the datacredit to databyte rate, taking effect at effective_from or immediately. A purchase is priced at the tier with the highest min_databytes not above its amount; the first tier must start at 1. Versions are never edited; schedule a new one to change the rate. Admin only.",
                 "consumes": [
                     "application/json"
                 ],
@@ -39,41 +112,47 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Databytes"
+                    "Admin"
                 ],
-                "summary": "Purchase Databytes",
+                "summary": "Schedule Databyte Rate",
                 "parameters": [
                     {
-                        "description": "Purchase details including databyte_amount",
-                        "name": "purchaseRequest",
+                        "description": "When the rate takes effect, its volume tiers and a note",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.DatabytePurchaseRequest"
+                            "$ref": "#/definitions/models.DatabyteRateRequest"
                         }
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Updated wallet information after the purchase",
+                    "201": {
+                        "description": "The scheduled rate version",
                         "schema": {
-                            "$ref": "#/definitions/models.Wallet"
+                            "$ref": "#/definitions/models.DatabyteRate"
                         }
                     },
                     "400": {
-                        "description": "Invalid input, insufficient datacredits, or positive databyte amount required",
+                        "description": "Invalid request payload, invalid tiers, a time in the past, or a version already scheduled for that time",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "401": {
-                        "description": "User not authenticated or UserID mismatch",
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "500": {
-                        "description": "Internal server error during databyte purchase",
+                        "description": "Internal server error scheduling the rate",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -81,45 +160,189 @@ const docTemplate = `{
                 }
             }
         },
-        "/payments/initialize": {
-            "post": {
+        "/admin/databyte-rates/{id}": {
+            "delete": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Start a new Paystack payment transaction to purchase datacredits. User must be authenticated.",
-                "consumes": [
+                "description": "Cancel a scheduled version of the databyte rate before it takes effect. A version that has taken effect cannot be cancelled, since purchases may have been priced at it; schedule a new one instead. Admin only.",
+                "produces": [
                     "application/json"
                 ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Cancel Databyte Rate",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Rate version",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The cancelled rate version",
+                        "schema": {
+                            "$ref": "#/definitions/models.DatabyteRate"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid rate version",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Rate version not found or already cancelled",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Rate version has already taken effect",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error cancelling the rate",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/disputes": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List chargebacks raised by cardholders, newest first. Admin only.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "Payments"
+                    "Admin"
                 ],
-                "summary": "Initialize Payment for Datacredit",
+                "summary": "List Disputes",
                 "parameters": [
                     {
-                        "description": "Payment details including email and amount in kobo",
-                        "name": "paymentRequest",
-                        "in": "body",
-                        "required": true,
+                        "enum": [
+                            "open",
+                            "evidence_submitted",
+                            "won",
+                            "lost"
+                        ],
+                        "type": "string",
+                        "description": "Only disputes in this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of disputes to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Disputes",
                         "schema": {
-                            "$ref": "#/definitions/models.PaystackInitializeRequest"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Dispute"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing disputes",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     }
+                }
+            }
+        },
+        "/admin/disputes/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a dispute, including its evidence deadline and how much of the disputed amount is frozen in the user's wallet. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get Dispute",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Dispute ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
                 ],
                 "responses": {
                     "200": {
-                        "description": "Returns message, authorization_url, access_code, and reference from Paystack",
+                        "description": "The dispute",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.Dispute"
                         }
                     },
                     "400": {
-                        "description": "Invalid request payload",
+                        "description": "Invalid dispute ID",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -130,14 +353,20 @@ const docTemplate = `{
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error during payment initialization",
+                    "403": {
+                        "description": "Admin access required",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "503": {
-                        "description": "Service Unavailable: Error from Paystack",
+                    "404": {
+                        "description": "Dispute not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the dispute",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -145,14 +374,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/payments/withdraw": {
+        "/admin/disputes/{id}/accept": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Initiate a withdrawal of datacredit for an authenticated user.",
+                "description": "Accept a dispute through Paystack, refunding the cardholder. The refunded amount is debited from the user's wallet; whatever they have already spent is recorded as a debt and collected from their next purchases. Admin only.",
                 "consumes": [
                     "application/json"
                 ],
@@ -160,50 +389,72 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Payments"
+                    "Admin"
                 ],
-                "summary": "Initiate Datacredit Withdrawal",
+                "summary": "Accept Dispute",
                 "parameters": [
                     {
-                        "description": "Withdrawal details including amount in kobo",
-                        "name": "withdrawalRequest",
+                        "type": "string",
+                        "description": "Dispute ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Message, optional refund amount in kobo and the uploaded evidence file name",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.WithdrawalRequest"
+                            "$ref": "#/definitions/models.DisputeAcceptRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "message, transfer_code",
+                        "description": "The dispute, lost",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Dispute"
                         }
                     },
                     "400": {
-                        "description": "Invalid input or insufficient datacredit balance",
+                        "description": "Invalid request payload or dispute ID, or a refund amount above the disputed amount",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "401": {
-                        "description": "User not authenticated or UserID mismatch",
+                        "description": "User not authenticated",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error during withdrawal initiation",
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Dispute not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Dispute is already resolved",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Paystack rejected the acceptance",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "503": {
-                        "description": "Service Unavailable: Error from Paystack during withdrawal",
+                        "description": "Paystack could not be reached",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
@@ -211,9 +462,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/webhooks/paystack": {
+        "/admin/disputes/{id}/evidence": {
             "post": {
-                "description": "Endpoint for Paystack to send asynchronous payment and transfer notifications. Signature is verified.",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Send evidence that the disputed purchase was genuine to Paystack. The disputed datacredit stays frozen until Paystack resolves the dispute. Admin only.",
                 "consumes": [
                     "application/json"
                 ],
@@ -221,118 +477,3876 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "Webhooks"
+                    "Admin"
                 ],
-                "summary": "Handle Paystack Webhook Events",
+                "summary": "Submit Dispute Evidence",
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Paystack signature for webhook verification",
-                        "name": "X-Paystack-Signature",
-                        "in": "header",
+                        "description": "Dispute ID",
+                        "name": "id",
+                        "in": "path",
                         "required": true
                     },
                     {
-                        "description": "Raw Paystack Webhook Event Payload",
-                        "name": "webhookEvent",
+                        "description": "Customer and service details",
+                        "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/models.PaystackWebhookPayload"
+                            "$ref": "#/definitions/models.DisputeEvidenceRequest"
                         }
                     }
                 ],
                 "responses": {
                     "200": {
-                        "description": "status: 'Webhook processed'",
+                        "description": "The dispute, with evidence submitted",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": {
-                                "type": "string"
-                            }
+                            "$ref": "#/definitions/models.Dispute"
                         }
                     },
                     "400": {
-                        "description": "Invalid payload or missing signature",
+                        "description": "Invalid request payload or dispute ID",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
                     "401": {
-                        "description": "Webhook signature verification failed",
+                        "description": "User not authenticated",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal server error processing webhook",
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Dispute not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Dispute is already resolved",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Paystack rejected the evidence",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Paystack could not be reached",
                         "schema": {
                             "$ref": "#/definitions/utils.ErrorResponse"
                         }
                     }
                 }
             }
-        }
-    },
+        },
+        "/admin/disputes/{id}/upload-url": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a signed Paystack URL to upload an evidence file (e.g. a receipt or usage log) for an open dispute to. The returned file name is passed when accepting the dispute. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get Dispute Evidence Upload URL",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Dispute ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Name of the file to upload, e.g. receipt.pdf",
+                        "name": "filename",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Signed upload URL and file name",
+                        "schema": {
+                            "$ref": "#/definitions/models.DisputeUploadURL"
+                        }
+                    },
+                    "400": {
+                        "description": "Missing filename or dispute ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Dispute not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Dispute is already resolved",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Paystack rejected the request",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Paystack could not be reached",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/ledger/check": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Recompute every wallet's balances from its transaction history, ledger accounts and active holds, and check the platform-wide invariants (the ledger sums to zero, and deposits minus withdrawals and other transactions equal the datacredit outstanding). Reports drifted wallets, failed invariants and the transaction totals per operation, and updates the ledger metrics at /debug/vars. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Check Ledger Invariants",
+                "responses": {
+                    "200": {
+                        "description": "Check result; healthy is false when anything disagrees",
+                        "schema": {
+                            "$ref": "#/definitions/models.LedgerCheckReport"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error running the check",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/payment-reviews": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List Paystack charges that were not credited automatically because they did not match their payment intent (unknown reference, amount, currency or user mismatch, or an intent that had failed), oldest first. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Payment Reviews",
+                "parameters": [
+                    {
+                        "enum": [
+                            "open",
+                            "resolved"
+                        ],
+                        "type": "string",
+                        "default": "open",
+                        "description": "Only reviews in this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of reviews to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Payment reviews",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PaymentReview"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing payment reviews",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/payment-reviews/{id}/resolve": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Close an open payment review. credit pays the amount Paystack received into the wallet of the user who started the payment and marks the payment intent succeeded; it is refused for charges with no payment intent or in another currency. dismiss leaves the wallet alone and fails the payment intent, e.g. when the charge is refunded instead. Admin only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Resolve Payment Review",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Payment review ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "credit or dismiss, and a note for the audit trail",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.ResolvePaymentReviewRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The resolved review and its payment intent",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaymentReviewResolution"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or review ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Payment review not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Review already resolved, or the charge cannot be credited",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error resolving the review",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/payout-batches": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the bulk transfer batches sent in payout-batching mode, newest first. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Payout Batches",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of batches to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Payout batches",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PayoutBatch"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing payout batches",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/payout-batches/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a payout batch with Paystack's per-transfer results and the current state of each of its withdrawals. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get Payout Batch",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payout batch ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The batch and its withdrawals",
+                        "schema": {
+                            "$ref": "#/definitions/models.PayoutBatchDetail"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payout batch ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Payout batch not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the payout batch",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/refunds": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List refunds, newest first. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Refunds",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only refunds of the payment with this Paystack reference",
+                        "name": "reference",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "pending",
+                            "processing",
+                            "processed",
+                            "failed"
+                        ],
+                        "type": "string",
+                        "description": "Only refunds in this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of refunds to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Refunds",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Refund"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing refunds",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Refund all or part of a datacredit purchase through Paystack. The refunded datacredit is debited from the user's wallet; whatever they have already spent is recorded as a debt and collected from their next purchases. If the refund later fails, the debited datacredit is returned. Admin only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Issue Refund",
+                "parameters": [
+                    {
+                        "description": "Paystack reference of the payment, optional amount in kobo (everything not yet refunded if omitted) and reason",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.RefundRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The refund, pending or processing at Paystack",
+                        "schema": {
+                            "$ref": "#/definitions/models.Refund"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No payment with this reference",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Payment did not succeed or the amount exceeds what is left to refund",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error recording the refund; nothing was sent to Paystack",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Paystack rejected the refund; the wallet was not debited",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Paystack could not be reached; the refund stays pending until its webhook arrives",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/refunds/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a refund, including how much was debited from the wallet and how much was recorded as a debt. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get Refund",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Refund ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The refund",
+                        "schema": {
+                            "$ref": "#/definitions/models.Refund"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid refund ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Refund not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the refund",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{userId}/balance": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reconstruct a user's datacredit and databyte balance at a moment from the transaction log, for settling disputes and month-end reports. Each balance is the last transaction's balance_after when recorded, otherwise the sum of all amounts so far; both are returned along with the last transaction that contributed, and consistent is false when they disagree. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Get User Balance As Of",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Moment to reconstruct the balance at (RFC3339), or the end of a day (YYYY-MM-DD); defaults to now and must not be in the future",
+                        "name": "as_of",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The user's balances at the moment",
+                        "schema": {
+                            "$ref": "#/definitions/models.BalanceAsOf"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID, or an invalid or future as_of",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error reconstructing the balance",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/users/{userId}/withdrawal-review": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Require admin approval for all of a user's future withdrawals, whatever their amount. Admin only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Flag User for Withdrawal Review",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason for the flag",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WithdrawalReviewFlagRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The user's flag",
+                        "schema": {
+                            "$ref": "#/definitions/models.WithdrawalReviewFlag"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or user ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error flagging the user",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Stop requiring admin approval for a user's withdrawals below the approval threshold. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Unflag User for Withdrawal Review",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "User ID",
+                        "name": "userId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid user ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "User is not flagged",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error removing the flag",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/withdrawals/approvals": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List all users' withdrawals awaiting admin approval, oldest first. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "List Withdrawals Awaiting Approval",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of withdrawals to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Withdrawals in pending_approval",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Withdrawal"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing withdrawals",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/withdrawals/{id}/approve": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Approve a withdrawal awaiting approval and start its Paystack transfer. The approving admin must not be the user who requested it. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Approve Withdrawal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Withdrawal ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "message, transfer_code and the withdrawal record",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid withdrawal ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required, or the admin requested the withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Withdrawal not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Withdrawal is not awaiting approval",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Approved, but the Paystack transfer could not be started",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/withdrawals/{id}/finalize": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Submit the OTP Paystack sent for a withdrawal awaiting OTP confirmation, sending its transfer. If Paystack rejects the OTP the withdrawal keeps waiting and the OTP can be retried. Admin only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Finalize Withdrawal Transfer",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Withdrawal ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "The transfer OTP",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.TransferOTPRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The withdrawal, now processing",
+                        "schema": {
+                            "$ref": "#/definitions/models.Withdrawal"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or withdrawal ID, or OTP rejected by Paystack",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Withdrawal not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Withdrawal is not awaiting an OTP",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Paystack could not be reached or did not confirm the transfer; it may have been sent, so check the withdrawal before retrying",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/withdrawals/{id}/reject": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Reject a withdrawal awaiting approval, releasing its held funds back to the user's available balance. Admin only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Reject Withdrawal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Withdrawal ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Reason for the rejection",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WithdrawalRejectionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The rejected withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/models.Withdrawal"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or withdrawal ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Withdrawal not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Withdrawal is not awaiting approval",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error rejecting the withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/admin/withdrawals/{id}/resend-otp": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Ask Paystack to send a new OTP for a withdrawal awaiting OTP confirmation. Admin only.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Admin"
+                ],
+                "summary": "Resend Withdrawal Transfer OTP",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Withdrawal ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid withdrawal ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Admin access required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Withdrawal not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Withdrawal is not awaiting an OTP",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Paystack could not resend the OTP",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/banks": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the banks and bank codes Paystack supports for a country and currency, for setting up payout accounts. Lists are cached in memory; admins may pass refresh=true to fetch a fresh list from Paystack.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Banks"
+                ],
+                "summary": "List Banks",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "default": "nigeria",
+                        "description": "Country name as used by Paystack",
+                        "name": "country",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "default": "NGN",
+                        "description": "Currency code",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Bypass the cache and fetch the list from Paystack (admin only)",
+                        "name": "refresh",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Banks for the country and currency",
+                        "schema": {
+                            "$ref": "#/definitions/models.BankList"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "refresh requested by a non-admin",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "The bank list could not be fetched from Paystack",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/databytes/purchase": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Purchase databytes using the authenticated user's datacredit balance, priced at the current databyte rate and volume tier (see /databytes/quote). Pass the quote's rate_version as expected_rate_version, or a max_cost_kobo, to refuse the purchase if the rate has changed since. The rate version and tier applied are recorded in the transaction metadata.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Databytes"
+                ],
+                "summary": "Purchase Databytes",
+                "parameters": [
+                    {
+                        "description": "Purchase details including databyte_amount",
+                        "name": "purchaseRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.DatabytePurchaseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Updated wallet information after the purchase",
+                        "schema": {
+                            "$ref": "#/definitions/models.Wallet"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input, insufficient datacredits, or positive databyte amount required",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated or UserID mismatch",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Rate version changed or cost above max_cost_kobo",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error during databyte purchase",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/databytes/quote": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Show the datacredit cost of purchasing an amount of databytes at the current rate and volume tier, before purchasing them.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Databytes"
+                ],
+                "summary": "Quote Databytes",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Amount of databytes to purchase",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Cost in kobo, the rate applied and its version",
+                        "schema": {
+                            "$ref": "#/definitions/models.DatabyteQuote"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid or too large amount",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error quoting the purchase",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/initialize": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Start a new Paystack payment transaction to purchase datacredits. User must be authenticated.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "Initialize Payment for Datacredit",
+                "parameters": [
+                    {
+                        "description": "Payment details including email and amount in kobo",
+                        "name": "paymentRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PaystackInitializeRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Returns message, authorization_url, access_code, and reference from Paystack",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error during payment initialization",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable: Error from Paystack",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/intents/{reference}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Poll the status of one of the authenticated user's payment intents by its Paystack reference.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "Get Payment Intent Status",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Paystack transaction reference returned by /payments/initialize",
+                        "name": "reference",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The payment intent and its current status",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaymentIntent"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No payment intent with this reference for the user",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the payment intent",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/verify/{reference}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Verify one of the authenticated user's payment references with Paystack. If Paystack reports the charge as successful and the webhook has not credited it yet, the credit is applied here through the same idempotent path the webhook uses.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "Verify Payment",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Paystack transaction reference returned by /payments/initialize",
+                        "name": "reference",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Paystack's status for the reference and the updated payment intent",
+                        "schema": {
+                            "$ref": "#/definitions/models.PaymentVerification"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No payment intent with this reference for the user",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error applying the payment",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "502": {
+                        "description": "Paystack could not verify the reference",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/withdraw": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Initiate a withdrawal of datacredit for an authenticated user. Large withdrawals, and withdrawals by users flagged for review, are held in pending_approval until an admin approves them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "Initiate Datacredit Withdrawal",
+                "parameters": [
+                    {
+                        "description": "Withdrawal details including amount in kobo (the fee is deducted from it) and an optional payout account ID (recipient_id)",
+                        "name": "withdrawalRequest",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.WithdrawalRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "message, transfer_code and the withdrawal record",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input, insufficient datacredit balance, amount outside the withdrawal limits, no payout account or a payout account not in NGN",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated or UserID mismatch",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Payout account not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error during withdrawal initiation",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "503": {
+                        "description": "Service Unavailable: Error from Paystack during withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/withdrawals": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the authenticated user's withdrawals, newest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "List Withdrawals",
+                "parameters": [
+                    {
+                        "enum": [
+                            "pending_approval",
+                            "queued",
+                            "pending",
+                            "awaiting_otp",
+                            "processing",
+                            "completed",
+                            "failed",
+                            "reversed",
+                            "rejected"
+                        ],
+                        "type": "string",
+                        "description": "Only withdrawals in this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 0,
+                        "description": "Number of withdrawals to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The user's withdrawals",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Withdrawal"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid limit or offset",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing withdrawals",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/withdrawals/quote": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Show the fee and net payout in NGN for withdrawing an amount to a payout account (the default one unless recipient_id is given), and the withdrawal limits that apply, before confirming the withdrawal.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "Quote Withdrawal",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Amount of datacredit (kobo) to withdraw",
+                        "name": "amount",
+                        "in": "query",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Payout account ID; defaults to the user's default payout account",
+                        "name": "recipient_id",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Fee, net payout and limits",
+                        "schema": {
+                            "$ref": "#/definitions/models.WithdrawalQuote"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid amount or recipient_id, amount outside the withdrawal limits, no payout account set up or a payout account not in NGN",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Payout account not found",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error quoting the withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payments/withdrawals/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get one of the authenticated user's withdrawals with its status history, including when each status was entered and why a withdrawal failed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payments"
+                ],
+                "summary": "Get Withdrawal",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Withdrawal ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The withdrawal and its status history",
+                        "schema": {
+                            "$ref": "#/definitions/models.WithdrawalDetail"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid withdrawal ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No withdrawal with this ID for the user",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the withdrawal",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payout-accounts": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the authenticated user's payout bank accounts, most recently added first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout Accounts"
+                ],
+                "summary": "List Payout Bank Accounts",
+                "responses": {
+                    "200": {
+                        "description": "The user's payout accounts",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.PayoutAccount"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing payout accounts",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Add a bank account for withdrawals. The account name is resolved through Paystack and a Paystack transfer recipient is created for it. The first account added becomes the default.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout Accounts"
+                ],
+                "summary": "Add Payout Bank Account",
+                "parameters": [
+                    {
+                        "description": "Bank code and account number",
+                        "name": "payoutAccount",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.AddPayoutAccountRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "The added payout account",
+                        "schema": {
+                            "$ref": "#/definitions/models.PayoutAccount"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid input, a currency other than NGN, or the account could not be resolved",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "The account has already been added",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error adding the payout account",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payout-accounts/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Remove one of the authenticated user's payout accounts. If it was the default, the most recently added remaining account becomes the default. An account cannot be removed while a withdrawal to it is still in progress.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout Accounts"
+                ],
+                "summary": "Remove Payout Bank Account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payout account ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "message",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payout account ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No payout account with this ID for the user",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "A withdrawal to the account is still in progress",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error removing the payout account",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/payout-accounts/{id}/default": {
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Make one of the authenticated user's payout accounts the default for withdrawals.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Payout Accounts"
+                ],
+                "summary": "Set Default Payout Bank Account",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Payout account ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The new default payout account",
+                        "schema": {
+                            "$ref": "#/definitions/models.PayoutAccount"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payout account ID",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No payout account with this ID for the user",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error updating the payout account",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the authenticated user's profile.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get My Profile",
+                "responses": {
+                    "200": {
+                        "description": "The user's profile",
+                        "schema": {
+                            "$ref": "#/definitions/models.Profile"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "The user has no profile",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the profile",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update the authenticated user's name, username or image URL. Omitted fields are left unchanged; an empty name or image_url clears it. Usernames may contain letters, digits, '_' and '.', and must be unique regardless of case.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Update My Profile",
+                "parameters": [
+                    {
+                        "description": "Profile fields to change",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.UpdateProfileRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The updated profile",
+                        "schema": {
+                            "$ref": "#/definitions/models.Profile"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request payload or field",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "The user has no profile",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Username is already taken",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error updating the profile",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile/wallet": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the authenticated user's wallet: datacredit balance (kobo), the part held for pending withdrawals and disputes, the datacredit available to spend or withdraw, and the databyte balance.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Profile"
+                ],
+                "summary": "Get My Wallet Balance",
+                "responses": {
+                    "200": {
+                        "description": "The user's wallet balances",
+                        "schema": {
+                            "$ref": "#/definitions/models.WalletBalance"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error fetching the wallet",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the authenticated user's wallet transactions, newest first, with cursor pagination. Pass next_cursor from a page as cursor to fetch the following page; pages stay stable while new transactions arrive.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Transactions"
+                ],
+                "summary": "List Transactions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Cursor returned as next_cursor by the previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "default": 20,
+                        "description": "Page size (max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only transactions of this operation, e.g. credit_purchase or withdrawal",
+                        "name": "operation",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "datacredit",
+                            "databyte"
+                        ],
+                        "type": "string",
+                        "description": "Only transactions in this currency",
+                        "name": "currency",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only transactions with this external reference, e.g. a Paystack reference",
+                        "name": "reference",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only transactions at or after this time (YYYY-MM-DD or RFC3339)",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Only transactions before this time (RFC3339), or on or before this day (YYYY-MM-DD)",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "A page of transactions",
+                        "schema": {
+                            "$ref": "#/definitions/models.TransactionPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid cursor, limit, currency or date",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error listing transactions",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/transactions/statement": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Download a statement of the authenticated user's transactions for a date range as CSV, JSON Lines or OFX. It includes the opening and closing balance of each currency, the running balance after each transaction and the totals per operation. Amounts are in kobo for datacredit and units for databytes (naira for datacredit in OFX). The statement is streamed as it is read.",
+                "produces": [
+                    "text/plain"
+                ],
+                "tags": [
+                    "Transactions"
+                ],
+                "summary": "Export Statement",
+                "parameters": [
+                    {
+                        "enum": [
+                            "csv",
+                            "jsonl",
+                            "ofx"
+                        ],
+                        "type": "string",
+                        "default": "csv",
+                        "description": "Statement format",
+                        "name": "format",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the statement (YYYY-MM-DD or RFC3339); defaults to 30 days before to",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the statement, exclusive (RFC3339), or its last day (YYYY-MM-DD); defaults to now",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "The statement",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid format or date",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "User not authenticated",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error exporting the statement",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/paystack": {
+            "post": {
+                "description": "Endpoint for Paystack to send asynchronous payment and transfer notifications. Signature is verified.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Webhooks"
+                ],
+                "summary": "Handle Paystack Webhook Events",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Paystack signature for webhook verification",
+                        "name": "X-Paystack-Signature",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Raw Paystack Webhook Event Payload",
+                        "name": "webhookEvent",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/models.PaystackWebhookPayload"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "status: 'Webhook processed' or 'Duplicate webhook ignored'",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": {
+                                "type": "string"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid payload or missing signature",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Webhook signature verification failed",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error processing webhook",
+                        "schema": {
+                            "$ref": "#/definitions/utils.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
     "definitions": {
+        "models.AddPayoutAccountRequest": {
+            "type": "object",
+            "required": [
+                "account_number",
+                "bank_code"
+            ],
+            "properties": {
+                "account_number": {
+                    "type": "string"
+                },
+                "bank_code": {
+                    "type": "string"
+                },
+                "currency": {
+                    "description": "Defaults to NGN, the only supported payout currency",
+                    "type": "string"
+                },
+                "is_default": {
+                    "description": "The first account added is always the default",
+                    "type": "boolean"
+                }
+            }
+        },
+        "models.BalanceAsOf": {
+            "type": "object",
+            "properties": {
+                "as_of": {
+                    "type": "string"
+                },
+                "databyte": {
+                    "$ref": "#/definitions/models.PointInTimeBalance"
+                },
+                "datacredit": {
+                    "description": "kobo",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.PointInTimeBalance"
+                        }
+                    ]
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Bank": {
+            "type": "object",
+            "properties": {
+                "active": {
+                    "type": "boolean"
+                },
+                "code": {
+                    "type": "string"
+                },
+                "country": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "longcode": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "slug": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.BankList": {
+            "type": "object",
+            "properties": {
+                "banks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Bank"
+                    }
+                },
+                "country": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "fetched_at": {
+                    "description": "When the list was fetched from Paystack",
+                    "type": "string"
+                }
+            }
+        },
         "models.DatabytePurchaseRequest": {
             "type": "object",
-            "required": [
-                "databyte_amount",
-                "user_id"
-            ],
+            "required": [
+                "databyte_amount",
+                "user_id"
+            ],
+            "properties": {
+                "databyte_amount": {
+                    "type": "integer"
+                },
+                "expected_rate_version": {
+                    "description": "Refuse the purchase unless priced at this rate version",
+                    "type": "integer"
+                },
+                "max_cost_kobo": {
+                    "description": "Refuse the purchase if it costs more",
+                    "type": "integer"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DatabyteQuote": {
+            "type": "object",
+            "properties": {
+                "cost_kobo": {
+                    "type": "integer"
+                },
+                "databyte_amount": {
+                    "type": "integer"
+                },
+                "per_databytes": {
+                    "description": "...per this many databytes",
+                    "type": "integer"
+                },
+                "price_kobo": {
+                    "description": "The tier's price...",
+                    "type": "integer"
+                },
+                "rate_version": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.DatabyteRate": {
+            "type": "object",
+            "properties": {
+                "cancelled_at": {
+                    "type": "string"
+                },
+                "cancelled_by": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "effective_from": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "The rate version, recorded on each purchase",
+                    "type": "integer"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DatabyteRateTier"
+                    }
+                }
+            }
+        },
+        "models.DatabyteRateRequest": {
+            "type": "object",
+            "required": [
+                "tiers"
+            ],
+            "properties": {
+                "effective_from": {
+                    "type": "string"
+                },
+                "note": {
+                    "type": "string"
+                },
+                "tiers": {
+                    "type": "array",
+                    "minItems": 1,
+                    "items": {
+                        "$ref": "#/definitions/models.DatabyteRateTier"
+                    }
+                }
+            }
+        },
+        "models.DatabyteRateTier": {
+            "type": "object",
+            "properties": {
+                "min_databytes": {
+                    "type": "integer"
+                },
+                "per_databytes": {
+                    "type": "integer"
+                },
+                "price_kobo": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Dispute": {
+            "type": "object",
+            "properties": {
+                "accepted_by": {
+                    "type": "string"
+                },
+                "amount": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "category": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "debit_journal_entry_id": {
+                    "type": "integer"
+                },
+                "debited_amount": {
+                    "description": "kobo debited when the dispute was lost",
+                    "type": "integer"
+                },
+                "debt_amount": {
+                    "description": "kobo recorded as a wallet debt when it was lost",
+                    "type": "integer"
+                },
+                "due_at": {
+                    "description": "Deadline for evidence or acceptance",
+                    "type": "string"
+                },
+                "evidence_submitted_at": {
+                    "type": "string"
+                },
+                "evidence_submitted_by": {
+                    "type": "string"
+                },
+                "held_amount": {
+                    "description": "kobo frozen in the wallet",
+                    "type": "integer"
+                },
+                "hold_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_reminded_at": {
+                    "type": "string"
+                },
+                "payment_intent_id": {
+                    "type": "string"
+                },
+                "paystack_dispute_id": {
+                    "type": "integer"
+                },
+                "paystack_status": {
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Paystack transaction reference of the disputed payment",
+                    "type": "string"
+                },
+                "resolution": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.DisputeAcceptRequest": {
+            "type": "object",
+            "required": [
+                "message",
+                "uploaded_filename"
+            ],
+            "properties": {
+                "message": {
+                    "type": "string"
+                },
+                "refund_amount": {
+                    "description": "kobo; the disputed amount if omitted",
+                    "type": "integer"
+                },
+                "uploaded_filename": {
+                    "description": "Evidence file uploaded via DisputeUploadURL",
+                    "type": "string"
+                }
+            }
+        },
+        "models.DisputeEvidenceRequest": {
+            "type": "object",
+            "required": [
+                "customer_email",
+                "customer_name",
+                "customer_phone",
+                "service_details"
+            ],
+            "properties": {
+                "customer_email": {
+                    "type": "string"
+                },
+                "customer_name": {
+                    "type": "string"
+                },
+                "customer_phone": {
+                    "type": "string"
+                },
+                "delivery_address": {
+                    "type": "string"
+                },
+                "delivery_date": {
+                    "description": "YYYY-MM-DD",
+                    "type": "string"
+                },
+                "service_details": {
+                    "description": "What the datacredit was bought and used for",
+                    "type": "string"
+                }
+            }
+        },
+        "models.DisputeUploadURL": {
+            "type": "object",
+            "properties": {
+                "fileName": {
+                    "type": "string"
+                },
+                "signedUrl": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.LedgerCheckReport": {
+            "type": "object",
+            "properties": {
+                "checked_at": {
+                    "type": "string"
+                },
+                "drifted_wallets": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WalletBalanceDrift"
+                    }
+                },
+                "failed_invariants": {
+                    "type": "integer"
+                },
+                "healthy": {
+                    "type": "boolean"
+                },
+                "invariants": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.LedgerInvariant"
+                    }
+                },
+                "transaction_totals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TransactionTotal"
+                    }
+                }
+            }
+        },
+        "models.LedgerInvariant": {
+            "type": "object",
+            "properties": {
+                "actual": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "expected": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PaymentIntent": {
+            "type": "object",
+            "properties": {
+                "access_code": {
+                    "type": "string"
+                },
+                "amount": {
+                    "description": "Expected amount in kobo",
+                    "type": "integer"
+                },
+                "authorization_url": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "last_verified_at": {
+                    "type": "string"
+                },
+                "paid_amount": {
+                    "type": "integer"
+                },
+                "paid_at": {
+                    "type": "string"
+                },
+                "paystack_status": {
+                    "description": "Last status reported by Paystack verify",
+                    "type": "string"
+                },
+                "paystack_transaction_id": {
+                    "type": "integer"
+                },
+                "purpose": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PaymentReview": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "expected_amount": {
+                    "type": "integer"
+                },
+                "expected_currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "intent_id": {
+                    "type": "string"
+                },
+                "payload": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "received_amount": {
+                    "type": "integer"
+                },
+                "received_currency": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                },
+                "resolution": {
+                    "description": "credited or dismissed",
+                    "type": "string"
+                },
+                "resolution_note": {
+                    "type": "string"
+                },
+                "resolved_at": {
+                    "type": "string"
+                },
+                "resolved_by": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PaymentReviewResolution": {
+            "type": "object",
+            "properties": {
+                "credited": {
+                    "type": "boolean"
+                },
+                "intent": {
+                    "$ref": "#/definitions/models.PaymentIntent"
+                },
+                "review": {
+                    "$ref": "#/definitions/models.PaymentReview"
+                }
+            }
+        },
+        "models.PaymentVerification": {
+            "type": "object",
+            "properties": {
+                "credited": {
+                    "description": "True if this request applied the credit",
+                    "type": "boolean"
+                },
+                "intent": {
+                    "$ref": "#/definitions/models.PaymentIntent"
+                },
+                "paystack_status": {
+                    "type": "string"
+                },
+                "reference": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PayoutAccount": {
+            "type": "object",
+            "properties": {
+                "account_name": {
+                    "description": "As resolved by Paystack",
+                    "type": "string"
+                },
+                "account_number": {
+                    "type": "string"
+                },
+                "bank_code": {
+                    "type": "string"
+                },
+                "bank_name": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "is_default": {
+                    "type": "boolean"
+                },
+                "recipient_code": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PayoutBatch": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "fee_total": {
+                    "description": "kobo kept as withdrawal fees",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "item_count": {
+                    "type": "integer"
+                },
+                "paystack_response": {
+                    "description": "Paystack's per-transfer results"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "description": "kobo sent, after fees",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PayoutBatchDetail": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "fee_total": {
+                    "description": "kobo kept as withdrawal fees",
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "item_count": {
+                    "type": "integer"
+                },
+                "paystack_response": {
+                    "description": "Paystack's per-transfer results"
+                },
+                "sent_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "total_amount": {
+                    "description": "kobo sent, after fees",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "withdrawals": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Withdrawal"
+                    }
+                }
+            }
+        },
+        "models.PaystackInitializeRequest": {
+            "type": "object",
+            "required": [
+                "amount",
+                "email"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "Amount in kobo (for datacredit purchase)",
+                    "type": "integer"
+                },
+                "email": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PaystackWebhookPayload": {
+            "type": "object",
+            "properties": {
+                "data": {},
+                "event": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.PointInTimeBalance": {
+            "type": "object",
+            "properties": {
+                "balance": {
+                    "type": "integer"
+                },
+                "consistent": {
+                    "type": "boolean"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "last_transaction": {
+                    "description": "The last transaction that contributed; null if none",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Transaction"
+                        }
+                    ]
+                },
+                "source": {
+                    "description": "BalanceSourceBalanceAfter or BalanceSourceTransactionSum",
+                    "type": "string"
+                },
+                "summed_balance": {
+                    "type": "integer"
+                },
+                "transaction_count": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.Profile": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "email": {
+                    "type": "string"
+                },
+                "id": {
+                    "description": "(UUID from auth.users)",
+                    "type": "string"
+                },
+                "image_url": {
+                    "type": "string"
+                },
+                "invite_code": {
+                    "type": "string"
+                },
+                "invited_by_user_id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "description": "Assuming 'roletype' is a string representation",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Refund": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "clawback_amount": {
+                    "description": "Debited from the wallet",
+                    "type": "integer"
+                },
+                "clawback_journal_entry_id": {
+                    "type": "integer"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "debt_amount": {
+                    "description": "Recorded as a wallet debt",
+                    "type": "integer"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "payment_intent_id": {
+                    "type": "string"
+                },
+                "paystack_refund_id": {
+                    "type": "integer"
+                },
+                "processed_at": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Paystack transaction reference of the refunded payment",
+                    "type": "string"
+                },
+                "requested_by": {
+                    "type": "string"
+                },
+                "reversal_journal_entry_id": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.RefundRequest": {
+            "type": "object",
+            "required": [
+                "reason",
+                "reference"
+            ],
+            "properties": {
+                "amount": {
+                    "description": "kobo; everything not yet refunded if omitted",
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Paystack transaction reference",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ResolvePaymentReviewRequest": {
+            "type": "object",
+            "required": [
+                "action",
+                "note"
+            ],
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "credit",
+                        "dismiss"
+                    ]
+                },
+                "note": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Transaction": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Amount in kobo for datacredit, or units for databyte",
+                    "type": "integer"
+                },
+                "balance_after": {
+                    "description": "You'll need logic to populate this",
+                    "type": "integer"
+                },
+                "balance_before": {
+                    "description": "Assuming 'transactionbalance' refers to this",
+                    "type": "integer"
+                },
+                "currency": {
+                    "description": "CurrencyDatacredit or CurrencyDatabyte",
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "external_reference_id": {
+                    "description": "e.g., Paystack reference",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "journal_entry_id": {
+                    "type": "integer"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": true
+                },
+                "operation": {
+                    "description": "Assuming 'transactionoperation' (e.g., \"credit_purchase\", \"withdrawal\", \"databyte_purchase\")",
+                    "type": "string"
+                },
+                "transaction_timestamp": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "(FK to profiles.id or auth.users.id)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.TransactionPage": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Transaction"
+                    }
+                },
+                "has_more": {
+                    "type": "boolean"
+                },
+                "next_cursor": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.TransactionTotal": {
+            "type": "object",
+            "properties": {
+                "count": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "operation": {
+                    "type": "string"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.TransferOTPRequest": {
+            "type": "object",
+            "required": [
+                "otp"
+            ],
+            "properties": {
+                "otp": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.UpdateProfileRequest": {
+            "type": "object",
+            "properties": {
+                "image_url": {
+                    "description": "http(s) URL of the profile image",
+                    "type": "string",
+                    "maxLength": 2048
+                },
+                "name": {
+                    "type": "string",
+                    "maxLength": 100
+                },
+                "username": {
+                    "description": "Letters, digits, '_' and '.'; unique regardless of case",
+                    "type": "string",
+                    "maxLength": 30,
+                    "minLength": 3
+                }
+            }
+        },
+        "models.Wallet": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "databyte_balance": {
+                    "type": "integer"
+                },
+                "datacredit_balance": {
+                    "description": "Represents NGN value in kobo",
+                    "type": "integer"
+                },
+                "held_datacredit": {
+                    "description": "Part of DatacreditBalance reserved by active holds",
+                    "type": "integer"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
+                    "description": "(FK to profiles.id or auth.users.id)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.WalletBalance": {
+            "type": "object",
             "properties": {
-                "databyte_amount": {
+                "available_datacredit": {
+                    "type": "integer"
+                },
+                "databyte_balance": {
+                    "type": "integer"
+                },
+                "datacredit_balance": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "held_datacredit": {
                     "type": "integer"
                 },
+                "updated_at": {
+                    "type": "string"
+                },
                 "user_id": {
                     "type": "string"
                 }
             }
         },
-        "models.PaystackInitializeRequest": {
+        "models.WalletBalanceDrift": {
             "type": "object",
-            "required": [
-                "amount",
-                "email"
-            ],
             "properties": {
-                "amount": {
-                    "description": "Amount in kobo (for datacredit purchase)",
+                "active_holds_datacredit": {
+                    "description": "Sum of the user's active wallet holds",
                     "type": "integer"
                 },
-                "email": {
+                "held_datacredit": {
+                    "type": "integer"
+                },
+                "ledger_databyte_balance": {
+                    "type": "integer"
+                },
+                "ledger_datacredit_balance": {
+                    "type": "integer"
+                },
+                "transactions_databyte_balance": {
+                    "description": "Sum of the user's databyte transactions",
+                    "type": "integer"
+                },
+                "transactions_datacredit_balance": {
+                    "description": "Sum of the user's datacredit transactions",
+                    "type": "integer"
+                },
+                "user_id": {
                     "type": "string"
+                },
+                "wallet_databyte_balance": {
+                    "type": "integer"
+                },
+                "wallet_datacredit_balance": {
+                    "type": "integer"
                 }
             }
         },
-        "models.PaystackWebhookPayload": {
+        "models.Withdrawal": {
             "type": "object",
             "properties": {
-                "data": {},
-                "event": {
+                "amount": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "approval_reason": {
+                    "description": "Why the withdrawal needs approval",
+                    "type": "string"
+                },
+                "approved_at": {
+                    "type": "string"
+                },
+                "approved_by": {
+                    "type": "string"
+                },
+                "completed_at": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "debit_journal_entry_id": {
+                    "type": "integer"
+                },
+                "failed_at": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "fee": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "fee_journal_entry_id": {
+                    "description": "Moves the fee to platform revenue when the transfer succeeds",
+                    "type": "integer"
+                },
+                "hold_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "paid_after_failure_at": {
+                    "description": "Set when Paystack paid out the transfer after the withdrawal failed",
+                    "type": "string"
+                },
+                "paid_after_failure_transfer_code": {
+                    "type": "string"
+                },
+                "payout_account_id": {
+                    "type": "string"
+                },
+                "payout_batch_id": {
+                    "description": "Set when sent in a bulk transfer",
+                    "type": "string"
+                },
+                "paystack_transfer_id": {
+                    "type": "integer"
+                },
+                "processing_at": {
+                    "type": "string"
+                },
+                "recipient_code": {
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Our transfer reference, sent to Paystack",
+                    "type": "string"
+                },
+                "rejected_at": {
+                    "type": "string"
+                },
+                "rejected_by": {
+                    "type": "string"
+                },
+                "reversal_journal_entry_id": {
+                    "type": "integer"
+                },
+                "reversed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "transfer_code": {
+                    "type": "string"
+                },
+                "transfer_verified_at": {
+                    "description": "Last checked with Paystack by the withdrawal sweeper",
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "user_id": {
                     "type": "string"
                 }
             }
         },
-        "models.Wallet": {
+        "models.WithdrawalDetail": {
             "type": "object",
             "properties": {
+                "amount": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "approval_reason": {
+                    "description": "Why the withdrawal needs approval",
+                    "type": "string"
+                },
+                "approved_at": {
+                    "type": "string"
+                },
+                "approved_by": {
+                    "type": "string"
+                },
+                "completed_at": {
+                    "type": "string"
+                },
                 "created_at": {
                     "type": "string"
                 },
-                "databyte_balance": {
+                "currency": {
+                    "type": "string"
+                },
+                "debit_journal_entry_id": {
                     "type": "integer"
                 },
-                "datacredit_balance": {
-                    "description": "Represents NGN value in kobo",
+                "failed_at": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "fee": {
+                    "description": "kobo",
+                    "type": "integer"
+                },
+                "fee_journal_entry_id": {
+                    "description": "Moves the fee to platform revenue when the transfer succeeds",
+                    "type": "integer"
+                },
+                "history": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.WithdrawalStatusEvent"
+                    }
+                },
+                "hold_id": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "paid_after_failure_at": {
+                    "description": "Set when Paystack paid out the transfer after the withdrawal failed",
+                    "type": "string"
+                },
+                "paid_after_failure_transfer_code": {
+                    "type": "string"
+                },
+                "payout_account_id": {
+                    "type": "string"
+                },
+                "payout_batch_id": {
+                    "description": "Set when sent in a bulk transfer",
+                    "type": "string"
+                },
+                "paystack_transfer_id": {
+                    "type": "integer"
+                },
+                "processing_at": {
+                    "type": "string"
+                },
+                "recipient_code": {
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Our transfer reference, sent to Paystack",
+                    "type": "string"
+                },
+                "rejected_at": {
+                    "type": "string"
+                },
+                "rejected_by": {
+                    "type": "string"
+                },
+                "reversal_journal_entry_id": {
                     "type": "integer"
                 },
+                "reversed_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                },
+                "transfer_code": {
+                    "type": "string"
+                },
+                "transfer_verified_at": {
+                    "description": "Last checked with Paystack by the withdrawal sweeper",
+                    "type": "string"
+                },
                 "updated_at": {
                     "type": "string"
                 },
                 "user_id": {
-                    "description": "(FK to profiles.id or auth.users.id)",
+                    "type": "string"
+                }
+            }
+        },
+        "models.WithdrawalQuote": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "description": "Debited from the wallet",
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                },
+                "daily_limit": {
+                    "type": "integer"
+                },
+                "fee": {
+                    "description": "Kept by the platform",
+                    "type": "integer"
+                },
+                "fee_mode": {
+                    "type": "string"
+                },
+                "max_amount": {
+                    "type": "integer"
+                },
+                "min_amount": {
+                    "type": "integer"
+                },
+                "net_amount": {
+                    "description": "Transferred to the bank",
+                    "type": "integer"
+                },
+                "withdrawn_today": {
+                    "type": "integer"
+                }
+            }
+        },
+        "models.WithdrawalRejectionRequest": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
                     "type": "string"
                 }
             }
@@ -348,11 +4362,67 @@ const docTemplate = `{
                     "description": "Amount of datacredit (kobo) to withdraw",
                     "type": "integer"
                 },
+                "recipient_id": {
+                    "description": "Payout account ID; the user's default payout account if empty",
+                    "type": "string"
+                },
+                "user_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WithdrawalReviewFlag": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "flagged_by": {
+                    "type": "string"
+                },
+                "reason": {
+                    "type": "string"
+                },
                 "user_id": {
                     "type": "string"
                 }
             }
         },
+        "models.WithdrawalReviewFlagRequest": {
+            "type": "object",
+            "required": [
+                "reason"
+            ],
+            "properties": {
+                "reason": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.WithdrawalStatusEvent": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "type": "string"
+                },
+                "from_status": {
+                    "description": "Empty for the status the withdrawal was created in",
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "to_status": {
+                    "type": "string"
+                },
+                "withdrawal_id": {
+                    "type": "string"
+                }
+            }
+        },
         "utils.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -15,8 +16,53 @@ type Config struct {
 	PaystackSecretKey  string
 	GinMode            string
 	Port               string
+
+	// Payment intent expiry sweeper
+	PaymentIntentSweepInterval time.Duration // How often the sweeper runs (0 disables it)
+	PaymentIntentStaleAfter    time.Duration // Age after which an open intent is verified with Paystack
+	PaymentIntentExpireAfter   time.Duration // Age after which an unpaid intent is closed as abandoned/expired
+
+	// Paystack bank list cache
+	BankListCacheTTL time.Duration // How long a Paystack bank list is served from memory
+
+	// Withdrawal approval
+	WithdrawalApprovalThreshold int64 // Withdrawals above this many kobo need admin approval (0 disables it)
+
+	// Withdrawal fees and limits (amounts in kobo; a zero limit means no limit)
+	WithdrawalFeeMode       string // One of the WithdrawalFeeMode* constants
+	WithdrawalFeeFlat       int64  // Fee per withdrawal in "flat" mode
+	WithdrawalFeePercentBps int64  // Fee in basis points of the amount in "percent" mode (150 = 1.5%)
+	WithdrawalFeeCap        int64  // Largest fee charged in "percent" mode
+	WithdrawalMinAmount     int64
+	WithdrawalMaxAmount     int64
+	WithdrawalDailyLimit    int64 // Most a user can withdraw per day (Lagos time)
+
+	// Withdrawal sweeper, for transfers whose outcome was never reported
+	WithdrawalSweepInterval time.Duration // How often the sweeper runs (0 disables it)
+	WithdrawalStaleAfter    time.Duration // Time without an update after which a withdrawal with a transfer in flight is verified with Paystack
+	WithdrawalAbandonAfter  time.Duration // Age after which a pending withdrawal Paystack has no transfer for is failed and its hold released
+
+	// Payout batching
+	PayoutBatchInterval time.Duration // How often queued withdrawals are sent as Paystack bulk transfers (0 sends each withdrawal immediately)
+	PayoutBatchSize     int           // Withdrawals per bulk transfer request (Paystack accepts at most 100)
+
+	// Reconciliation with Paystack
+	ReconciliationInterval time.Duration // How often the reconciler runs (0 disables it; cmd/reconcile runs it by hand)
+	ReconciliationWindow   time.Duration // How far back each scheduled run looks
+	ReconciliationAutoFix  bool          // Whether scheduled runs replay missed Paystack events
+
+	// Ledger invariant checks
+	LedgerCheckInterval time.Duration // How often wallets and the ledger are checked for drift (0 disables the schedule)
 }
 
+// Withdrawal fee modes.
+const (
+	WithdrawalFeeModeNone     = "none"
+	WithdrawalFeeModeFlat     = "flat"
+	WithdrawalFeeModePercent  = "percent"
+	WithdrawalFeeModePaystack = "paystack" // Paystack's tiered NGN transfer fee schedule, passed on to the user
+)
+
 // LoadConfig loads configuration from environment variables
 func LoadConfig() (*Config, error) {
 	// Load .env file if it exists (good for local development)
@@ -57,12 +103,84 @@ func LoadConfig() (*Config, error) {
 		log.Fatalf("Invalid PORT: %s. Must be a number.", cfg.Port)
 	}
 
+	cfg.PaymentIntentSweepInterval = getDuration("PAYMENT_INTENT_SWEEP_INTERVAL", 5*time.Minute)
+	cfg.PaymentIntentStaleAfter = getDuration("PAYMENT_INTENT_STALE_AFTER", 15*time.Minute)
+	cfg.PaymentIntentExpireAfter = getDuration("PAYMENT_INTENT_EXPIRE_AFTER", 24*time.Hour)
+	cfg.BankListCacheTTL = getDuration("BANK_LIST_CACHE_TTL", 24*time.Hour)
+	cfg.WithdrawalApprovalThreshold = getInt64("WITHDRAWAL_APPROVAL_THRESHOLD_KOBO", 0)
+	cfg.WithdrawalFeeMode = os.Getenv("WITHDRAWAL_FEE_MODE")
+	if cfg.WithdrawalFeeMode == "" {
+		cfg.WithdrawalFeeMode = WithdrawalFeeModeNone
+	}
+	switch cfg.WithdrawalFeeMode {
+	case WithdrawalFeeModeNone, WithdrawalFeeModeFlat, WithdrawalFeeModePercent, WithdrawalFeeModePaystack:
+	default:
+		log.Fatalf("Invalid WITHDRAWAL_FEE_MODE: %s. Must be one of none, flat, percent or paystack.", cfg.WithdrawalFeeMode)
+	}
+	cfg.WithdrawalFeeFlat = getInt64("WITHDRAWAL_FEE_FLAT_KOBO", 0)
+	cfg.WithdrawalFeePercentBps = getInt64("WITHDRAWAL_FEE_PERCENT_BPS", 0)
+	cfg.WithdrawalFeeCap = getInt64("WITHDRAWAL_FEE_CAP_KOBO", 0)
+	cfg.WithdrawalMinAmount = getInt64("WITHDRAWAL_MIN_KOBO", 0)
+	cfg.WithdrawalMaxAmount = getInt64("WITHDRAWAL_MAX_KOBO", 0)
+	cfg.WithdrawalDailyLimit = getInt64("WITHDRAWAL_DAILY_LIMIT_KOBO", 0)
+	if cfg.WithdrawalMaxAmount > 0 && cfg.WithdrawalMaxAmount < cfg.WithdrawalMinAmount {
+		log.Fatalf("Invalid WITHDRAWAL_MAX_KOBO: %d is below WITHDRAWAL_MIN_KOBO (%d).", cfg.WithdrawalMaxAmount, cfg.WithdrawalMinAmount)
+	}
+
+	cfg.WithdrawalSweepInterval = getDuration("WITHDRAWAL_SWEEP_INTERVAL", 10*time.Minute)
+	cfg.WithdrawalStaleAfter = getDuration("WITHDRAWAL_STALE_AFTER", 15*time.Minute)
+	cfg.WithdrawalAbandonAfter = getDuration("WITHDRAWAL_ABANDON_AFTER", time.Hour)
+
+	cfg.PayoutBatchInterval = getDuration("PAYOUT_BATCH_INTERVAL", 0)
+	cfg.PayoutBatchSize = int(getInt64("PAYOUT_BATCH_SIZE", 100))
+	if cfg.PayoutBatchSize < 1 || cfg.PayoutBatchSize > 100 {
+		log.Fatalf("Invalid PAYOUT_BATCH_SIZE: %d. Must be between 1 and 100.", cfg.PayoutBatchSize)
+	}
+
+	cfg.ReconciliationInterval = getDuration("RECONCILIATION_INTERVAL", 0)
+	cfg.ReconciliationWindow = getDuration("RECONCILIATION_WINDOW", 48*time.Hour)
+	cfg.ReconciliationAutoFix = getBool("RECONCILIATION_AUTO_FIX", false)
+
+	cfg.LedgerCheckInterval = getDuration("LEDGER_CHECK_INTERVAL", time.Hour)
 
 	return cfg, nil
 }
 
-const (
-	// DATABYTES_PER_DATACREDIT_KOBO defines how many Databytes a user gets for 1 unit of Datacredit (which is 1 kobo).
-	// Example: If 1 kobo buys 100 Databytes.
-	DATABYTES_PER_DATACREDIT_KOBO = 100 // Adjust this value as per your business model
-)
\ No newline at end of file
+// getDuration reads a Go duration (e.g. "15m", "24h") from the environment, falling back to def when unset.
+func getDuration(key string, def time.Duration) time.Duration {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil || d < 0 {
+		log.Fatalf("Invalid %s: %s. Must be a non-negative duration such as 15m or 24h.", key, raw)
+	}
+	return d
+}
+
+// getInt64 reads a non-negative integer from the environment, falling back to def when unset.
+func getInt64(key string, def int64) int64 {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	n, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid %s: %s. Must be a non-negative integer.", key, raw)
+	}
+	return n
+}
+
+// getBool reads a boolean ("true", "false", "1", "0", ...) from the environment, falling back to def when unset.
+func getBool(key string, def bool) bool {
+	raw := os.Getenv(key)
+	if raw == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(raw)
+	if err != nil {
+		log.Fatalf("Invalid %s: %s. Must be true or false.", key, raw)
+	}
+	return b
+}
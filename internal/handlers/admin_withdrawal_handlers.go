@@ -0,0 +1,300 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListWithdrawalApprovals godoc
+// @Summary     List Withdrawals Awaiting Approval
+// @Description List all users' withdrawals awaiting admin approval, oldest first. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       limit  query int false "Page size (max 100)" default(20)
+// @Param       offset query int false "Number of withdrawals to skip" default(0)
+// @Success     200 {array}  models.Withdrawal "Withdrawals in pending_approval"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing withdrawals"
+// @Router      /admin/withdrawals/approvals [get]
+func (h *PaymentHandler) ListWithdrawalApprovals(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	withdrawals, err := h.SupabaseService.ListWithdrawalsByStatus(models.WithdrawalPendingApproval, limit, offset)
+	if err != nil {
+		log.Printf("Error listing withdrawals awaiting approval: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list withdrawals")
+		return
+	}
+	if withdrawals == nil {
+		withdrawals = []models.Withdrawal{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, withdrawals)
+}
+
+// ApproveWithdrawal godoc
+// @Summary     Approve Withdrawal
+// @Description Approve a withdrawal awaiting approval and start its Paystack transfer. The approving admin must not be the user who requested it. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Withdrawal ID"
+// @Success     200 {object} map[string]interface{} "message, transfer_code and the withdrawal record"
+// @Failure     400 {object} utils.ErrorResponse "Invalid withdrawal ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required, or the admin requested the withdrawal"
+// @Failure     404 {object} utils.ErrorResponse "Withdrawal not found"
+// @Failure     409 {object} utils.ErrorResponse "Withdrawal is not awaiting approval"
+// @Failure     503 {object} utils.ErrorResponse "Approved, but the Paystack transfer could not be started"
+// @Router      /admin/withdrawals/{id}/approve [post]
+func (h *PaymentHandler) ApproveWithdrawal(c *gin.Context) {
+	adminID := c.GetString("adminID")
+	withdrawalID, ok := uuidParam(c, "id", "withdrawal")
+	if !ok {
+		return
+	}
+
+	withdrawal, err := h.PaystackService.ApproveWithdrawal(withdrawalID, adminID, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error approving withdrawal %s by admin %s: %v", withdrawalID, adminID, err)
+		switch {
+		case errors.Is(err, services.ErrWithdrawalNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Withdrawal not found")
+		case errors.Is(err, services.ErrWithdrawalStateConflict):
+			utils.RespondWithError(c, http.StatusConflict, "Withdrawal is not awaiting approval")
+		case errors.Is(err, services.ErrSelfApproval):
+			utils.RespondWithError(c, http.StatusForbidden, err.Error())
+		default:
+			// The approval is recorded; the transfer failed or stays pending like any other.
+			utils.RespondWithError(c, http.StatusServiceUnavailable, "Withdrawal approved but the transfer could not be started: "+err.Error())
+		}
+		return
+	}
+
+	log.Printf("INFO: Withdrawal %s approved by admin %s", withdrawal.ID, adminID)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{
+		"message":       withdrawalStatusMessage(withdrawal),
+		"transfer_code": withdrawal.TransferCode,
+		"withdrawal":    withdrawal,
+	})
+}
+
+// RejectWithdrawal godoc
+// @Summary     Reject Withdrawal
+// @Description Reject a withdrawal awaiting approval, releasing its held funds back to the user's available balance. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id      path string                            true "Withdrawal ID"
+// @Param       request body models.WithdrawalRejectionRequest true "Reason for the rejection"
+// @Success     200 {object} models.Withdrawal "The rejected withdrawal"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or withdrawal ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Withdrawal not found"
+// @Failure     409 {object} utils.ErrorResponse "Withdrawal is not awaiting approval"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error rejecting the withdrawal"
+// @Router      /admin/withdrawals/{id}/reject [post]
+func (h *PaymentHandler) RejectWithdrawal(c *gin.Context) {
+	var req models.WithdrawalRejectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+	withdrawalID, ok := uuidParam(c, "id", "withdrawal")
+	if !ok {
+		return
+	}
+
+	withdrawal, err := h.SupabaseService.RejectWithdrawal(withdrawalID, adminID, req.Reason)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWithdrawalNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Withdrawal not found")
+		case errors.Is(err, services.ErrWithdrawalStateConflict):
+			utils.RespondWithError(c, http.StatusConflict, "Withdrawal is not awaiting approval")
+		default:
+			log.Printf("Error rejecting withdrawal %s by admin %s: %v", withdrawalID, adminID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to reject withdrawal")
+		}
+		return
+	}
+
+	log.Printf("INFO: Withdrawal %s rejected by admin %s: %s", withdrawal.ID, adminID, req.Reason)
+	utils.RespondWithJSON(c, http.StatusOK, withdrawal)
+}
+
+// FinalizeWithdrawalTransfer godoc
+// @Summary     Finalize Withdrawal Transfer
+// @Description Submit the OTP Paystack sent for a withdrawal awaiting OTP confirmation, sending its transfer. If Paystack rejects the OTP the withdrawal keeps waiting and the OTP can be retried. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id      path string                    true "Withdrawal ID"
+// @Param       request body models.TransferOTPRequest true "The transfer OTP"
+// @Success     200 {object} models.Withdrawal "The withdrawal, now processing"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or withdrawal ID, or OTP rejected by Paystack"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Withdrawal not found"
+// @Failure     409 {object} utils.ErrorResponse "Withdrawal is not awaiting an OTP"
+// @Failure     503 {object} utils.ErrorResponse "Paystack could not be reached or did not confirm the transfer; it may have been sent, so check the withdrawal before retrying"
+// @Router      /admin/withdrawals/{id}/finalize [post]
+func (h *PaymentHandler) FinalizeWithdrawalTransfer(c *gin.Context) {
+	var req models.TransferOTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+	withdrawalID, ok := uuidParam(c, "id", "withdrawal")
+	if !ok {
+		return
+	}
+
+	withdrawal, err := h.PaystackService.FinalizeWithdrawalTransfer(withdrawalID, req.OTP, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error finalizing transfer of withdrawal %s by admin %s: %v", withdrawalID, adminID, err)
+		respondWithTransferOTPError(c, err)
+		return
+	}
+
+	log.Printf("INFO: Transfer of withdrawal %s finalized by admin %s", withdrawal.ID, adminID)
+	utils.RespondWithJSON(c, http.StatusOK, withdrawal)
+}
+
+// ResendWithdrawalOTP godoc
+// @Summary     Resend Withdrawal Transfer OTP
+// @Description Ask Paystack to send a new OTP for a withdrawal awaiting OTP confirmation. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Withdrawal ID"
+// @Success     200 {object} map[string]string "message"
+// @Failure     400 {object} utils.ErrorResponse "Invalid withdrawal ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Withdrawal not found"
+// @Failure     409 {object} utils.ErrorResponse "Withdrawal is not awaiting an OTP"
+// @Failure     503 {object} utils.ErrorResponse "Paystack could not resend the OTP"
+// @Router      /admin/withdrawals/{id}/resend-otp [post]
+func (h *PaymentHandler) ResendWithdrawalOTP(c *gin.Context) {
+	adminID := c.GetString("adminID")
+	withdrawalID, ok := uuidParam(c, "id", "withdrawal")
+	if !ok {
+		return
+	}
+
+	if _, err := h.PaystackService.ResendWithdrawalOTP(withdrawalID, h.SupabaseService); err != nil {
+		log.Printf("Error resending transfer OTP of withdrawal %s for admin %s: %v", withdrawalID, adminID, err)
+		respondWithTransferOTPError(c, err)
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Transfer OTP resent"})
+}
+
+// respondWithTransferOTPError maps errors from the transfer OTP endpoints to responses.
+func respondWithTransferOTPError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrWithdrawalNotFound):
+		utils.RespondWithError(c, http.StatusNotFound, "Withdrawal not found")
+	case errors.Is(err, services.ErrWithdrawalStateConflict):
+		utils.RespondWithError(c, http.StatusConflict, "Withdrawal is not awaiting an OTP")
+	case errors.Is(err, services.ErrTransferOTPRejected):
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrTransferOutcomeUnknown):
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Paystack did not confirm the transfer; it may have been sent, so check the withdrawal before retrying")
+	default:
+		utils.RespondWithError(c, http.StatusServiceUnavailable, err.Error())
+	}
+}
+
+// FlagUserForWithdrawalReview godoc
+// @Summary     Flag User for Withdrawal Review
+// @Description Require admin approval for all of a user's future withdrawals, whatever their amount. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       userId  path string                             true "User ID"
+// @Param       request body models.WithdrawalReviewFlagRequest true "Reason for the flag"
+// @Success     200 {object} models.WithdrawalReviewFlag "The user's flag"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or user ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error flagging the user"
+// @Router      /admin/users/{userId}/withdrawal-review [put]
+func (h *PaymentHandler) FlagUserForWithdrawalReview(c *gin.Context) {
+	var req models.WithdrawalReviewFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+	userID, ok := uuidParam(c, "userId", "user")
+	if !ok {
+		return
+	}
+
+	flag, err := h.SupabaseService.FlagUserForWithdrawalReview(userID, req.Reason, adminID)
+	if err != nil {
+		log.Printf("Error flagging UserID %s for withdrawal review: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to flag user")
+		return
+	}
+
+	log.Printf("INFO: UserID %s flagged for withdrawal review by admin %s: %s", userID, adminID, req.Reason)
+	utils.RespondWithJSON(c, http.StatusOK, flag)
+}
+
+// UnflagUserForWithdrawalReview godoc
+// @Summary     Unflag User for Withdrawal Review
+// @Description Stop requiring admin approval for a user's withdrawals below the approval threshold. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       userId path string true "User ID"
+// @Success     200 {object} map[string]string "message"
+// @Failure     400 {object} utils.ErrorResponse "Invalid user ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "User is not flagged"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error removing the flag"
+// @Router      /admin/users/{userId}/withdrawal-review [delete]
+func (h *PaymentHandler) UnflagUserForWithdrawalReview(c *gin.Context) {
+	adminID := c.GetString("adminID")
+	userID, ok := uuidParam(c, "userId", "user")
+	if !ok {
+		return
+	}
+
+	if err := h.SupabaseService.UnflagUserForWithdrawalReview(userID); err != nil {
+		if errors.Is(err, services.ErrWithdrawalReviewFlagNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "User is not flagged for withdrawal review")
+			return
+		}
+		log.Printf("Error removing withdrawal review flag for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to remove flag")
+		return
+	}
+
+	log.Printf("INFO: Withdrawal review flag for UserID %s removed by admin %s", userID, adminID)
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Withdrawal review flag removed"})
+}
@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/middleware"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListBanks godoc
+// @Summary     List Banks
+// @Description List the banks and bank codes Paystack supports for a country and currency, for setting up payout accounts. Lists are cached in memory; admins may pass refresh=true to fetch a fresh list from Paystack.
+// @Tags        Banks
+// @Produce     json
+// @Security    BearerAuth
+// @Param       country  query string false "Country name as used by Paystack" default(nigeria)
+// @Param       currency query string false "Currency code" default(NGN)
+// @Param       refresh  query bool   false "Bypass the cache and fetch the list from Paystack (admin only)"
+// @Success     200 {object} models.BankList "Banks for the country and currency"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "refresh requested by a non-admin"
+// @Failure     502 {object} utils.ErrorResponse "The bank list could not be fetched from Paystack"
+// @Router      /banks [get]
+func (h *PaymentHandler) ListBanks(c *gin.Context) {
+	country := c.DefaultQuery("country", "nigeria")
+	currency := c.DefaultQuery("currency", "NGN")
+	refresh := c.Query("refresh") == "true"
+	// A refresh always calls Paystack, so only admins may force one.
+	if refresh && !middleware.IsAdmin(c) {
+		utils.RespondWithError(c, http.StatusForbidden, "Admin access required to refresh the bank list")
+		return
+	}
+
+	banks, err := h.PaystackService.ListBanks(country, currency, refresh)
+	if err != nil {
+		log.Printf("Error listing banks for %s/%s: %v", country, currency, err)
+		utils.RespondWithError(c, http.StatusBadGateway, "Failed to fetch bank list from Paystack")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, banks)
+}
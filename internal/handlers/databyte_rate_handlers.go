@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QuoteDatabytes godoc
+// @Summary     Quote Databytes
+// @Description Show the datacredit cost of purchasing an amount of databytes at the current rate and volume tier, before purchasing them.
+// @Tags        Databytes
+// @Produce     json
+// @Security    BearerAuth
+// @Param       amount query int true "Amount of databytes to purchase"
+// @Success     200 {object} models.DatabyteQuote "Cost in kobo, the rate applied and its version"
+// @Failure     400 {object} utils.ErrorResponse "Invalid or too large amount"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error quoting the purchase"
+// @Router      /databytes/quote [get]
+func (h *PaymentHandler) QuoteDatabytes(c *gin.Context) {
+	amount, err := strconv.ParseInt(c.Query("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "amount must be a positive integer (databytes)")
+		return
+	}
+
+	quote, err := h.SupabaseService.QuoteDatabytes(amount)
+	if err != nil {
+		if errors.Is(err, services.ErrDatabyteAmountTooBig) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Error quoting purchase of %d databytes: %v", amount, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to quote databytes")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, quote)
+}
+
+// ScheduleDatabyteRate godoc
+// @Summary     Schedule Databyte Rate
+// @Description Schedule a new version of the datacredit to databyte rate, taking effect at effective_from or immediately. A purchase is priced at the tier with the highest min_databytes not above its amount; the first tier must start at 1. Versions are never edited; schedule a new one to change the rate. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.DatabyteRateRequest true "When the rate takes effect, its volume tiers and a note"
+// @Success     201 {object} models.DatabyteRate "The scheduled rate version"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload, invalid tiers, a time in the past, or a version already scheduled for that time"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error scheduling the rate"
+// @Router      /admin/databyte-rates [post]
+func (h *PaymentHandler) ScheduleDatabyteRate(c *gin.Context) {
+	var req models.DatabyteRateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+
+	rate, err := h.SupabaseService.ScheduleDatabyteRate(req, adminID)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidDatabyteRate) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		log.Printf("Error scheduling databyte rate by admin %s: %v", adminID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to schedule databyte rate")
+		return
+	}
+
+	log.Printf("INFO: Databyte rate version %d effective from %s scheduled by admin %s", rate.ID, rate.EffectiveFrom, adminID)
+	utils.RespondWithJSON(c, http.StatusCreated, rate)
+}
+
+// ListDatabyteRates godoc
+// @Summary     List Databyte Rates
+// @Description List the versions of the datacredit to databyte rate, latest effective first, including those scheduled for the future. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       include_cancelled query bool false "Also list cancelled versions" default(false)
+// @Param       limit             query int  false "Page size (max 100)" default(20)
+// @Param       offset            query int  false "Number of versions to skip" default(0)
+// @Success     200 {array}  models.DatabyteRate "Rate versions"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing rates"
+// @Router      /admin/databyte-rates [get]
+func (h *PaymentHandler) ListDatabyteRates(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+	includeCancelled := c.Query("include_cancelled") == "true"
+
+	rates, err := h.SupabaseService.ListDatabyteRates(includeCancelled, limit, offset)
+	if err != nil {
+		log.Printf("Error listing databyte rates: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list databyte rates")
+		return
+	}
+	if rates == nil {
+		rates = []models.DatabyteRate{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, rates)
+}
+
+// CancelDatabyteRate godoc
+// @Summary     Cancel Databyte Rate
+// @Description Cancel a scheduled version of the databyte rate before it takes effect. A version that has taken effect cannot be cancelled, since purchases may have been priced at it; schedule a new one instead. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path int true "Rate version"
+// @Success     200 {object} models.DatabyteRate "The cancelled rate version"
+// @Failure     400 {object} utils.ErrorResponse "Invalid rate version"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Rate version not found or already cancelled"
+// @Failure     409 {object} utils.ErrorResponse "Rate version has already taken effect"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error cancelling the rate"
+// @Router      /admin/databyte-rates/{id} [delete]
+func (h *PaymentHandler) CancelDatabyteRate(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid databyte rate version")
+		return
+	}
+	adminID := c.GetString("adminID")
+
+	rate, err := h.SupabaseService.CancelDatabyteRate(id, adminID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrDatabyteRateNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Databyte rate not found")
+		case errors.Is(err, services.ErrDatabyteRateInEffect):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Error cancelling databyte rate %d by admin %s: %v", id, adminID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to cancel databyte rate")
+		}
+		return
+	}
+
+	log.Printf("INFO: Databyte rate version %d cancelled by admin %s", id, adminID)
+	utils.RespondWithJSON(c, http.StatusOK, rate)
+}
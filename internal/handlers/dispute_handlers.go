@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListDisputes godoc
+// @Summary     List Disputes
+// @Description List chargebacks raised by cardholders, newest first. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       status query string false "Only disputes in this status" Enums(open, evidence_submitted, won, lost)
+// @Param       limit  query int    false "Page size (max 100)" default(20)
+// @Param       offset query int    false "Number of disputes to skip" default(0)
+// @Success     200 {array}  models.Dispute "Disputes"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing disputes"
+// @Router      /admin/disputes [get]
+func (h *PaymentHandler) ListDisputes(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	disputes, err := h.SupabaseService.ListDisputes(c.Query("status"), limit, offset)
+	if err != nil {
+		log.Printf("Error listing disputes: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list disputes")
+		return
+	}
+	if disputes == nil {
+		disputes = []models.Dispute{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, disputes)
+}
+
+// GetDispute godoc
+// @Summary     Get Dispute
+// @Description Get a dispute, including its evidence deadline and how much of the disputed amount is frozen in the user's wallet. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Dispute ID"
+// @Success     200 {object} models.Dispute "The dispute"
+// @Failure     400 {object} utils.ErrorResponse "Invalid dispute ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Dispute not found"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the dispute"
+// @Router      /admin/disputes/{id} [get]
+func (h *PaymentHandler) GetDispute(c *gin.Context) {
+	disputeID, ok := uuidParam(c, "id", "dispute")
+	if !ok {
+		return
+	}
+
+	dispute, err := h.SupabaseService.GetDispute(disputeID)
+	if err != nil {
+		if errors.Is(err, services.ErrDisputeNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Dispute not found")
+			return
+		}
+		log.Printf("Error fetching dispute %s: %v", disputeID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch dispute")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, dispute)
+}
+
+// GetDisputeUploadURL godoc
+// @Summary     Get Dispute Evidence Upload URL
+// @Description Get a signed Paystack URL to upload an evidence file (e.g. a receipt or usage log) for an open dispute to. The returned file name is passed when accepting the dispute. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id       path  string true "Dispute ID"
+// @Param       filename query string true "Name of the file to upload, e.g. receipt.pdf"
+// @Success     200 {object} models.DisputeUploadURL "Signed upload URL and file name"
+// @Failure     400 {object} utils.ErrorResponse "Missing filename or dispute ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Dispute not found"
+// @Failure     409 {object} utils.ErrorResponse "Dispute is already resolved"
+// @Failure     502 {object} utils.ErrorResponse "Paystack rejected the request"
+// @Failure     503 {object} utils.ErrorResponse "Paystack could not be reached"
+// @Router      /admin/disputes/{id}/upload-url [get]
+func (h *PaymentHandler) GetDisputeUploadURL(c *gin.Context) {
+	disputeID, ok := uuidParam(c, "id", "dispute")
+	if !ok {
+		return
+	}
+
+	fileName := c.Query("filename")
+	if fileName == "" {
+		utils.RespondWithError(c, http.StatusBadRequest, "filename is required")
+		return
+	}
+
+	uploadURL, err := h.PaystackService.GetDisputeUploadURL(disputeID, fileName, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error getting evidence upload URL for dispute %s: %v", disputeID, err)
+		respondWithDisputeError(c, err)
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, uploadURL)
+}
+
+// SubmitDisputeEvidence godoc
+// @Summary     Submit Dispute Evidence
+// @Description Send evidence that the disputed purchase was genuine to Paystack. The disputed datacredit stays frozen until Paystack resolves the dispute. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id      path string                        true "Dispute ID"
+// @Param       request body models.DisputeEvidenceRequest true "Customer and service details"
+// @Success     200 {object} models.Dispute "The dispute, with evidence submitted"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or dispute ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Dispute not found"
+// @Failure     409 {object} utils.ErrorResponse "Dispute is already resolved"
+// @Failure     502 {object} utils.ErrorResponse "Paystack rejected the evidence"
+// @Failure     503 {object} utils.ErrorResponse "Paystack could not be reached"
+// @Router      /admin/disputes/{id}/evidence [post]
+func (h *PaymentHandler) SubmitDisputeEvidence(c *gin.Context) {
+	disputeID, ok := uuidParam(c, "id", "dispute")
+	if !ok {
+		return
+	}
+
+	var req models.DisputeEvidenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+
+	dispute, err := h.PaystackService.SubmitDisputeEvidence(disputeID, req, adminID, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error submitting evidence for dispute %s by admin %s: %v", disputeID, adminID, err)
+		respondWithDisputeError(c, err)
+		return
+	}
+
+	log.Printf("INFO: Evidence for dispute %s submitted by admin %s", dispute.ID, adminID)
+	utils.RespondWithJSON(c, http.StatusOK, dispute)
+}
+
+// AcceptDispute godoc
+// @Summary     Accept Dispute
+// @Description Accept a dispute through Paystack, refunding the cardholder. The refunded amount is debited from the user's wallet; whatever they have already spent is recorded as a debt and collected from their next purchases. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id      path string                      true "Dispute ID"
+// @Param       request body models.DisputeAcceptRequest true "Message, optional refund amount in kobo and the uploaded evidence file name"
+// @Success     200 {object} models.Dispute "The dispute, lost"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or dispute ID, or a refund amount above the disputed amount"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Dispute not found"
+// @Failure     409 {object} utils.ErrorResponse "Dispute is already resolved"
+// @Failure     502 {object} utils.ErrorResponse "Paystack rejected the acceptance"
+// @Failure     503 {object} utils.ErrorResponse "Paystack could not be reached"
+// @Router      /admin/disputes/{id}/accept [post]
+func (h *PaymentHandler) AcceptDispute(c *gin.Context) {
+	disputeID, ok := uuidParam(c, "id", "dispute")
+	if !ok {
+		return
+	}
+
+	var req models.DisputeAcceptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+
+	dispute, err := h.PaystackService.AcceptDispute(disputeID, req, adminID, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error accepting dispute %s by admin %s: %v", disputeID, adminID, err)
+		respondWithDisputeError(c, err)
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, dispute)
+}
+
+// respondWithDisputeError maps an error from a dispute action to its response.
+func respondWithDisputeError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrDisputeNotFound):
+		utils.RespondWithError(c, http.StatusNotFound, "Dispute not found")
+	case errors.Is(err, services.ErrDisputeStateConflict):
+		utils.RespondWithError(c, http.StatusConflict, "Dispute is already resolved")
+	case errors.Is(err, services.ErrDisputeRefundTooHigh):
+		utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+	case errors.Is(err, services.ErrDisputeRejected):
+		utils.RespondWithError(c, http.StatusBadGateway, err.Error())
+	default:
+		utils.RespondWithError(c, http.StatusServiceUnavailable, "Error from Paystack: "+err.Error())
+	}
+}
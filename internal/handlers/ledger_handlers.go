@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CheckLedger godoc
+// @Summary     Check Ledger Invariants
+// @Description Recompute every wallet's balances from its transaction history, ledger accounts and active holds, and check the platform-wide invariants (the ledger sums to zero, and deposits minus withdrawals and other transactions equal the datacredit outstanding). Reports drifted wallets, failed invariants and the transaction totals per operation, and updates the ledger metrics at /debug/vars. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} models.LedgerCheckReport "Check result; healthy is false when anything disagrees"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error running the check"
+// @Router      /admin/ledger/check [get]
+func (h *PaymentHandler) CheckLedger(c *gin.Context) {
+	report, err := h.SupabaseService.CheckLedger()
+	if err != nil {
+		log.Printf("Error checking ledger invariants: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to check the ledger")
+		return
+	}
+	if !report.Healthy {
+		log.Printf("WARNING: Ledger check requested by admin %s found %d drifted wallet(s) and %d failed invariant(s)",
+			c.GetString("adminID"), len(report.DriftedWallets), report.FailedInvariants)
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, report)
+}
+
+// GetUserBalanceAsOf godoc
+// @Summary     Get User Balance As Of
+// @Description Reconstruct a user's datacredit and databyte balance at a moment from the transaction log, for settling disputes and month-end reports. Each balance is the last transaction's balance_after when recorded, otherwise the sum of all amounts so far; both are returned along with the last transaction that contributed, and consistent is false when they disagree. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       userId path  string true  "User ID"
+// @Param       as_of  query string false "Moment to reconstruct the balance at (RFC3339), or the end of a day (YYYY-MM-DD); defaults to now and must not be in the future"
+// @Success     200 {object} models.BalanceAsOf "The user's balances at the moment"
+// @Failure     400 {object} utils.ErrorResponse "Invalid user ID, or an invalid or future as_of"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "User not found"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error reconstructing the balance"
+// @Router      /admin/users/{userId}/balance [get]
+func (h *PaymentHandler) GetUserBalanceAsOf(c *gin.Context) {
+	userID, ok := uuidParam(c, "userId", "user")
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	asOf := now
+	if raw := c.Query("as_of"); raw != "" {
+		parsed, ok := timeParam(c, "as_of", true)
+		if !ok {
+			return
+		}
+		asOf = *parsed
+		if _, err := time.Parse(time.RFC3339, raw); err != nil {
+			// A date means its end; the reconstruction includes transactions at as_of,
+			// and timestamps are stored to the microsecond.
+			asOf = asOf.Add(-time.Microsecond)
+		}
+		if asOf.After(now) {
+			// Later transactions could still change the result.
+			utils.RespondWithError(c, http.StatusBadRequest, "as_of must not be in the future")
+			return
+		}
+	}
+
+	balance, err := h.SupabaseService.GetBalanceAsOf(userID, asOf)
+	if err != nil {
+		if errors.Is(err, services.ErrProfileNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "User not found")
+			return
+		}
+		log.Printf("Error reconstructing balance of UserID %s as of %s: %v", userID, asOf.Format(time.RFC3339), err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to reconstruct balance")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, balance)
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"net/http"
@@ -64,7 +65,7 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 	// For example: "https://yourapp.com/payment/callback"
 	callbackURL := "YOUR_APPLICATION_PAYMENT_CALLBACK_URL" // Replace with actual or configured URL
 
-	resp, err := h.PaystackService.InitializePayment(req, userID, callbackURL)
+	resp, err := h.PaystackService.InitializePayment(req, userID, callbackURL, h.SupabaseService)
 	if err != nil {
 		log.Printf("Error initializing payment for UserID %s: %v", userID, err)
 		// Check if the error message indicates a Paystack specific issue known from SDK
@@ -88,6 +89,115 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 	})
 }
 
+// GetPaymentIntent godoc
+// @Summary     Get Payment Intent Status
+// @Description Poll the status of one of the authenticated user's payment intents by its Paystack reference.
+// @Tags        Payments
+// @Produce     json
+// @Security    BearerAuth
+// @Param       reference path string true "Paystack transaction reference returned by /payments/initialize"
+// @Success     200 {object} models.PaymentIntent "The payment intent and its current status"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "No payment intent with this reference for the user"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the payment intent"
+// @Router      /payments/intents/{reference} [get]
+func (h *PaymentHandler) GetPaymentIntent(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	reference := c.Param("reference")
+	intent, err := h.SupabaseService.GetPaymentIntent(reference)
+	if err != nil {
+		log.Printf("Error fetching payment intent %s for UserID %s: %v", reference, userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch payment intent")
+		return
+	}
+	// Intents belonging to other users are reported as missing rather than forbidden.
+	if intent == nil || intent.UserID != userID {
+		utils.RespondWithError(c, http.StatusNotFound, "Payment intent not found")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, intent)
+}
+
+// VerifyPayment godoc
+// @Summary     Verify Payment
+// @Description Verify one of the authenticated user's payment references with Paystack. If Paystack reports the charge as successful and the webhook has not credited it yet, the credit is applied here through the same idempotent path the webhook uses.
+// @Tags        Payments
+// @Produce     json
+// @Security    BearerAuth
+// @Param       reference path string true "Paystack transaction reference returned by /payments/initialize"
+// @Success     200 {object} models.PaymentVerification "Paystack's status for the reference and the updated payment intent"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "No payment intent with this reference for the user"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error applying the payment"
+// @Failure     502 {object} utils.ErrorResponse "Paystack could not verify the reference"
+// @Router      /payments/verify/{reference} [get]
+func (h *PaymentHandler) VerifyPayment(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	reference := c.Param("reference")
+	intent, err := h.SupabaseService.GetPaymentIntent(reference)
+	if err != nil {
+		log.Printf("Error fetching payment intent %s for UserID %s: %v", reference, userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch payment intent")
+		return
+	}
+	// Intents belonging to other users are reported as missing rather than forbidden.
+	if intent == nil || intent.UserID != userID {
+		utils.RespondWithError(c, http.StatusNotFound, "Payment intent not found")
+		return
+	}
+
+	transactionData, err := h.PaystackService.VerifyPayment(reference)
+	if err != nil {
+		log.Printf("Error verifying payment %s with Paystack for UserID %s: %v", reference, userID, err)
+		utils.RespondWithError(c, http.StatusBadGateway, "Failed to verify payment with Paystack")
+		return
+	}
+
+	verification := models.PaymentVerification{
+		Reference:      reference,
+		PaystackStatus: transactionData.Status,
+		Intent:         intent,
+	}
+
+	if transactionData.Status == "success" && intent.Status != models.PaymentIntentSucceeded {
+		// Claims the same charge.success event the webhook does, so whichever arrives
+		// second sees it as already handled.
+		_, applied, err := h.PaystackService.ApplyChargeSuccess(*transactionData, h.SupabaseService)
+		if err != nil {
+			log.Printf("Error applying verified payment %s for UserID %s: %v", reference, userID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to apply verified payment")
+			return
+		}
+		if applied {
+			log.Printf("Payment %s credited on client verification for UserID %s", reference, userID)
+		}
+
+		updatedIntent, err := h.SupabaseService.GetPaymentIntent(reference)
+		if err != nil {
+			log.Printf("Error re-fetching payment intent %s for UserID %s: %v", reference, userID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch payment intent")
+			return
+		}
+		verification.Intent = updatedIntent
+		verification.Credited = applied && updatedIntent.Status == models.PaymentIntentSucceeded
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, verification)
+}
+
 // PaystackWebhook godoc
 // @Summary     Handle Paystack Webhook Events
 // @Description Endpoint for Paystack to send asynchronous payment and transfer notifications. Signature is verified.
@@ -96,7 +206,7 @@ func (h *PaymentHandler) InitializePayment(c *gin.Context) {
 // @Produce     json
 // @Param       X-Paystack-Signature header string true "Paystack signature for webhook verification"
 // @Param       webhookEvent body models.PaystackWebhookPayload true "Raw Paystack Webhook Event Payload"
-// @Success     200 {object} map[string]string "status: 'Webhook processed'"
+// @Success     200 {object} map[string]string "status: 'Webhook processed' or 'Duplicate webhook ignored'"
 // @Failure     400 {object} utils.ErrorResponse "Invalid payload or missing signature"
 // @Failure     401 {object} utils.ErrorResponse "Webhook signature verification failed"
 // @Failure     500 {object} utils.ErrorResponse "Internal server error processing webhook"
@@ -137,58 +247,121 @@ func (h *PaymentHandler) PaystackWebhook(c *gin.Context) {
 
 	log.Printf("Received verified Paystack webhook. Event: %s", payload.Event)
 
+	// Paystack retries deliveries until it gets a 2xx, so claim the event before applying it.
+	// Deliveries of an event we have already handled are acknowledged and not applied again.
+	eventKey := services.WebhookEventKey(payload, body)
+	var whErr *webhookError
+	event, processed, _, err := h.SupabaseService.ProcessEventOnce(payload.Event, eventKey, body, func() (map[string]interface{}, error) {
+		result, perr := h.processWebhookEvent(payload)
+		if perr != nil {
+			whErr = perr
+			return nil, perr.err
+		}
+		return result, nil
+	})
+	if whErr != nil {
+		log.Printf("Error processing Paystack webhook %s/%s: %v", payload.Event, eventKey, whErr.err)
+		// A non-2xx response makes Paystack retry, and the failed event can be claimed again.
+		utils.RespondWithError(c, http.StatusInternalServerError, whErr.message)
+		return
+	}
+	if err != nil {
+		log.Printf("Error claiming Paystack webhook %s/%s: %v", payload.Event, eventKey, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Error recording webhook event")
+		return
+	}
+	if !processed {
+		log.Printf("Duplicate Paystack webhook %s/%s acknowledged without reprocessing (status: %s)", payload.Event, eventKey, event.Status)
+		utils.RespondWithJSON(c, http.StatusOK, gin.H{"status": "Duplicate webhook ignored"})
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"status": "Webhook processed"})
+}
+
+// webhookError pairs the message returned to Paystack with the underlying error.
+type webhookError struct {
+	message string
+	err     error
+}
+
+// processWebhookEvent applies a claimed Paystack event and returns the result to record for audit.
+func (h *PaymentHandler) processWebhookEvent(payload models.PaystackWebhookPayload) (map[string]interface{}, *webhookError) {
 	switch payload.Event {
 	case "charge.success":
 		var transactionData models.PaystackTransactionData
 		dataBytes, _ := json.Marshal(payload.Data) // Convert interface{} to bytes
 		if err := json.Unmarshal(dataBytes, &transactionData); err != nil {
-			log.Printf("Error unmarshalling charge.success data: %v", err)
-			utils.RespondWithError(c, http.StatusInternalServerError, "Error processing charge.success event data")
-			return
+			return nil, &webhookError{"Error processing charge.success event data", err}
 		}
 
-		err := h.PaystackService.ProcessSuccessfulPayment(transactionData, h.SupabaseService)
+		result, err := h.PaystackService.HandleChargeSuccess(transactionData, h.SupabaseService)
 		if err != nil {
-			log.Printf("Error processing successful payment for Paystack reference %s: %v", transactionData.Reference, err)
-			utils.RespondWithError(c, http.StatusInternalServerError, "Error processing successful payment")
-			return
+			return nil, &webhookError{"Error processing successful payment", err}
 		}
 		log.Printf("Successfully processed charge.success for Paystack reference: %s", transactionData.Reference)
+		return result, nil
+
+	case "transfer.success", "transfer.failed", "transfer.reversed":
+		var transferData models.PaystackTransferData
+		dataBytes, _ := json.Marshal(payload.Data)
+		if err := json.Unmarshal(dataBytes, &transferData); err != nil {
+			return nil, &webhookError{"Error processing " + payload.Event + " event data", err}
+		}
 
-	case "transfer.success":
-		log.Printf("Received transfer.success webhook: %+v", payload.Data)
-		// TODO: Implement logic for successful transfers
-		// e.g., final logging, notifying user that withdrawal is complete.
-		// This is where you'd be certain the money has reached the user.
+		result, err := h.PaystackService.HandleTransferEvent(payload.Event, transferData, h.SupabaseService)
+		if err != nil {
+			return nil, &webhookError{"Error processing transfer event", err}
+		}
+		log.Printf("Successfully processed %s for Paystack transfer: %s", payload.Event, transferData.TransferCode)
+		return result, nil
+
+	case "refund.processed", "refund.failed":
+		var refundData models.PaystackRefundData
+		dataBytes, _ := json.Marshal(payload.Data)
+		if err := json.Unmarshal(dataBytes, &refundData); err != nil {
+			return nil, &webhookError{"Error processing " + payload.Event + " event data", err}
+		}
 
-	case "transfer.failed":
-		log.Printf("Received transfer.failed webhook: %+v", payload.Data)
-		// TODO: Implement logic for failed transfers
-		// e.g., Re-credit user if debited prematurely, notify user, investigate failure.
+		result, err := h.PaystackService.HandleRefundEvent(payload.Event, refundData, h.SupabaseService)
+		if err != nil {
+			return nil, &webhookError{"Error processing refund event", err}
+		}
+		log.Printf("Successfully processed %s for Paystack reference: %s", payload.Event, refundData.TransactionReference)
+		return result, nil
+
+	case "charge.dispute.create", "charge.dispute.remind", "charge.dispute.resolve":
+		var disputeData models.PaystackDisputeData
+		dataBytes, _ := json.Marshal(payload.Data)
+		if err := json.Unmarshal(dataBytes, &disputeData); err != nil {
+			return nil, &webhookError{"Error processing " + payload.Event + " event data", err}
+		}
 
-	case "transfer.reversed":
-		log.Printf("Received transfer.reversed webhook: %+v", payload.Data)
-		// TODO: Implement logic for reversed transfers
-		// This might happen after a transfer was initially successful.
+		result, err := h.PaystackService.HandleDisputeEvent(payload.Event, disputeData, h.SupabaseService)
+		if err != nil {
+			return nil, &webhookError{"Error processing dispute event", err}
+		}
+		log.Printf("Successfully processed %s for Paystack dispute: %d", payload.Event, disputeData.ID)
+		return result, nil
 
 	default:
 		log.Printf("Unhandled Paystack webhook event: %s", payload.Event)
+		return gin.H{"action": "ignored"}, nil
 	}
-
-	utils.RespondWithJSON(c, http.StatusOK, gin.H{"status": "Webhook processed"})
 }
 
 // HandleWithdrawal godoc
 // @Summary     Initiate Datacredit Withdrawal
-// @Description Initiate a withdrawal of datacredit for an authenticated user.
+// @Description Initiate a withdrawal of datacredit for an authenticated user. Large withdrawals, and withdrawals by users flagged for review, are held in pending_approval until an admin approves them.
 // @Tags        Payments
 // @Accept      json
 // @Produce     json
 // @Security    BearerAuth
-// @Param       withdrawalRequest body models.WithdrawalRequest true "Withdrawal details including amount in kobo"
-// @Success     200 {object} map[string]string "message, transfer_code"
-// @Failure     400 {object} utils.ErrorResponse "Invalid input or insufficient datacredit balance"
+// @Param       withdrawalRequest body models.WithdrawalRequest true "Withdrawal details including amount in kobo (the fee is deducted from it) and an optional payout account ID (recipient_id)"
+// @Success     200 {object} map[string]interface{} "message, transfer_code and the withdrawal record"
+// @Failure     400 {object} utils.ErrorResponse "Invalid input, insufficient datacredit balance, amount outside the withdrawal limits, no payout account or a payout account not in NGN"
 // @Failure     401 {object} utils.ErrorResponse "User not authenticated or UserID mismatch"
+// @Failure     404 {object} utils.ErrorResponse "Payout account not found"
 // @Failure     500 {object} utils.ErrorResponse "Internal server error during withdrawal initiation"
 // @Failure     503 {object} utils.ErrorResponse "Service Unavailable: Error from Paystack during withdrawal"
 // @Router      /payments/withdraw [post]
@@ -209,14 +382,14 @@ func (h *PaymentHandler) HandleWithdrawal(c *gin.Context) {
 	}
 	// UserID is now validated against authenticated user.
 
-	transferCode, err := h.PaystackService.InitiateWithdrawal(req, h.SupabaseService)
+	withdrawal, err := h.PaystackService.InitiateWithdrawal(req, h.SupabaseService)
 	if err != nil {
 		log.Printf("Error initiating withdrawal for UserID %s: %v", req.UserID, err)
-		// Using strings.Contains is fragile. Ideally, services.PaystackService.InitiateWithdrawal
-		// would return specific error types (e.g., ErrInsufficientBalance).
-		if strings.Contains(strings.ToLower(err.Error()), "insufficient datacredit balance") {
+		if errors.Is(err, services.ErrInsufficientDatacredit) || errors.Is(err, services.ErrNoPayoutAccount) || errors.Is(err, services.ErrPayoutCurrency) || isWithdrawalLimitError(err) {
 			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
-		} else if strings.Contains(strings.ToLower(err.Error()), "paystack transfer initiation failed") {
+		} else if errors.Is(err, services.ErrPayoutAccountNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Payout account not found")
+		} else if errors.Is(err, services.ErrTransferInitiation) {
 			utils.RespondWithError(c, http.StatusServiceUnavailable, err.Error()) // Paystack specific issue
 		} else {
 			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to initiate withdrawal: "+err.Error())
@@ -225,14 +398,15 @@ func (h *PaymentHandler) HandleWithdrawal(c *gin.Context) {
 	}
 
 	utils.RespondWithJSON(c, http.StatusOK, gin.H{
-		"message":       "Withdrawal initiation request processed", // Message reflects that it's an async process
-		"transfer_code": transferCode,
+		"message":       withdrawalStatusMessage(withdrawal), // Message reflects that it's an async process
+		"transfer_code": withdrawal.TransferCode,
+		"withdrawal":    withdrawal,
 	})
 }
 
 // PurchaseDatabytes godoc
 // @Summary     Purchase Databytes
-// @Description Purchase databytes using the authenticated user's datacredit balance.
+// @Description Purchase databytes using the authenticated user's datacredit balance, priced at the current databyte rate and volume tier (see /databytes/quote). Pass the quote's rate_version as expected_rate_version, or a max_cost_kobo, to refuse the purchase if the rate has changed since. The rate version and tier applied are recorded in the transaction metadata.
 // @Tags        Databytes
 // @Accept      json
 // @Produce     json
@@ -241,6 +415,7 @@ func (h *PaymentHandler) HandleWithdrawal(c *gin.Context) {
 // @Success     200 {object} models.Wallet "Updated wallet information after the purchase"
 // @Failure     400 {object} utils.ErrorResponse "Invalid input, insufficient datacredits, or positive databyte amount required"
 // @Failure     401 {object} utils.ErrorResponse "User not authenticated or UserID mismatch"
+// @Failure     409 {object} utils.ErrorResponse "Rate version changed or cost above max_cost_kobo"
 // @Failure     500 {object} utils.ErrorResponse "Internal server error during databyte purchase"
 // @Router      /databytes/purchase [post]
 func (h *PaymentHandler) PurchaseDatabytes(c *gin.Context) {
@@ -264,15 +439,15 @@ func (h *PaymentHandler) PurchaseDatabytes(c *gin.Context) {
 		return
 	}
 
-	wallet, err := h.SupabaseService.PurchaseDatabytesWithDatacredit(req.UserID, req.DatabyteAmount)
+	wallet, err := h.SupabaseService.PurchaseDatabytesWithDatacredit(req.UserID, req.DatabyteAmount, req.ExpectedRateVersion, req.MaxCostKobo)
 	if err != nil {
-		// Again, using strings.Contains is not ideal.
-		// Define custom error types in your service layer (e.g., services.ErrInsufficientDatacredit).
-		if strings.Contains(strings.ToLower(err.Error()), "insufficient datacredit balance") {
+		if errors.Is(err, services.ErrInsufficientDatacredit) || errors.Is(err, services.ErrDatabyteAmountTooBig) {
 			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
-		} else if strings.Contains(strings.ToLower(err.Error()), "invalid configuration for databytes_per_datacredit_kobo") {
-			log.Printf("Configuration error during databyte purchase for UserID %s: %v", req.UserID, err)
-			utils.RespondWithError(c, http.StatusInternalServerError, "Configuration error, please contact support.")
+		} else if errors.Is(err, services.ErrDatabyteRateChanged) || errors.Is(err, services.ErrDatabyteCostTooHigh) {
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		} else if errors.Is(err, services.ErrNoDatabyteRate) {
+			log.Printf("Databyte pricing error during databyte purchase for UserID %s: %v", req.UserID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Databyte pricing is not configured, please contact support.")
 		} else {
 			log.Printf("Error purchasing databytes for UserID %s: %v", req.UserID, err)
 			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to purchase databytes")
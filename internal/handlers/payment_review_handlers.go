@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPaymentReviews godoc
+// @Summary     List Payment Reviews
+// @Description List Paystack charges that were not credited automatically because they did not match their payment intent (unknown reference, amount, currency or user mismatch, or an intent that had failed), oldest first. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       status query string false "Only reviews in this status" Enums(open, resolved) default(open)
+// @Param       limit  query int    false "Page size (max 100)" default(20)
+// @Param       offset query int    false "Number of reviews to skip" default(0)
+// @Success     200 {array}  models.PaymentReview "Payment reviews"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing payment reviews"
+// @Router      /admin/payment-reviews [get]
+func (h *PaymentHandler) ListPaymentReviews(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	reviews, err := h.SupabaseService.ListPaymentReviews(c.DefaultQuery("status", "open"), limit, offset)
+	if err != nil {
+		log.Printf("Error listing payment reviews: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list payment reviews")
+		return
+	}
+	if reviews == nil {
+		reviews = []models.PaymentReview{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, reviews)
+}
+
+// ResolvePaymentReview godoc
+// @Summary     Resolve Payment Review
+// @Description Close an open payment review. credit pays the amount Paystack received into the wallet of the user who started the payment and marks the payment intent succeeded; it is refused for charges with no payment intent or in another currency. dismiss leaves the wallet alone and fails the payment intent, e.g. when the charge is refunded instead. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id      path int                                true "Payment review ID"
+// @Param       request body models.ResolvePaymentReviewRequest true "credit or dismiss, and a note for the audit trail"
+// @Success     200 {object} models.PaymentReviewResolution "The resolved review and its payment intent"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or review ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Payment review not found"
+// @Failure     409 {object} utils.ErrorResponse "Review already resolved, or the charge cannot be credited"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error resolving the review"
+// @Router      /admin/payment-reviews/{id}/resolve [post]
+func (h *PaymentHandler) ResolvePaymentReview(c *gin.Context) {
+	reviewID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || reviewID <= 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid payment review ID")
+		return
+	}
+	var req models.ResolvePaymentReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+
+	resolution, err := h.PaystackService.ResolvePaymentReview(reviewID, req, adminID, h.SupabaseService)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrPaymentReviewNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Payment review not found")
+		case errors.Is(err, services.ErrPaymentReviewResolved),
+			errors.Is(err, services.ErrPaymentReviewNotCreditable),
+			errors.Is(err, services.ErrInvalidPaymentIntentTransition):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			log.Printf("Error resolving payment review %d by admin %s: %v", reviewID, adminID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to resolve payment review")
+		}
+		return
+	}
+
+	log.Printf("INFO: Payment review %d resolved (%s) by admin %s: %s", reviewID, req.Action, adminID, req.Note)
+	utils.RespondWithJSON(c, http.StatusOK, resolution)
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AddPayoutAccount godoc
+// @Summary     Add Payout Bank Account
+// @Description Add a bank account for withdrawals. The account name is resolved through Paystack and a Paystack transfer recipient is created for it. The first account added becomes the default.
+// @Tags        Payout Accounts
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       payoutAccount body models.AddPayoutAccountRequest true "Bank code and account number"
+// @Success     201 {object} models.PayoutAccount "The added payout account"
+// @Failure     400 {object} utils.ErrorResponse "Invalid input, a currency other than NGN, or the account could not be resolved"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     409 {object} utils.ErrorResponse "The account has already been added"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error adding the payout account"
+// @Router      /payout-accounts [post]
+func (h *PaymentHandler) AddPayoutAccount(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	var req models.AddPayoutAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	account, err := h.PaystackService.AddPayoutAccount(userID, req, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error adding payout account for UserID %s: %v", userID, err)
+		switch {
+		case errors.Is(err, services.ErrBankAccountUnresolved), errors.Is(err, services.ErrPayoutCurrency):
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrPayoutAccountExists):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to add payout account")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusCreated, account)
+}
+
+// ListPayoutAccounts godoc
+// @Summary     List Payout Bank Accounts
+// @Description List the authenticated user's payout bank accounts, most recently added first.
+// @Tags        Payout Accounts
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {array}  models.PayoutAccount "The user's payout accounts"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing payout accounts"
+// @Router      /payout-accounts [get]
+func (h *PaymentHandler) ListPayoutAccounts(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	accounts, err := h.SupabaseService.ListPayoutAccounts(userID)
+	if err != nil {
+		log.Printf("Error listing payout accounts for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list payout accounts")
+		return
+	}
+	if accounts == nil {
+		accounts = []models.PayoutAccount{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, accounts)
+}
+
+// SetDefaultPayoutAccount godoc
+// @Summary     Set Default Payout Bank Account
+// @Description Make one of the authenticated user's payout accounts the default for withdrawals.
+// @Tags        Payout Accounts
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Payout account ID"
+// @Success     200 {object} models.PayoutAccount "The new default payout account"
+// @Failure     400 {object} utils.ErrorResponse "Invalid payout account ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "No payout account with this ID for the user"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error updating the payout account"
+// @Router      /payout-accounts/{id}/default [put]
+func (h *PaymentHandler) SetDefaultPayoutAccount(c *gin.Context) {
+	accountID, ok := uuidParam(c, "id", "payout account")
+	if !ok {
+		return
+	}
+
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	account, err := h.SupabaseService.SetDefaultPayoutAccount(userID, accountID)
+	if err != nil {
+		log.Printf("Error setting default payout account for UserID %s: %v", userID, err)
+		if errors.Is(err, services.ErrPayoutAccountNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Payout account not found")
+		} else {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to set default payout account")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, account)
+}
+
+// RemovePayoutAccount godoc
+// @Summary     Remove Payout Bank Account
+// @Description Remove one of the authenticated user's payout accounts. If it was the default, the most recently added remaining account becomes the default. An account cannot be removed while a withdrawal to it is still in progress.
+// @Tags        Payout Accounts
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Payout account ID"
+// @Success     200 {object} map[string]string "message"
+// @Failure     400 {object} utils.ErrorResponse "Invalid payout account ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "No payout account with this ID for the user"
+// @Failure     409 {object} utils.ErrorResponse "A withdrawal to the account is still in progress"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error removing the payout account"
+// @Router      /payout-accounts/{id} [delete]
+func (h *PaymentHandler) RemovePayoutAccount(c *gin.Context) {
+	accountID, ok := uuidParam(c, "id", "payout account")
+	if !ok {
+		return
+	}
+
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	if err := h.PaystackService.RemovePayoutAccount(userID, accountID, h.SupabaseService); err != nil {
+		log.Printf("Error removing payout account for UserID %s: %v", userID, err)
+		if errors.Is(err, services.ErrPayoutAccountNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Payout account not found")
+		} else if errors.Is(err, services.ErrPayoutAccountInUse) {
+			utils.RespondWithError(c, http.StatusConflict, "Payout account has withdrawals in progress; remove it once they complete")
+		} else {
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to remove payout account")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, gin.H{"message": "Payout account removed"})
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListPayoutBatches godoc
+// @Summary     List Payout Batches
+// @Description List the bulk transfer batches sent in payout-batching mode, newest first. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       limit  query int false "Page size (max 100)" default(20)
+// @Param       offset query int false "Number of batches to skip" default(0)
+// @Success     200 {array}  models.PayoutBatch "Payout batches"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing payout batches"
+// @Router      /admin/payout-batches [get]
+func (h *PaymentHandler) ListPayoutBatches(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	batches, err := h.SupabaseService.ListPayoutBatches(limit, offset)
+	if err != nil {
+		log.Printf("Error listing payout batches: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list payout batches")
+		return
+	}
+	if batches == nil {
+		batches = []models.PayoutBatch{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, batches)
+}
+
+// GetPayoutBatch godoc
+// @Summary     Get Payout Batch
+// @Description Get a payout batch with Paystack's per-transfer results and the current state of each of its withdrawals. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Payout batch ID"
+// @Success     200 {object} models.PayoutBatchDetail "The batch and its withdrawals"
+// @Failure     400 {object} utils.ErrorResponse "Invalid payout batch ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Payout batch not found"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the payout batch"
+// @Router      /admin/payout-batches/{id} [get]
+func (h *PaymentHandler) GetPayoutBatch(c *gin.Context) {
+	batchID, ok := uuidParam(c, "id", "payout batch")
+	if !ok {
+		return
+	}
+
+	batch, err := h.SupabaseService.GetPayoutBatch(batchID)
+	if err != nil {
+		if errors.Is(err, services.ErrPayoutBatchNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Payout batch not found")
+			return
+		}
+		log.Printf("Error fetching payout batch %s: %v", batchID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch payout batch")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, batch)
+}
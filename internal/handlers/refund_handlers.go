@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IssueRefund godoc
+// @Summary     Issue Refund
+// @Description Refund all or part of a datacredit purchase through Paystack. The refunded datacredit is debited from the user's wallet; whatever they have already spent is recorded as a debt and collected from their next purchases. If the refund later fails, the debited datacredit is returned. Admin only.
+// @Tags        Admin
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.RefundRequest true "Paystack reference of the payment, optional amount in kobo (everything not yet refunded if omitted) and reason"
+// @Success     200 {object} models.Refund "The refund, pending or processing at Paystack"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "No payment with this reference"
+// @Failure     409 {object} utils.ErrorResponse "Payment did not succeed or the amount exceeds what is left to refund"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error recording the refund; nothing was sent to Paystack"
+// @Failure     502 {object} utils.ErrorResponse "Paystack rejected the refund; the wallet was not debited"
+// @Failure     503 {object} utils.ErrorResponse "Paystack could not be reached; the refund stays pending until its webhook arrives"
+// @Router      /admin/refunds [post]
+func (h *PaymentHandler) IssueRefund(c *gin.Context) {
+	var req models.RefundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+	adminID := c.GetString("adminID")
+
+	refund, err := h.PaystackService.IssueRefund(req, adminID, h.SupabaseService)
+	if err != nil {
+		log.Printf("Error refunding Paystack Ref %s by admin %s: %v", req.Reference, adminID, err)
+		switch {
+		case errors.Is(err, services.ErrPaymentIntentNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Payment not found")
+		case errors.Is(err, services.ErrNotRefundable):
+			utils.RespondWithError(c, http.StatusConflict, err.Error())
+		case errors.Is(err, services.ErrRefundRejected):
+			utils.RespondWithError(c, http.StatusBadGateway, err.Error())
+		case errors.Is(err, services.ErrRefundOutcomeUnknown):
+			utils.RespondWithError(c, http.StatusServiceUnavailable, "Refund recorded but Paystack could not be reached: "+err.Error())
+		default:
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to issue refund")
+		}
+		return
+	}
+
+	log.Printf("INFO: Refund %s issued by admin %s", refund.ID, adminID)
+	utils.RespondWithJSON(c, http.StatusOK, refund)
+}
+
+// ListRefunds godoc
+// @Summary     List Refunds
+// @Description List refunds, newest first. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       reference query string false "Only refunds of the payment with this Paystack reference"
+// @Param       status    query string false "Only refunds in this status" Enums(pending, processing, processed, failed)
+// @Param       limit     query int    false "Page size (max 100)" default(20)
+// @Param       offset    query int    false "Number of refunds to skip" default(0)
+// @Success     200 {array}  models.Refund "Refunds"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing refunds"
+// @Router      /admin/refunds [get]
+func (h *PaymentHandler) ListRefunds(c *gin.Context) {
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	refunds, err := h.SupabaseService.ListRefunds(c.Query("reference"), c.Query("status"), limit, offset)
+	if err != nil {
+		log.Printf("Error listing refunds: %v", err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list refunds")
+		return
+	}
+	if refunds == nil {
+		refunds = []models.Refund{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, refunds)
+}
+
+// GetRefund godoc
+// @Summary     Get Refund
+// @Description Get a refund, including how much was debited from the wallet and how much was recorded as a debt. Admin only.
+// @Tags        Admin
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Refund ID"
+// @Success     200 {object} models.Refund "The refund"
+// @Failure     400 {object} utils.ErrorResponse "Invalid refund ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     403 {object} utils.ErrorResponse "Admin access required"
+// @Failure     404 {object} utils.ErrorResponse "Refund not found"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the refund"
+// @Router      /admin/refunds/{id} [get]
+func (h *PaymentHandler) GetRefund(c *gin.Context) {
+	refundID, ok := uuidParam(c, "id", "refund")
+	if !ok {
+		return
+	}
+
+	refund, err := h.SupabaseService.GetRefund(refundID)
+	if err != nil {
+		if errors.Is(err, services.ErrRefundNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Refund not found")
+			return
+		}
+		log.Printf("Error fetching refund %s: %v", refundID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch refund")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, refund)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListTransactions godoc
+// @Summary     List Transactions
+// @Description List the authenticated user's wallet transactions, newest first, with cursor pagination. Pass next_cursor from a page as cursor to fetch the following page; pages stay stable while new transactions arrive.
+// @Tags        Transactions
+// @Produce     json
+// @Security    BearerAuth
+// @Param       cursor    query string false "Cursor returned as next_cursor by the previous page"
+// @Param       limit     query int    false "Page size (max 100)" default(20)
+// @Param       operation query string false "Only transactions of this operation, e.g. credit_purchase or withdrawal"
+// @Param       currency  query string false "Only transactions in this currency" Enums(datacredit, databyte)
+// @Param       reference query string false "Only transactions with this external reference, e.g. a Paystack reference"
+// @Param       from      query string false "Only transactions at or after this time (YYYY-MM-DD or RFC3339)"
+// @Param       to        query string false "Only transactions before this time (RFC3339), or on or before this day (YYYY-MM-DD)"
+// @Success     200 {object} models.TransactionPage "A page of transactions"
+// @Failure     400 {object} utils.ErrorResponse "Invalid cursor, limit, currency or date"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing transactions"
+// @Router      /transactions [get]
+func (h *PaymentHandler) ListTransactions(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	limit := defaultPageSize
+	if raw := c.Query("limit"); raw != "" {
+		var err error
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxPageSize {
+			utils.RespondWithError(c, http.StatusBadRequest, "limit must be between 1 and 100")
+			return
+		}
+	}
+
+	filter := models.TransactionFilter{
+		UserID:            userID,
+		Operation:         c.Query("operation"),
+		Currency:          c.Query("currency"),
+		ExternalReference: c.Query("reference"),
+	}
+	if filter.Currency != "" && filter.Currency != models.CurrencyDatacredit && filter.Currency != models.CurrencyDatabyte {
+		utils.RespondWithError(c, http.StatusBadRequest, "currency must be datacredit or databyte")
+		return
+	}
+	var ok bool
+	if filter.From, ok = timeParam(c, "from", false); !ok {
+		return
+	}
+	if filter.To, ok = timeParam(c, "to", true); !ok {
+		return
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		utils.RespondWithError(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	page, err := h.SupabaseService.ListTransactions(filter, c.Query("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		log.Printf("Error listing transactions for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list transactions")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, page)
+}
+
+// timeParam parses an optional query parameter given as an RFC3339 time or a date
+// (midnight UTC). With endOfDay, a date means the end of that day, so a date range
+// includes its last day. It responds with 400 and returns ok=false when invalid.
+func timeParam(c *gin.Context, name string, endOfDay bool) (t *time.Time, ok bool) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, true
+	}
+	if parsed, err := time.Parse(time.RFC3339, raw); err == nil {
+		return &parsed, true
+	}
+	parsed, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, name+" must be a date (YYYY-MM-DD) or an RFC3339 time")
+		return nil, false
+	}
+	if endOfDay {
+		parsed = parsed.AddDate(0, 0, 1)
+	}
+	return &parsed, true
+}
+
+// statementContentTypes are the response content types of the statement formats.
+var statementContentTypes = map[string]string{
+	models.StatementFormatCSV:   "text/csv; charset=utf-8",
+	models.StatementFormatJSONL: "application/x-ndjson",
+	models.StatementFormatOFX:   "application/x-ofx",
+}
+
+// ExportStatement godoc
+// @Summary     Export Statement
+// @Description Download a statement of the authenticated user's transactions for a date range as CSV, JSON Lines or OFX. It includes the opening and closing balance of each currency, the running balance after each transaction and the totals per operation. Amounts are in kobo for datacredit and units for databytes (naira for datacredit in OFX). The statement is streamed as it is read.
+// @Tags        Transactions
+// @Produce     plain
+// @Security    BearerAuth
+// @Param       format query string false "Statement format" Enums(csv, jsonl, ofx) default(csv)
+// @Param       from   query string false "Start of the statement (YYYY-MM-DD or RFC3339); defaults to 30 days before to"
+// @Param       to     query string false "End of the statement, exclusive (RFC3339), or its last day (YYYY-MM-DD); defaults to now"
+// @Success     200 {file} file "The statement"
+// @Failure     400 {object} utils.ErrorResponse "Invalid format or date"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error exporting the statement"
+// @Router      /transactions/statement [get]
+func (h *PaymentHandler) ExportStatement(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	format := c.DefaultQuery("format", models.StatementFormatCSV)
+	contentType, ok := statementContentTypes[format]
+	if !ok {
+		utils.RespondWithError(c, http.StatusBadRequest, "format must be csv, jsonl or ofx")
+		return
+	}
+	from, ok := timeParam(c, "from", false)
+	if !ok {
+		return
+	}
+	to, ok := timeParam(c, "to", true)
+	if !ok {
+		return
+	}
+	if to == nil {
+		now := time.Now()
+		to = &now
+	}
+	if from == nil {
+		start := to.AddDate(0, 0, -30)
+		from = &start
+	}
+	if !from.Before(*to) {
+		utils.RespondWithError(c, http.StatusBadRequest, "from must be before to")
+		return
+	}
+
+	// The opening balances are read before anything is written, so a failure there can
+	// still be reported as an error response.
+	opening, err := h.SupabaseService.TransactionBalancesBefore(userID, *from)
+	if err != nil {
+		log.Printf("Error exporting statement for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to export statement")
+		return
+	}
+
+	fileName := fmt.Sprintf("statement_%s_%s.%s", from.UTC().Format("20060102"), to.UTC().Format("20060102"), format)
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	c.Status(http.StatusOK)
+	if err := h.SupabaseService.WriteStatementFrom(c.Writer, format, userID, *from, *to, opening); err != nil {
+		// Headers are already sent; the client sees a truncated download.
+		log.Printf("Error streaming statement for UserID %s: %v", userID, err)
+	}
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserHandler handles the authenticated user's own profile and wallet.
+type UserHandler struct {
+	SupabaseService *services.SupabaseService
+}
+
+// NewUserHandler creates a new UserHandler
+func NewUserHandler(ss *services.SupabaseService) *UserHandler {
+	return &UserHandler{
+		SupabaseService: ss,
+	}
+}
+
+// GetMyProfile godoc
+// @Summary     Get My Profile
+// @Description Get the authenticated user's profile.
+// @Tags        Profile
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} models.Profile "The user's profile"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "The user has no profile"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the profile"
+// @Router      /profile [get]
+func (h *UserHandler) GetMyProfile(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	profile, err := h.SupabaseService.GetUserProfile(userID)
+	if err != nil {
+		if errors.Is(err, services.ErrProfileNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Profile not found")
+			return
+		}
+		log.Printf("Error fetching profile for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch profile")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, profile)
+}
+
+// UpdateMyProfile godoc
+// @Summary     Update My Profile
+// @Description Update the authenticated user's name, username or image URL. Omitted fields are left unchanged; an empty name or image_url clears it. Usernames may contain letters, digits, '_' and '.', and must be unique regardless of case.
+// @Tags        Profile
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       request body models.UpdateProfileRequest true "Profile fields to change"
+// @Success     200 {object} models.Profile "The updated profile"
+// @Failure     400 {object} utils.ErrorResponse "Invalid request payload or field"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "The user has no profile"
+// @Failure     409 {object} utils.ErrorResponse "Username is already taken"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error updating the profile"
+// @Router      /profile [put]
+func (h *UserHandler) UpdateMyProfile(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	profile, err := h.SupabaseService.UpdateUserProfile(userID, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidProfile):
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+		case errors.Is(err, services.ErrUsernameTaken):
+			utils.RespondWithError(c, http.StatusConflict, "Username is already taken")
+		case errors.Is(err, services.ErrProfileNotFound):
+			utils.RespondWithError(c, http.StatusNotFound, "Profile not found")
+		default:
+			log.Printf("Error updating profile for UserID %s: %v", userID, err)
+			utils.RespondWithError(c, http.StatusInternalServerError, "Failed to update profile")
+		}
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, profile)
+}
+
+// GetMyWalletBalance godoc
+// @Summary     Get My Wallet Balance
+// @Description Get the authenticated user's wallet: datacredit balance (kobo), the part held for pending withdrawals and disputes, the datacredit available to spend or withdraw, and the databyte balance.
+// @Tags        Profile
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} models.WalletBalance "The user's wallet balances"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the wallet"
+// @Router      /profile/wallet [get]
+func (h *UserHandler) GetMyWalletBalance(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	balance, err := h.SupabaseService.GetWalletBalance(userID)
+	if err != nil {
+		log.Printf("Error fetching wallet for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch wallet")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, balance)
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+	"github.com/tedobanks/datagram_payment_processor/internal/services"
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Page size limits for list endpoints.
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// ListWithdrawals godoc
+// @Summary     List Withdrawals
+// @Description List the authenticated user's withdrawals, newest first.
+// @Tags        Payments
+// @Produce     json
+// @Security    BearerAuth
+// @Param       status query string false "Only withdrawals in this status" Enums(pending_approval, queued, pending, awaiting_otp, processing, completed, failed, reversed, rejected)
+// @Param       limit  query int    false "Page size (max 100)" default(20)
+// @Param       offset query int    false "Number of withdrawals to skip" default(0)
+// @Success     200 {array}  models.Withdrawal "The user's withdrawals"
+// @Failure     400 {object} utils.ErrorResponse "Invalid limit or offset"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error listing withdrawals"
+// @Router      /payments/withdrawals [get]
+func (h *PaymentHandler) ListWithdrawals(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	limit, offset, ok := pageParams(c)
+	if !ok {
+		return
+	}
+
+	withdrawals, err := h.SupabaseService.ListWithdrawals(userID, c.Query("status"), limit, offset)
+	if err != nil {
+		log.Printf("Error listing withdrawals for UserID %s: %v", userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to list withdrawals")
+		return
+	}
+	if withdrawals == nil {
+		withdrawals = []models.Withdrawal{}
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, withdrawals)
+}
+
+// GetWithdrawal godoc
+// @Summary     Get Withdrawal
+// @Description Get one of the authenticated user's withdrawals with its status history, including when each status was entered and why a withdrawal failed.
+// @Tags        Payments
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Withdrawal ID"
+// @Success     200 {object} models.WithdrawalDetail "The withdrawal and its status history"
+// @Failure     400 {object} utils.ErrorResponse "Invalid withdrawal ID"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "No withdrawal with this ID for the user"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error fetching the withdrawal"
+// @Router      /payments/withdrawals/{id} [get]
+func (h *PaymentHandler) GetWithdrawal(c *gin.Context) {
+	withdrawalID, ok := uuidParam(c, "id", "withdrawal")
+	if !ok {
+		return
+	}
+
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	withdrawal, err := h.SupabaseService.GetWithdrawal(userID, withdrawalID)
+	if err != nil {
+		if errors.Is(err, services.ErrWithdrawalNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Withdrawal not found")
+			return
+		}
+		log.Printf("Error fetching withdrawal %s for UserID %s: %v", withdrawalID, userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to fetch withdrawal")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, withdrawal)
+}
+
+// QuoteWithdrawal godoc
+// @Summary     Quote Withdrawal
+// @Description Show the fee and net payout in NGN for withdrawing an amount to a payout account (the default one unless recipient_id is given), and the withdrawal limits that apply, before confirming the withdrawal.
+// @Tags        Payments
+// @Produce     json
+// @Security    BearerAuth
+// @Param       amount       query int    true  "Amount of datacredit (kobo) to withdraw"
+// @Param       recipient_id query string false "Payout account ID; defaults to the user's default payout account"
+// @Success     200 {object} models.WithdrawalQuote "Fee, net payout and limits"
+// @Failure     400 {object} utils.ErrorResponse "Invalid amount or recipient_id, amount outside the withdrawal limits, no payout account set up or a payout account not in NGN"
+// @Failure     401 {object} utils.ErrorResponse "User not authenticated"
+// @Failure     404 {object} utils.ErrorResponse "Payout account not found"
+// @Failure     500 {object} utils.ErrorResponse "Internal server error quoting the withdrawal"
+// @Router      /payments/withdrawals/quote [get]
+func (h *PaymentHandler) QuoteWithdrawal(c *gin.Context) {
+	userIDFromAuth, exists := c.Get("userID")
+	if !exists {
+		utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+	userID := userIDFromAuth.(string)
+
+	amount, err := strconv.ParseInt(c.Query("amount"), 10, 64)
+	if err != nil || amount <= 0 {
+		utils.RespondWithError(c, http.StatusBadRequest, "amount must be a positive integer (kobo)")
+		return
+	}
+	recipientID := c.Query("recipient_id")
+	if recipientID != "" {
+		if _, err := uuid.Parse(recipientID); err != nil {
+			utils.RespondWithError(c, http.StatusBadRequest, "Invalid recipient_id")
+			return
+		}
+	}
+
+	quote, err := h.PaystackService.QuoteWithdrawal(userID, recipientID, amount, h.SupabaseService)
+	if err != nil {
+		if isWithdrawalLimitError(err) || errors.Is(err, services.ErrNoPayoutAccount) || errors.Is(err, services.ErrPayoutCurrency) {
+			utils.RespondWithError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrPayoutAccountNotFound) {
+			utils.RespondWithError(c, http.StatusNotFound, "Payout account not found")
+			return
+		}
+		log.Printf("Error quoting withdrawal of %d kobo for UserID %s: %v", amount, userID, err)
+		utils.RespondWithError(c, http.StatusInternalServerError, "Failed to quote withdrawal")
+		return
+	}
+
+	utils.RespondWithJSON(c, http.StatusOK, quote)
+}
+
+// isWithdrawalLimitError reports whether err is a violation of the withdrawal limits.
+func isWithdrawalLimitError(err error) bool {
+	return errors.Is(err, services.ErrWithdrawalBelowMinimum) ||
+		errors.Is(err, services.ErrWithdrawalAboveMaximum) ||
+		errors.Is(err, services.ErrWithdrawalBelowFee) ||
+		errors.Is(err, services.ErrWithdrawalDailyLimit)
+}
+
+// pageParams reads the limit and offset query parameters, responding with 400 and
+// returning ok=false when they are invalid.
+func pageParams(c *gin.Context) (limit, offset int, ok bool) {
+	limit, offset = defaultPageSize, 0
+	var err error
+	if raw := c.Query("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 || limit > maxPageSize {
+			utils.RespondWithError(c, http.StatusBadRequest, "limit must be between 1 and 100")
+			return 0, 0, false
+		}
+	}
+	if raw := c.Query("offset"); raw != "" {
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			utils.RespondWithError(c, http.StatusBadRequest, "offset must be a non-negative integer")
+			return 0, 0, false
+		}
+	}
+	return limit, offset, true
+}
+
+// uuidParam reads a UUID path parameter, responding with 400 and returning ok=false
+// when it is not a UUID, so malformed IDs never reach Postgres.
+func uuidParam(c *gin.Context, name, what string) (string, bool) {
+	value := c.Param(name)
+	if _, err := uuid.Parse(value); err != nil {
+		utils.RespondWithError(c, http.StatusBadRequest, "Invalid "+what+" ID")
+		return "", false
+	}
+	return value, true
+}
+
+// withdrawalStatusMessage describes where a newly initiated or approved withdrawal stands.
+func withdrawalStatusMessage(withdrawal *models.Withdrawal) string {
+	switch withdrawal.Status {
+	case models.WithdrawalPendingApproval:
+		return "Withdrawal is awaiting approval"
+	case models.WithdrawalQueued:
+		return "Withdrawal queued for the next payout batch"
+	case models.WithdrawalAwaitingOTP:
+		return "Withdrawal transfer is awaiting OTP confirmation"
+	default:
+		return "Withdrawal initiation request processed"
+	}
+}
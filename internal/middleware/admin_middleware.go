@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminRole is the app_metadata role that grants access to the admin API. app_metadata
+// can only be changed with the service role key, so users cannot grant it to themselves.
+const AdminRole = "admin"
+
+// AdminMiddleware allows only admins through. It must run after AuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			utils.RespondWithError(c, http.StatusUnauthorized, "User not authenticated")
+			c.Abort()
+			return
+		}
+
+		if !IsAdmin(c) {
+			log.Printf("WARNING: Non-admin UserID '%s' denied access to %s", userID, c.FullPath())
+			utils.RespondWithError(c, http.StatusForbidden, "Admin access required")
+			c.Abort()
+			return
+		}
+
+		c.Set("adminID", userID)
+		c.Next()
+	}
+}
+
+// IsAdmin reports whether the authenticated user has the admin role, for handlers open
+// to all users that allow admins more. It must run after AuthMiddleware.
+func IsAdmin(c *gin.Context) bool {
+	appMetadata, _ := c.Get("userAppMetadata")
+	metadata, _ := appMetadata.(map[string]interface{})
+	role, _ := metadata["role"].(string)
+	return role == AdminRole
+}
@@ -112,6 +112,8 @@ func AuthMiddleware() gin.HandlerFunc {
 		c.Set("userID", claims.Subject)   // This is the Supabase User ID (UUID)
 		c.Set("userEmail", claims.Email) // Optional: make email available
 		c.Set("userRole", claims.Role)   // Optional: make role available
+		// app_metadata is server-controlled; AdminMiddleware reads the admin role from it.
+		c.Set("userAppMetadata", claims.AppMetadata)
 
 		log.Printf("AuthMiddleware: UserID '%s' (Email: '%s', Role: '%s') authenticated successfully.", claims.Subject, claims.Email, claims.Role)
 		c.Next() // Proceed to the next handler
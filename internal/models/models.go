@@ -1,7 +1,10 @@
 
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Profile matches your 'profiles' table.
 type Profile struct {
@@ -17,29 +20,377 @@ type Profile struct {
 	UpdatedAt       time.Time  `json:"updated_at,omitempty"`
 }
 
+// UpdateProfileRequest changes the authenticated user's profile. Omitted fields are left
+// unchanged; an empty name or image_url clears it.
+type UpdateProfileRequest struct {
+	Name     *string `json:"name,omitempty" binding:"omitempty,max=100"`
+	Username *string `json:"username,omitempty" binding:"omitempty,min=3,max=30"` // Letters, digits, '_' and '.'; unique regardless of case
+	ImageURL *string `json:"image_url,omitempty" binding:"omitempty,max=2048"`    // http(s) URL of the profile image
+}
+
 // Wallet matches your 'wallets' table.
 type Wallet struct {
 	UserID            string    `json:"user_id"` // (FK to profiles.id or auth.users.id)
 	DatabyteBalance   int64     `json:"databyte_balance"`
 	DatacreditBalance int64     `json:"datacredit_balance"` // Represents NGN value in kobo
+	HeldDatacredit    int64     `json:"held_datacredit"`    // Part of DatacreditBalance reserved by active holds
 	CreatedAt         time.Time `json:"created_at,omitempty"`
 	UpdatedAt         time.Time `json:"updated_at,omitempty"`
 }
 
+// AvailableDatacredit is the datacredit the user can spend or withdraw: the balance
+// minus what is held.
+func (w *Wallet) AvailableDatacredit() int64 {
+	return w.DatacreditBalance - w.HeldDatacredit
+}
+
+// WalletBalance is a wallet as shown to its owner: the datacredit balance, the part of it
+// held for pending withdrawals and disputes, what is available to spend or withdraw, and
+// the databyte balance.
+type WalletBalance struct {
+	UserID              string    `json:"user_id"`
+	DatacreditBalance   int64     `json:"datacredit_balance"` // kobo
+	HeldDatacredit      int64     `json:"held_datacredit"`
+	AvailableDatacredit int64     `json:"available_datacredit"`
+	DatabyteBalance     int64     `json:"databyte_balance"`
+	UpdatedAt           time.Time `json:"updated_at,omitempty"`
+}
+
+// WalletChange is returned by the apply_wallet_delta database function: the wallet
+// after an atomic balance change, plus the balances the change was applied to.
+type WalletChange struct {
+	Wallet                  Wallet `json:"wallet"`
+	DatacreditBalanceBefore int64  `json:"datacredit_balance_before"`
+	DatabyteBalanceBefore   int64  `json:"databyte_balance_before"`
+	JournalEntryID          int64         `json:"journal_entry_id"`
+	Transactions            []Transaction `json:"transactions"` // One per currency that changed
+}
+
+// Operations recorded on journal entries and transactions.
+const (
+	OperationCreditPurchase             = "credit_purchase"
+	OperationWithdrawal                 = "withdrawal"
+	OperationWithdrawalReversal         = "withdrawal_reversal" // Compensating credit for a failed or reversed transfer
+	OperationRefund                     = "refund"
+	OperationRefundReversal             = "refund_reversal" // Compensating credit for a failed refund
+	OperationDebtCollection             = "debt_collection" // Repayment of datacredit owed for a refund or chargeback
+	OperationChargeback                 = "chargeback"      // Debit for a dispute the cardholder won
+	OperationDatabytePurchase           = "databyte_purchase"
+	OperationDatabyteUpdate             = "databyte_update"
+	OperationDatacreditDebitForDatabyte = "datacredit_debit_for_databyte"
+	OperationDatabyteCreditFromPurchase = "databyte_credit_from_purchase"
+)
+
+// Ledger currencies. Datacredit is denominated in NGN kobo.
+const (
+	CurrencyDatacredit = "datacredit"
+	CurrencyDatabyte   = "databyte"
+)
+
+// LedgerAccount matches the 'ledger_accounts' table. User accounts are created on
+// first use, one per user and currency; platform accounts are seeded by migration.
+type LedgerAccount struct {
+	ID          int64     `json:"id"`
+	Code        string    `json:"code"`
+	OwnerUserID *string   `json:"owner_user_id,omitempty"`
+	Currency    string    `json:"currency"`
+	Kind        string    `json:"kind"` // "user" or "platform"
+	Balance     int64     `json:"balance"`
+	CreatedAt   time.Time `json:"created_at,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at,omitempty"`
+}
+
+// JournalEntry matches the 'journal_entries' table. Its postings always sum to zero per currency.
+type JournalEntry struct {
+	ID                  int64                  `json:"id"`
+	Operation           string                 `json:"operation"`
+	Description         *string                `json:"description,omitempty"`
+	ExternalReferenceID *string                `json:"external_reference_id,omitempty"`
+	Metadata            map[string]interface{} `json:"metadata,omitempty"`
+	CreatedAt           time.Time              `json:"created_at,omitempty"`
+	Postings            []Posting              `json:"postings,omitempty"`
+}
+
+// Posting matches the 'postings' table: one signed amount against one ledger account.
+type Posting struct {
+	ID        int64     `json:"id"`
+	EntryID   int64     `json:"entry_id"`
+	AccountID int64     `json:"account_id"`
+	Currency  string    `json:"currency"`
+	Amount    int64     `json:"amount"`
+	CreatedAt time.Time `json:"created_at,omitempty"`
+}
+
+// LedgerDrift is a row of the 'ledger_wallet_drift' view: a wallet whose balances
+// disagree with its ledger accounts.
+type LedgerDrift struct {
+	UserID                  string `json:"user_id"`
+	WalletDatacreditBalance int64  `json:"wallet_datacredit_balance"`
+	LedgerDatacreditBalance int64  `json:"ledger_datacredit_balance"`
+	WalletDatabyteBalance   int64  `json:"wallet_databyte_balance"`
+	LedgerDatabyteBalance   int64  `json:"ledger_databyte_balance"`
+}
+
+// WalletBalanceDrift is a row of the 'wallet_balance_drift' view: a user whose wallet
+// disagrees with their transaction history, their ledger accounts or their active holds.
+type WalletBalanceDrift struct {
+	UserID                        string `json:"user_id"`
+	WalletDatacreditBalance       int64  `json:"wallet_datacredit_balance"`
+	TransactionsDatacreditBalance int64  `json:"transactions_datacredit_balance"` // Sum of the user's datacredit transactions
+	LedgerDatacreditBalance       int64  `json:"ledger_datacredit_balance"`
+	WalletDatabyteBalance         int64  `json:"wallet_databyte_balance"`
+	TransactionsDatabyteBalance   int64  `json:"transactions_databyte_balance"` // Sum of the user's databyte transactions
+	LedgerDatabyteBalance         int64  `json:"ledger_databyte_balance"`
+	HeldDatacredit                int64  `json:"held_datacredit"`
+	ActiveHoldsDatacredit         int64  `json:"active_holds_datacredit"` // Sum of the user's active wallet holds
+}
+
+// LedgerInvariant is a platform-wide invariant checked by 'check_ledger_invariants'.
+// It holds when Expected equals Actual.
+type LedgerInvariant struct {
+	Name        string `json:"name"`
+	Currency    string `json:"currency"`
+	Description string `json:"description"`
+	Expected    int64  `json:"expected"`
+	Actual      int64  `json:"actual"`
+}
+
+// Holds reports whether the invariant holds.
+func (i LedgerInvariant) Holds() bool {
+	return i.Expected == i.Actual
+}
+
+// TransactionTotal is a row of the 'transaction_totals' view: the sum of all
+// transactions of one operation in one currency.
+type TransactionTotal struct {
+	Currency  string `json:"currency"`
+	Operation string `json:"operation"`
+	Total     int64  `json:"total"`
+	Count     int64  `json:"count"`
+}
+
+// LedgerCheckReport is the result of a ledger invariant check. Healthy is true when no
+// wallet drifted and every invariant holds; TransactionTotals breaks the outstanding
+// balances down into deposits, withdrawals and the other operations.
+type LedgerCheckReport struct {
+	CheckedAt         time.Time            `json:"checked_at"`
+	Healthy           bool                 `json:"healthy"`
+	DriftedWallets    []WalletBalanceDrift `json:"drifted_wallets"`
+	Invariants        []LedgerInvariant    `json:"invariants"`
+	FailedInvariants  int                  `json:"failed_invariants"`
+	TransactionTotals []TransactionTotal   `json:"transaction_totals"`
+}
+
 // Transaction matches your 'transactions' table.
 type Transaction struct {
-	ID                   int64                  `json:"id"`
+	ID                   int64                  `json:"id,omitempty"`
 	UserID               string                 `json:"user_id"` // (FK to profiles.id or auth.users.id)
 	Amount               int64                  `json:"amount"`  // Amount in kobo for datacredit, or units for databyte
 	BalanceBefore        *int64                 `json:"balance_before,omitempty"` // Assuming 'transactionbalance' refers to this
 	BalanceAfter         *int64                 `json:"balance_after,omitempty"`  // You'll need logic to populate this
 	Operation            string                 `json:"operation"`                 // Assuming 'transactionoperation' (e.g., "credit_purchase", "withdrawal", "databyte_purchase")
+	Currency             string                 `json:"currency"`                  // CurrencyDatacredit or CurrencyDatabyte
 	Description          *string                `json:"description,omitempty"`
 	ExternalReferenceID  *string                `json:"external_reference_id,omitempty"` // e.g., Paystack reference
 	Metadata             map[string]interface{} `json:"metadata,omitempty"`
+	JournalEntryID       *int64                 `json:"journal_entry_id,omitempty"`
 	TransactionTimestamp time.Time              `json:"transaction_timestamp,omitempty"`
 }
 
+// TransactionFilter narrows a user's transaction history. Empty fields and nil times
+// are not filtered on; From is inclusive and To exclusive.
+type TransactionFilter struct {
+	UserID            string
+	Operation         string
+	Currency          string
+	ExternalReference string
+	From              *time.Time
+	To                *time.Time
+}
+
+// TransactionPage is one page of a transaction history, newest first. NextCursor is
+// passed back as the cursor to fetch the following page and is null on the last page.
+type TransactionPage struct {
+	Data       []Transaction `json:"data"`
+	NextCursor *string       `json:"next_cursor"`
+	HasMore    bool          `json:"has_more"`
+}
+
+// Statement export formats.
+const (
+	StatementFormatCSV   = "csv"
+	StatementFormatJSONL = "jsonl"
+	StatementFormatOFX   = "ofx"
+)
+
+// StatementLine is a transaction on a statement with the currency's balance after it,
+// computed from the opening balance and the transactions before it.
+type StatementLine struct {
+	Transaction
+	RunningBalance int64 `json:"running_balance"`
+}
+
+// StatementBalance summarizes one currency of a statement: the balance before the first
+// transaction in range, the balance after the last, and the totals per operation.
+type StatementBalance struct {
+	Currency          string           `json:"currency"`
+	Opening           int64            `json:"opening"`
+	Closing           int64            `json:"closing"`
+	TransactionCount  int64            `json:"transaction_count"`
+	TotalsByOperation map[string]int64 `json:"totals_by_operation"`
+	CountsByOperation map[string]int64 `json:"counts_by_operation"`
+}
+
+// Sources of a reconstructed balance.
+const (
+	BalanceSourceBalanceAfter   = "balance_after"   // balance_after of the last transaction
+	BalanceSourceTransactionSum = "transaction_sum" // Sum of the amounts of all transactions so far
+)
+
+// PointInTimeBalance is one currency of a user's balance reconstructed from the
+// transaction log at a moment. Balance is the last transaction's balance_after when it
+// was recorded, otherwise the sum of all amounts so far; Consistent is false when both
+// are known and disagree, which means the wallet had drifted from its history.
+type PointInTimeBalance struct {
+	Currency         string       `json:"currency"`
+	Balance          int64        `json:"balance"`
+	Source           string       `json:"source"` // BalanceSourceBalanceAfter or BalanceSourceTransactionSum
+	SummedBalance    int64        `json:"summed_balance"`
+	Consistent       bool         `json:"consistent"`
+	TransactionCount int64        `json:"transaction_count"`
+	LastTransaction  *Transaction `json:"last_transaction"` // The last transaction that contributed; null if none
+}
+
+// BalanceAsOf is a user's datacredit and databyte balance at a moment.
+type BalanceAsOf struct {
+	UserID     string             `json:"user_id"`
+	AsOf       time.Time          `json:"as_of"`
+	Datacredit PointInTimeBalance `json:"datacredit"` // kobo
+	Databyte   PointInTimeBalance `json:"databyte"`
+}
+
+// Payment intent statuses.
+const (
+	PaymentIntentInitialized = "initialized"
+	PaymentIntentPending     = "pending"
+	PaymentIntentSucceeded   = "succeeded"
+	PaymentIntentFailed      = "failed"
+	PaymentIntentAbandoned   = "abandoned"
+	PaymentIntentExpired     = "expired"
+	PaymentIntentUnderReview = "under_review"
+)
+
+// paymentIntentTransitions lists the statuses a payment intent may move to from each status.
+// A late charge.success still credits the user, so succeeded is reachable from the
+// abandoned and expired states as well. A charge.success for a failed intent goes to the
+// review queue, and an intent under review only succeeds when an admin credits it.
+var paymentIntentTransitions = map[string][]string{
+	PaymentIntentInitialized: {PaymentIntentPending, PaymentIntentSucceeded, PaymentIntentFailed, PaymentIntentAbandoned, PaymentIntentExpired, PaymentIntentUnderReview},
+	PaymentIntentPending:     {PaymentIntentSucceeded, PaymentIntentFailed, PaymentIntentAbandoned, PaymentIntentExpired, PaymentIntentUnderReview},
+	PaymentIntentAbandoned:   {PaymentIntentSucceeded, PaymentIntentUnderReview},
+	PaymentIntentExpired:     {PaymentIntentSucceeded, PaymentIntentUnderReview},
+	PaymentIntentFailed:      {PaymentIntentUnderReview},
+	PaymentIntentUnderReview: {PaymentIntentSucceeded, PaymentIntentFailed},
+}
+
+// CanTransitionPaymentIntent reports whether a payment intent may move from one status to another.
+func CanTransitionPaymentIntent(from, to string) bool {
+	for _, allowed := range paymentIntentTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether the intent has reached a state the expiry sweeper no longer checks.
+func (p *PaymentIntent) IsFinal() bool {
+	return p.Status != PaymentIntentInitialized && p.Status != PaymentIntentPending
+}
+
+// PaymentIntent matches the 'payment_intents' table: one row per Paystack initialization,
+// holding what we expect the eventual charge.success to contain.
+type PaymentIntent struct {
+	ID                    string     `json:"id,omitempty"`
+	Reference             string     `json:"reference"`
+	UserID                string     `json:"user_id"`
+	Email                 string     `json:"email"`
+	Amount                int64      `json:"amount"` // Expected amount in kobo
+	Currency              string     `json:"currency"`
+	Purpose               string     `json:"purpose"`
+	Status                string     `json:"status"`
+	AccessCode            *string    `json:"access_code,omitempty"`
+	AuthorizationURL      *string    `json:"authorization_url,omitempty"`
+	PaystackTransactionID *int64     `json:"paystack_transaction_id,omitempty"`
+	PaidAmount            *int64     `json:"paid_amount,omitempty"`
+	PaidAt                *time.Time `json:"paid_at,omitempty"`
+	FailureReason         *string    `json:"failure_reason,omitempty"`
+	PaystackStatus        *string    `json:"paystack_status,omitempty"` // Last status reported by Paystack verify
+	LastVerifiedAt        *time.Time `json:"last_verified_at,omitempty"`
+	CreatedAt             time.Time  `json:"created_at,omitempty"`
+	UpdatedAt             time.Time  `json:"updated_at,omitempty"`
+}
+
+// Reasons a Paystack charge is sent to the payment review queue instead of being credited.
+const (
+	ReviewReasonUnknownReference = "unknown_reference"
+	ReviewReasonAmountMismatch   = "amount_mismatch"
+	ReviewReasonCurrencyMismatch = "currency_mismatch"
+	ReviewReasonUserMismatch     = "user_mismatch"
+	ReviewReasonIntentFailed     = "intent_failed"
+)
+
+// PaymentReview matches the 'payment_reviews' table: a charge that did not match its
+// payment intent and is waiting for a human decision.
+type PaymentReview struct {
+	ID               int64                  `json:"id,omitempty"`
+	Reference        string                 `json:"reference"`
+	IntentID         *string                `json:"intent_id,omitempty"`
+	UserID           *string                `json:"user_id,omitempty"`
+	Reason           string                 `json:"reason"`
+	ExpectedAmount   *int64                 `json:"expected_amount,omitempty"`
+	ReceivedAmount   *int64                 `json:"received_amount,omitempty"`
+	ExpectedCurrency *string                `json:"expected_currency,omitempty"`
+	ReceivedCurrency *string                `json:"received_currency,omitempty"`
+	Payload          map[string]interface{} `json:"payload,omitempty"`
+	Status           string                 `json:"status,omitempty"`
+	Resolution       *string                `json:"resolution,omitempty"` // credited or dismissed
+	ResolutionNote   *string                `json:"resolution_note,omitempty"`
+	ResolvedBy       *string                `json:"resolved_by,omitempty"`
+	CreatedAt        *time.Time             `json:"created_at,omitempty"`
+	ResolvedAt       *time.Time             `json:"resolved_at,omitempty"`
+}
+
+// Payment review resolutions.
+const (
+	PaymentReviewCredit  = "credit"
+	PaymentReviewDismiss = "dismiss"
+)
+
+// ResolvePaymentReviewRequest closes a payment review: credit pays the amount Paystack
+// received into the intent's user's wallet, dismiss leaves the wallet alone (e.g. the
+// charge is being refunded).
+type ResolvePaymentReviewRequest struct {
+	Action string `json:"action" binding:"required,oneof=credit dismiss"`
+	Note   string `json:"note" binding:"required"`
+}
+
+// PaymentReviewResolution is a resolved payment review and its payment intent.
+type PaymentReviewResolution struct {
+	Review   PaymentReview  `json:"review"`
+	Intent   *PaymentIntent `json:"intent,omitempty"`
+	Credited bool           `json:"credited"`
+}
+
+// PaymentVerification is the result of verifying a payment reference with Paystack on the
+// client's behalf.
+type PaymentVerification struct {
+	Reference      string         `json:"reference"`
+	PaystackStatus string         `json:"paystack_status"`
+	Credited       bool           `json:"credited"` // True if this request applied the credit
+	Intent         *PaymentIntent `json:"intent"`
+}
+
 // PaystackInitializeRequest remains the same.
 type PaystackInitializeRequest struct {
 	Email  string `json:"email" binding:"required,email"`
@@ -52,6 +403,29 @@ type PaystackWebhookPayload struct {
 	Data  interface{} `json:"data"`
 }
 
+// Statuses of a row in the 'processed_webhook_events' table.
+const (
+	WebhookEventProcessing = "processing"
+	WebhookEventProcessed  = "processed"
+	WebhookEventFailed     = "failed"
+)
+
+// ProcessedWebhookEvent matches the 'processed_webhook_events' table, which makes
+// Paystack webhook processing idempotent per event type and reference.
+type ProcessedWebhookEvent struct {
+	ID              int64                  `json:"id"`
+	EventType       string                 `json:"event_type"`
+	EventKey        string                 `json:"event_key"` // Paystack reference or transfer code
+	Status          string                 `json:"status"`
+	Attempts        int                    `json:"attempts"`
+	DuplicateCount  int                    `json:"duplicate_count"`
+	Result          map[string]interface{} `json:"result,omitempty"`
+	FirstReceivedAt time.Time              `json:"first_received_at,omitempty"`
+	LastReceivedAt  time.Time              `json:"last_received_at,omitempty"`
+	ProcessedAt     *time.Time             `json:"processed_at,omitempty"`
+	ClaimedAt       time.Time              `json:"claimed_at,omitempty"` // Start of the current processing lease
+}
+
 // PaystackTransactionData remains largely the same, ensure it captures what you need.
 type PaystackTransactionData struct {
 	ID              int64                  `json:"id"`
@@ -74,17 +448,498 @@ type PaystackTransactionData struct {
 	} `json:"customer"`
 }
 
+// Wallet hold statuses.
+const (
+	WalletHoldActive   = "active"
+	WalletHoldCaptured = "captured" // Debited from the wallet
+	WalletHoldReleased = "released" // Made available again
+)
+
+// WalletHold matches the 'wallet_holds' table: datacredit reserved on a wallet until
+// it is captured (debited) or released.
+type WalletHold struct {
+	ID        int64      `json:"id"`
+	UserID    string     `json:"user_id"`
+	Amount    int64      `json:"amount"` // kobo
+	Reason    string     `json:"reason"`
+	Reference *string    `json:"reference,omitempty"`
+	Status    string     `json:"status"`
+	CreatedAt time.Time  `json:"created_at"`
+	SettledAt *time.Time `json:"settled_at,omitempty"`
+}
+
+// Withdrawal statuses. See supabase/migrations for the lifecycle.
+const (
+	WithdrawalPendingApproval = "pending_approval" // Held on the wallet until an admin approves or rejects it
+	WithdrawalQueued          = "queued"           // Waiting for the next payout batch (batching mode); funds held
+	WithdrawalPending         = "pending"          // Created with a hold on the wallet; Paystack not called yet
+	WithdrawalAwaitingOTP     = "awaiting_otp"     // Transfer created but waiting to be finalized with an OTP; funds still held
+	WithdrawalProcessing      = "processing"       // Transfer accepted by Paystack; funds still held
+	WithdrawalCompleted       = "completed"
+	WithdrawalFailed          = "failed"
+	WithdrawalReversed        = "reversed"
+	WithdrawalRejected        = "rejected" // Rejected by an admin; hold released
+)
+
+// Withdrawal matches the 'withdrawals' table.
+type Withdrawal struct {
+	ID                     string     `json:"id,omitempty"`
+	UserID                 string     `json:"user_id"`
+	Amount                 int64      `json:"amount"` // kobo
+	Fee                    int64      `json:"fee"`    // kobo
+	Currency               string     `json:"currency"`
+	Reference              string     `json:"reference"` // Our transfer reference, sent to Paystack
+	RecipientCode          *string    `json:"recipient_code,omitempty"`
+	PayoutAccountID        *string    `json:"payout_account_id,omitempty"`
+	HoldID                 *int64     `json:"hold_id,omitempty"`
+	TransferCode           *string    `json:"transfer_code,omitempty"`
+	PaystackTransferID     *int64     `json:"paystack_transfer_id,omitempty"`
+	PayoutBatchID          *string    `json:"payout_batch_id,omitempty"` // Set when sent in a bulk transfer
+	Status                 string     `json:"status"`
+	FailureReason          *string    `json:"failure_reason,omitempty"`
+	DebitJournalEntryID    *int64     `json:"debit_journal_entry_id,omitempty"`
+	ReversalJournalEntryID *int64     `json:"reversal_journal_entry_id,omitempty"`
+	FeeJournalEntryID      *int64     `json:"fee_journal_entry_id,omitempty"` // Moves the fee to platform revenue when the transfer succeeds
+	ApprovalReason         *string    `json:"approval_reason,omitempty"` // Why the withdrawal needs approval
+	ApprovedBy             *string    `json:"approved_by,omitempty"`
+	ApprovedAt             *time.Time `json:"approved_at,omitempty"`
+	RejectedBy             *string    `json:"rejected_by,omitempty"`
+	RejectedAt             *time.Time `json:"rejected_at,omitempty"`
+	TransferVerifiedAt     *time.Time `json:"transfer_verified_at,omitempty"`  // Last checked with Paystack by the withdrawal sweeper
+	PaidAfterFailureAt     *time.Time `json:"paid_after_failure_at,omitempty"` // Set when Paystack paid out the transfer after the withdrawal failed
+	PaidAfterFailureCode   *string    `json:"paid_after_failure_transfer_code,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
+	ProcessingAt           *time.Time `json:"processing_at,omitempty"`
+	CompletedAt            *time.Time `json:"completed_at,omitempty"`
+	FailedAt               *time.Time `json:"failed_at,omitempty"`
+	ReversedAt             *time.Time `json:"reversed_at,omitempty"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+// NetAmount is what is transferred to the user's bank: the amount minus the fee.
+func (w *Withdrawal) NetAmount() int64 {
+	return w.Amount - w.Fee
+}
+
+// WithdrawalQuote is the fee and net payout for a withdrawal amount, with the limits
+// that apply to the user. Amounts are in kobo; a zero limit means no limit.
+type WithdrawalQuote struct {
+	Amount         int64  `json:"amount"`     // Debited from the wallet
+	Fee            int64  `json:"fee"`        // Kept by the platform
+	NetAmount      int64  `json:"net_amount"` // Transferred to the bank
+	Currency       string `json:"currency"`
+	FeeMode        string `json:"fee_mode"`
+	MinAmount      int64  `json:"min_amount"`
+	MaxAmount      int64  `json:"max_amount"`
+	DailyLimit     int64  `json:"daily_limit"`
+	WithdrawnToday int64  `json:"withdrawn_today"`
+}
+
+// WithdrawalStatusEvent matches the 'withdrawal_status_events' table: one status change
+// of a withdrawal.
+type WithdrawalStatusEvent struct {
+	ID            int64     `json:"id"`
+	WithdrawalID  string    `json:"withdrawal_id"`
+	FromStatus    *string   `json:"from_status,omitempty"` // Empty for the status the withdrawal was created in
+	ToStatus      string    `json:"to_status"`
+	FailureReason *string   `json:"failure_reason,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// WithdrawalDetail is a withdrawal with its status history, oldest first.
+type WithdrawalDetail struct {
+	Withdrawal
+	History []WithdrawalStatusEvent `json:"history"`
+}
+
+// Payout batch statuses.
+const (
+	PayoutBatchSending     = "sending"     // Claimed; bulk transfer request not answered yet
+	PayoutBatchSent        = "sent"        // Paystack accepted the bulk transfer
+	PayoutBatchRejected    = "rejected"    // Paystack rejected the bulk transfer; its withdrawals failed
+	PayoutBatchUnconfirmed = "unconfirmed" // Paystack could not be reached or failed with a server error; webhooks settle its withdrawals
+)
+
+// PayoutBatch matches the 'payout_batches' table: withdrawals sent to Paystack in one
+// bulk transfer request.
+type PayoutBatch struct {
+	ID               string      `json:"id"`
+	Currency         string      `json:"currency"`
+	Status           string      `json:"status"`
+	ItemCount        int         `json:"item_count"`
+	TotalAmount      int64       `json:"total_amount"` // kobo sent, after fees
+	FeeTotal         int64       `json:"fee_total"`    // kobo kept as withdrawal fees
+	FailureReason    *string     `json:"failure_reason,omitempty"`
+	PaystackResponse interface{} `json:"paystack_response,omitempty"` // Paystack's per-transfer results
+	CreatedAt        time.Time   `json:"created_at"`
+	SentAt           *time.Time  `json:"sent_at,omitempty"`
+	UpdatedAt        time.Time   `json:"updated_at"`
+}
+
+// PayoutBatchDetail is a payout batch with its withdrawals.
+type PayoutBatchDetail struct {
+	PayoutBatch
+	Withdrawals []Withdrawal `json:"withdrawals"`
+}
+
+// PaystackBulkTransferResult is one transfer in Paystack's bulk transfer response.
+type PaystackBulkTransferResult struct {
+	Reference    string `json:"reference"`
+	Recipient    string `json:"recipient"`
+	Amount       int64  `json:"amount"` // kobo
+	TransferCode string `json:"transfer_code"`
+	Currency     string `json:"currency"`
+	Status       string `json:"status"`
+}
+
+// WithdrawalReviewFlag matches the 'withdrawal_review_flags' table: a user whose
+// withdrawals always need admin approval.
+type WithdrawalReviewFlag struct {
+	UserID    string    `json:"user_id"`
+	Reason    string    `json:"reason"`
+	FlaggedBy *string   `json:"flagged_by,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WithdrawalReviewFlagRequest is the body of the admin endpoint that flags a user.
+type WithdrawalReviewFlagRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// TransferOTPRequest is the body of the admin endpoint that finalizes a transfer.
+type TransferOTPRequest struct {
+	OTP string `json:"otp" binding:"required"`
+}
+
+// WithdrawalRejectionRequest is the body of the admin endpoint that rejects a withdrawal.
+type WithdrawalRejectionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// PaystackTransferData is the 'data' object of Paystack transfer.* webhook events.
+type PaystackTransferData struct {
+	ID           int64       `json:"id"`
+	Amount       int64       `json:"amount"` // in kobo
+	Currency     string      `json:"currency"`
+	Reference    string      `json:"reference"`
+	TransferCode string      `json:"transfer_code"`
+	Status       string      `json:"status"`
+	Reason       string      `json:"reason"`
+	Failures     interface{} `json:"failures"`
+}
+
+// Refund statuses.
+const (
+	RefundPending    = "pending"    // Recorded and clawed back; Paystack not yet confirmed to have accepted it
+	RefundProcessing = "processing" // Accepted by Paystack
+	RefundProcessed  = "processed"
+	RefundFailed     = "failed" // Clawback and any collected debt returned to the wallet
+)
+
+// Refund matches the 'refunds' table: a full or partial refund of a datacredit
+// purchase through Paystack. The refunded amount is clawed back from the wallet;
+// whatever the user had already spent is recorded as a WalletDebt.
+type Refund struct {
+	ID                     string     `json:"id"`
+	PaymentIntentID        string     `json:"payment_intent_id"`
+	UserID                 string     `json:"user_id"`
+	Reference              string     `json:"reference"` // Paystack transaction reference of the refunded payment
+	Amount                 int64      `json:"amount"`    // kobo
+	Currency               string     `json:"currency"`
+	ClawbackAmount         int64      `json:"clawback_amount"` // Debited from the wallet
+	DebtAmount             int64      `json:"debt_amount"`     // Recorded as a wallet debt
+	Status                 string     `json:"status"`
+	Reason                 *string    `json:"reason,omitempty"`
+	RequestedBy            *string    `json:"requested_by,omitempty"`
+	PaystackRefundID       *int64     `json:"paystack_refund_id,omitempty"`
+	FailureReason          *string    `json:"failure_reason,omitempty"`
+	ClawbackJournalEntryID *int64     `json:"clawback_journal_entry_id,omitempty"`
+	ReversalJournalEntryID *int64     `json:"reversal_journal_entry_id,omitempty"`
+	CreatedAt              time.Time  `json:"created_at"`
+	ProcessedAt            *time.Time `json:"processed_at,omitempty"`
+	FailedAt               *time.Time `json:"failed_at,omitempty"`
+	UpdatedAt              time.Time  `json:"updated_at"`
+}
+
+// WalletDebt matches the 'wallet_debts' table: refunded or charged-back datacredit a
+// user had already spent, collected from their next purchases.
+type WalletDebt struct {
+	ID          int64      `json:"id"`
+	UserID      string     `json:"user_id"`
+	RefundID    *string    `json:"refund_id,omitempty"`
+	DisputeID   *string    `json:"dispute_id,omitempty"`
+	Amount      int64      `json:"amount"`      // kobo
+	Outstanding int64      `json:"outstanding"` // kobo still owed
+	Status      string     `json:"status"`      // open, settled or cancelled
+	CreatedAt   time.Time  `json:"created_at"`
+	SettledAt   *time.Time `json:"settled_at,omitempty"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+// RefundRequest is the body of the admin endpoint that issues a refund.
+type RefundRequest struct {
+	Reference string `json:"reference" binding:"required"`              // Paystack transaction reference
+	Amount    *int64 `json:"amount,omitempty" binding:"omitempty,gt=0"` // kobo; everything not yet refunded if omitted
+	Reason    string `json:"reason" binding:"required"`
+}
+
+// PaystackRefundData is the 'data' object of Paystack refund.* webhook events and of
+// the refund API's response. Paystack sends id and amount as strings in some payloads.
+type PaystackRefundData struct {
+	ID                   json.Number `json:"id"`
+	TransactionReference string      `json:"transaction_reference"`
+	Amount               json.Number `json:"amount"` // in kobo
+	Currency             string      `json:"currency"`
+	Status               string      `json:"status"`
+}
+
+// Dispute statuses.
+const (
+	DisputeOpen              = "open"
+	DisputeEvidenceSubmitted = "evidence_submitted"
+	DisputeWon               = "won"  // Resolved in our favour; the hold was released
+	DisputeLost              = "lost" // Resolved in the cardholder's favour; the amount was debited
+)
+
+// Dispute matches the 'disputes' table: a cardholder's dispute (chargeback) of a
+// datacredit purchase. While it is open the disputed amount is held on the wallet.
+type Dispute struct {
+	ID                  string     `json:"id"`
+	PaystackDisputeID   int64      `json:"paystack_dispute_id"`
+	PaymentIntentID     *string    `json:"payment_intent_id,omitempty"`
+	UserID              *string    `json:"user_id,omitempty"`
+	Reference           string     `json:"reference"` // Paystack transaction reference of the disputed payment
+	Amount              int64      `json:"amount"`    // kobo
+	Currency            string     `json:"currency"`
+	Category            *string    `json:"category,omitempty"`
+	Status              string     `json:"status"`
+	PaystackStatus      *string    `json:"paystack_status,omitempty"`
+	Resolution          *string    `json:"resolution,omitempty"`
+	HoldID              *int64     `json:"hold_id,omitempty"`
+	HeldAmount          int64      `json:"held_amount"`    // kobo frozen in the wallet
+	DebitedAmount       int64      `json:"debited_amount"` // kobo debited when the dispute was lost
+	DebtAmount          int64      `json:"debt_amount"`    // kobo recorded as a wallet debt when it was lost
+	DebitJournalEntryID *int64     `json:"debit_journal_entry_id,omitempty"`
+	DueAt               *time.Time `json:"due_at,omitempty"` // Deadline for evidence or acceptance
+	LastRemindedAt      *time.Time `json:"last_reminded_at,omitempty"`
+	EvidenceSubmittedBy *string    `json:"evidence_submitted_by,omitempty"`
+	EvidenceSubmittedAt *time.Time `json:"evidence_submitted_at,omitempty"`
+	AcceptedBy          *string    `json:"accepted_by,omitempty"`
+	ResolvedAt          *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// PaystackDisputeData is the 'data' object of Paystack charge.dispute.* webhook events.
+type PaystackDisputeData struct {
+	ID           int64      `json:"id"`
+	RefundAmount int64      `json:"refund_amount"` // in kobo
+	Currency     string     `json:"currency"`
+	Status       string     `json:"status"`
+	Resolution   *string    `json:"resolution"` // merchant-accepted or declined, once resolved
+	Category     string     `json:"category"`
+	DueAt        *time.Time `json:"dueAt"`
+	Transaction  struct {
+		ID        int64  `json:"id"`
+		Reference string `json:"reference"`
+		Amount    int64  `json:"amount"` // in kobo
+	} `json:"transaction"`
+}
+
+// DisputeEvidenceRequest is the body of the admin endpoint that submits evidence for a
+// dispute to Paystack. Files are uploaded separately (see DisputeUploadURL).
+type DisputeEvidenceRequest struct {
+	CustomerEmail   string `json:"customer_email" binding:"required,email"`
+	CustomerName    string `json:"customer_name" binding:"required"`
+	CustomerPhone   string `json:"customer_phone" binding:"required"`
+	ServiceDetails  string `json:"service_details" binding:"required"` // What the datacredit was bought and used for
+	DeliveryAddress string `json:"delivery_address,omitempty"`
+	DeliveryDate    string `json:"delivery_date,omitempty"` // YYYY-MM-DD
+}
+
+// DisputeAcceptRequest is the body of the admin endpoint that accepts a dispute,
+// refunding the cardholder.
+type DisputeAcceptRequest struct {
+	Message          string `json:"message" binding:"required"`
+	RefundAmount     *int64 `json:"refund_amount,omitempty" binding:"omitempty,gt=0"` // kobo; the disputed amount if omitted
+	UploadedFilename string `json:"uploaded_filename" binding:"required"`             // Evidence file uploaded via DisputeUploadURL
+}
+
+// DisputeUploadURL is a signed URL to upload an evidence file for a dispute to.
+type DisputeUploadURL struct {
+	SignedURL string `json:"signedUrl"`
+	FileName  string `json:"fileName"`
+}
+
+// PaystackTransactionSummary is one transaction in Paystack's list transactions response.
+type PaystackTransactionSummary struct {
+	ID              int64  `json:"id"`
+	Status          string `json:"status"`
+	Reference       string `json:"reference"`
+	Amount          int64  `json:"amount"` // in kobo
+	Currency        string `json:"currency"`
+	GatewayResponse string `json:"gateway_response"`
+	CreatedAt       string `json:"created_at"`
+}
+
+// Kinds of discrepancy found by reconciliation.
+const (
+	DiscrepancyMissingCredit         = "missing_credit"           // Paystack charge succeeded but its intent was not credited
+	DiscrepancyAmountMismatch        = "amount_mismatch"          // Paystack and our records disagree on an amount
+	DiscrepancyUnknownPayment        = "unknown_payment"          // Successful Paystack charge with no payment intent
+	DiscrepancyOrphanedCredit        = "orphaned_credit"          // Intent credited without a successful Paystack charge
+	DiscrepancyMissingTransactionLog = "missing_transaction_log"  // Intent credited but no credit_purchase transaction logged
+	DiscrepancyUnsettledTransfer     = "unsettled_transfer"       // Paystack transfer settled but its withdrawal was not
+	DiscrepancyTransferMismatch      = "transfer_status_mismatch" // Paystack and the withdrawal disagree on a final status
+	DiscrepancyUnknownTransfer       = "unknown_transfer"         // Paystack transfer with no withdrawal
+	DiscrepancyOrphanedWithdrawal    = "orphaned_withdrawal"      // Withdrawal with no Paystack transfer
+)
+
+// ReconciliationDiscrepancy is one difference between Paystack and our records.
+// Fixable discrepancies can be repaired by replaying the Paystack event they missed.
+type ReconciliationDiscrepancy struct {
+	Kind           string `json:"kind"`
+	Reference      string `json:"reference"`
+	PaystackStatus string `json:"paystack_status,omitempty"`
+	PaystackAmount *int64 `json:"paystack_amount,omitempty"` // kobo
+	RecordedStatus string `json:"recorded_status,omitempty"`
+	RecordedAmount *int64 `json:"recorded_amount,omitempty"` // kobo
+	Detail         string `json:"detail"`
+	Fixable        bool   `json:"fixable"`
+	Fixed          bool   `json:"fixed"`
+	FixError       string `json:"fix_error,omitempty"`
+}
+
+// ReconciliationReport is the result of reconciling Paystack with our records over a
+// date range.
+type ReconciliationReport struct {
+	From                 time.Time                   `json:"from"`
+	To                   time.Time                   `json:"to"`
+	GeneratedAt          time.Time                   `json:"generated_at"`
+	AutoFix              bool                        `json:"auto_fix"`
+	PaystackTransactions int                         `json:"paystack_transactions"` // Successful charges listed by Paystack
+	PaystackTransfers    int                         `json:"paystack_transfers"`
+	PaymentIntents       int                         `json:"payment_intents"`
+	Withdrawals          int                         `json:"withdrawals"`
+	Discrepancies        []ReconciliationDiscrepancy `json:"discrepancies"`
+	Fixed                int                         `json:"fixed"`
+}
+
+// Unresolved returns how many discrepancies were not fixed.
+func (r *ReconciliationReport) Unresolved() int {
+	return len(r.Discrepancies) - r.Fixed
+}
+
 // WithdrawalRequest now primarily concerns datacredit (NGN value).
 type WithdrawalRequest struct {
-	UserID string `json:"user_id" binding:"required"`
-	Amount int64  `json:"amount" binding:"required,gt=0"` // Amount of datacredit (kobo) to withdraw
-	// Paystack recipient details might be needed here or fetched from user's profile
-	// BankCode      string `json:"bank_code" binding:"required"`
-	// AccountNumber string `json:"account_number" binding:"required"`
+	UserID      string `json:"user_id" binding:"required"`
+	Amount      int64  `json:"amount" binding:"required,gt=0"`                  // Amount of datacredit (kobo) to withdraw
+	RecipientID string `json:"recipient_id,omitempty" binding:"omitempty,uuid"` // Payout account ID; the user's default payout account if empty
+}
+
+// PayoutAccount matches the 'payout_accounts' table: a bank account a user can
+// withdraw to, with the Paystack transfer recipient created for it.
+type PayoutAccount struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id"`
+	BankCode      string    `json:"bank_code"`
+	BankName      *string   `json:"bank_name,omitempty"`
+	AccountNumber string    `json:"account_number"`
+	AccountName   string    `json:"account_name"` // As resolved by Paystack
+	Currency      string    `json:"currency"`
+	RecipientCode string    `json:"recipient_code"`
+	IsDefault     bool      `json:"is_default"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Bank is a bank from Paystack's list-banks API. Code is the bank_code used when
+// adding a payout account.
+type Bank struct {
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	Slug     string `json:"slug"`
+	Code     string `json:"code"`
+	Longcode string `json:"longcode"`
+	Country  string `json:"country"`
+	Currency string `json:"currency"`
+	Type     string `json:"type"`
+	Active   bool   `json:"active"`
+}
+
+// BankList is a cached list of banks for a country and currency.
+type BankList struct {
+	Country   string    `json:"country"`
+	Currency  string    `json:"currency"`
+	Banks     []Bank    `json:"banks"`
+	FetchedAt time.Time `json:"fetched_at"` // When the list was fetched from Paystack
+}
+
+// AddPayoutAccountRequest is the body for adding a payout bank account.
+type AddPayoutAccountRequest struct {
+	BankCode      string `json:"bank_code" binding:"required"`
+	AccountNumber string `json:"account_number" binding:"required,numeric"`
+	Currency      string `json:"currency,omitempty"`   // Defaults to NGN, the only supported payout currency
+	IsDefault     bool   `json:"is_default,omitempty"` // The first account added is always the default
+}
+
+// DatabyteRateTier prices purchases of at least MinDatabytes databytes at PriceKobo kobo
+// per PerDatabytes databytes, so a databyte can cost a fraction of a kobo (1 per 100) or
+// several kobo (3 per 1).
+type DatabyteRateTier struct {
+	MinDatabytes int64 `json:"min_databytes" binding:"gt=0"`
+	PriceKobo    int64 `json:"price_kobo" binding:"gt=0"`
+	PerDatabytes int64 `json:"per_databytes" binding:"gt=0"`
+}
+
+// DatabyteRate matches the 'databyte_rates' table: one version of the datacredit to
+// databyte rate, in effect from EffectiveFrom until the next version takes effect. Tiers
+// are ascending by MinDatabytes and the first starts at 1.
+type DatabyteRate struct {
+	ID            int64              `json:"id"` // The rate version, recorded on each purchase
+	EffectiveFrom time.Time          `json:"effective_from"`
+	Tiers         []DatabyteRateTier `json:"tiers"`
+	Note          *string            `json:"note,omitempty"`
+	CreatedBy     *string            `json:"created_by,omitempty"`
+	CancelledBy   *string            `json:"cancelled_by,omitempty"`
+	CancelledAt   *time.Time         `json:"cancelled_at,omitempty"`
+	CreatedAt     time.Time          `json:"created_at,omitempty"`
+}
+
+// TierFor returns the tier that prices a purchase of amount databytes: the one with the
+// highest MinDatabytes not above amount.
+func (r *DatabyteRate) TierFor(amount int64) DatabyteRateTier {
+	tier := r.Tiers[0]
+	for _, t := range r.Tiers[1:] {
+		if t.MinDatabytes <= amount {
+			tier = t
+		}
+	}
+	return tier
+}
+
+// DatabyteRateRequest schedules a new databyte rate version. A missing effective_from
+// makes it take effect immediately.
+type DatabyteRateRequest struct {
+	EffectiveFrom *time.Time         `json:"effective_from,omitempty"`
+	Tiers         []DatabyteRateTier `json:"tiers" binding:"required,min=1,dive"`
+	Note          string             `json:"note,omitempty"`
+}
+
+// DatabyteQuote is the price of a databyte purchase at the current rate.
+type DatabyteQuote struct {
+	DatabyteAmount int64 `json:"databyte_amount"`
+	CostKobo       int64 `json:"cost_kobo"`
+	PriceKobo      int64 `json:"price_kobo"`    // The tier's price...
+	PerDatabytes   int64 `json:"per_databytes"` // ...per this many databytes
+	RateVersion    int64 `json:"rate_version"`
 }
 
 // DatabytePurchaseRequest could be a model for users buying Databytes using their Datacredits.
+// ExpectedRateVersion and MaxCostKobo guard against the rate changing after a quote.
 type DatabytePurchaseRequest struct {
-	UserID         string `json:"user_id" binding:"required"`
-	DatabyteAmount int64  `json:"databyte_amount" binding:"required,gt=0"`
+	UserID              string `json:"user_id" binding:"required"`
+	DatabyteAmount      int64  `json:"databyte_amount" binding:"required,gt=0"`
+	ExpectedRateVersion *int64 `json:"expected_rate_version,omitempty" binding:"omitempty,gt=0"` // Refuse the purchase unless priced at this rate version
+	MaxCostKobo         *int64 `json:"max_cost_kobo,omitempty" binding:"omitempty,gt=0"`         // Refuse the purchase if it costs more
 }
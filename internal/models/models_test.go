@@ -0,0 +1,77 @@
+package models
+
+import "testing"
+
+func TestCanTransitionPaymentIntent(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{PaymentIntentInitialized, PaymentIntentPending, true},
+		{PaymentIntentInitialized, PaymentIntentSucceeded, true},
+		{PaymentIntentPending, PaymentIntentSucceeded, true},
+		{PaymentIntentPending, PaymentIntentInitialized, false},
+		{PaymentIntentAbandoned, PaymentIntentSucceeded, true},
+		{PaymentIntentExpired, PaymentIntentSucceeded, true},
+		{PaymentIntentAbandoned, PaymentIntentPending, false},
+		{PaymentIntentFailed, PaymentIntentSucceeded, false},
+		{PaymentIntentFailed, PaymentIntentUnderReview, true},
+		{PaymentIntentUnderReview, PaymentIntentSucceeded, true},
+		{PaymentIntentUnderReview, PaymentIntentFailed, true},
+		{PaymentIntentUnderReview, PaymentIntentPending, false},
+		{PaymentIntentSucceeded, PaymentIntentFailed, false},
+		{PaymentIntentSucceeded, PaymentIntentUnderReview, false},
+		{PaymentIntentPending, PaymentIntentPending, false},
+		{"unknown", PaymentIntentSucceeded, false},
+	}
+	for _, tt := range tests {
+		if got := CanTransitionPaymentIntent(tt.from, tt.to); got != tt.want {
+			t.Errorf("CanTransitionPaymentIntent(%q, %q) = %t, want %t", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestWithdrawalNetAmount(t *testing.T) {
+	tests := []struct {
+		amount, fee, want int64
+	}{
+		{100000, 0, 100000},
+		{100000, 1000, 99000},
+		{1001, 1000, 1},
+	}
+	for _, tt := range tests {
+		w := Withdrawal{Amount: tt.amount, Fee: tt.fee}
+		if got := w.NetAmount(); got != tt.want {
+			t.Errorf("Withdrawal{Amount: %d, Fee: %d}.NetAmount() = %d, want %d", tt.amount, tt.fee, got, tt.want)
+		}
+	}
+}
+
+func TestDatabyteRateTierFor(t *testing.T) {
+	rate := DatabyteRate{Tiers: []DatabyteRateTier{
+		{MinDatabytes: 1, PriceKobo: 1, PerDatabytes: 100},
+		{MinDatabytes: 1000, PriceKobo: 1, PerDatabytes: 120},
+		{MinDatabytes: 100000, PriceKobo: 1, PerDatabytes: 150},
+	}}
+	tests := []struct {
+		amount  int64
+		wantMin int64
+	}{
+		{1, 1},
+		{999, 1},
+		{1000, 1000},
+		{99999, 1000},
+		{100000, 100000},
+		{10000000, 100000},
+	}
+	for _, tt := range tests {
+		if got := rate.TierFor(tt.amount); got.MinDatabytes != tt.wantMin {
+			t.Errorf("TierFor(%d) = tier from %d, want tier from %d", tt.amount, got.MinDatabytes, tt.wantMin)
+		}
+	}
+
+	single := DatabyteRate{Tiers: []DatabyteRateTier{{MinDatabytes: 1, PriceKobo: 3, PerDatabytes: 1}}}
+	if got := single.TierFor(5); got.PriceKobo != 3 {
+		t.Errorf("TierFor(5) on a single tier = %+v, want the only tier", got)
+	}
+}
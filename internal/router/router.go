@@ -2,6 +2,8 @@
 package router
 
 import (
+	"expvar"
+
 	// Ensure your module name is correct in the import path
 	"github.com/tedobanks/datagram_payment_processor/internal/handlers"
 	"github.com/tedobanks/datagram_payment_processor/internal/middleware" // We'll create a placeholder for this
@@ -16,8 +18,8 @@ import (
 // It takes the initialized handlers as dependencies.
 func SetupRouter(
 	paymentHandler *handlers.PaymentHandler,
+	userHandler *handlers.UserHandler,
 	// Add other handlers here if you create them, e.g.:
-	// userHandler *handlers.UserHandler,
 	// databyteHandler *handlers.DatabyteHandler, // If you separated databyte logic
 ) *gin.Engine {
 
@@ -45,6 +47,10 @@ func SetupRouter(
 		c.JSON(200, gin.H{"status": "UP", "message": "Datagram Payment Processor is running!"})
 	})
 
+	// Runtime and ledger check metrics (expvar), for monitoring to scrape with an admin token
+	// GET /debug/vars
+	router.GET("/debug/vars", middleware.AuthMiddleware(), middleware.AdminMiddleware(), gin.WrapH(expvar.Handler()))
+
 	// API v1 group
 	// All routes within this group will be prefixed with /api/v1
 	apiV1 := router.Group("/api/v1")
@@ -86,6 +92,24 @@ func SetupRouter(
 			// @Failure     403 {object} handlers.ErrorResponse
 			// @Router      /payments/withdraw [post]
 			paymentRoutes.POST("/withdraw", middleware.AuthMiddleware(), paymentHandler.HandleWithdrawal) // Added AuthMiddleware
+
+			// Fee and net payout for a withdrawal amount, before confirming it
+			// GET /api/v1/payments/withdrawals/quote?amount=500000
+			paymentRoutes.GET("/withdrawals/quote", middleware.AuthMiddleware(), paymentHandler.QuoteWithdrawal)
+
+			// Withdrawal history and status for the authenticated user
+			// GET /api/v1/payments/withdrawals
+			// GET /api/v1/payments/withdrawals/:id
+			paymentRoutes.GET("/withdrawals", middleware.AuthMiddleware(), paymentHandler.ListWithdrawals)
+			paymentRoutes.GET("/withdrawals/:id", middleware.AuthMiddleware(), paymentHandler.GetWithdrawal)
+
+			// Poll the status of a payment intent by its Paystack reference
+			// GET /api/v1/payments/intents/:reference
+			paymentRoutes.GET("/intents/:reference", middleware.AuthMiddleware(), paymentHandler.GetPaymentIntent)
+
+			// Verify a payment with Paystack when the redirect beats the webhook
+			// GET /api/v1/payments/verify/:reference
+			paymentRoutes.GET("/verify/:reference", middleware.AuthMiddleware(), paymentHandler.VerifyPayment)
 		}
 
 		// Databyte related routes
@@ -108,6 +132,117 @@ func SetupRouter(
 			// @Failure     403 {object} handlers.ErrorResponse
 			// @Router      /databytes/purchase [post]
 			databyteRoutes.POST("/purchase", middleware.AuthMiddleware(), paymentHandler.PurchaseDatabytes) // Added AuthMiddleware
+
+			// Cost of a databyte purchase at the current rate, before purchasing
+			// GET /api/v1/databytes/quote?amount=50000
+			databyteRoutes.GET("/quote", middleware.AuthMiddleware(), paymentHandler.QuoteDatabytes)
+		}
+
+		// Wallet transaction history of the authenticated user, newest first
+		// GET /api/v1/transactions?cursor=...&limit=20&operation=credit_purchase&currency=datacredit&from=2026-10-01&to=2026-10-18&reference=...
+		apiV1.GET("/transactions", middleware.AuthMiddleware(), paymentHandler.ListTransactions)
+
+		// Statement export of the authenticated user's transactions
+		// GET /api/v1/transactions/statement?format=csv|jsonl|ofx&from=2026-09-01&to=2026-09-30
+		apiV1.GET("/transactions/statement", middleware.AuthMiddleware(), paymentHandler.ExportStatement)
+
+		// Banks and bank codes for payout account setup, cached from Paystack
+		// GET /api/v1/banks?country=nigeria&currency=NGN
+		apiV1.GET("/banks", middleware.AuthMiddleware(), paymentHandler.ListBanks)
+
+		// Payout bank account routes
+		payoutAccountRoutes := apiV1.Group("/payout-accounts")
+		{
+			// Add a payout bank account (resolves the name and creates a Paystack transfer recipient)
+			// POST /api/v1/payout-accounts
+			payoutAccountRoutes.POST("", middleware.AuthMiddleware(), paymentHandler.AddPayoutAccount)
+
+			// List the user's payout bank accounts
+			// GET /api/v1/payout-accounts
+			payoutAccountRoutes.GET("", middleware.AuthMiddleware(), paymentHandler.ListPayoutAccounts)
+
+			// Make a payout bank account the default for withdrawals
+			// PUT /api/v1/payout-accounts/:id/default
+			payoutAccountRoutes.PUT("/:id/default", middleware.AuthMiddleware(), paymentHandler.SetDefaultPayoutAccount)
+
+			// Remove a payout bank account
+			// DELETE /api/v1/payout-accounts/:id
+			payoutAccountRoutes.DELETE("/:id", middleware.AuthMiddleware(), paymentHandler.RemovePayoutAccount)
+		}
+
+		// Admin routes. AdminMiddleware requires the 'admin' role in the user's app_metadata.
+		adminRoutes := apiV1.Group("/admin")
+		adminRoutes.Use(middleware.AuthMiddleware(), middleware.AdminMiddleware())
+		{
+			// Withdrawals awaiting approval (maker-checker)
+			// GET /api/v1/admin/withdrawals/approvals
+			adminRoutes.GET("/withdrawals/approvals", paymentHandler.ListWithdrawalApprovals)
+
+			// Approve a withdrawal (starts its transfer) or reject it (releases its hold)
+			// POST /api/v1/admin/withdrawals/:id/approve
+			// POST /api/v1/admin/withdrawals/:id/reject
+			adminRoutes.POST("/withdrawals/:id/approve", paymentHandler.ApproveWithdrawal)
+			adminRoutes.POST("/withdrawals/:id/reject", paymentHandler.RejectWithdrawal)
+
+			// Finalize a withdrawal's transfer with its Paystack OTP, or have the OTP resent
+			// POST /api/v1/admin/withdrawals/:id/finalize
+			// POST /api/v1/admin/withdrawals/:id/resend-otp
+			adminRoutes.POST("/withdrawals/:id/finalize", paymentHandler.FinalizeWithdrawalTransfer)
+			adminRoutes.POST("/withdrawals/:id/resend-otp", paymentHandler.ResendWithdrawalOTP)
+
+			// Bulk transfer batches sent in payout-batching mode
+			// GET /api/v1/admin/payout-batches
+			// GET /api/v1/admin/payout-batches/:id
+			adminRoutes.GET("/payout-batches", paymentHandler.ListPayoutBatches)
+			adminRoutes.GET("/payout-batches/:id", paymentHandler.GetPayoutBatch)
+
+			// Charges that did not match their payment intent, credited or dismissed by an admin
+			// GET /api/v1/admin/payment-reviews?status=open
+			// POST /api/v1/admin/payment-reviews/:id/resolve
+			adminRoutes.GET("/payment-reviews", paymentHandler.ListPaymentReviews)
+			adminRoutes.POST("/payment-reviews/:id/resolve", paymentHandler.ResolvePaymentReview)
+
+			// Refunds of datacredit purchases through Paystack
+			// POST /api/v1/admin/refunds
+			// GET /api/v1/admin/refunds
+			// GET /api/v1/admin/refunds/:id
+			adminRoutes.POST("/refunds", paymentHandler.IssueRefund)
+			adminRoutes.GET("/refunds", paymentHandler.ListRefunds)
+			adminRoutes.GET("/refunds/:id", paymentHandler.GetRefund)
+
+			// Chargebacks raised by cardholders, answered with evidence or accepted
+			// GET /api/v1/admin/disputes
+			// GET /api/v1/admin/disputes/:id
+			// GET /api/v1/admin/disputes/:id/upload-url
+			// POST /api/v1/admin/disputes/:id/evidence
+			// POST /api/v1/admin/disputes/:id/accept
+			adminRoutes.GET("/disputes", paymentHandler.ListDisputes)
+			adminRoutes.GET("/disputes/:id", paymentHandler.GetDispute)
+			adminRoutes.GET("/disputes/:id/upload-url", paymentHandler.GetDisputeUploadURL)
+			adminRoutes.POST("/disputes/:id/evidence", paymentHandler.SubmitDisputeEvidence)
+			adminRoutes.POST("/disputes/:id/accept", paymentHandler.AcceptDispute)
+
+			// Versioned, volume-tiered databyte rates, scheduled ahead and cancellable until they take effect
+			// POST /api/v1/admin/databyte-rates
+			// GET /api/v1/admin/databyte-rates
+			// DELETE /api/v1/admin/databyte-rates/:id
+			adminRoutes.POST("/databyte-rates", paymentHandler.ScheduleDatabyteRate)
+			adminRoutes.GET("/databyte-rates", paymentHandler.ListDatabyteRates)
+			adminRoutes.DELETE("/databyte-rates/:id", paymentHandler.CancelDatabyteRate)
+
+			// Recompute wallet balances and check the ledger invariants
+			// GET /api/v1/admin/ledger/check
+			adminRoutes.GET("/ledger/check", paymentHandler.CheckLedger)
+
+			// A user's datacredit and databyte balance at a moment, rebuilt from the transaction log
+			// GET /api/v1/admin/users/:userId/balance?as_of=2026-09-30T23:59:59Z
+			adminRoutes.GET("/users/:userId/balance", paymentHandler.GetUserBalanceAsOf)
+
+			// Flag or unflag a user so all of their withdrawals need approval
+			// PUT /api/v1/admin/users/:userId/withdrawal-review
+			// DELETE /api/v1/admin/users/:userId/withdrawal-review
+			adminRoutes.PUT("/users/:userId/withdrawal-review", paymentHandler.FlagUserForWithdrawalReview)
+			adminRoutes.DELETE("/users/:userId/withdrawal-review", paymentHandler.UnflagUserForWithdrawalReview)
 		}
 
 		// Webhook routes do NOT typically have authentication middleware,
@@ -131,14 +266,19 @@ func SetupRouter(
 			webhookRoutes.POST("/paystack", paymentHandler.PaystackWebhook)
 		}
 
-		// Example: User profile routes (if you add user handlers)
-		// profileRoutes := apiV1.Group("/profile")
-		// profileRoutes.Use(middleware.AuthMiddleware())
-		// {
-		// 	profileRoutes.GET("", userHandler.GetMyProfile)
-		// 	profileRoutes.PUT("", userHandler.UpdateMyProfile)
-		//  profileRoutes.GET("/wallet", userHandler.GetMyWalletBalance)
-		// }
+		// Profile and wallet of the authenticated user
+		profileRoutes := apiV1.Group("/profile")
+		profileRoutes.Use(middleware.AuthMiddleware())
+		{
+			// GET /api/v1/profile
+			// PUT /api/v1/profile
+			profileRoutes.GET("", userHandler.GetMyProfile)
+			profileRoutes.PUT("", userHandler.UpdateMyProfile)
+
+			// Datacredit (total, held and available) and databyte balances
+			// GET /api/v1/profile/wallet
+			profileRoutes.GET("/wallet", userHandler.GetMyWalletBalance)
+		}
 	}
 
 	// Fallback for unmatched routes (optional)
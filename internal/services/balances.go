@@ -0,0 +1,58 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// GetBalanceAsOf reconstructs the user's datacredit and databyte balances at a moment
+// from the transaction log, including every transaction up to and including asOf. It
+// returns ErrProfileNotFound for an unknown user.
+func (s *SupabaseService) GetBalanceAsOf(userID string, asOf time.Time) (*models.BalanceAsOf, error) {
+	if _, err := s.GetUserProfile(userID); err != nil {
+		return nil, err
+	}
+
+	params := map[string]interface{}{
+		"p_user_id": userID,
+		"p_as_of":   asOf.UTC().Format(time.RFC3339Nano),
+	}
+
+	var rows []struct {
+		Currency         string              `json:"currency"`
+		SummedBalance    int64               `json:"summed_balance"`
+		TransactionCount int64               `json:"transaction_count"`
+		LastTransaction  *models.Transaction `json:"last_transaction"`
+	}
+	if err := s.callRPC("transaction_balances_as_of", params, &rows); err != nil {
+		return nil, fmt.Errorf("error reconstructing balances of user %s as of %s: %w", userID, asOf.Format(time.RFC3339), err)
+	}
+
+	result := &models.BalanceAsOf{UserID: userID, AsOf: asOf}
+	for _, row := range rows {
+		balance := models.PointInTimeBalance{
+			Currency:         row.Currency,
+			Balance:          row.SummedBalance,
+			Source:           models.BalanceSourceTransactionSum,
+			SummedBalance:    row.SummedBalance,
+			Consistent:       true,
+			TransactionCount: row.TransactionCount,
+			LastTransaction:  row.LastTransaction,
+		}
+		if row.LastTransaction != nil && row.LastTransaction.BalanceAfter != nil {
+			balance.Balance = *row.LastTransaction.BalanceAfter
+			balance.Source = models.BalanceSourceBalanceAfter
+			balance.Consistent = balance.Balance == row.SummedBalance
+		}
+
+		switch row.Currency {
+		case models.CurrencyDatacredit:
+			result.Datacredit = balance
+		case models.CurrencyDatabyte:
+			result.Databyte = balance
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// bankListPageSize is the largest page Paystack's list-banks API returns.
+const bankListPageSize = 100
+
+// bankCache holds Paystack bank lists in memory, keyed by country and currency.
+type bankCache struct {
+	mu      sync.Mutex
+	entries map[string]*models.BankList
+}
+
+// ListBanks returns the banks Paystack supports for a country and currency. Lists are
+// cached for Cfg.BankListCacheTTL; refresh forces a new fetch. If Paystack cannot be
+// reached, a previously cached list is returned even if it has expired.
+func (s *PaystackService) ListBanks(country, currency string, refresh bool) (*models.BankList, error) {
+	country = strings.ToLower(country)
+	currency = strings.ToUpper(currency)
+	key := country + "|" + currency
+
+	s.banks.mu.Lock()
+	cached := s.banks.entries[key]
+	s.banks.mu.Unlock()
+
+	if cached != nil && !refresh && time.Since(cached.FetchedAt) < s.Cfg.BankListCacheTTL {
+		return cached, nil
+	}
+
+	banks, err := s.fetchBanks(country, currency)
+	if err != nil {
+		if cached != nil {
+			log.Printf("WARNING: Serving bank list for %s/%s cached at %s: %v", country, currency, cached.FetchedAt.Format(time.RFC3339), err)
+			return cached, nil
+		}
+		return nil, err
+	}
+
+	list := &models.BankList{Country: country, Currency: currency, Banks: banks, FetchedAt: time.Now()}
+	s.banks.mu.Lock()
+	if s.banks.entries == nil {
+		s.banks.entries = map[string]*models.BankList{}
+	}
+	s.banks.entries[key] = list
+	s.banks.mu.Unlock()
+
+	log.Printf("INFO: Fetched %d bank(s) for %s/%s from Paystack", len(banks), country, currency)
+	return list, nil
+}
+
+// fetchBanks pages through Paystack's list-banks API. paystack.BankService.List takes no
+// filters, so the request is sent directly.
+func (s *PaystackService) fetchBanks(country, currency string) ([]models.Bank, error) {
+	var banks []models.Bank
+	next := ""
+	for {
+		query := url.Values{}
+		query.Set("country", country)
+		query.Set("currency", currency)
+		query.Set("perPage", fmt.Sprint(bankListPageSize))
+		query.Set("use_cursor", "true")
+		if next != "" {
+			query.Set("next", next)
+		}
+
+		var page struct {
+			Data []models.Bank `json:"data"`
+			Meta struct {
+				Next string `json:"next"`
+			} `json:"meta"`
+		}
+		if err := s.Client.Call("GET", "/bank?"+query.Encode(), nil, &page); err != nil {
+			return nil, fmt.Errorf("failed to list Paystack banks for %s/%s: %w", country, currency, err)
+		}
+		banks = append(banks, page.Data...)
+
+		if page.Meta.Next == "" || page.Meta.Next == next || len(page.Data) == 0 {
+			return banks, nil
+		}
+		next = page.Meta.Next
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// GetDatabyteRate returns the databyte rate version in effect at a moment. It returns
+// ErrNoDatabyteRate when none is.
+func (s *SupabaseService) GetDatabyteRate(at time.Time) (*models.DatabyteRate, error) {
+	var rates []models.DatabyteRate
+	_, err := s.Client.From("databyte_rates").
+		Select("*", "", false).
+		Lte("effective_from", at.UTC().Format(time.RFC3339Nano)).
+		Is("cancelled_at", "null").
+		Order("effective_from", &postgrest.OrderOpts{Ascending: false}).
+		Limit(1, "").
+		ExecuteTo(&rates)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching databyte rate at %s: %w", at.Format(time.RFC3339), err)
+	}
+	if len(rates) == 0 || len(rates[0].Tiers) == 0 {
+		return nil, fmt.Errorf("%w at %s", ErrNoDatabyteRate, at.Format(time.RFC3339))
+	}
+	return &rates[0], nil
+}
+
+// QuoteDatabytes prices a purchase of databyteAmount databytes at the current rate.
+func (s *SupabaseService) QuoteDatabytes(databyteAmount int64) (*models.DatabyteQuote, error) {
+	rate, err := s.GetDatabyteRate(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	tier := rate.TierFor(databyteAmount)
+	cost, err := databyteCost(databyteAmount, tier)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DatabyteQuote{
+		DatabyteAmount: databyteAmount,
+		CostKobo:       cost,
+		PriceKobo:      tier.PriceKobo,
+		PerDatabytes:   tier.PerDatabytes,
+		RateVersion:    rate.ID,
+	}, nil
+}
+
+// databyteCost is the datacredit (kobo) charged for databyteAmount databytes at a tier,
+// rounded up so fractions of a kobo are never given away, and at least 1 kobo. It
+// returns ErrDatabyteAmountTooBig when the cost does not fit in an int64.
+func databyteCost(databyteAmount int64, tier models.DatabyteRateTier) (int64, error) {
+	cost, ok := mulDivCeil(databyteAmount, tier.PriceKobo, tier.PerDatabytes)
+	if !ok {
+		return 0, fmt.Errorf("%w: %d databytes at %d kobo per %d", ErrDatabyteAmountTooBig, databyteAmount, tier.PriceKobo, tier.PerDatabytes)
+	}
+	if cost <= 0 {
+		cost = 1
+	}
+	return cost, nil
+}
+
+// mulDivCeil returns a*b/c rounded up, computed without intermediate overflow, for
+// non-negative a and b and positive c. ok is false when the result does not fit in an
+// int64.
+func mulDivCeil(a, b, c int64) (result int64, ok bool) {
+	hi, lo := bits.Mul64(uint64(a), uint64(b))
+	if hi >= uint64(c) {
+		return 0, false
+	}
+	quo, rem := bits.Div64(hi, lo, uint64(c))
+	if rem > 0 {
+		quo++
+	}
+	if quo > math.MaxInt64 {
+		return 0, false
+	}
+	return int64(quo), true
+}
+
+// ScheduleDatabyteRate records a new databyte rate version taking effect at
+// req.EffectiveFrom, or immediately when it is nil. It returns ErrInvalidDatabyteRate
+// for tiers that do not start at 1 or repeat a minimum, for a time in the past, or when
+// another version is already scheduled for the same moment.
+func (s *SupabaseService) ScheduleDatabyteRate(req models.DatabyteRateRequest, adminID string) (*models.DatabyteRate, error) {
+	now := time.Now()
+	effectiveFrom := now
+	if req.EffectiveFrom != nil {
+		if req.EffectiveFrom.Before(now) {
+			return nil, fmt.Errorf("%w: effective_from must not be in the past", ErrInvalidDatabyteRate)
+		}
+		effectiveFrom = *req.EffectiveFrom
+	}
+
+	tiers := append([]models.DatabyteRateTier(nil), req.Tiers...)
+	sort.Slice(tiers, func(i, j int) bool { return tiers[i].MinDatabytes < tiers[j].MinDatabytes })
+	if tiers[0].MinDatabytes != 1 {
+		return nil, fmt.Errorf("%w: the first tier must start at 1 databyte", ErrInvalidDatabyteRate)
+	}
+	for i := 1; i < len(tiers); i++ {
+		if tiers[i].MinDatabytes == tiers[i-1].MinDatabytes {
+			return nil, fmt.Errorf("%w: more than one tier starts at %d databytes", ErrInvalidDatabyteRate, tiers[i].MinDatabytes)
+		}
+	}
+
+	newRate := map[string]interface{}{
+		"effective_from": effectiveFrom.UTC(),
+		"tiers":          tiers,
+		"note":           nullIfEmpty(req.Note),
+		"created_by":     nullIfEmpty(adminID),
+	}
+
+	var created []models.DatabyteRate
+	_, err := s.Client.From("databyte_rates").
+		Insert(newRate, false, "", "", "").
+		ExecuteTo(&created)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, fmt.Errorf("%w: a rate is already scheduled for %s", ErrInvalidDatabyteRate, effectiveFrom.Format(time.RFC3339))
+		}
+		return nil, fmt.Errorf("error scheduling databyte rate: %w", err)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("no data returned after scheduling databyte rate")
+	}
+	return &created[0], nil
+}
+
+// ListDatabyteRates returns databyte rate versions, latest effective first, optionally
+// including cancelled ones.
+func (s *SupabaseService) ListDatabyteRates(includeCancelled bool, limit, offset int) ([]models.DatabyteRate, error) {
+	query := s.Client.From("databyte_rates").
+		Select("*", "", false)
+	if !includeCancelled {
+		query = query.Is("cancelled_at", "null")
+	}
+
+	var rates []models.DatabyteRate
+	_, err := query.
+		Order("effective_from", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&rates)
+	if err != nil {
+		return nil, fmt.Errorf("error listing databyte rates: %w", err)
+	}
+	return rates, nil
+}
+
+// CancelDatabyteRate cancels a scheduled databyte rate version before it takes effect.
+// It returns ErrDatabyteRateNotFound for an unknown or already cancelled version and
+// ErrDatabyteRateInEffect once the version has taken effect, since purchases may have
+// been priced at it.
+func (s *SupabaseService) CancelDatabyteRate(id int64, adminID string) (*models.DatabyteRate, error) {
+	now := time.Now()
+	updateData := map[string]interface{}{
+		"cancelled_at": now,
+		"cancelled_by": nullIfEmpty(adminID),
+	}
+
+	var rates []models.DatabyteRate
+	_, err := s.Client.From("databyte_rates").
+		Update(updateData, "", "").
+		Eq("id", fmt.Sprint(id)).
+		Is("cancelled_at", "null").
+		Gt("effective_from", now.UTC().Format(time.RFC3339Nano)).
+		ExecuteTo(&rates)
+	if err != nil {
+		return nil, fmt.Errorf("error cancelling databyte rate %d: %w", id, err)
+	}
+	if len(rates) > 0 {
+		return &rates[0], nil
+	}
+
+	// Nothing was cancelled: find out why.
+	_, err = s.Client.From("databyte_rates").
+		Select("*", "", false).
+		Eq("id", fmt.Sprint(id)).
+		Is("cancelled_at", "null").
+		ExecuteTo(&rates)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching databyte rate %d: %w", id, err)
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: %d", ErrDatabyteRateNotFound, id)
+	}
+	return nil, fmt.Errorf("%w: version %d has been in effect since %s", ErrDatabyteRateInEffect, id, rates[0].EffectiveFrom.Format(time.RFC3339))
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+func TestDatabyteCost(t *testing.T) {
+	tier := func(priceKobo, perDatabytes int64) models.DatabyteRateTier {
+		return models.DatabyteRateTier{MinDatabytes: 1, PriceKobo: priceKobo, PerDatabytes: perDatabytes}
+	}
+	tests := []struct {
+		name    string
+		amount  int64
+		tier    models.DatabyteRateTier
+		want    int64
+		wantErr error
+	}{
+		{"whole kobo", 1000, tier(1, 100), 10, nil},
+		{"rounds up", 1001, tier(1, 100), 11, nil},
+		{"at least 1 kobo", 1, tier(1, 100), 1, nil},
+		{"several kobo per databyte", 7, tier(3, 1), 21, nil},
+		{"fractional rate", 10, tier(3, 4), 8, nil},
+		{"large amount without overflow", math.MaxInt64 / 2, tier(3, 4), 3458764513820540928, nil},
+		{"too large", math.MaxInt64, tier(2, 1), 0, ErrDatabyteAmountTooBig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := databyteCost(tt.amount, tt.tier)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("databyteCost(%d) error = %v, want %v", tt.amount, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("databyteCost(%d) = %d, %v; want %d", tt.amount, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,336 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/rpip/paystack-go"
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// Dispute outcomes passed to ResolveDispute.
+const (
+	DisputeOutcomeWon  = models.DisputeWon
+	DisputeOutcomeLost = models.DisputeLost
+)
+
+// Paystack dispute resolutions, as sent to and received from its dispute API.
+const (
+	paystackDisputeMerchantAccepted = "merchant-accepted"
+	paystackDisputeAutoAccepted     = "auto-accepted"
+	paystackDisputeDeclined         = "declined"
+)
+
+// disputeOutcome maps a Paystack resolution to the dispute's outcome for us. ok is false
+// for resolutions we do not know.
+func disputeOutcome(resolution string) (outcome string, ok bool) {
+	switch resolution {
+	case paystackDisputeDeclined:
+		return DisputeOutcomeWon, true
+	case paystackDisputeMerchantAccepted, paystackDisputeAutoAccepted:
+		return DisputeOutcomeLost, true
+	default:
+		return "", false
+	}
+}
+
+// HandleDisputeEvent applies a charge.dispute.create, charge.dispute.remind or
+// charge.dispute.resolve event and returns the result recorded for audit. A new dispute
+// freezes the disputed amount in the user's wallet; its resolution releases the hold or
+// debits the amount. Disputes first seen in a reminder or resolution are recorded then.
+func (s *PaystackService) HandleDisputeEvent(event string, disputeData models.PaystackDisputeData, supabaseService *SupabaseService) (map[string]interface{}, error) {
+	dispute, opened, err := supabaseService.OpenDispute(disputeData)
+	if err != nil {
+		return nil, err
+	}
+	if opened {
+		if dispute.HeldAmount < dispute.Amount {
+			log.Printf("WARNING: Dispute %d on Paystack Ref %s: only %d of %d kobo could be frozen", disputeData.ID, dispute.Reference, dispute.HeldAmount, dispute.Amount)
+		} else {
+			log.Printf("INFO: Dispute %d on Paystack Ref %s opened; %d kobo frozen", disputeData.ID, dispute.Reference, dispute.HeldAmount)
+		}
+	}
+
+	result := map[string]interface{}{
+		"dispute_id":          dispute.ID,
+		"paystack_dispute_id": disputeData.ID,
+		"held_amount":         dispute.HeldAmount,
+	}
+
+	switch event {
+	case "charge.dispute.create":
+		result["action"] = "frozen"
+		if !opened {
+			result["action"] = "already_recorded"
+		}
+
+	case "charge.dispute.remind":
+		if dispute, err = supabaseService.UpdateDispute(dispute.ID, map[string]interface{}{"last_reminded_at": time.Now()}); err != nil {
+			return nil, err
+		}
+		dueAt := "unknown"
+		if dispute.DueAt != nil {
+			dueAt = dispute.DueAt.Format(time.RFC3339)
+		}
+		log.Printf("WARNING: Dispute %d on Paystack Ref %s is still %s; respond before it is due at %s", disputeData.ID, dispute.Reference, dispute.Status, dueAt)
+		result["action"] = "reminded"
+
+	case "charge.dispute.resolve":
+		resolution := ""
+		if disputeData.Resolution != nil {
+			resolution = *disputeData.Resolution
+		}
+		outcome, ok := disputeOutcome(resolution)
+		if !ok {
+			// Retrying would not help; leave the funds frozen for an admin to look at.
+			log.Printf("WARNING: Dispute %d resolved with unknown resolution %q; funds stay frozen", disputeData.ID, resolution)
+			result["action"] = "unknown_resolution"
+			return result, nil
+		}
+
+		resolved, changed, err := supabaseService.ResolveDispute(disputeData.ID, outcome, resolution, disputeData.RefundAmount)
+		if err != nil {
+			return nil, err
+		}
+		result["status"] = resolved.Status
+		switch {
+		case !changed:
+			log.Printf("INFO: Dispute %d already resolved as %s; %s ignored", disputeData.ID, resolved.Status, event)
+			result["action"] = "already_resolved"
+		case outcome == DisputeOutcomeWon:
+			log.Printf("INFO: Dispute %d won; %d kobo released", disputeData.ID, resolved.HeldAmount)
+			result["action"] = "released"
+		default:
+			log.Printf("INFO: Dispute %d lost; %d kobo debited, %d kobo recorded as debt", disputeData.ID, resolved.DebitedAmount, resolved.DebtAmount)
+			result["action"] = "debited"
+		}
+	}
+	return result, nil
+}
+
+// SubmitDisputeEvidence sends evidence for an open dispute to Paystack. It returns
+// ErrDisputeStateConflict when the dispute is already resolved and ErrDisputeRejected
+// when Paystack rejects the evidence.
+func (s *PaystackService) SubmitDisputeEvidence(disputeID string, req models.DisputeEvidenceRequest, adminID string, supabaseService *SupabaseService) (*models.Dispute, error) {
+	dispute, err := disputeAwaitingResponse(disputeID, supabaseService)
+	if err != nil {
+		return nil, err
+	}
+
+	evidenceReq := map[string]interface{}{
+		"customer_email":  req.CustomerEmail,
+		"customer_name":   req.CustomerName,
+		"customer_phone":  req.CustomerPhone,
+		"service_details": req.ServiceDetails,
+	}
+	if req.DeliveryAddress != "" {
+		evidenceReq["delivery_address"] = req.DeliveryAddress
+	}
+	if req.DeliveryDate != "" {
+		evidenceReq["delivery_date"] = req.DeliveryDate
+	}
+	path := fmt.Sprintf("/dispute/%d/evidence", dispute.PaystackDisputeID)
+	if err := s.Client.Call("POST", path, evidenceReq, &paystack.Response{}); err != nil {
+		return nil, disputeCallError(err, dispute)
+	}
+
+	return supabaseService.UpdateDispute(dispute.ID, map[string]interface{}{
+		"status":                models.DisputeEvidenceSubmitted,
+		"evidence_submitted_by": nullIfEmpty(adminID),
+		"evidence_submitted_at": time.Now(),
+	})
+}
+
+// GetDisputeUploadURL returns a signed URL to upload an evidence file for a dispute to,
+// and the file name to refer to it by when accepting the dispute.
+func (s *PaystackService) GetDisputeUploadURL(disputeID, fileName string, supabaseService *SupabaseService) (*models.DisputeUploadURL, error) {
+	dispute, err := disputeAwaitingResponse(disputeID, supabaseService)
+	if err != nil {
+		return nil, err
+	}
+
+	path := fmt.Sprintf("/dispute/%d/upload_url?upload_filename=%s", dispute.PaystackDisputeID, url.QueryEscape(fileName))
+	uploadURL := &models.DisputeUploadURL{}
+	if err := s.Client.Call("GET", path, nil, uploadURL); err != nil {
+		return nil, disputeCallError(err, dispute)
+	}
+	return uploadURL, nil
+}
+
+// AcceptDispute accepts an open dispute through Paystack, refunding the cardholder, and
+// debits the refunded amount from the user's wallet (recording a debt for what they
+// have already spent). It returns ErrDisputeStateConflict when the dispute is already
+// resolved, ErrDisputeRefundTooHigh for a refund above the disputed amount and
+// ErrDisputeRejected when Paystack rejects the acceptance.
+func (s *PaystackService) AcceptDispute(disputeID string, req models.DisputeAcceptRequest, adminID string, supabaseService *SupabaseService) (*models.Dispute, error) {
+	dispute, err := disputeAwaitingResponse(disputeID, supabaseService)
+	if err != nil {
+		return nil, err
+	}
+	refundAmount := dispute.Amount
+	if req.RefundAmount != nil {
+		refundAmount = *req.RefundAmount
+	}
+	if refundAmount > dispute.Amount {
+		return nil, fmt.Errorf("%w: %d kobo requested, %d kobo disputed", ErrDisputeRefundTooHigh, refundAmount, dispute.Amount)
+	}
+
+	resolveReq := map[string]interface{}{
+		"resolution":        paystackDisputeMerchantAccepted,
+		"message":           req.Message,
+		"refund_amount":     refundAmount,
+		"uploaded_filename": req.UploadedFilename,
+	}
+	path := fmt.Sprintf("/dispute/%d/resolve", dispute.PaystackDisputeID)
+	if err := s.Client.Call("PUT", path, resolveReq, &paystack.Response{}); err != nil {
+		return nil, disputeCallError(err, dispute)
+	}
+	log.Printf("INFO: Dispute %d accepted by admin %s; %d kobo refunded to the cardholder", dispute.PaystackDisputeID, adminID, refundAmount)
+
+	if _, err := supabaseService.UpdateDispute(dispute.ID, map[string]interface{}{"accepted_by": nullIfEmpty(adminID)}); err != nil {
+		log.Printf("WARNING: Failed to record admin %s accepting dispute %s: %v", adminID, dispute.ID, err)
+	}
+
+	// Resolve it now rather than waiting for charge.dispute.resolve, which then finds
+	// it already resolved.
+	resolved, _, err := supabaseService.ResolveDispute(dispute.PaystackDisputeID, DisputeOutcomeLost, paystackDisputeMerchantAccepted, refundAmount)
+	if err != nil {
+		// Not fatal: the dispute was accepted and its webhook resolves it.
+		log.Printf("WARNING: Failed to debit accepted dispute %s: %v", dispute.ID, err)
+		return dispute, nil
+	}
+	return resolved, nil
+}
+
+// disputeAwaitingResponse fetches a dispute and checks that it is still open to evidence
+// or acceptance.
+func disputeAwaitingResponse(disputeID string, supabaseService *SupabaseService) (*models.Dispute, error) {
+	dispute, err := supabaseService.GetDispute(disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status != models.DisputeOpen && dispute.Status != models.DisputeEvidenceSubmitted {
+		return nil, fmt.Errorf("%w: dispute %s is %s", ErrDisputeStateConflict, dispute.ID, dispute.Status)
+	}
+	return dispute, nil
+}
+
+// disputeCallError wraps an error from Paystack's dispute API, as ErrDisputeRejected
+// when Paystack rejected the request.
+func disputeCallError(err error, dispute *models.Dispute) error {
+	var apiErr *paystack.APIError
+	if errors.As(err, &apiErr) {
+		return fmt.Errorf("%w: %s", ErrDisputeRejected, paystackErrorMessage(err))
+	}
+	return fmt.Errorf("failed to call Paystack for dispute %d: %w", dispute.PaystackDisputeID, err)
+}
+
+// OpenDispute records a dispute from a Paystack event and freezes as much of the
+// disputed amount as the user has available. opened is false when the dispute was
+// already recorded; its Paystack status and due date are refreshed.
+func (s *SupabaseService) OpenDispute(disputeData models.PaystackDisputeData) (dispute *models.Dispute, opened bool, err error) {
+	amount := disputeData.RefundAmount
+	if amount == 0 {
+		amount = disputeData.Transaction.Amount
+	}
+
+	params := map[string]interface{}{
+		"p_paystack_dispute_id": disputeData.ID,
+		"p_reference":           disputeData.Transaction.Reference,
+		"p_amount":              amount,
+		"p_currency":            nullIfEmpty(disputeData.Currency),
+		"p_category":            nullIfEmpty(disputeData.Category),
+		"p_paystack_status":     nullIfEmpty(disputeData.Status),
+		"p_due_at":              disputeData.DueAt,
+	}
+
+	var result struct {
+		Changed bool           `json:"changed"`
+		Dispute models.Dispute `json:"dispute"`
+	}
+	if err := s.callRPC("open_dispute", params, &result); err != nil {
+		return nil, false, fmt.Errorf("error recording dispute %d on %s: %w", disputeData.ID, disputeData.Transaction.Reference, err)
+	}
+	return &result.Dispute, result.Changed, nil
+}
+
+// ResolveDispute applies a dispute's outcome: DisputeOutcomeWon releases its hold and
+// DisputeOutcomeLost debits amountKobo (the disputed amount if 0) from the wallet as a
+// chargeback, recording a debt for whatever is not available. changed is false when
+// the dispute had already been resolved.
+func (s *SupabaseService) ResolveDispute(paystackDisputeID int64, outcome, resolution string, amountKobo int64) (dispute *models.Dispute, changed bool, err error) {
+	params := map[string]interface{}{
+		"p_paystack_dispute_id": paystackDisputeID,
+		"p_outcome":             outcome,
+		"p_resolution":          nullIfEmpty(resolution),
+		"p_amount":              nullIfZero(amountKobo),
+		"p_description":         fmt.Sprintf("Chargeback of datacredit purchase (Paystack Dispute: %d)", paystackDisputeID),
+		"p_counterparty":        ledgerCounterparties[models.OperationChargeback].datacredit,
+	}
+
+	var result struct {
+		Changed bool           `json:"changed"`
+		Dispute models.Dispute `json:"dispute"`
+	}
+	if err := s.callRPC("resolve_dispute", params, &result); err != nil {
+		return nil, false, fmt.Errorf("error resolving dispute %d as %s: %w", paystackDisputeID, outcome, err)
+	}
+	return &result.Dispute, result.Changed, nil
+}
+
+// UpdateDispute applies a partial update to a dispute.
+func (s *SupabaseService) UpdateDispute(id string, updateData map[string]interface{}) (*models.Dispute, error) {
+	updateData["updated_at"] = time.Now()
+
+	var updated []models.Dispute
+	_, err := s.Client.From("disputes").
+		Update(updateData, "", "").
+		Eq("id", id).
+		ExecuteTo(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("error updating dispute %s: %w", id, err)
+	}
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDisputeNotFound, id)
+	}
+	return &updated[0], nil
+}
+
+// ListDisputes returns disputes, newest first, optionally filtered by status.
+func (s *SupabaseService) ListDisputes(status string, limit, offset int) ([]models.Dispute, error) {
+	query := s.Client.From("disputes").
+		Select("*", "", false)
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+
+	var disputes []models.Dispute
+	_, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&disputes)
+	if err != nil {
+		return nil, fmt.Errorf("error listing disputes: %w", err)
+	}
+	return disputes, nil
+}
+
+// GetDispute fetches a dispute by ID.
+func (s *SupabaseService) GetDispute(id string) (*models.Dispute, error) {
+	var disputes []models.Dispute
+	_, err := s.Client.From("disputes").
+		Select("*", "", false).
+		Eq("id", id).
+		ExecuteTo(&disputes)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching dispute %s: %w", id, err)
+	}
+	if len(disputes) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrDisputeNotFound, id)
+	}
+	return &disputes[0], nil
+}
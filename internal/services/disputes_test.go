@@ -0,0 +1,25 @@
+package services
+
+import "testing"
+
+func TestDisputeOutcome(t *testing.T) {
+	tests := []struct {
+		resolution string
+		want       string
+		wantOK     bool
+	}{
+		{"declined", DisputeOutcomeWon, true},
+		{"merchant-accepted", DisputeOutcomeLost, true},
+		{"auto-accepted", DisputeOutcomeLost, true},
+		{"", "", false},
+		{"pending", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.resolution, func(t *testing.T) {
+			got, ok := disputeOutcome(tt.resolution)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("disputeOutcome(%q) = %q, %t; want %q, %t", tt.resolution, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"errors"
+	"strings"
+)
+
+// Sentinel errors returned by the service layer. Handlers should match these with
+// errors.Is instead of inspecting error strings.
+var (
+	ErrInsufficientDatacredit = errors.New("insufficient datacredit balance")
+	ErrInsufficientDatabyte   = errors.New("insufficient databyte balance")
+	ErrLedgerImbalance        = errors.New("ledger is out of balance")
+	ErrPaymentIntentNotFound  = errors.New("payment intent not found")
+	ErrPaymentQueuedForReview = errors.New("payment queued for review")
+
+	ErrPaymentReviewNotFound      = errors.New("payment review not found")
+	ErrPaymentReviewResolved      = errors.New("payment review is already resolved")
+	ErrPaymentReviewNotCreditable = errors.New("payment review cannot be credited to a wallet")
+
+	ErrInvalidPaymentIntentTransition = errors.New("invalid payment intent transition")
+	ErrPaymentIntentConflict          = errors.New("payment intent was changed concurrently")
+
+	ErrWithdrawalNotFound      = errors.New("withdrawal not found")
+	ErrWithdrawalStateConflict = errors.New("withdrawal is not in the expected state")
+	ErrWalletHoldNotActive     = errors.New("wallet hold is not active")
+	ErrSelfApproval            = errors.New("withdrawal cannot be approved by its requester")
+	ErrTransferInitiation      = errors.New("failed to initiate Paystack transfer")
+	ErrTransferOTPRejected     = errors.New("transfer OTP was rejected by Paystack")
+	ErrTransferOutcomeUnknown  = errors.New("Paystack did not confirm whether the transfer was sent")
+
+	ErrWithdrawalBelowMinimum = errors.New("withdrawal amount is below the minimum")
+	ErrWithdrawalAboveMaximum = errors.New("withdrawal amount is above the maximum")
+	ErrWithdrawalBelowFee     = errors.New("withdrawal amount does not cover the withdrawal fee")
+	ErrWithdrawalDailyLimit   = errors.New("withdrawal would exceed the daily withdrawal limit")
+
+	ErrWithdrawalReviewFlagNotFound = errors.New("user is not flagged for withdrawal review")
+	ErrPayoutBatchNotFound          = errors.New("payout batch not found")
+
+	ErrRefundNotFound       = errors.New("refund not found")
+	ErrNotRefundable        = errors.New("payment cannot be refunded for this amount")
+	ErrRefundRejected       = errors.New("refund was rejected by Paystack")
+	ErrRefundOutcomeUnknown = errors.New("Paystack did not confirm whether the refund was started")
+
+	ErrDisputeNotFound      = errors.New("dispute not found")
+	ErrDisputeStateConflict = errors.New("dispute is no longer open")
+	ErrDisputeRejected      = errors.New("dispute response was rejected by Paystack")
+	ErrDisputeRefundTooHigh = errors.New("refund amount exceeds the disputed amount")
+
+	ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+	ErrNoDatabyteRate       = errors.New("no databyte rate is in effect")
+	ErrInvalidDatabyteRate  = errors.New("invalid databyte rate")
+	ErrDatabyteRateNotFound = errors.New("databyte rate not found")
+	ErrDatabyteRateInEffect = errors.New("databyte rate has already taken effect")
+	ErrDatabyteRateChanged  = errors.New("databyte rate has changed since the quote")
+	ErrDatabyteCostTooHigh  = errors.New("databyte purchase costs more than the maximum given")
+	ErrDatabyteAmountTooBig = errors.New("databyte amount is too large to price")
+
+	ErrProfileNotFound = errors.New("profile not found")
+	ErrInvalidProfile  = errors.New("invalid profile")
+	ErrUsernameTaken   = errors.New("username is already taken")
+
+	ErrPayoutAccountNotFound = errors.New("payout account not found")
+	ErrPayoutAccountExists   = errors.New("payout account already added")
+	ErrNoPayoutAccount       = errors.New("no payout account set up for withdrawals")
+	ErrPayoutAccountInUse    = errors.New("payout account has withdrawals in progress")
+	ErrBankAccountUnresolved = errors.New("bank account could not be resolved")
+	ErrPayoutCurrency        = errors.New("payout currency is not supported")
+)
+
+// rpcErrorCodes maps the custom SQLSTATE codes raised by our Postgres functions
+// (see supabase/migrations) to their sentinel errors.
+var rpcErrorCodes = map[string]error{
+	"DG001": ErrInsufficientDatacredit,
+	"DG002": ErrInsufficientDatabyte,
+	"DG003": ErrLedgerImbalance,
+	"DG005": ErrLedgerImbalance,
+	"DG006": ErrPaymentIntentNotFound,
+	"DG007": ErrWithdrawalNotFound,
+	"DG008": ErrWithdrawalStateConflict,
+	"DG009": ErrPayoutAccountNotFound,
+	"DG010": ErrWalletHoldNotActive,
+	"DG011": ErrSelfApproval,
+	"DG012": ErrWithdrawalDailyLimit,
+	"DG013": ErrNotRefundable,
+	"DG014": ErrRefundNotFound,
+	"DG015": ErrDisputeNotFound,
+	"DG016": ErrInvalidPaymentIntentTransition,
+	"DG017": ErrPaymentReviewNotFound,
+	"DG018": ErrPaymentReviewResolved,
+	"DG019": ErrPaymentReviewNotCreditable,
+	"DG020": ErrPayoutAccountInUse,
+}
+
+// rpcError carries the message raised by the database while unwrapping to the
+// matching sentinel error.
+type rpcError struct {
+	sentinel error
+	message  string
+}
+
+func (e *rpcError) Error() string { return e.message }
+func (e *rpcError) Unwrap() error { return e.sentinel }
+
+// isUniqueViolation reports whether a PostgREST error is a unique constraint violation.
+func isUniqueViolation(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "(23505)")
+}
+
+// mapRPCError translates a PostgREST error of the form "(CODE) message" into a
+// sentinel error when CODE is one of ours, keeping the database message for context.
+func mapRPCError(err error) error {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "(") {
+		return err
+	}
+	end := strings.Index(msg, ")")
+	if end < 0 {
+		return err
+	}
+	if sentinel, ok := rpcErrorCodes[msg[1:end]]; ok {
+		return &rpcError{sentinel: sentinel, message: strings.TrimSpace(msg[end+1:])}
+	}
+	return err
+}
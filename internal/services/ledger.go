@@ -0,0 +1,96 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// Platform ledger accounts, seeded by supabase/migrations. User accounts are named
+// "user:<user_id>:<currency>" and are created by the database on first use.
+const (
+	LedgerAccountPaystackClearing = "platform:paystack_clearing"
+	LedgerAccountRevenue          = "platform:revenue"
+	LedgerAccountFees             = "platform:fees"
+	LedgerAccountDatabyteIssuance = "platform:databyte_issuance"
+	LedgerAccountReceivables      = "platform:receivables" // Datacredit users owe for refunds and chargebacks they had already spent
+)
+
+// ledgerCounterparties is the platform account on the other side of a wallet change,
+// per operation and currency. Every wallet change posts one balanced journal entry
+// against these accounts.
+var ledgerCounterparties = map[string]struct{ datacredit, databyte string }{
+	models.OperationCreditPurchase:     {datacredit: LedgerAccountPaystackClearing},
+	models.OperationWithdrawal:         {datacredit: LedgerAccountPaystackClearing},
+	models.OperationWithdrawalReversal: {datacredit: LedgerAccountPaystackClearing},
+	models.OperationRefund:             {datacredit: LedgerAccountPaystackClearing},
+	models.OperationRefundReversal:     {datacredit: LedgerAccountPaystackClearing},
+	models.OperationDebtCollection:     {datacredit: LedgerAccountReceivables},
+	models.OperationChargeback:         {datacredit: LedgerAccountPaystackClearing},
+	models.OperationDatabytePurchase:   {datacredit: LedgerAccountRevenue, databyte: LedgerAccountDatabyteIssuance},
+	models.OperationDatabyteUpdate:     {databyte: LedgerAccountDatabyteIssuance},
+}
+
+// walletDelta is the parameter set of the apply_wallet_delta database function.
+type walletDelta struct {
+	UserID                 string                 `json:"p_user_id"`
+	DatacreditDelta        int64                  `json:"p_datacredit_delta"`
+	DatabyteDelta          int64                  `json:"p_databyte_delta"`
+	Operation              string                 `json:"p_operation"`
+	Description            string                 `json:"p_description"`
+	ExternalRef            *string                `json:"p_external_ref"`
+	Metadata               map[string]interface{} `json:"p_metadata"`
+	DatacreditCounterparty *string                `json:"p_datacredit_counterparty"`
+	DatabyteCounterparty   *string                `json:"p_databyte_counterparty"`
+	DatacreditOperation    *string                `json:"p_datacredit_operation"` // Overrides Operation on the datacredit transaction row
+	DatabyteOperation      *string                `json:"p_databyte_operation"`   // Overrides Operation on the databyte transaction row
+}
+
+// newWalletDelta fills in the counterparty accounts for operation and rejects
+// operations the ledger does not know how to post.
+func newWalletDelta(userID, operation string, datacreditDelta, databyteDelta int64) (walletDelta, error) {
+	counterparty, ok := ledgerCounterparties[operation]
+	if !ok {
+		return walletDelta{}, fmt.Errorf("no ledger counterparty configured for operation %q", operation)
+	}
+
+	d := walletDelta{
+		UserID:          userID,
+		DatacreditDelta: datacreditDelta,
+		DatabyteDelta:   databyteDelta,
+		Operation:       operation,
+	}
+	if counterparty.datacredit != "" {
+		d.DatacreditCounterparty = &counterparty.datacredit
+	}
+	if counterparty.databyte != "" {
+		d.DatabyteCounterparty = &counterparty.databyte
+	}
+	return d, nil
+}
+
+// GetLedgerAccounts returns the ledger accounts owned by a user.
+func (s *SupabaseService) GetLedgerAccounts(userID string) ([]models.LedgerAccount, error) {
+	var accounts []models.LedgerAccount
+	_, err := s.Client.From("ledger_accounts").
+		Select("*", "", false).
+		Eq("owner_user_id", userID).
+		ExecuteTo(&accounts)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ledger accounts for user %s: %w", userID, err)
+	}
+	return accounts, nil
+}
+
+// GetLedgerDrift returns every wallet whose balances disagree with its ledger accounts.
+// An empty result means the wallets table and the ledger agree.
+func (s *SupabaseService) GetLedgerDrift() ([]models.LedgerDrift, error) {
+	var drift []models.LedgerDrift
+	_, err := s.Client.From("ledger_wallet_drift").
+		Select("*", "", false).
+		ExecuteTo(&drift)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ledger drift: %w", err)
+	}
+	return drift, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// Ledger check metrics, published under "ledger" at /debug/vars (admin only). They
+// reflect the most recent check, scheduled or requested by an admin.
+var (
+	ledgerMetrics                = expvar.NewMap("ledger")
+	ledgerChecks                 = new(expvar.Int)
+	ledgerCheckErrors            = new(expvar.Int)
+	ledgerLastCheck              = new(expvar.Int) // Unix time of the last completed check
+	ledgerHealthy                = new(expvar.Int) // 1 when the last check found nothing wrong
+	ledgerDriftedWallets         = new(expvar.Int)
+	ledgerFailedInvariants       = new(expvar.Int)
+	ledgerInvariantDiscrepancies = new(expvar.Map) // expected - actual per failed invariant, keyed "<name>:<currency>"
+)
+
+func init() {
+	ledgerMetrics.Set("checks", ledgerChecks)
+	ledgerMetrics.Set("check_errors", ledgerCheckErrors)
+	ledgerMetrics.Set("last_check_unix", ledgerLastCheck)
+	ledgerMetrics.Set("healthy", ledgerHealthy)
+	ledgerMetrics.Set("drifted_wallets", ledgerDriftedWallets)
+	ledgerMetrics.Set("failed_invariants", ledgerFailedInvariants)
+	ledgerMetrics.Set("invariant_discrepancies", ledgerInvariantDiscrepancies)
+}
+
+// LedgerChecker periodically recomputes wallet balances from the transaction history and
+// the ledger and checks the platform-wide invariants, logging and publishing what it finds.
+type LedgerChecker struct {
+	SupabaseService *SupabaseService
+	Interval        time.Duration
+}
+
+// NewLedgerChecker creates a checker using the interval from cfg.
+func NewLedgerChecker(ss *SupabaseService, cfg *config.Config) *LedgerChecker {
+	return &LedgerChecker{
+		SupabaseService: ss,
+		Interval:        cfg.LedgerCheckInterval,
+	}
+}
+
+// Run checks every Interval until ctx is cancelled. A zero Interval disables the schedule;
+// admins can still run a check on demand.
+func (c *LedgerChecker) Run(ctx context.Context) {
+	if c.Interval <= 0 {
+		log.Println("INFO: Scheduled ledger checks disabled.")
+		return
+	}
+	log.Printf("INFO: Ledger checker running every %s", c.Interval)
+
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.CheckOnce()
+		}
+	}
+}
+
+// CheckOnce runs one check and logs every drifted wallet and failed invariant.
+func (c *LedgerChecker) CheckOnce() *models.LedgerCheckReport {
+	report, err := c.SupabaseService.CheckLedger()
+	if err != nil {
+		log.Printf("ERROR: Ledger check failed: %v", err)
+		return nil
+	}
+	if report.Healthy {
+		log.Println("INFO: Ledger check passed: wallets, transactions and ledger agree.")
+		return report
+	}
+
+	for _, d := range report.DriftedWallets {
+		log.Printf("CRITICAL ERROR: Wallet of UserID %s has drifted: datacredit wallet/transactions/ledger %d/%d/%d, databyte %d/%d/%d, held %d vs active holds %d",
+			d.UserID, d.WalletDatacreditBalance, d.TransactionsDatacreditBalance, d.LedgerDatacreditBalance,
+			d.WalletDatabyteBalance, d.TransactionsDatabyteBalance, d.LedgerDatabyteBalance,
+			d.HeldDatacredit, d.ActiveHoldsDatacredit)
+	}
+	for _, i := range report.Invariants {
+		if !i.Holds() {
+			log.Printf("CRITICAL ERROR: Ledger invariant %s (%s) failed: expected %d, got %d. %s", i.Name, i.Currency, i.Expected, i.Actual, i.Description)
+		}
+	}
+	return report
+}
+
+// CheckLedger recomputes every wallet's balances from its transactions, ledger accounts and
+// active holds, and checks the platform-wide invariants. The result is also published as
+// metrics.
+func (s *SupabaseService) CheckLedger() (*models.LedgerCheckReport, error) {
+	ledgerChecks.Add(1)
+
+	report, err := s.checkLedger()
+	if err != nil {
+		ledgerCheckErrors.Add(1)
+		return nil, err
+	}
+
+	ledgerLastCheck.Set(report.CheckedAt.Unix())
+	ledgerDriftedWallets.Set(int64(len(report.DriftedWallets)))
+	ledgerFailedInvariants.Set(int64(report.FailedInvariants))
+	if report.Healthy {
+		ledgerHealthy.Set(1)
+	} else {
+		ledgerHealthy.Set(0)
+	}
+	ledgerInvariantDiscrepancies.Init()
+	for _, i := range report.Invariants {
+		if !i.Holds() {
+			discrepancy := new(expvar.Int)
+			discrepancy.Set(i.Expected - i.Actual)
+			ledgerInvariantDiscrepancies.Set(i.Name+":"+i.Currency, discrepancy)
+		}
+	}
+	return report, nil
+}
+
+func (s *SupabaseService) checkLedger() (*models.LedgerCheckReport, error) {
+	drift, err := s.GetWalletBalanceDrift()
+	if err != nil {
+		return nil, err
+	}
+	invariants, err := s.CheckLedgerInvariants()
+	if err != nil {
+		return nil, err
+	}
+	totals, err := s.GetTransactionTotals()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.LedgerCheckReport{
+		CheckedAt:         time.Now(),
+		DriftedWallets:    drift,
+		Invariants:        invariants,
+		TransactionTotals: totals,
+	}
+	if report.DriftedWallets == nil {
+		report.DriftedWallets = []models.WalletBalanceDrift{}
+	}
+	if report.TransactionTotals == nil {
+		report.TransactionTotals = []models.TransactionTotal{}
+	}
+	for _, i := range invariants {
+		if !i.Holds() {
+			report.FailedInvariants++
+		}
+	}
+	report.Healthy = len(drift) == 0 && report.FailedInvariants == 0
+	return report, nil
+}
+
+// GetWalletBalanceDrift returns every user whose wallet disagrees with their transaction
+// history, ledger accounts or active holds. An empty result means they all agree.
+func (s *SupabaseService) GetWalletBalanceDrift() ([]models.WalletBalanceDrift, error) {
+	var drift []models.WalletBalanceDrift
+	_, err := s.Client.From("wallet_balance_drift").
+		Select("*", "", false).
+		ExecuteTo(&drift)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching wallet balance drift: %w", err)
+	}
+	return drift, nil
+}
+
+// CheckLedgerInvariants evaluates the platform-wide ledger invariants.
+func (s *SupabaseService) CheckLedgerInvariants() ([]models.LedgerInvariant, error) {
+	var invariants []models.LedgerInvariant
+	if err := s.callRPC("check_ledger_invariants", map[string]interface{}{}, &invariants); err != nil {
+		return nil, fmt.Errorf("error checking ledger invariants: %w", err)
+	}
+	return invariants, nil
+}
+
+// GetTransactionTotals returns the transaction history summed per currency and operation.
+func (s *SupabaseService) GetTransactionTotals() ([]models.TransactionTotal, error) {
+	var totals []models.TransactionTotal
+	_, err := s.Client.From("transaction_totals").
+		Select("*", "", false).
+		Order("currency", &postgrest.OrderOpts{Ascending: true}).
+		Order("operation", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&totals)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching transaction totals: %w", err)
+	}
+	return totals, nil
+}
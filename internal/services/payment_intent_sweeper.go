@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// sweepBatchSize caps how many intents or withdrawals one sweep verifies with Paystack.
+const sweepBatchSize = 100
+
+// PaymentIntentSweeper periodically verifies stale open payment intents with Paystack and
+// moves them to the state Paystack reports, closing out checkouts users abandoned.
+type PaymentIntentSweeper struct {
+	PaystackService *PaystackService
+	SupabaseService *SupabaseService
+	Interval        time.Duration
+	StaleAfter      time.Duration
+	ExpireAfter     time.Duration
+}
+
+// NewPaymentIntentSweeper creates a sweeper using the intervals from cfg.
+func NewPaymentIntentSweeper(ps *PaystackService, ss *SupabaseService, cfg *config.Config) *PaymentIntentSweeper {
+	return &PaymentIntentSweeper{
+		PaystackService: ps,
+		SupabaseService: ss,
+		Interval:        cfg.PaymentIntentSweepInterval,
+		StaleAfter:      cfg.PaymentIntentStaleAfter,
+		ExpireAfter:     cfg.PaymentIntentExpireAfter,
+	}
+}
+
+// Run sweeps every Interval until ctx is cancelled. A zero Interval disables the sweeper.
+func (w *PaymentIntentSweeper) Run(ctx context.Context) {
+	if w.Interval <= 0 {
+		log.Println("INFO: Payment intent sweeper disabled.")
+		return
+	}
+	log.Printf("INFO: Payment intent sweeper running every %s (stale after %s, expire after %s)", w.Interval, w.StaleAfter, w.ExpireAfter)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.SweepOnce()
+		}
+	}
+}
+
+// SweepOnce verifies one batch of stale intents and returns how many changed state.
+func (w *PaymentIntentSweeper) SweepOnce() int {
+	now := time.Now()
+	intents, err := w.SupabaseService.ListStalePaymentIntents(now.Add(-w.StaleAfter), sweepBatchSize)
+	if err != nil {
+		log.Printf("ERROR: Payment intent sweep failed to list intents: %v", err)
+		return 0
+	}
+
+	changed := 0
+	for i := range intents {
+		if w.sweepIntent(&intents[i], now) {
+			changed++
+		}
+	}
+	if len(intents) > 0 {
+		log.Printf("INFO: Payment intent sweep checked %d intent(s), %d changed state", len(intents), changed)
+	}
+	return changed
+}
+
+// sweepIntent verifies a single intent with Paystack and applies the resulting transition.
+func (w *PaymentIntentSweeper) sweepIntent(intent *models.PaymentIntent, now time.Time) bool {
+	expired := now.Sub(intent.CreatedAt) >= w.ExpireAfter
+
+	transaction, err := w.PaystackService.VerifyPayment(intent.Reference)
+	if err != nil {
+		if isPaystackNotFound(err) && expired {
+			// Paystack never saw a payment attempt for this reference.
+			return w.transition(intent, models.PaymentIntentExpired, "", "no Paystack transaction found before expiry")
+		}
+		log.Printf("WARNING: Sweeper could not verify payment intent %s: %v", intent.Reference, err)
+		w.markVerified(intent, "")
+		return false
+	}
+
+	switch transaction.Status {
+	case "success":
+		// Same idempotent path as the charge.success webhook.
+		_, applied, err := w.PaystackService.ApplyChargeSuccess(*transaction, w.SupabaseService)
+		if err != nil {
+			log.Printf("ERROR: Sweeper failed to credit payment intent %s: %v", intent.Reference, err)
+			return false
+		}
+		return applied
+	case "failed", "reversed":
+		return w.transition(intent, models.PaymentIntentFailed, transaction.Status, transaction.GatewayResponse)
+	case "ongoing", "pending", "processing", "queued":
+		if expired {
+			return w.transition(intent, models.PaymentIntentExpired, transaction.Status, "payment still "+transaction.Status+" at expiry")
+		}
+		if intent.Status == models.PaymentIntentInitialized {
+			return w.transition(intent, models.PaymentIntentPending, transaction.Status, "")
+		}
+	case "abandoned":
+		if expired {
+			return w.transition(intent, models.PaymentIntentAbandoned, transaction.Status, "checkout abandoned")
+		}
+	default:
+		log.Printf("WARNING: Sweeper got unexpected Paystack status %q for payment intent %s", transaction.Status, intent.Reference)
+	}
+
+	w.markVerified(intent, transaction.Status)
+	return false
+}
+
+// transition moves an intent to a new status, recording what Paystack reported.
+func (w *PaymentIntentSweeper) transition(intent *models.PaymentIntent, to, paystackStatus, reason string) bool {
+	updateData := map[string]interface{}{"last_verified_at": time.Now()}
+	if paystackStatus != "" {
+		updateData["paystack_status"] = paystackStatus
+	}
+	if reason != "" {
+		updateData["failure_reason"] = reason
+	}
+
+	if _, err := w.SupabaseService.TransitionPaymentIntent(intent.Reference, intent.Status, to, updateData); err != nil {
+		if errors.Is(err, ErrPaymentIntentConflict) {
+			// Something else (usually the webhook) moved it first; that result wins.
+			return false
+		}
+		log.Printf("ERROR: Sweeper failed to move payment intent %s to %s: %v", intent.Reference, to, err)
+		return false
+	}
+	log.Printf("INFO: Payment intent %s moved from %s to %s", intent.Reference, intent.Status, to)
+	return true
+}
+
+// markVerified records that an intent was checked without changing its status, so the
+// next sweep does not pick it up again until it is stale once more.
+func (w *PaymentIntentSweeper) markVerified(intent *models.PaymentIntent, paystackStatus string) {
+	updateData := map[string]interface{}{"last_verified_at": time.Now()}
+	if paystackStatus != "" {
+		updateData["paystack_status"] = paystackStatus
+	}
+	if _, err := w.SupabaseService.UpdatePaymentIntent(intent.Reference, updateData); err != nil {
+		log.Printf("WARNING: Failed to record verification of payment intent %s: %v", intent.Reference, err)
+	}
+}
@@ -0,0 +1,170 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// NewPaymentReference generates a unique Paystack transaction reference. We generate
+// references ourselves so the payment intent can be stored before Paystack is called.
+func NewPaymentReference() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating payment reference: %w", err)
+	}
+	return "DGP_" + hex.EncodeToString(buf), nil
+}
+
+// CreatePaymentIntent stores a new payment intent in the initialized state.
+func (s *SupabaseService) CreatePaymentIntent(intent models.PaymentIntent) (*models.PaymentIntent, error) {
+	insertData := map[string]interface{}{
+		"reference": intent.Reference,
+		"user_id":   intent.UserID,
+		"email":     intent.Email,
+		"amount":    intent.Amount,
+		"currency":  intent.Currency,
+		"purpose":   intent.Purpose,
+		"status":    models.PaymentIntentInitialized,
+	}
+
+	var created []models.PaymentIntent
+	_, err := s.Client.From("payment_intents").
+		Insert(insertData, false, "", "", "").
+		ExecuteTo(&created)
+	if err != nil {
+		return nil, fmt.Errorf("error creating payment intent %s: %w", intent.Reference, err)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("no data returned after creating payment intent %s", intent.Reference)
+	}
+	return &created[0], nil
+}
+
+// GetPaymentIntent fetches a payment intent by its Paystack reference.
+// It returns (nil, nil) when no intent exists for the reference.
+func (s *SupabaseService) GetPaymentIntent(reference string) (*models.PaymentIntent, error) {
+	var intents []models.PaymentIntent
+	_, err := s.Client.From("payment_intents").
+		Select("*", "", false).
+		Eq("reference", reference).
+		ExecuteTo(&intents)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching payment intent %s: %w", reference, err)
+	}
+	if len(intents) == 0 {
+		return nil, nil
+	}
+	return &intents[0], nil
+}
+
+// UpdatePaymentIntent applies a partial update to a payment intent.
+func (s *SupabaseService) UpdatePaymentIntent(reference string, updateData map[string]interface{}) (*models.PaymentIntent, error) {
+	updateData["updated_at"] = time.Now()
+
+	var updated []models.PaymentIntent
+	_, err := s.Client.From("payment_intents").
+		Update(updateData, "", "").
+		Eq("reference", reference).
+		ExecuteTo(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("error updating payment intent %s: %w", reference, err)
+	}
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("payment intent %s not found", reference)
+	}
+	return &updated[0], nil
+}
+
+// TransitionPaymentIntent moves a payment intent from one status to another. The update
+// only applies while the intent is still in the from status, so a concurrent change
+// (e.g. a webhook crediting it while the sweeper expires it) makes this return
+// ErrPaymentIntentConflict rather than overwrite the other writer.
+func (s *SupabaseService) TransitionPaymentIntent(reference, from, to string, updateData map[string]interface{}) (*models.PaymentIntent, error) {
+	if !models.CanTransitionPaymentIntent(from, to) {
+		return nil, fmt.Errorf("%w: %s -> %s", ErrInvalidPaymentIntentTransition, from, to)
+	}
+	if updateData == nil {
+		updateData = map[string]interface{}{}
+	}
+	updateData["status"] = to
+	updateData["updated_at"] = time.Now()
+
+	var updated []models.PaymentIntent
+	_, err := s.Client.From("payment_intents").
+		Update(updateData, "", "").
+		Eq("reference", reference).
+		Eq("status", from).
+		ExecuteTo(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("error moving payment intent %s from %s to %s: %w", reference, from, to, err)
+	}
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("%w: %s is no longer %s", ErrPaymentIntentConflict, reference, from)
+	}
+	return &updated[0], nil
+}
+
+// ListStalePaymentIntents returns open (initialized or pending) intents created before
+// staleBefore that have not been verified with Paystack since then, oldest first.
+func (s *SupabaseService) ListStalePaymentIntents(staleBefore time.Time, limit int) ([]models.PaymentIntent, error) {
+	cutoff := staleBefore.UTC().Format(time.RFC3339)
+
+	var intents []models.PaymentIntent
+	_, err := s.Client.From("payment_intents").
+		Select("*", "", false).
+		In("status", []string{models.PaymentIntentInitialized, models.PaymentIntentPending}).
+		Lt("created_at", cutoff).
+		Or("last_verified_at.is.null,last_verified_at.lt."+cutoff, "").
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Limit(limit, "").
+		ExecuteTo(&intents)
+	if err != nil {
+		return nil, fmt.Errorf("error listing stale payment intents: %w", err)
+	}
+	return intents, nil
+}
+
+// CreditPaymentIntent credits the intent's user with the intent's amount and marks the
+// intent succeeded in one database transaction. credited is false when the intent had
+// already been credited, so callers can treat repeated calls as no-ops.
+func (s *SupabaseService) CreditPaymentIntent(reference string, paidAmount int64, paystackTransactionID int64, description string, metadata map[string]interface{}) (intent *models.PaymentIntent, credited bool, err error) {
+	params := map[string]interface{}{
+		"p_reference":      reference,
+		"p_description":    description,
+		"p_metadata":       metadata,
+		"p_counterparty":   ledgerCounterparties[models.OperationCreditPurchase].datacredit,
+		"p_paid_amount":    paidAmount,
+		"p_transaction_id": paystackTransactionID,
+	}
+
+	var result struct {
+		Credited bool                 `json:"credited"`
+		Intent   models.PaymentIntent `json:"intent"`
+	}
+	if err := s.callRPC("credit_payment_intent", params, &result); err != nil {
+		return nil, false, fmt.Errorf("error crediting payment intent %s: %w", reference, err)
+	}
+	return &result.Intent, result.Credited, nil
+}
+
+// CreatePaymentReview adds a charge to the payment review queue.
+func (s *SupabaseService) CreatePaymentReview(review models.PaymentReview) (*models.PaymentReview, error) {
+	review.Status = "open"
+
+	var created []models.PaymentReview
+	_, err := s.Client.From("payment_reviews").
+		Insert(review, false, "", "", "").
+		ExecuteTo(&created)
+	if err != nil {
+		return nil, fmt.Errorf("error creating payment review for %s: %w", review.Reference, err)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("no data returned after creating payment review for %s", review.Reference)
+	}
+	return &created[0], nil
+}
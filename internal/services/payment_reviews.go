@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// ListPaymentReviews returns payment reviews, oldest first so the queue is worked in
+// order, optionally filtered by status (open or resolved).
+func (s *SupabaseService) ListPaymentReviews(status string, limit, offset int) ([]models.PaymentReview, error) {
+	query := s.Client.From("payment_reviews").
+		Select("*", "", false)
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+
+	var reviews []models.PaymentReview
+	_, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&reviews)
+	if err != nil {
+		return nil, fmt.Errorf("error listing payment reviews: %w", err)
+	}
+	return reviews, nil
+}
+
+// ResolvePaymentReview closes an open payment review in one database call: crediting
+// credits the review's payment intent with the amount Paystack received, dismissing
+// fails the intent. It returns ErrPaymentReviewNotFound, ErrPaymentReviewResolved, or
+// ErrPaymentReviewNotCreditable for a charge with no intent or in another currency.
+func (s *SupabaseService) ResolvePaymentReview(reviewID int64, req models.ResolvePaymentReviewRequest, adminID string) (*models.PaymentReviewResolution, error) {
+	params := map[string]interface{}{
+		"p_review_id":    reviewID,
+		"p_action":       req.Action,
+		"p_admin_id":     nullIfEmpty(adminID),
+		"p_note":         req.Note,
+		"p_description":  fmt.Sprintf("Datacredit purchase credited on review (review %d)", reviewID),
+		"p_counterparty": ledgerCounterparties[models.OperationCreditPurchase].datacredit,
+	}
+
+	var resolution models.PaymentReviewResolution
+	if err := s.callRPC("resolve_payment_review", params, &resolution); err != nil {
+		return nil, fmt.Errorf("error resolving payment review %d: %w", reviewID, err)
+	}
+	return &resolution, nil
+}
+
+// ResolvePaymentReview resolves a payment review and, when it credited the user,
+// collects any refund debts from the credit as a normal purchase would.
+func (s *PaystackService) ResolvePaymentReview(reviewID int64, req models.ResolvePaymentReviewRequest, adminID string, supabaseService *SupabaseService) (*models.PaymentReviewResolution, error) {
+	resolution, err := supabaseService.ResolvePaymentReview(reviewID, req, adminID)
+	if err != nil {
+		return nil, err
+	}
+	if resolution.Credited && resolution.Intent != nil {
+		log.Printf("INFO: Payment review %d credited to UserID %s by admin %s", reviewID, resolution.Intent.UserID, adminID)
+		s.collectWalletDebts(resolution.Intent.UserID, supabaseService)
+	}
+	return resolution, nil
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/rpip/paystack-go"
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// payoutCurrency is the only currency payout accounts may be in: withdrawals are held
+// and transferred as NGN kobo.
+const payoutCurrency = "NGN"
+
+// checkPayoutCurrency returns ErrPayoutCurrency unless currency is payoutCurrency.
+func checkPayoutCurrency(currency string) error {
+	if currency != payoutCurrency {
+		return fmt.Errorf("%w: %s (only %s payouts are supported)", ErrPayoutCurrency, currency, payoutCurrency)
+	}
+	return nil
+}
+
+// ResolveBankAccount looks up the name on a bank account through Paystack's resolve API.
+func (s *PaystackService) ResolveBankAccount(accountNumber, bankCode string) (string, error) {
+	query := url.Values{}
+	query.Set("account_number", accountNumber)
+	query.Set("bank_code", bankCode)
+
+	var resolved struct {
+		AccountNumber string `json:"account_number"`
+		AccountName   string `json:"account_name"`
+	}
+	if err := s.Client.Call("GET", "/bank/resolve?"+query.Encode(), nil, &resolved); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrBankAccountUnresolved, paystackErrorMessage(err))
+	}
+	if resolved.AccountName == "" {
+		return "", fmt.Errorf("%w: Paystack returned no account name", ErrBankAccountUnresolved)
+	}
+	return resolved.AccountName, nil
+}
+
+// CreateTransferRecipient creates a Paystack NUBAN transfer recipient for a bank account
+// and returns its recipient code and the bank name Paystack reports.
+// paystack.TransferRecipient serializes its type field under the wrong key, so the
+// request is sent directly.
+func (s *PaystackService) CreateTransferRecipient(userID, accountName, accountNumber, bankCode, currency string) (recipientCode, bankName string, err error) {
+	recipientReq := map[string]interface{}{
+		"type":           "nuban",
+		"name":           accountName,
+		"account_number": accountNumber,
+		"bank_code":      bankCode,
+		"currency":       currency,
+		"metadata":       map[string]interface{}{"user_id": userID},
+	}
+
+	var recipient struct {
+		RecipientCode string `json:"recipient_code"`
+		Details       struct {
+			BankName string `json:"bank_name"`
+		} `json:"details"`
+	}
+	if err := s.Client.Call("POST", "/transferrecipient", recipientReq, &recipient); err != nil {
+		return "", "", fmt.Errorf("failed to create Paystack transfer recipient: %w", err)
+	}
+	if recipient.RecipientCode == "" {
+		return "", "", fmt.Errorf("could not extract recipient_code from Paystack response")
+	}
+	return recipient.RecipientCode, recipient.Details.BankName, nil
+}
+
+// DeleteTransferRecipient deactivates a Paystack transfer recipient.
+func (s *PaystackService) DeleteTransferRecipient(recipientCode string) error {
+	var resp map[string]interface{}
+	if err := s.Client.Call("DELETE", "/transferrecipient/"+url.PathEscape(recipientCode), nil, &resp); err != nil {
+		return fmt.Errorf("failed to delete Paystack transfer recipient %s: %w", recipientCode, err)
+	}
+	return nil
+}
+
+// AddPayoutAccount resolves a bank account through Paystack, creates a transfer recipient
+// for it and stores it as one of the user's payout accounts. The user's first account
+// becomes their default. Only NGN accounts can be added (ErrPayoutCurrency).
+func (s *PaystackService) AddPayoutAccount(userID string, req models.AddPayoutAccountRequest, supabaseService *SupabaseService) (*models.PayoutAccount, error) {
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = payoutCurrency
+	}
+	if err := checkPayoutCurrency(currency); err != nil {
+		return nil, err
+	}
+
+	existing, err := supabaseService.ListPayoutAccounts(userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range existing {
+		if account.BankCode == req.BankCode && account.AccountNumber == req.AccountNumber {
+			return nil, fmt.Errorf("%w: account %s at bank %s", ErrPayoutAccountExists, req.AccountNumber, req.BankCode)
+		}
+	}
+
+	accountName, err := s.ResolveBankAccount(req.AccountNumber, req.BankCode)
+	if err != nil {
+		return nil, err
+	}
+
+	recipientCode, bankName, err := s.CreateTransferRecipient(userID, accountName, req.AccountNumber, req.BankCode, currency)
+	if err != nil {
+		return nil, err
+	}
+
+	account := models.PayoutAccount{
+		UserID:        userID,
+		BankCode:      req.BankCode,
+		AccountNumber: req.AccountNumber,
+		AccountName:   accountName,
+		Currency:      currency,
+		RecipientCode: recipientCode,
+	}
+	if bankName != "" {
+		account.BankName = &bankName
+	}
+
+	created, err := supabaseService.CreatePayoutAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: Payout account %s added for UserID %s (recipient %s)", created.ID, userID, recipientCode)
+
+	if req.IsDefault || len(existing) == 0 {
+		return supabaseService.SetDefaultPayoutAccount(userID, created.ID)
+	}
+	return created, nil
+}
+
+// RemovePayoutAccount deletes one of the user's payout accounts and deactivates its
+// Paystack transfer recipient. If it was the default, the most recently added remaining
+// account becomes the default. Accounts with withdrawals still in progress are not
+// removed (ErrPayoutAccountInUse).
+func (s *PaystackService) RemovePayoutAccount(userID, accountID string, supabaseService *SupabaseService) error {
+	removed, err := supabaseService.DeletePayoutAccount(userID, accountID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.DeleteTransferRecipient(removed.RecipientCode); err != nil {
+		// The account is gone on our side, so the recipient can no longer be paid out to.
+		log.Printf("WARNING: %v", err)
+	}
+
+	if removed.IsDefault {
+		remaining, err := supabaseService.ListPayoutAccounts(userID)
+		if err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			if _, err := supabaseService.SetDefaultPayoutAccount(userID, remaining[0].ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CreatePayoutAccount stores a payout account.
+func (s *SupabaseService) CreatePayoutAccount(account models.PayoutAccount) (*models.PayoutAccount, error) {
+	insertData := map[string]interface{}{
+		"user_id":        account.UserID,
+		"bank_code":      account.BankCode,
+		"bank_name":      account.BankName,
+		"account_number": account.AccountNumber,
+		"account_name":   account.AccountName,
+		"currency":       account.Currency,
+		"recipient_code": account.RecipientCode,
+	}
+
+	var created []models.PayoutAccount
+	_, err := s.Client.From("payout_accounts").
+		Insert(insertData, false, "", "", "").
+		ExecuteTo(&created)
+	if isUniqueViolation(err) {
+		return nil, fmt.Errorf("%w: account %s at bank %s", ErrPayoutAccountExists, account.AccountNumber, account.BankCode)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error creating payout account for user %s: %w", account.UserID, err)
+	}
+	if len(created) == 0 {
+		return nil, fmt.Errorf("no data returned after creating payout account for user %s", account.UserID)
+	}
+	return &created[0], nil
+}
+
+// ListPayoutAccounts returns a user's payout accounts, most recently added first.
+func (s *SupabaseService) ListPayoutAccounts(userID string) ([]models.PayoutAccount, error) {
+	var accounts []models.PayoutAccount
+	_, err := s.Client.From("payout_accounts").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		ExecuteTo(&accounts)
+	if err != nil {
+		return nil, fmt.Errorf("error listing payout accounts for user %s: %w", userID, err)
+	}
+	return accounts, nil
+}
+
+// GetPayoutAccount fetches one of a user's payout accounts. Accounts belonging to
+// other users are reported as ErrPayoutAccountNotFound.
+func (s *SupabaseService) GetPayoutAccount(userID, accountID string) (*models.PayoutAccount, error) {
+	var accounts []models.PayoutAccount
+	_, err := s.Client.From("payout_accounts").
+		Select("*", "", false).
+		Eq("id", accountID).
+		Eq("user_id", userID).
+		ExecuteTo(&accounts)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching payout account %s: %w", accountID, err)
+	}
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPayoutAccountNotFound, accountID)
+	}
+	return &accounts[0], nil
+}
+
+// GetPayoutAccountForWithdrawal returns the payout account a withdrawal should go to:
+// the one named by accountID, or the user's default when accountID is empty.
+func (s *SupabaseService) GetPayoutAccountForWithdrawal(userID, accountID string) (*models.PayoutAccount, error) {
+	if accountID != "" {
+		return s.GetPayoutAccount(userID, accountID)
+	}
+
+	var accounts []models.PayoutAccount
+	_, err := s.Client.From("payout_accounts").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		Is("is_default", "true").
+		ExecuteTo(&accounts)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching default payout account for user %s: %w", userID, err)
+	}
+	if len(accounts) == 0 {
+		return nil, ErrNoPayoutAccount
+	}
+	return &accounts[0], nil
+}
+
+// SetDefaultPayoutAccount makes one of the user's payout accounts their default.
+func (s *SupabaseService) SetDefaultPayoutAccount(userID, accountID string) (*models.PayoutAccount, error) {
+	params := map[string]interface{}{
+		"p_user_id":    userID,
+		"p_account_id": accountID,
+	}
+
+	var account models.PayoutAccount
+	if err := s.callRPC("set_default_payout_account", params, &account); err != nil {
+		return nil, fmt.Errorf("error setting default payout account %s: %w", accountID, err)
+	}
+	return &account, nil
+}
+
+// DeletePayoutAccount deletes one of the user's payout accounts and returns it. It
+// returns ErrPayoutAccountInUse while a withdrawal that has not reached a final state
+// pays out to the account.
+func (s *SupabaseService) DeletePayoutAccount(userID, accountID string) (*models.PayoutAccount, error) {
+	params := map[string]interface{}{
+		"p_user_id":    userID,
+		"p_account_id": accountID,
+	}
+
+	var deleted models.PayoutAccount
+	if err := s.callRPC("delete_payout_account", params, &deleted); err != nil {
+		return nil, fmt.Errorf("error deleting payout account %s: %w", accountID, err)
+	}
+	return &deleted, nil
+}
+
+// paystackErrorMessage returns the message Paystack gave for a failed API call (see
+// paystackErrorTransport), or the error itself when it did not come from the Paystack API.
+func paystackErrorMessage(err error) string {
+	var apiErr *paystack.APIError
+	if errors.As(err, &apiErr) {
+		if message := apiErr.Header.Get(paystackMessageHeader); message != "" {
+			return message
+		}
+		if apiErr.Details.Message != "" {
+			return apiErr.Details.Message
+		}
+	}
+	return err.Error()
+}
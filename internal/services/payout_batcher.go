@@ -0,0 +1,77 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+)
+
+// maxBatchesPerRun caps how many payout batches one run sends, so a backlog is worked
+// off over several runs instead of in one long burst.
+const maxBatchesPerRun = 20
+
+// PayoutBatcher periodically sends queued withdrawals to Paystack as bulk transfers. It
+// only runs in payout-batching mode (a non-zero PayoutBatchInterval).
+type PayoutBatcher struct {
+	PaystackService *PaystackService
+	SupabaseService *SupabaseService
+	Interval        time.Duration
+	BatchSize       int
+}
+
+// NewPayoutBatcher creates a batcher using the interval and batch size from cfg.
+func NewPayoutBatcher(ps *PaystackService, ss *SupabaseService, cfg *config.Config) *PayoutBatcher {
+	return &PayoutBatcher{
+		PaystackService: ps,
+		SupabaseService: ss,
+		Interval:        cfg.PayoutBatchInterval,
+		BatchSize:       cfg.PayoutBatchSize,
+	}
+}
+
+// Run sends batches every Interval until ctx is cancelled. A zero Interval disables
+// batching: withdrawals then start their own transfers.
+func (b *PayoutBatcher) Run(ctx context.Context) {
+	if b.Interval <= 0 {
+		log.Println("INFO: Payout batching disabled; withdrawals are transferred individually.")
+		return
+	}
+	log.Printf("INFO: Payout batcher running every %s (up to %d withdrawal(s) per batch)", b.Interval, b.BatchSize)
+
+	ticker := time.NewTicker(b.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.RunOnce()
+		}
+	}
+}
+
+// RunOnce claims and sends batches until the queue is empty or maxBatchesPerRun is
+// reached, and returns how many batches were claimed.
+func (b *PayoutBatcher) RunOnce() int {
+	claimed := 0
+	for claimed < maxBatchesPerRun {
+		batch, withdrawals, err := b.SupabaseService.ClaimPayoutBatch(b.BatchSize)
+		if err != nil {
+			log.Printf("ERROR: Payout batcher failed to claim a batch: %v", err)
+			break
+		}
+		if batch == nil {
+			break
+		}
+		claimed++
+
+		if _, err := b.PaystackService.SendPayoutBatch(batch, withdrawals, b.SupabaseService); err != nil {
+			// The batch records the failure; stop so an outage is not hammered with batches.
+			log.Printf("ERROR: Payout batch %s was not sent: %v", batch.ID, err)
+			break
+		}
+	}
+	return claimed
+}
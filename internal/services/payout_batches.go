@@ -0,0 +1,211 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// payoutBatchingEnabled reports whether withdrawals are sent in scheduled bulk transfers
+// instead of one transfer each.
+func (s *PaystackService) payoutBatchingEnabled() bool {
+	return s.Cfg.PayoutBatchInterval > 0
+}
+
+// queueOrStartTransfer starts the transfer of a pending withdrawal, or in payout-batching
+// mode queues it for the PayoutBatcher.
+func (s *PaystackService) queueOrStartTransfer(withdrawal *models.Withdrawal, supabaseService *SupabaseService) (*models.Withdrawal, error) {
+	if !s.payoutBatchingEnabled() {
+		return s.startTransfer(withdrawal, supabaseService)
+	}
+
+	queued, updated, err := supabaseService.QueueWithdrawal(withdrawal.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !updated {
+		return nil, fmt.Errorf("%w: withdrawal %s is no longer pending", ErrWithdrawalStateConflict, withdrawal.ID)
+	}
+	log.Printf("INFO: Withdrawal %s for UserID %s queued for the next payout batch", queued.ID, queued.UserID)
+	return queued, nil
+}
+
+// SendPayoutBatch sends a claimed batch of pending withdrawals as one Paystack bulk
+// transfer and maps each transfer's result back to its withdrawal. If Paystack rejects
+// the request every withdrawal in it fails and its hold is released; if Paystack cannot
+// be reached or fails with a server error the withdrawals stay pending with their holds
+// for their webhooks (or the withdrawal sweeper) to settle, as for a single transfer.
+//
+// paystack.TransferService.MakeBulkTransfer posts to /transfer instead of /transfer/bulk,
+// so the request is sent directly. Bulk transfers require OTP to be disabled on the
+// Paystack account.
+func (s *PaystackService) SendPayoutBatch(batch *models.PayoutBatch, withdrawals []models.Withdrawal, supabaseService *SupabaseService) (*models.PayoutBatch, error) {
+	var sendable []models.Withdrawal
+	transfers := make([]map[string]interface{}, 0, len(withdrawals))
+	for i := range withdrawals {
+		withdrawal := withdrawals[i]
+		if withdrawal.RecipientCode == nil {
+			// create_withdrawal always stores the recipient; this would be a data bug.
+			log.Printf("ERROR: Withdrawal %s in payout batch %s has no transfer recipient; failing it", withdrawal.ID, batch.ID)
+			s.failWithdrawal(&withdrawals[i], "no transfer recipient", supabaseService)
+			continue
+		}
+		if err := checkPayoutCurrency(withdrawal.Currency); err != nil {
+			log.Printf("ERROR: Withdrawal %s in payout batch %s is in %s; failing it", withdrawal.ID, batch.ID, withdrawal.Currency)
+			s.failWithdrawal(&withdrawals[i], err.Error(), supabaseService)
+			continue
+		}
+		sendable = append(sendable, withdrawal)
+		transfers = append(transfers, map[string]interface{}{
+			"amount":    withdrawal.NetAmount(),
+			"recipient": *withdrawal.RecipientCode,
+			"reference": withdrawal.Reference,
+			"reason":    fmt.Sprintf("Datacredit withdrawal for UserID %s", withdrawal.UserID),
+		})
+	}
+
+	if len(transfers) == 0 {
+		return supabaseService.UpdatePayoutBatch(batch.ID, map[string]interface{}{
+			"status":         models.PayoutBatchRejected,
+			"failure_reason": "no withdrawal in the batch could be transferred",
+		})
+	}
+
+	bulkReq := map[string]interface{}{
+		"currency":  batch.Currency,
+		"source":    "balance",
+		"transfers": transfers,
+	}
+
+	log.Printf("Attempting Paystack bulk transfer: payout batch %s, %d transfer(s), %d kobo %s (fees %d kobo)", batch.ID, len(transfers), batch.TotalAmount, batch.Currency, batch.FeeTotal)
+	var response struct {
+		Data []models.PaystackBulkTransferResult `json:"data"`
+	}
+	if err := s.Client.Call("POST", "/transfer/bulk", bulkReq, &response); err != nil {
+		log.Printf("Error response from Paystack bulk transfer for payout batch %s: %v", batch.ID, err)
+		status := models.PayoutBatchUnconfirmed
+		if isPaystackRejection(err) {
+			// Paystack rejected the whole request, so no money moved: release every hold.
+			status = models.PayoutBatchRejected
+			for i := range sendable {
+				s.failWithdrawal(&sendable[i], paystackErrorMessage(err), supabaseService)
+			}
+		} else {
+			log.Printf("WARNING: Outcome of payout batch %s unknown; its withdrawals stay pending with their holds", batch.ID)
+		}
+		if _, updateErr := supabaseService.UpdatePayoutBatch(batch.ID, map[string]interface{}{
+			"status":         status,
+			"failure_reason": paystackErrorMessage(err),
+		}); updateErr != nil {
+			log.Printf("ERROR: Failed to record outcome of payout batch %s: %v", batch.ID, updateErr)
+		}
+		return nil, fmt.Errorf("failed to send Paystack bulk transfer for payout batch %s: %w", batch.ID, err)
+	}
+
+	results := make(map[string]models.PaystackBulkTransferResult, len(response.Data))
+	for _, result := range response.Data {
+		results[result.Reference] = result
+	}
+	for i := range sendable {
+		withdrawal := &sendable[i]
+		result, ok := results[withdrawal.Reference]
+		if !ok || result.TransferCode == "" {
+			// Keep the hold; the transfer webhook (matched by reference) will settle it.
+			log.Printf("WARNING: No transfer for withdrawal %s in Paystack's response to payout batch %s; it stays pending with its hold", withdrawal.ID, batch.ID)
+			continue
+		}
+		s.recordTransferStarted(withdrawal, result.TransferCode, 0, result.Status, supabaseService)
+	}
+
+	sent, err := supabaseService.UpdatePayoutBatch(batch.ID, map[string]interface{}{
+		"status":            models.PayoutBatchSent,
+		"paystack_response": response.Data,
+		"sent_at":           time.Now(),
+	})
+	if err != nil {
+		// Not fatal: the transfers were sent and each withdrawal records its own.
+		log.Printf("WARNING: Failed to record payout batch %s as sent: %v", batch.ID, err)
+		return batch, nil
+	}
+
+	log.Printf("INFO: Payout batch %s sent: %d of %d withdrawal(s) accepted by Paystack", batch.ID, len(response.Data), len(sendable))
+	return sent, nil
+}
+
+// ClaimPayoutBatch claims up to limit queued withdrawals, all in one currency, into a new
+// payout batch and moves them to pending. batch is nil when nothing is queued.
+func (s *SupabaseService) ClaimPayoutBatch(limit int) (batch *models.PayoutBatch, withdrawals []models.Withdrawal, err error) {
+	var claimed struct {
+		Batch       *models.PayoutBatch `json:"batch"`
+		Withdrawals []models.Withdrawal `json:"withdrawals"`
+	}
+	if err := s.callRPC("claim_payout_batch", map[string]interface{}{"p_limit": limit}, &claimed); err != nil {
+		return nil, nil, fmt.Errorf("error claiming payout batch: %w", err)
+	}
+	return claimed.Batch, claimed.Withdrawals, nil
+}
+
+// UpdatePayoutBatch applies a partial update to a payout batch.
+func (s *SupabaseService) UpdatePayoutBatch(id string, updateData map[string]interface{}) (*models.PayoutBatch, error) {
+	updateData["updated_at"] = time.Now()
+
+	var updated []models.PayoutBatch
+	_, err := s.Client.From("payout_batches").
+		Update(updateData, "", "").
+		Eq("id", id).
+		ExecuteTo(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("error updating payout batch %s: %w", id, err)
+	}
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPayoutBatchNotFound, id)
+	}
+	return &updated[0], nil
+}
+
+// ListPayoutBatches returns payout batches, newest first.
+func (s *SupabaseService) ListPayoutBatches(limit, offset int) ([]models.PayoutBatch, error) {
+	var batches []models.PayoutBatch
+	_, err := s.Client.From("payout_batches").
+		Select("*", "", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&batches)
+	if err != nil {
+		return nil, fmt.Errorf("error listing payout batches: %w", err)
+	}
+	return batches, nil
+}
+
+// GetPayoutBatch fetches a payout batch with its withdrawals.
+func (s *SupabaseService) GetPayoutBatch(id string) (*models.PayoutBatchDetail, error) {
+	var batches []models.PayoutBatch
+	_, err := s.Client.From("payout_batches").
+		Select("*", "", false).
+		Eq("id", id).
+		ExecuteTo(&batches)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching payout batch %s: %w", id, err)
+	}
+	if len(batches) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPayoutBatchNotFound, id)
+	}
+
+	var withdrawals []models.Withdrawal
+	_, err = s.Client.From("withdrawals").
+		Select("*", "", false).
+		Eq("payout_batch_id", id).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawals of payout batch %s: %w", id, err)
+	}
+	if withdrawals == nil {
+		withdrawals = []models.Withdrawal{}
+	}
+
+	return &models.PayoutBatchDetail{PayoutBatch: batches[0], Withdrawals: withdrawals}, nil
+}
@@ -2,11 +2,19 @@ package services
 
 import (
 	// Standard library imports
+	"bytes"
 	"crypto/hmac"
 	"crypto/sha512"
 	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	// Third-party imports
 	"github.com/rpip/paystack-go"
@@ -19,6 +27,7 @@ import (
 type PaystackService struct {
 	Client *paystack.Client
 	Cfg    *config.Config // Store config for access to secret key for webhooks etc.
+	banks  bankCache      // Paystack bank lists, see ListBanks
 }
 
 // NewPaystackService creates a new PaystackService
@@ -27,13 +36,36 @@ func NewPaystackService(cfg *config.Config) (*PaystackService, error) {
 		return nil, fmt.Errorf("Paystack secret key is not configured")
 	}
 	// The second argument to paystack.NewClient can be a custom http.Client, nil for default.
-	client := paystack.NewClient(cfg.PaystackSecretKey, nil)
+	// Ours keeps Paystack's error message where paystack.APIError can carry it.
+	client := paystack.NewClient(cfg.PaystackSecretKey, &http.Client{
+		Timeout:   60 * time.Second,
+		Transport: paystackErrorTransport{next: http.DefaultTransport},
+	})
 	log.Println("Successfully initialized Paystack client.")
 	return &PaystackService{Client: client, Cfg: cfg}, nil
 }
 
-// InitializePayment initializes a payment transaction with Paystack and returns raw response
-func (s *PaystackService) InitializePayment(req models.PaystackInitializeRequest, userID string, callbackURL string) (interface{}, error) {
+// InitializePayment initializes a payment transaction with Paystack and returns raw response.
+// A payment intent is stored under our own reference before Paystack is called, so the
+// eventual charge.success can be checked against what we expected to receive.
+func (s *PaystackService) InitializePayment(req models.PaystackInitializeRequest, userID string, callbackURL string, supabaseService *SupabaseService) (interface{}, error) {
+    reference, err := NewPaymentReference()
+    if err != nil {
+        return nil, err
+    }
+
+    _, err = supabaseService.CreatePaymentIntent(models.PaymentIntent{
+        Reference: reference,
+        UserID:    userID,
+        Email:     req.Email,
+        Amount:    int64(req.Amount),
+        Currency:  "NGN",
+        Purpose:   "datacredit_purchase",
+    })
+    if err != nil {
+        return nil, fmt.Errorf("failed to store payment intent: %w", err)
+    }
+
     metadata := map[string]interface{}{
         "user_id": userID,
         "custom_fields": []map[string]string{
@@ -46,6 +78,7 @@ func (s *PaystackService) InitializePayment(req models.PaystackInitializeRequest
         Email:       req.Email,
         Amount:      float32(req.Amount),
         Currency:    "NGN",
+        Reference:   reference,
         Metadata:    metadata,
         CallbackURL: callbackURL,
     }
@@ -53,25 +86,102 @@ func (s *PaystackService) InitializePayment(req models.PaystackInitializeRequest
     // Return the raw response for inspection
     resp, err := s.Client.Transaction.Initialize(transactionReq)
     if err != nil {
+        if _, uerr := supabaseService.TransitionPaymentIntent(reference, models.PaymentIntentInitialized, models.PaymentIntentFailed, map[string]interface{}{
+            "failure_reason": err.Error(),
+        }); uerr != nil {
+            log.Printf("WARNING: Failed to mark payment intent %s as failed: %v", reference, uerr)
+        }
         return nil, fmt.Errorf("error initializing Paystack transaction: %w", err)
     }
 
+    updateData := map[string]interface{}{}
+    if accessCode, ok := resp["access_code"].(string); ok {
+        updateData["access_code"] = accessCode
+    }
+    if authorizationURL, ok := resp["authorization_url"].(string); ok {
+        updateData["authorization_url"] = authorizationURL
+    }
+    if len(updateData) > 0 {
+        if _, err := supabaseService.UpdatePaymentIntent(reference, updateData); err != nil {
+            log.Printf("WARNING: Failed to store Paystack checkout details on payment intent %s: %v", reference, err)
+        }
+    }
+
     return resp, nil
 }
 
 // VerifyPayment verifies a payment transaction with Paystack using the transaction reference.
-func (s *PaystackService) VerifyPayment(reference string) (*paystack.Transaction, error) {
+// The response is decoded into models.PaystackTransactionData rather than paystack.Transaction,
+// whose Metadata field is typed as a string and cannot hold the object Paystack returns.
+func (s *PaystackService) VerifyPayment(reference string) (*models.PaystackTransactionData, error) {
 	if reference == "" {
 		return nil, fmt.Errorf("payment reference cannot be empty for verification")
 	}
-	transaction, err := s.Client.Transaction.Verify(reference)
-	if err != nil {
+	transaction := &models.PaystackTransactionData{}
+	if err := s.Client.Call("GET", "/transaction/verify/"+url.PathEscape(reference), nil, transaction); err != nil {
 		return nil, fmt.Errorf("error verifying Paystack transaction %s: %w", reference, err)
 	}
 	// Note: `transaction.Status` inside the returned object will indicate "success", "failed", "abandoned"
 	return transaction, nil
 }
 
+// paystackMessageHeader is the response header paystackErrorTransport copies Paystack's
+// error message into.
+const paystackMessageHeader = "X-Datagram-Paystack-Message"
+
+// paystackErrorTransport copies the message of Paystack error responses into a header.
+// paystack-go reads the response body before building its APIError, so
+// APIError.Details is always empty; APIError.Header is the only part of the response it
+// keeps. Without the message a 400 "Transaction reference not found" cannot be told
+// apart from any other 400.
+type paystackErrorTransport struct {
+	next http.RoundTripper
+}
+
+func (t paystackErrorTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode < http.StatusBadRequest {
+		return resp, err
+	}
+	body, readErr := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		return resp, nil
+	}
+	var payload struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &payload) == nil && payload.Message != "" {
+		resp.Header.Set(paystackMessageHeader, payload.Message)
+	}
+	return resp, nil
+}
+
+// isPaystackRejection reports whether err is Paystack definitively refusing a request, so
+// whatever it asked for did not happen: a 4xx response (other than 408 Request Timeout),
+// or a response with status false. Network errors and 5xx or gateway responses leave the
+// outcome unknown, since Paystack may have acted on the request before failing.
+func isPaystackRejection(err error) bool {
+	var apiErr *paystack.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.HTTPStatusCode < http.StatusInternalServerError && apiErr.HTTPStatusCode != http.StatusRequestTimeout
+}
+
+// isPaystackNotFound reports whether err is Paystack telling us a resource (e.g. a
+// transaction reference) does not exist: a 404, or the 400 Paystack's verify endpoints
+// answer unknown references with.
+func isPaystackNotFound(err error) bool {
+	var apiErr *paystack.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	return apiErr.HTTPStatusCode == http.StatusNotFound ||
+		(apiErr.HTTPStatusCode == http.StatusBadRequest && strings.Contains(strings.ToLower(paystackErrorMessage(err)), "not found"))
+}
+
 // VerifyWebhookSignature verifies the signature of an incoming Paystack webhook.
 // This is a CRITICAL security step.
 func (s *PaystackService) VerifyWebhookSignature(requestBody []byte, signatureFromHeader string) bool {
@@ -107,147 +217,351 @@ func (s *PaystackService) VerifyWebhookSignature(requestBody []byte, signatureFr
 }
 
 // ProcessSuccessfulPayment is called after a payment is verified (e.g., via webhook).
-// It involves updating user datacredit balance in Supabase.
+// The charge is matched against the payment intent stored at initialization; an unknown
+// reference or a mismatched amount, currency or user is queued for review (and
+// ErrPaymentQueuedForReview returned) instead of being credited. Crediting is idempotent
+// per intent, so calling this again for an already-credited reference is a no-op.
 func (s *PaystackService) ProcessSuccessfulPayment(transactionData models.PaystackTransactionData, supabaseService *SupabaseService) error {
 	log.Printf("Processing successful Paystack payment for reference: %s, Email: %s, Amount: %d kobo",
 		transactionData.Reference, transactionData.Customer.Email, transactionData.Amount)
 
-	var userID string
-	// Option 1: Get userID from Paystack metadata (best if you set it reliably)
-	if metadataUserID, ok := transactionData.Metadata["user_id"].(string); ok && metadataUserID != "" {
-		userID = metadataUserID
-	} else {
-		// Option 2: Fallback to find user by email (ensure email is unique in profiles table)
-		// This assumes a user profile already exists with this email.
-		log.Printf("user_id not found in Paystack metadata for ref: %s. Attempting fallback to email: %s", transactionData.Reference, transactionData.Customer.Email)
-		profile, err := supabaseService.GetUserByEmail(transactionData.Customer.Email)
-		if err != nil {
-			return fmt.Errorf("could not find user by email '%s' to credit after payment (Paystack ref: %s): %w",
-				transactionData.Customer.Email, transactionData.Reference, err)
-		}
-		userID = profile.ID
+	intent, err := supabaseService.GetPaymentIntent(transactionData.Reference)
+	if err != nil {
+		return fmt.Errorf("failed to look up payment intent for Paystack ref %s: %w", transactionData.Reference, err)
+	}
+
+	if reason := paymentReviewReason(intent, transactionData); reason != "" {
+		return s.queuePaymentForReview(reason, intent, transactionData, supabaseService)
 	}
 
-	if userID == "" {
-		return fmt.Errorf("unable to determine user ID for crediting from Paystack transaction %s", transactionData.Reference)
+	if intent.Status == models.PaymentIntentSucceeded {
+		log.Printf("Payment intent %s has already been credited; nothing to do.", intent.Reference)
+		return nil
+	}
+	if intent.Status == models.PaymentIntentUnderReview {
+		// Only an admin resolving its review may credit it (see ResolvePaymentReview).
+		return fmt.Errorf("%w: payment intent %s is awaiting review", ErrPaymentQueuedForReview, intent.Reference)
 	}
 
-	// Amount from Paystack is already in kobo, which is our unit for datacredit_balance.
-	datacreditToAdd := int64(transactionData.Amount)
 	opDescription := fmt.Sprintf("Datacredit purchase via Paystack (Ref: %s)", transactionData.Reference)
-	externalRef := transactionData.Reference
+	metadata := map[string]interface{}{
+		"paystack_transaction_id": transactionData.ID,
+		"paystack_reference":      transactionData.Reference,
+		"amount_kobo":             transactionData.Amount,
+		"currency":                transactionData.Currency,
+		"channel":                 transactionData.Channel,
+		"customer_email":          transactionData.Customer.Email,
+		"paid_at":                 transactionData.PaidAt,
+	}
 
-	_, err := supabaseService.UpdateDatacreditBalance(userID, datacreditToAdd, opDescription, &externalRef)
+	_, credited, err := supabaseService.CreditPaymentIntent(intent.Reference, int64(transactionData.Amount), transactionData.ID, opDescription, metadata)
 	if err != nil {
 		// This is a critical error. Payment received but crediting failed.
-		// Implement alerting or a retry mechanism with idempotency.
+		// The webhook event is recorded as failed, so Paystack's retry will attempt it again.
 		log.Printf("CRITICAL ERROR: Paystack payment %s received for user %s, but failed to update datacredit balance: %v",
-			transactionData.Reference, userID, err)
+			transactionData.Reference, intent.UserID, err)
 		return fmt.Errorf("failed to update user datacredit balance for UserID %s after payment %s: %w",
-			userID, transactionData.Reference, err)
+			intent.UserID, transactionData.Reference, err)
+	}
+
+	if !credited {
+		log.Printf("Payment intent %s was credited concurrently; nothing to do.", intent.Reference)
+		return nil
 	}
 
 	log.Printf("Successfully credited %d datacredit (kobo) to UserID %s for Paystack Ref: %s",
-		datacreditToAdd, userID, transactionData.Reference)
+		intent.Amount, intent.UserID, transactionData.Reference)
+
+	// Datacredit the user owes for refunds they had already spent is repaid first.
+	s.collectWalletDebts(intent.UserID, supabaseService)
 	return nil
 }
 
+// HandleChargeSuccess applies a successful charge and returns the result recorded for audit.
+// A charge queued for review is not an error here: retrying would not make it match its
+// payment intent, so the event is considered handled.
+func (s *PaystackService) HandleChargeSuccess(transactionData models.PaystackTransactionData, supabaseService *SupabaseService) (map[string]interface{}, error) {
+	err := s.ProcessSuccessfulPayment(transactionData, supabaseService)
+	if errors.Is(err, ErrPaymentQueuedForReview) {
+		return map[string]interface{}{"action": "queued_for_review", "reference": transactionData.Reference, "detail": err.Error()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"action": "credited", "reference": transactionData.Reference, "amount": transactionData.Amount}, nil
+}
+
+// ApplyChargeSuccess runs HandleChargeSuccess through the same processed-events claim the
+// charge.success webhook uses, so a charge is applied once no matter whether the webhook,
+// client verification or the sweeper sees it first. applied is false if it had already been handled.
+func (s *PaystackService) ApplyChargeSuccess(transactionData models.PaystackTransactionData, supabaseService *SupabaseService) (result map[string]interface{}, applied bool, err error) {
+	rawPayload, err := json.Marshal(transactionData)
+	if err != nil {
+		return nil, false, fmt.Errorf("error encoding Paystack transaction %s: %w", transactionData.Reference, err)
+	}
+	_, applied, result, err = supabaseService.ProcessEventOnce("charge.success", transactionData.Reference, rawPayload, func() (map[string]interface{}, error) {
+		return s.HandleChargeSuccess(transactionData, supabaseService)
+	})
+	return result, applied, err
+}
+
+// paymentReviewReason returns why a charge cannot be credited automatically against its
+// intent, or "" when it matches.
+func paymentReviewReason(intent *models.PaymentIntent, transactionData models.PaystackTransactionData) string {
+	switch {
+	case intent == nil:
+		return models.ReviewReasonUnknownReference
+	case int64(transactionData.Amount) != intent.Amount:
+		return models.ReviewReasonAmountMismatch
+	case !strings.EqualFold(transactionData.Currency, intent.Currency):
+		return models.ReviewReasonCurrencyMismatch
+	case intent.Status == models.PaymentIntentFailed:
+		return models.ReviewReasonIntentFailed
+	}
+	if metadataUserID, ok := transactionData.Metadata["user_id"].(string); ok && metadataUserID != intent.UserID {
+		return models.ReviewReasonUserMismatch
+	}
+	return ""
+}
+
+// queuePaymentForReview parks a charge in the payment review queue and flags its intent.
+func (s *PaystackService) queuePaymentForReview(reason string, intent *models.PaymentIntent, transactionData models.PaystackTransactionData, supabaseService *SupabaseService) error {
+	receivedAmount := int64(transactionData.Amount)
+	receivedCurrency := transactionData.Currency
+	review := models.PaymentReview{
+		Reference:        transactionData.Reference,
+		Reason:           reason,
+		ReceivedAmount:   &receivedAmount,
+		ReceivedCurrency: &receivedCurrency,
+		Payload: map[string]interface{}{
+			"paystack_transaction_id": transactionData.ID,
+			"status":                  transactionData.Status,
+			"customer_email":          transactionData.Customer.Email,
+			"metadata":                transactionData.Metadata,
+			"paid_at":                 transactionData.PaidAt,
+		},
+	}
+	if intent != nil {
+		review.IntentID = &intent.ID
+		review.UserID = &intent.UserID
+		review.ExpectedAmount = &intent.Amount
+		review.ExpectedCurrency = &intent.Currency
+	}
+
+	if _, err := supabaseService.CreatePaymentReview(review); err != nil {
+		return fmt.Errorf("failed to queue Paystack payment %s for review (%s): %w", transactionData.Reference, reason, err)
+	}
+	if intent != nil && models.CanTransitionPaymentIntent(intent.Status, models.PaymentIntentUnderReview) {
+		if _, err := supabaseService.TransitionPaymentIntent(intent.Reference, intent.Status, models.PaymentIntentUnderReview, nil); err != nil {
+			log.Printf("WARNING: Failed to flag payment intent %s as under review: %v", intent.Reference, err)
+		}
+	}
+
+	log.Printf("ALERT: Paystack payment %s queued for review instead of being credited: %s", transactionData.Reference, reason)
+	return fmt.Errorf("%w: %s (Paystack ref: %s)", ErrPaymentQueuedForReview, reason, transactionData.Reference)
+}
+
 // InitiateWithdrawal processes a withdrawal request by initiating a transfer via Paystack.
-func (s *PaystackService) InitiateWithdrawal(req models.WithdrawalRequest, supabaseService *SupabaseService) (string, error) {
-	// Amount in req.Amount is datacredit (kobo) to withdraw
+// The amount is held on the wallet before Paystack is called and only debited when the
+// transfer succeeds (see HandleTransferEvent), so it cannot be spent twice meanwhile.
+// Withdrawals that need admin approval (see withdrawalApprovalReason) are returned in
+// pending_approval with their hold, and transferred by ApproveWithdrawal. In payout-batching
+// mode the transfer is left to the PayoutBatcher.
+func (s *PaystackService) InitiateWithdrawal(req models.WithdrawalRequest, supabaseService *SupabaseService) (*models.Withdrawal, error) {
+	// Amount in req.Amount is datacredit (kobo) to withdraw. The fee is kept from it
+	// and the rest is transferred.
 	datacreditToWithdrawKobo := req.Amount
+	fee := s.WithdrawalFee(datacreditToWithdrawKobo)
+	if err := s.checkWithdrawalAmount(datacreditToWithdrawKobo, fee); err != nil {
+		return nil, err
+	}
 
-	// 1. Check user's datacredit balance from Supabase
-	wallet, err := supabaseService.GetOrCreateWallet(req.UserID)
+	// 1. Find the payout account: the one the user picked, or their default.
+	// Its Paystack transfer recipient was created when the account was added.
+	payoutAccount, err := supabaseService.GetPayoutAccountForWithdrawal(req.UserID, req.RecipientID)
 	if err != nil {
-		return "", fmt.Errorf("failed to get user wallet for withdrawal: %w", err)
-	}
-
-	if wallet.DatacreditBalance < datacreditToWithdrawKobo {
-		return "", fmt.Errorf("insufficient datacredit balance for withdrawal. Has: %d kobo, Wants: %d kobo", wallet.DatacreditBalance, datacreditToWithdrawKobo)
-	}
-
-	// 2. Create Transfer Recipient with Paystack (or retrieve existing one)
-	// This part needs your actual logic for managing recipients.
-	// For example, you might fetch bank details from the user's profile or a dedicated table.
-	// Let's assume you have a function to get/create a recipient code.
-	// For this example, we'll use a placeholder.
-	//
-	// Example:
-	// recipientDetails := &paystack.TransferRecipientRequest{
-	// 	Type: "nuban",
-	// 	Name: "User Full Name from Profile", // Fetch this
-	// 	AccountNumber: "USER_BANK_ACCOUNT_NUMBER", // Fetch this
-	// 	BankCode: "USER_BANK_CODE", // Fetch this (Paystack has API to list banks)
-	// 	Currency: "NGN",
-	// }
-	// recipient, err := s.Client.Transfer.CreateRecipient(recipientDetails)
-	// if err != nil || !recipient.Status {
-	// 	return "", fmt.Errorf("failed to create Paystack transfer recipient: %v - Message: %s", err, recipient.Message)
-	// }
-	// recipientCode := recipient.Data.RecipientCode
-	//
-	// For now, using a placeholder:
-	recipientCode := "RCP_PLACEHOLDER_RECIPIENT_CODE" // !! REPLACE WITH ACTUAL LOGIC !!
-	if recipientCode == "RCP_PLACEHOLDER_RECIPIENT_CODE" {
-		log.Println("WARNING: Using placeholder Paystack recipient code for withdrawal.")
-		// In a real app, you'd return an error or have robust recipient management.
-		// return "", fmt.Errorf("recipient code management not implemented")
-	}
-
-	// 3. Initiate Transfer with Paystack
-	transferReq := &paystack.TransferRequest{
-		Source:    "balance",                         // Transfer from your Paystack balance
-		Amount:    float32(datacreditToWithdrawKobo), // Amount in Kobo - corrected to float32
-		Recipient: recipientCode,
-		Reason:    fmt.Sprintf("Datacredit withdrawal for UserID %s", req.UserID),
-		Currency:  "NGN",
-		// Reference: You can generate a unique reference for this transfer for idempotency
-	}
-
-	log.Printf("Attempting Paystack transfer: UserID %s, Amount %d kobo, Recipient %s", req.UserID, datacreditToWithdrawKobo, recipientCode)
-	transferResponse, err := s.Client.Transfer.Initiate(transferReq)
+		return nil, err
+	}
+	if err := checkPayoutCurrency(payoutAccount.Currency); err != nil {
+		return nil, err
+	}
+	recipientCode := payoutAccount.RecipientCode
+
+	approvalReason, err := s.withdrawalApprovalReason(req.UserID, datacreditToWithdrawKobo, supabaseService)
 	if err != nil {
-		// This could be a network error or an error from Paystack API itself (e.g., bad request)
-		log.Printf("Error response from Paystack transfer initiation: %v", err)
-		return "", fmt.Errorf("failed to initiate Paystack transfer: %w", err)
+		return nil, err
+	}
+
+	// 2. Record the withdrawal and hold its amount on the wallet in one database call.
+	// This is also the balance check: it fails with ErrInsufficientDatacredit when the
+	// available (unheld) balance is too low, and ErrWithdrawalDailyLimit when the user
+	// has reached the daily limit.
+	reference, err := NewTransferReference()
+	if err != nil {
+		return nil, err
+	}
+	withdrawal, err := supabaseService.CreateWithdrawal(models.Withdrawal{
+		UserID:          req.UserID,
+		Amount:          datacreditToWithdrawKobo,
+		Fee:             fee,
+		Currency:        payoutAccount.Currency,
+		Reference:       reference,
+		RecipientCode:   &recipientCode,
+		PayoutAccountID: &payoutAccount.ID,
+		ApprovalReason:  nullIfEmpty(approvalReason),
+	}, s.Cfg.WithdrawalDailyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record withdrawal: %w", err)
 	}
 
-	// Check if the transfer initiation was successful
-	// The transfer response should contain transfer details
-	if transferResponse == nil {
-		return "", fmt.Errorf("received nil response from Paystack transfer initiation")
+	if withdrawal.Status == models.WithdrawalPendingApproval {
+		log.Printf("INFO: Withdrawal %s for UserID %s (%d kobo) awaits admin approval: %s", withdrawal.ID, req.UserID, datacreditToWithdrawKobo, approvalReason)
+		return withdrawal, nil
+	}
+
+	return s.queueOrStartTransfer(withdrawal, supabaseService)
+}
+
+// startTransfer initiates the Paystack transfer for a pending withdrawal whose amount
+// is already held on the wallet, and marks it processing.
+func (s *PaystackService) startTransfer(withdrawal *models.Withdrawal, supabaseService *SupabaseService) (*models.Withdrawal, error) {
+	if withdrawal.RecipientCode == nil {
+		return nil, fmt.Errorf("withdrawal %s has no transfer recipient", withdrawal.ID)
+	}
+	if err := checkPayoutCurrency(withdrawal.Currency); err != nil {
+		// The amount is NGN kobo; sending it in another currency would overpay.
+		s.failWithdrawal(withdrawal, err.Error(), supabaseService)
+		return nil, err
+	}
+	recipientCode := *withdrawal.RecipientCode
+	reference := withdrawal.Reference
+
+	// 1. Initiate Transfer with Paystack. paystack.TransferRequest has no reference field,
+	// so the request is sent directly.
+	transferReq := map[string]interface{}{
+		"source":    "balance",              // Transfer from your Paystack balance
+		"amount":    withdrawal.NetAmount(), // Amount in Kobo, after our fee
+		"recipient": recipientCode,
+		"reason":    fmt.Sprintf("Datacredit withdrawal for UserID %s", withdrawal.UserID),
+		"currency":  withdrawal.Currency,
+		"reference": reference, // Makes retries idempotent and lets webhooks find the withdrawal
+	}
+
+	log.Printf("Attempting Paystack transfer: UserID %s, Amount %d kobo (fee %d kobo), Recipient %s, Reference %s", withdrawal.UserID, withdrawal.NetAmount(), withdrawal.Fee, recipientCode, reference)
+	transferResponse := &paystack.Transfer{}
+	if err := s.Client.Call("POST", "/transfer", transferReq, transferResponse); err != nil {
+		log.Printf("Error response from Paystack transfer initiation: %v", err)
+		if isPaystackRejection(err) {
+			// Paystack rejected the transfer, so no money moved: release the hold.
+			s.failWithdrawal(withdrawal, paystackErrorMessage(err), supabaseService)
+		} else {
+			// A network error or a Paystack server error leaves us not knowing whether the
+			// transfer started. Keep the hold; the transfer webhook (matched by reference)
+			// will settle it.
+			log.Printf("WARNING: Outcome of Paystack transfer %s unknown; withdrawal %s stays pending with its hold", reference, withdrawal.ID)
+		}
+		return nil, fmt.Errorf("%w: %w", ErrTransferInitiation, err)
 	}
 
-	// Extract transfer code from the response
-	// Note: You may need to adjust this based on the actual structure of the Transfer type
-	// from the paystack-go library. Check the library documentation for the correct field names.
 	transferCode := transferResponse.TransferCode
 	if transferCode == "" {
-		return "", fmt.Errorf("could not extract transfer_code from Paystack response")
+		log.Printf("WARNING: No transfer_code in Paystack response for withdrawal %s; it stays pending with its hold", withdrawal.ID)
+		return nil, fmt.Errorf("could not extract transfer_code from Paystack response")
+	}
+
+	log.Printf("Paystack transfer successfully initiated. Transfer Code: %s, Status: %s", transferCode, transferResponse.Status)
+
+	// 2. Mark the withdrawal processing (or awaiting OTP). The funds stay held until the
+	// transfer.success webhook captures them (or transfer.failed releases them).
+	withdrawal = s.recordTransferStarted(withdrawal, transferCode, int64(transferResponse.ID), transferResponse.Status, supabaseService)
+
+	log.Printf("Datacredit held for UserID %s pending Paystack transfer %s (withdrawal %s)", withdrawal.UserID, transferCode, withdrawal.ID)
+	return withdrawal, nil
+}
+
+// recordTransferStarted marks a pending withdrawal whose transfer Paystack accepted as
+// processing, or as awaiting OTP when OTP is enabled on the Paystack account: the
+// transfer is then not sent until an admin finalizes it (see FinalizeWithdrawalTransfer).
+// Failing to record it is not fatal, as the webhook finds the withdrawal by our reference.
+func (s *PaystackService) recordTransferStarted(withdrawal *models.Withdrawal, transferCode string, transferID int64, paystackStatus string, supabaseService *SupabaseService) *models.Withdrawal {
+	markTransferStarted := supabaseService.MarkWithdrawalProcessing
+	if paystackStatus == paystackTransferStatusOTP {
+		markTransferStarted = supabaseService.MarkWithdrawalAwaitingOTP
+	}
+	started, updated, err := markTransferStarted(withdrawal.ID, transferCode, transferID)
+	if err != nil {
+		log.Printf("WARNING: Failed to record transfer %s on withdrawal %s: %v", transferCode, withdrawal.ID, err)
+	} else if !updated {
+		log.Printf("INFO: Withdrawal %s was settled by its webhook before the transfer code was recorded", withdrawal.ID)
+	} else {
+		withdrawal = started
+	}
+	withdrawal.TransferCode = &transferCode
+	return withdrawal
+}
+
+// failWithdrawal marks a withdrawal whose transfer Paystack rejected as failed and
+// releases its hold. Nothing has been debited at that point.
+func (s *PaystackService) failWithdrawal(withdrawal *models.Withdrawal, reason string, supabaseService *SupabaseService) {
+	if _, _, err := supabaseService.SettleWithdrawal(withdrawal.ID, "", "", TransferOutcomeFailed, reason); err != nil {
+		log.Printf("ERROR: Failed to release hold of rejected withdrawal %s: %v", withdrawal.ID, err)
 	}
+}
 
-	log.Printf("Paystack transfer successfully initiated. Transfer Code: %s", transferCode)
+// ApplyTransferEvent runs HandleTransferEvent through the same processed-events claim the
+// transfer webhooks use (keyed on our transfer reference), so a transfer outcome is applied
+// once whether the webhook or reconciliation sees it first. applied is false if it had
+// already been handled.
+func (s *PaystackService) ApplyTransferEvent(event string, transferData models.PaystackTransferData, supabaseService *SupabaseService) (result map[string]interface{}, applied bool, err error) {
+	rawPayload, err := json.Marshal(transferData)
+	if err != nil {
+		return nil, false, fmt.Errorf("error encoding Paystack transfer %s: %w", transferData.Reference, err)
+	}
+	_, applied, result, err = supabaseService.ProcessEventOnce(event, transferData.Reference, rawPayload, func() (map[string]interface{}, error) {
+		return s.HandleTransferEvent(event, transferData, supabaseService)
+	})
+	return result, applied, err
+}
 
-	// 4. If Paystack transfer initiation is successful, debit user's app credits in Supabase.
-	// IMPORTANT: The most robust approach is to wait for a 'transfer.success' webhook from Paystack
-	// before debiting the user's balance in your system. This prevents issues if the transfer
-	// is initiated but fails later for reasons Paystack can only determine asynchronously.
-	//
-	// For this example, we'll debit immediately after successful *initiation*.
-	// Be aware of the risks and implement a reconciliation process if needed.
-	opDescription := fmt.Sprintf("Datacredit withdrawal to bank (Paystack Transfer Code: %s)", transferCode)
+// HandleTransferEvent applies a transfer.success, transfer.failed or transfer.reversed
+// event to its withdrawal and returns the result recorded for audit. A success for a
+// withdrawal that already failed is flagged on the withdrawal and alerted on, with the
+// action "succeeded_after_failure", since its user was paid without being debited.
+func (s *PaystackService) HandleTransferEvent(event string, transferData models.PaystackTransferData, supabaseService *SupabaseService) (map[string]interface{}, error) {
+	outcome := strings.TrimPrefix(event, "transfer.")
+	reason := transferData.Reason
+	if outcome != TransferOutcomeSuccess && transferData.Failures != nil {
+		reason = fmt.Sprintf("%s (failures: %v)", reason, transferData.Failures)
+	}
 
-	_, err = supabaseService.UpdateDatacreditBalance(req.UserID, -datacreditToWithdrawKobo, opDescription, &transferCode) // Negative amount
+	withdrawal, changed, err := supabaseService.SettleWithdrawal("", transferData.Reference, transferData.TransferCode, outcome, reason)
 	if err != nil {
-		log.Printf("CRITICAL ERROR: Initiated Paystack transfer %s but failed to debit datacredit for UserID %s: %v", transferCode, req.UserID, err)
-		// What to do here? The money might be on its way via Paystack.
-		// - Log for manual reconciliation.
-		// - Attempt to flag the transaction.
-		// - Depending on Paystack's capabilities, see if an initiated transfer can be cancelled (unlikely for most).
-		return "", fmt.Errorf("initiated Paystack transfer (%s) but failed to debit user credits. Error: %w", transferCode, err)
+		return nil, err
 	}
 
-	log.Printf("Datacredit debited for UserID %s after Paystack transfer initiation. Transfer Code: %s", req.UserID, transferCode)
-	return transferCode, nil
+	result := map[string]interface{}{
+		"withdrawal_id": withdrawal.ID,
+		"status":        withdrawal.Status,
+		"transfer_code": transferData.TransferCode,
+	}
+	switch {
+	case !changed && outcome == TransferOutcomeSuccess && withdrawal.PaidAfterFailureAt != nil:
+		log.Printf("ALERT: Paystack transfer %s (reference %s) succeeded after withdrawal %s was %s and its hold released; UserID %s was paid %d kobo without being debited. Recover the funds or debit the user manually.",
+			transferData.TransferCode, transferData.Reference, withdrawal.ID, withdrawal.Status, withdrawal.UserID, withdrawal.NetAmount())
+		result["action"] = "succeeded_after_failure"
+	case !changed:
+		log.Printf("INFO: Withdrawal %s already settled as %s; %s ignored", withdrawal.ID, withdrawal.Status, event)
+		result["action"] = "already_settled"
+	case outcome == TransferOutcomeSuccess:
+		log.Printf("INFO: Withdrawal %s completed (Transfer Code: %s)", withdrawal.ID, transferData.TransferCode)
+		result["action"] = "completed"
+	case withdrawal.ReversalJournalEntryID != nil:
+		// The funds had already been debited, so they were credited back.
+		log.Printf("INFO: Withdrawal %s %s; %d kobo credited back to UserID %s", withdrawal.ID, outcome, withdrawal.Amount, withdrawal.UserID)
+		result["action"] = "recredited"
+	default:
+		log.Printf("INFO: Withdrawal %s %s; %d kobo hold released for UserID %s", withdrawal.ID, outcome, withdrawal.Amount, withdrawal.UserID)
+		result["action"] = "hold_released"
+	}
+	return result, nil
 }
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+func TestPaymentReviewReason(t *testing.T) {
+	intent := func(status string) *models.PaymentIntent {
+		return &models.PaymentIntent{Reference: "ref_1", UserID: "user-1", Amount: 50000, Currency: "NGN", Status: status}
+	}
+	charge := func(amount int, currency string, metadata map[string]interface{}) models.PaystackTransactionData {
+		return models.PaystackTransactionData{Reference: "ref_1", Amount: amount, Currency: currency, Metadata: metadata}
+	}
+	tests := []struct {
+		name   string
+		intent *models.PaymentIntent
+		charge models.PaystackTransactionData
+		want   string
+	}{
+		{"match", intent(models.PaymentIntentInitialized), charge(50000, "NGN", map[string]interface{}{"user_id": "user-1"}), ""},
+		{"currency case ignored", intent(models.PaymentIntentInitialized), charge(50000, "ngn", nil), ""},
+		{"no user in metadata", intent(models.PaymentIntentInitialized), charge(50000, "NGN", nil), ""},
+		{"unknown reference", nil, charge(50000, "NGN", nil), models.ReviewReasonUnknownReference},
+		{"amount mismatch", intent(models.PaymentIntentInitialized), charge(40000, "NGN", nil), models.ReviewReasonAmountMismatch},
+		{"currency mismatch", intent(models.PaymentIntentInitialized), charge(50000, "USD", nil), models.ReviewReasonCurrencyMismatch},
+		{"failed intent", intent(models.PaymentIntentFailed), charge(50000, "NGN", nil), models.ReviewReasonIntentFailed},
+		{"user mismatch", intent(models.PaymentIntentInitialized), charge(50000, "NGN", map[string]interface{}{"user_id": "user-2"}), models.ReviewReasonUserMismatch},
+		{"amount checked before user", intent(models.PaymentIntentInitialized), charge(40000, "NGN", map[string]interface{}{"user_id": "user-2"}), models.ReviewReasonAmountMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paymentReviewReason(tt.intent, tt.charge); got != tt.want {
+				t.Errorf("paymentReviewReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// usernamePattern is what a username may contain.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// Username length limits, checked after surrounding spaces are trimmed.
+const (
+	usernameMinLength = 3
+	usernameMaxLength = 30
+)
+
+// UpdateUserProfile applies a profile update for the user and returns the updated profile.
+// It returns ErrInvalidProfile for invalid fields, ErrUsernameTaken when another user has
+// the username (compared regardless of case) and ErrProfileNotFound when the user has no
+// profile.
+func (s *SupabaseService) UpdateUserProfile(userID string, req models.UpdateProfileRequest) (*models.Profile, error) {
+	updateData := map[string]interface{}{}
+
+	if req.Name != nil {
+		updateData["name"] = nullIfEmpty(strings.TrimSpace(*req.Name))
+	}
+	if req.ImageURL != nil {
+		imageURL := strings.TrimSpace(*req.ImageURL)
+		if imageURL != "" {
+			parsed, err := url.Parse(imageURL)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				return nil, fmt.Errorf("%w: image_url must be an http or https URL", ErrInvalidProfile)
+			}
+		}
+		updateData["image_url"] = nullIfEmpty(imageURL)
+	}
+	if req.Username != nil {
+		username := strings.TrimSpace(*req.Username)
+		if len(username) < usernameMinLength || len(username) > usernameMaxLength {
+			return nil, fmt.Errorf("%w: username must be %d to %d characters", ErrInvalidProfile, usernameMinLength, usernameMaxLength)
+		}
+		if !usernamePattern.MatchString(username) {
+			return nil, fmt.Errorf("%w: username may only contain letters, digits, '_' and '.'", ErrInvalidProfile)
+		}
+		taken, err := s.usernameTaken(username, userID)
+		if err != nil {
+			return nil, err
+		}
+		if taken {
+			return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, username)
+		}
+		updateData["username"] = username
+	}
+
+	if len(updateData) == 0 {
+		return s.GetUserProfile(userID)
+	}
+	updateData["updated_at"] = time.Now()
+
+	var profiles []models.Profile
+	_, err := s.Client.From("profiles").
+		Update(updateData, "", "").
+		Eq("id", userID).
+		ExecuteTo(&profiles)
+	if err != nil {
+		if isUniqueViolation(err) {
+			// Another user claimed the username since it was checked.
+			return nil, fmt.Errorf("%w: %s", ErrUsernameTaken, *req.Username)
+		}
+		return nil, fmt.Errorf("error updating profile for user %s: %w", userID, err)
+	}
+	if len(profiles) == 0 {
+		return nil, fmt.Errorf("%w for user %s", ErrProfileNotFound, userID)
+	}
+	return &profiles[0], nil
+}
+
+// usernameTaken reports whether a user other than userID has the username, ignoring case.
+func (s *SupabaseService) usernameTaken(username, userID string) (bool, error) {
+	// '_' is a LIKE wildcard; usernamePattern rules out the others.
+	pattern := strings.ReplaceAll(username, "_", `\_`)
+
+	var profiles []models.Profile
+	_, err := s.Client.From("profiles").
+		Select("id", "", false).
+		Ilike("username", pattern).
+		Neq("id", userID).
+		Limit(1, "").
+		ExecuteTo(&profiles)
+	if err != nil {
+		return false, fmt.Errorf("error checking username %s: %w", username, err)
+	}
+	return len(profiles) > 0, nil
+}
+
+// GetWalletBalance returns the user's wallet balances, creating an empty wallet on first use.
+func (s *SupabaseService) GetWalletBalance(userID string) (*models.WalletBalance, error) {
+	wallet, err := s.GetOrCreateWallet(userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.WalletBalance{
+		UserID:              wallet.UserID,
+		DatacreditBalance:   wallet.DatacreditBalance,
+		HeldDatacredit:      wallet.HeldDatacredit,
+		AvailableDatacredit: wallet.AvailableDatacredit(),
+		DatabyteBalance:     wallet.DatabyteBalance,
+		UpdatedAt:           wallet.UpdatedAt,
+	}, nil
+}
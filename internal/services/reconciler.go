@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// reconcileSettleMargin keeps the most recent records out of a scheduled run, so charges
+// and transfers whose webhooks are still in flight are not reported.
+const reconcileSettleMargin = 10 * time.Minute
+
+// Reconciler periodically reconciles the last Window of Paystack activity with our records.
+type Reconciler struct {
+	PaystackService *PaystackService
+	SupabaseService *SupabaseService
+	Interval        time.Duration
+	Window          time.Duration
+	AutoFix         bool
+}
+
+// NewReconciler creates a reconciler using the interval, window and auto-fix setting from cfg.
+func NewReconciler(ps *PaystackService, ss *SupabaseService, cfg *config.Config) *Reconciler {
+	return &Reconciler{
+		PaystackService: ps,
+		SupabaseService: ss,
+		Interval:        cfg.ReconciliationInterval,
+		Window:          cfg.ReconciliationWindow,
+		AutoFix:         cfg.ReconciliationAutoFix,
+	}
+}
+
+// Run reconciles every Interval until ctx is cancelled. A zero Interval disables the
+// schedule; cmd/reconcile can still be run by hand.
+func (r *Reconciler) Run(ctx context.Context) {
+	if r.Interval <= 0 {
+		log.Println("INFO: Scheduled reconciliation disabled.")
+		return
+	}
+	log.Printf("INFO: Reconciler running every %s over the last %s (auto-fix: %t)", r.Interval, r.Window, r.AutoFix)
+
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.RunOnce()
+		}
+	}
+}
+
+// RunOnce reconciles the Window ending reconcileSettleMargin ago and logs the report.
+func (r *Reconciler) RunOnce() *models.ReconciliationReport {
+	to := time.Now().Add(-reconcileSettleMargin)
+	report, err := r.PaystackService.Reconcile(to.Add(-r.Window), to, r.AutoFix, r.SupabaseService)
+	if err != nil {
+		log.Printf("ERROR: Reconciliation failed: %v", err)
+		return nil
+	}
+
+	log.Printf("INFO: Reconciled %d Paystack charge(s) and %d transfer(s) with %d payment intent(s) and %d withdrawal(s): %d discrepancy(ies), %d fixed",
+		report.PaystackTransactions, report.PaystackTransfers, report.PaymentIntents, report.Withdrawals, len(report.Discrepancies), report.Fixed)
+	for _, d := range report.Discrepancies {
+		if d.Fixed {
+			continue
+		}
+		log.Printf("WARNING: Reconciliation: %s for %s: %s", d.Kind, d.Reference, d.Detail)
+	}
+	return report
+}
@@ -0,0 +1,575 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// Page sizes used when reconciling. Paystack lists at most 100 records per page and
+// PostgREST returns at most 1000 rows per request by default.
+const (
+	paystackListPageSize = 100
+	recordsPageSize      = 1000
+	referencesPerQuery   = 100
+)
+
+// Reconcile compares Paystack's successful charges and transfers created between from and
+// to with our payment intents, transaction log and withdrawals, and reports where they
+// disagree. With autoFix, the safe cases are repaired by replaying the Paystack event that
+// was missed, through the same idempotent paths the webhooks use: successful charges that
+// were never credited, and transfers whose outcome was never applied to their withdrawal.
+// Everything else is only reported.
+func (s *PaystackService) Reconcile(from, to time.Time, autoFix bool, supabaseService *SupabaseService) (*models.ReconciliationReport, error) {
+	report := &models.ReconciliationReport{
+		From:          from,
+		To:            to,
+		GeneratedAt:   time.Now(),
+		AutoFix:       autoFix,
+		Discrepancies: []models.ReconciliationDiscrepancy{},
+	}
+
+	if err := s.reconcilePayments(report, supabaseService); err != nil {
+		return nil, err
+	}
+	if err := s.reconcileTransfers(report, supabaseService); err != nil {
+		return nil, err
+	}
+
+	if autoFix {
+		for i := range report.Discrepancies {
+			if s.fixDiscrepancy(&report.Discrepancies[i], supabaseService) {
+				report.Fixed++
+			}
+		}
+	}
+	return report, nil
+}
+
+// reconcilePayments checks successful Paystack charges against payment intents and the
+// transaction log, and credited intents against Paystack.
+func (s *PaystackService) reconcilePayments(report *models.ReconciliationReport, supabaseService *SupabaseService) error {
+	transactions, err := s.listPaystackTransactions(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	intents, err := supabaseService.ListPaymentIntentsCreatedBetween(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	report.PaystackTransactions = len(transactions)
+	report.PaymentIntents = len(intents)
+
+	intentsByRef := make(map[string]*models.PaymentIntent, len(intents))
+	for i := range intents {
+		intentsByRef[intents[i].Reference] = &intents[i]
+	}
+
+	charged := make(map[string]bool, len(transactions))
+	for _, tx := range transactions {
+		charged[tx.Reference] = true
+		paystackAmount := tx.Amount
+
+		intent, ok := intentsByRef[tx.Reference]
+		if !ok {
+			// The intent may have been created just before the range.
+			if intent, err = supabaseService.GetPaymentIntent(tx.Reference); err != nil {
+				return err
+			}
+		}
+		if intent == nil {
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyUnknownPayment,
+				Reference:      tx.Reference,
+				PaystackStatus: tx.Status,
+				PaystackAmount: &paystackAmount,
+				Detail:         "successful Paystack charge has no payment intent",
+			})
+			continue
+		}
+
+		recordedAmount := intent.Amount
+		switch {
+		case intent.Status == models.PaymentIntentSucceeded:
+			if intent.PaidAmount != nil && *intent.PaidAmount != tx.Amount {
+				recordedAmount = *intent.PaidAmount
+				report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+					Kind:           models.DiscrepancyAmountMismatch,
+					Reference:      tx.Reference,
+					PaystackStatus: tx.Status,
+					PaystackAmount: &paystackAmount,
+					RecordedStatus: intent.Status,
+					RecordedAmount: &recordedAmount,
+					Detail:         "paid amount recorded on the intent differs from Paystack's",
+				})
+			}
+		case tx.Amount != intent.Amount:
+			// Crediting would only queue it for review; leave it to an admin.
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyAmountMismatch,
+				Reference:      tx.Reference,
+				PaystackStatus: tx.Status,
+				PaystackAmount: &paystackAmount,
+				RecordedStatus: intent.Status,
+				RecordedAmount: &recordedAmount,
+				Detail:         "successful charge amount differs from the payment intent; not credited",
+			})
+		case intent.Status == models.PaymentIntentUnderReview:
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyMissingCredit,
+				Reference:      tx.Reference,
+				PaystackStatus: tx.Status,
+				PaystackAmount: &paystackAmount,
+				RecordedStatus: intent.Status,
+				RecordedAmount: &recordedAmount,
+				Detail:         "successful charge is in the payment review queue",
+			})
+		default:
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyMissingCredit,
+				Reference:      tx.Reference,
+				PaystackStatus: tx.Status,
+				PaystackAmount: &paystackAmount,
+				RecordedStatus: intent.Status,
+				RecordedAmount: &recordedAmount,
+				Detail:         "successful charge was never credited",
+				Fixable:        true,
+			})
+		}
+	}
+
+	var credited []*models.PaymentIntent
+	for i := range intents {
+		if intents[i].Status == models.PaymentIntentSucceeded {
+			credited = append(credited, &intents[i])
+		}
+	}
+	for _, intent := range credited {
+		if charged[intent.Reference] {
+			continue
+		}
+		// Paystack lists charges by creation time, so verify before calling it orphaned.
+		transaction, err := s.VerifyPayment(intent.Reference)
+		if err == nil && transaction.Status == "success" {
+			continue
+		}
+		recordedAmount := intent.Amount
+		d := models.ReconciliationDiscrepancy{
+			Kind:           models.DiscrepancyOrphanedCredit,
+			Reference:      intent.Reference,
+			RecordedStatus: intent.Status,
+			RecordedAmount: &recordedAmount,
+			Detail:         "intent was credited but Paystack has no successful charge for it",
+		}
+		if err == nil {
+			paystackAmount := int64(transaction.Amount)
+			d.PaystackStatus = transaction.Status
+			d.PaystackAmount = &paystackAmount
+		} else if !isPaystackNotFound(err) {
+			log.Printf("WARNING: Reconciliation could not verify payment intent %s: %v", intent.Reference, err)
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, d)
+	}
+
+	return s.reconcileTransactionLog(report, credited, supabaseService)
+}
+
+// reconcileTransactionLog checks that every credited intent has its credit_purchase
+// transaction, for the intent's amount.
+func (s *PaystackService) reconcileTransactionLog(report *models.ReconciliationReport, credited []*models.PaymentIntent, supabaseService *SupabaseService) error {
+	references := make([]string, len(credited))
+	for i, intent := range credited {
+		references[i] = intent.Reference
+	}
+	transactions, err := supabaseService.ListTransactionsByExternalRefs(models.OperationCreditPurchase, references)
+	if err != nil {
+		return err
+	}
+
+	logged := make(map[string]int64, len(transactions))
+	for _, tx := range transactions {
+		if tx.ExternalReferenceID != nil {
+			logged[*tx.ExternalReferenceID] += tx.Amount
+		}
+	}
+	for _, intent := range credited {
+		recordedAmount := intent.Amount
+		amount, ok := logged[intent.Reference]
+		switch {
+		case !ok:
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyMissingTransactionLog,
+				Reference:      intent.Reference,
+				RecordedStatus: intent.Status,
+				RecordedAmount: &recordedAmount,
+				Detail:         "intent was credited but no credit_purchase transaction was logged",
+			})
+		case amount != intent.Amount:
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyAmountMismatch,
+				Reference:      intent.Reference,
+				RecordedStatus: intent.Status,
+				RecordedAmount: &recordedAmount,
+				Detail:         fmt.Sprintf("credit_purchase transactions total %d kobo, not the intent's amount", amount),
+			})
+		}
+	}
+	return nil
+}
+
+// reconcileTransfers checks Paystack transfers against withdrawals, and withdrawals sent
+// to Paystack against its transfers.
+func (s *PaystackService) reconcileTransfers(report *models.ReconciliationReport, supabaseService *SupabaseService) error {
+	transfers, err := s.listPaystackTransfers(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	withdrawals, err := supabaseService.ListWithdrawalsCreatedBetween(report.From, report.To)
+	if err != nil {
+		return err
+	}
+	report.PaystackTransfers = len(transfers)
+	report.Withdrawals = len(withdrawals)
+
+	withdrawalsByRef := make(map[string]*models.Withdrawal, len(withdrawals))
+	for i := range withdrawals {
+		withdrawalsByRef[withdrawals[i].Reference] = &withdrawals[i]
+	}
+
+	listed := make(map[string]bool, len(transfers))
+	for _, transfer := range transfers {
+		listed[transfer.Reference] = true
+
+		withdrawal, ok := withdrawalsByRef[transfer.Reference]
+		if !ok {
+			// The withdrawal may have been created just before the range.
+			if withdrawal, err = supabaseService.GetWithdrawalByReference(transfer.Reference); err != nil {
+				return err
+			}
+		}
+		if withdrawal == nil {
+			paystackAmount := transfer.Amount
+			report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+				Kind:           models.DiscrepancyUnknownTransfer,
+				Reference:      transfer.Reference,
+				PaystackStatus: transfer.Status,
+				PaystackAmount: &paystackAmount,
+				Detail:         fmt.Sprintf("Paystack transfer %s has no withdrawal", transfer.TransferCode),
+			})
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, transferDiscrepancies(transfer, withdrawal)...)
+	}
+
+	for i := range withdrawals {
+		withdrawal := &withdrawals[i]
+		if listed[withdrawal.Reference] || !withdrawalSentToPaystack(withdrawal) {
+			continue
+		}
+		// Paystack lists transfers by creation time, so look it up before calling it orphaned.
+		transfer, err := s.verifyTransfer(withdrawal.Reference)
+		if err == nil {
+			report.Discrepancies = append(report.Discrepancies, transferDiscrepancies(*transfer, withdrawal)...)
+			continue
+		}
+		if !isPaystackNotFound(err) {
+			log.Printf("WARNING: Reconciliation could not verify transfer %s: %v", withdrawal.Reference, err)
+			continue
+		}
+		if withdrawal.Status == models.WithdrawalPending && time.Since(withdrawal.CreatedAt) < time.Hour {
+			// Its transfer may simply not have been started yet.
+			continue
+		}
+		recordedAmount := withdrawal.NetAmount()
+		report.Discrepancies = append(report.Discrepancies, models.ReconciliationDiscrepancy{
+			Kind:           models.DiscrepancyOrphanedWithdrawal,
+			Reference:      withdrawal.Reference,
+			RecordedStatus: withdrawal.Status,
+			RecordedAmount: &recordedAmount,
+			Detail:         "Paystack has no transfer for this withdrawal",
+		})
+	}
+	return nil
+}
+
+// withdrawalSentToPaystack reports whether a withdrawal's transfer should exist at Paystack.
+// Pending withdrawals are included because a transfer whose request timed out may exist.
+func withdrawalSentToPaystack(withdrawal *models.Withdrawal) bool {
+	switch withdrawal.Status {
+	case models.WithdrawalPending, models.WithdrawalAwaitingOTP, models.WithdrawalProcessing, models.WithdrawalCompleted, models.WithdrawalReversed:
+		return true
+	default:
+		return false
+	}
+}
+
+// transferDiscrepancies compares a Paystack transfer with its withdrawal. A final Paystack
+// status the withdrawal has not caught up with is fixable by replaying the transfer event.
+func transferDiscrepancies(transfer models.PaystackTransferData, withdrawal *models.Withdrawal) []models.ReconciliationDiscrepancy {
+	var found []models.ReconciliationDiscrepancy
+	paystackAmount := transfer.Amount
+	recordedAmount := withdrawal.NetAmount()
+
+	if transfer.Amount != withdrawal.NetAmount() {
+		found = append(found, models.ReconciliationDiscrepancy{
+			Kind:           models.DiscrepancyAmountMismatch,
+			Reference:      transfer.Reference,
+			PaystackStatus: transfer.Status,
+			PaystackAmount: &paystackAmount,
+			RecordedStatus: withdrawal.Status,
+			RecordedAmount: &recordedAmount,
+			Detail:         fmt.Sprintf("Paystack transfer %s amount differs from the withdrawal's net amount", transfer.TransferCode),
+		})
+	}
+
+	unsettled := withdrawal.Status == models.WithdrawalPending ||
+		withdrawal.Status == models.WithdrawalAwaitingOTP ||
+		withdrawal.Status == models.WithdrawalProcessing
+
+	var settledAs []string
+	switch transfer.Status {
+	case TransferOutcomeSuccess:
+		settledAs = []string{models.WithdrawalCompleted}
+	case TransferOutcomeFailed:
+		settledAs = []string{models.WithdrawalFailed}
+	case TransferOutcomeReversed:
+		settledAs = []string{models.WithdrawalReversed, models.WithdrawalFailed}
+		// A completed withdrawal whose transfer was reversed is credited back.
+		unsettled = unsettled || withdrawal.Status == models.WithdrawalCompleted
+	default:
+		return found // Still in flight at Paystack.
+	}
+
+	for _, status := range settledAs {
+		if withdrawal.Status == status {
+			return found
+		}
+	}
+	d := models.ReconciliationDiscrepancy{
+		Kind:           models.DiscrepancyTransferMismatch,
+		Reference:      transfer.Reference,
+		PaystackStatus: transfer.Status,
+		PaystackAmount: &paystackAmount,
+		RecordedStatus: withdrawal.Status,
+		RecordedAmount: &recordedAmount,
+		Detail:         fmt.Sprintf("Paystack transfer %s is %s but the withdrawal is %s", transfer.TransferCode, transfer.Status, withdrawal.Status),
+	}
+	if unsettled {
+		d.Kind = models.DiscrepancyUnsettledTransfer
+		d.Fixable = true
+	}
+	return append(found, d)
+}
+
+// fixDiscrepancy replays the Paystack event a fixable discrepancy missed and records the
+// outcome on it.
+func (s *PaystackService) fixDiscrepancy(d *models.ReconciliationDiscrepancy, supabaseService *SupabaseService) bool {
+	if !d.Fixable {
+		return false
+	}
+
+	var err error
+	switch d.Kind {
+	case models.DiscrepancyMissingCredit:
+		err = s.fixMissingCredit(d.Reference, supabaseService)
+	case models.DiscrepancyUnsettledTransfer:
+		err = s.fixUnsettledTransfer(d.Reference, supabaseService)
+	default:
+		err = fmt.Errorf("no fix for %s", d.Kind)
+	}
+	if err != nil {
+		log.Printf("ERROR: Reconciliation failed to fix %s for %s: %v", d.Kind, d.Reference, err)
+		d.FixError = err.Error()
+		return false
+	}
+	log.Printf("INFO: Reconciliation fixed %s for %s", d.Kind, d.Reference)
+	d.Fixed = true
+	return true
+}
+
+// fixMissingCredit credits a successful charge that was never credited, through the same
+// path as the charge.success webhook.
+func (s *PaystackService) fixMissingCredit(reference string, supabaseService *SupabaseService) error {
+	transaction, err := s.VerifyPayment(reference)
+	if err != nil {
+		return err
+	}
+	if transaction.Status != "success" {
+		return fmt.Errorf("Paystack now reports the charge as %s", transaction.Status)
+	}
+	_, _, err = s.ApplyChargeSuccess(*transaction, supabaseService)
+	return err
+}
+
+// fixUnsettledTransfer applies a transfer's final status to its withdrawal, through the
+// same path as the transfer webhooks. The transfer is verified again first so a stale
+// listing is never applied.
+func (s *PaystackService) fixUnsettledTransfer(reference string, supabaseService *SupabaseService) error {
+	transfer, err := s.verifyTransfer(reference)
+	if err != nil {
+		return err
+	}
+	switch transfer.Status {
+	case TransferOutcomeSuccess, TransferOutcomeFailed, TransferOutcomeReversed:
+	default:
+		return fmt.Errorf("Paystack now reports the transfer as %s", transfer.Status)
+	}
+	_, _, err = s.ApplyTransferEvent("transfer."+transfer.Status, *transfer, supabaseService)
+	return err
+}
+
+// listPaystackTransactions pages through Paystack's successful charges created between
+// from and to.
+func (s *PaystackService) listPaystackTransactions(from, to time.Time) ([]models.PaystackTransactionSummary, error) {
+	var transactions []models.PaystackTransactionSummary
+	for page := 1; ; page++ {
+		query := paystackListQuery(from, to, page)
+		query.Set("status", "success")
+
+		var resp struct {
+			Data []models.PaystackTransactionSummary `json:"data"`
+			Meta struct {
+				PageCount int `json:"pageCount"`
+			} `json:"meta"`
+		}
+		if err := s.Client.Call("GET", "/transaction?"+query.Encode(), nil, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list Paystack transactions (page %d): %w", page, err)
+		}
+		transactions = append(transactions, resp.Data...)
+		if len(resp.Data) == 0 || page >= resp.Meta.PageCount {
+			return transactions, nil
+		}
+	}
+}
+
+// listPaystackTransfers pages through Paystack's transfers created between from and to.
+func (s *PaystackService) listPaystackTransfers(from, to time.Time) ([]models.PaystackTransferData, error) {
+	var transfers []models.PaystackTransferData
+	for page := 1; ; page++ {
+		query := paystackListQuery(from, to, page)
+
+		var resp struct {
+			Data []models.PaystackTransferData `json:"data"`
+			Meta struct {
+				PageCount int `json:"pageCount"`
+			} `json:"meta"`
+		}
+		if err := s.Client.Call("GET", "/transfer?"+query.Encode(), nil, &resp); err != nil {
+			return nil, fmt.Errorf("failed to list Paystack transfers (page %d): %w", page, err)
+		}
+		transfers = append(transfers, resp.Data...)
+		if len(resp.Data) == 0 || page >= resp.Meta.PageCount {
+			return transfers, nil
+		}
+	}
+}
+
+// verifyTransfer fetches a transfer from Paystack by our reference.
+func (s *PaystackService) verifyTransfer(reference string) (*models.PaystackTransferData, error) {
+	transfer := &models.PaystackTransferData{}
+	if err := s.Client.Call("GET", "/transfer/verify/"+url.PathEscape(reference), nil, transfer); err != nil {
+		return nil, fmt.Errorf("error verifying Paystack transfer %s: %w", reference, err)
+	}
+	return transfer, nil
+}
+
+// paystackListQuery is the query for one page of a Paystack list endpoint filtered by
+// creation time.
+func paystackListQuery(from, to time.Time, page int) url.Values {
+	query := url.Values{}
+	query.Set("from", from.UTC().Format(time.RFC3339))
+	query.Set("to", to.UTC().Format(time.RFC3339))
+	query.Set("perPage", fmt.Sprint(paystackListPageSize))
+	query.Set("page", fmt.Sprint(page))
+	return query
+}
+
+// ListPaymentIntentsCreatedBetween returns every payment intent created in [from, to).
+func (s *SupabaseService) ListPaymentIntentsCreatedBetween(from, to time.Time) ([]models.PaymentIntent, error) {
+	var intents []models.PaymentIntent
+	for offset := 0; ; offset += recordsPageSize {
+		var page []models.PaymentIntent
+		_, err := s.Client.From("payment_intents").
+			Select("*", "", false).
+			Gte("created_at", from.UTC().Format(time.RFC3339)).
+			Lt("created_at", to.UTC().Format(time.RFC3339)).
+			Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+			Range(offset, offset+recordsPageSize-1, "").
+			ExecuteTo(&page)
+		if err != nil {
+			return nil, fmt.Errorf("error listing payment intents created between %s and %s: %w", from, to, err)
+		}
+		intents = append(intents, page...)
+		if len(page) < recordsPageSize {
+			return intents, nil
+		}
+	}
+}
+
+// ListWithdrawalsCreatedBetween returns every withdrawal created in [from, to).
+func (s *SupabaseService) ListWithdrawalsCreatedBetween(from, to time.Time) ([]models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	for offset := 0; ; offset += recordsPageSize {
+		var page []models.Withdrawal
+		_, err := s.Client.From("withdrawals").
+			Select("*", "", false).
+			Gte("created_at", from.UTC().Format(time.RFC3339)).
+			Lt("created_at", to.UTC().Format(time.RFC3339)).
+			Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+			Range(offset, offset+recordsPageSize-1, "").
+			ExecuteTo(&page)
+		if err != nil {
+			return nil, fmt.Errorf("error listing withdrawals created between %s and %s: %w", from, to, err)
+		}
+		withdrawals = append(withdrawals, page...)
+		if len(page) < recordsPageSize {
+			return withdrawals, nil
+		}
+	}
+}
+
+// GetWithdrawalByReference fetches a withdrawal by our transfer reference. It returns
+// (nil, nil) when no withdrawal has the reference.
+func (s *SupabaseService) GetWithdrawalByReference(reference string) (*models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Select("*", "", false).
+		Eq("reference", reference).
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawal %s: %w", reference, err)
+	}
+	if len(withdrawals) == 0 {
+		return nil, nil
+	}
+	return &withdrawals[0], nil
+}
+
+// ListTransactionsByExternalRefs returns the transactions of an operation logged against
+// any of the given external references.
+func (s *SupabaseService) ListTransactionsByExternalRefs(operation string, references []string) ([]models.Transaction, error) {
+	var transactions []models.Transaction
+	for start := 0; start < len(references); start += referencesPerQuery {
+		end := start + referencesPerQuery
+		if end > len(references) {
+			end = len(references)
+		}
+
+		var page []models.Transaction
+		_, err := s.Client.From("transactions").
+			Select("*", "", false).
+			Eq("operation", operation).
+			In("external_reference_id", references[start:end]).
+			ExecuteTo(&page)
+		if err != nil {
+			return nil, fmt.Errorf("error listing %s transactions: %w", operation, err)
+		}
+		transactions = append(transactions, page...)
+	}
+	return transactions, nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+func TestTransferDiscrepancies(t *testing.T) {
+	withdrawal := func(status string) *models.Withdrawal {
+		return &models.Withdrawal{Reference: "wd_1", Amount: 101000, Fee: 1000, Status: status}
+	}
+	transfer := func(status string, amount int64) models.PaystackTransferData {
+		return models.PaystackTransferData{Reference: "wd_1", TransferCode: "TRF_1", Status: status, Amount: amount}
+	}
+	type want struct {
+		kind    string
+		fixable bool
+	}
+	tests := []struct {
+		name       string
+		transfer   models.PaystackTransferData
+		withdrawal *models.Withdrawal
+		want       []want
+	}{
+		{"in flight", transfer("pending", 100000), withdrawal(models.WithdrawalProcessing), nil},
+		{"settled success", transfer(TransferOutcomeSuccess, 100000), withdrawal(models.WithdrawalCompleted), nil},
+		{"settled failure", transfer(TransferOutcomeFailed, 100000), withdrawal(models.WithdrawalFailed), nil},
+		{"settled reversal", transfer(TransferOutcomeReversed, 100000), withdrawal(models.WithdrawalReversed), nil},
+		{"reversal before processing", transfer(TransferOutcomeReversed, 100000), withdrawal(models.WithdrawalFailed), nil},
+		{"unsettled success", transfer(TransferOutcomeSuccess, 100000), withdrawal(models.WithdrawalProcessing),
+			[]want{{models.DiscrepancyUnsettledTransfer, true}}},
+		{"unsettled failure while pending", transfer(TransferOutcomeFailed, 100000), withdrawal(models.WithdrawalPending),
+			[]want{{models.DiscrepancyUnsettledTransfer, true}}},
+		{"unsettled while awaiting OTP", transfer(TransferOutcomeSuccess, 100000), withdrawal(models.WithdrawalAwaitingOTP),
+			[]want{{models.DiscrepancyUnsettledTransfer, true}}},
+		{"completed then reversed", transfer(TransferOutcomeReversed, 100000), withdrawal(models.WithdrawalCompleted),
+			[]want{{models.DiscrepancyUnsettledTransfer, true}}},
+		{"success for failed withdrawal", transfer(TransferOutcomeSuccess, 100000), withdrawal(models.WithdrawalFailed),
+			[]want{{models.DiscrepancyTransferMismatch, false}}},
+		{"amount mismatch", transfer(TransferOutcomeSuccess, 101000), withdrawal(models.WithdrawalCompleted),
+			[]want{{models.DiscrepancyAmountMismatch, false}}},
+		{"amount mismatch and unsettled", transfer(TransferOutcomeSuccess, 101000), withdrawal(models.WithdrawalProcessing),
+			[]want{{models.DiscrepancyAmountMismatch, false}, {models.DiscrepancyUnsettledTransfer, true}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := transferDiscrepancies(tt.transfer, tt.withdrawal)
+			if len(got) != len(tt.want) {
+				t.Fatalf("transferDiscrepancies() returned %d discrepancies, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i, w := range tt.want {
+				if got[i].Kind != w.kind || got[i].Fixable != w.fixable {
+					t.Errorf("discrepancy %d = %s (fixable %t), want %s (fixable %t)", i, got[i].Kind, got[i].Fixable, w.kind, w.fixable)
+				}
+				if got[i].Reference != "wd_1" {
+					t.Errorf("discrepancy %d reference = %q, want wd_1", i, got[i].Reference)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// IssueRefund refunds all or part of a datacredit purchase through Paystack's refund API.
+// The refund is recorded and clawed back from the user's wallet before Paystack is called,
+// so the refunded datacredit cannot be spent meanwhile; what the user has already spent
+// is recorded as a debt and collected from their next purchases. It returns
+// ErrPaymentIntentNotFound for an unknown reference and ErrNotRefundable when the payment
+// did not succeed or the amount exceeds what is left to refund. If Paystack rejects the
+// refund it fails and the clawback is returned (ErrRefundRejected); if Paystack cannot be
+// reached or fails with a server error the refund stays pending for its webhook to settle
+// (ErrRefundOutcomeUnknown).
+//
+// paystack-go has no refund API, so the request is sent directly.
+func (s *PaystackService) IssueRefund(req models.RefundRequest, adminID string, supabaseService *SupabaseService) (*models.Refund, error) {
+	refund, err := supabaseService.CreateRefund(req.Reference, req.Amount, req.Reason, adminID)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("INFO: Refund %s of %d kobo for Paystack Ref %s recorded by admin %s: %d kobo clawed back, %d kobo owed by UserID %s",
+		refund.ID, refund.Amount, refund.Reference, adminID, refund.ClawbackAmount, refund.DebtAmount, refund.UserID)
+
+	refundReq := map[string]interface{}{
+		"transaction":   refund.Reference,
+		"amount":        refund.Amount,
+		"currency":      refund.Currency,
+		"merchant_note": req.Reason,
+	}
+	refundResponse := &models.PaystackRefundData{}
+	if err := s.Client.Call("POST", "/refund", refundReq, refundResponse); err != nil {
+		log.Printf("Error response from Paystack refund of %s: %v", refund.Reference, err)
+		if isPaystackRejection(err) {
+			// Paystack rejected the refund, so no money moved: return the clawback.
+			reason := paystackErrorMessage(err)
+			if _, _, serr := supabaseService.SettleRefund(refund.ID, 0, "", 0, models.RefundFailed, reason); serr != nil {
+				log.Printf("ERROR: Failed to return clawback of rejected refund %s: %v", refund.ID, serr)
+			}
+			return nil, fmt.Errorf("%w: %s", ErrRefundRejected, reason)
+		}
+		// A network error or a Paystack server error leaves us not knowing whether the
+		// refund started. Keep the clawback; the refund webhook (matched by reference and
+		// amount) will settle it.
+		log.Printf("WARNING: Outcome of Paystack refund %s unknown; refund %s stays pending", refund.Reference, refund.ID)
+		return nil, fmt.Errorf("%w: %w", ErrRefundOutcomeUnknown, err)
+	}
+
+	paystackRefundID, _ := refundResponse.ID.Int64()
+	log.Printf("Paystack refund successfully initiated. Refund ID: %d, Status: %s", paystackRefundID, refundResponse.Status)
+
+	processing, updated, err := supabaseService.MarkRefundProcessing(refund.ID, paystackRefundID)
+	if err != nil {
+		// Not fatal: the webhook finds the refund by reference and amount.
+		log.Printf("WARNING: Failed to record Paystack refund %d on refund %s: %v", paystackRefundID, refund.ID, err)
+		return refund, nil
+	}
+	if !updated {
+		log.Printf("INFO: Refund %s was settled by its webhook before the Paystack refund ID was recorded", refund.ID)
+		return supabaseService.GetRefund(refund.ID)
+	}
+	return processing, nil
+}
+
+// HandleRefundEvent applies a refund.processed or refund.failed event to its refund and
+// returns the result recorded for audit.
+func (s *PaystackService) HandleRefundEvent(event string, refundData models.PaystackRefundData, supabaseService *SupabaseService) (map[string]interface{}, error) {
+	outcome := strings.TrimPrefix(event, "refund.")
+	paystackRefundID, _ := refundData.ID.Int64()
+	amount, _ := refundData.Amount.Int64()
+	reason := ""
+	if outcome == models.RefundFailed {
+		reason = "Paystack could not process the refund"
+	}
+
+	refund, changed, err := supabaseService.SettleRefund("", paystackRefundID, refundData.TransactionReference, amount, outcome, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	result := map[string]interface{}{
+		"refund_id":          refund.ID,
+		"status":             refund.Status,
+		"paystack_refund_id": paystackRefundID,
+	}
+	switch {
+	case !changed:
+		log.Printf("INFO: Refund %s already settled as %s; %s ignored", refund.ID, refund.Status, event)
+		result["action"] = "already_settled"
+	case outcome == models.RefundProcessed:
+		log.Printf("INFO: Refund %s of %d kobo processed (Paystack Ref: %s)", refund.ID, refund.Amount, refund.Reference)
+		result["action"] = "processed"
+	default:
+		log.Printf("INFO: Refund %s failed; clawback returned to UserID %s and debt cancelled", refund.ID, refund.UserID)
+		result["action"] = "recredited"
+	}
+	return result, nil
+}
+
+// collectWalletDebts repays a user's refund debts from their balance after a credit.
+// Failing to collect is not fatal: the debt stays open and the next credit tries again.
+func (s *PaystackService) collectWalletDebts(userID string, supabaseService *SupabaseService) {
+	collected, err := supabaseService.CollectWalletDebts(userID)
+	if err != nil {
+		log.Printf("WARNING: Failed to collect refund debts of UserID %s: %v", userID, err)
+		return
+	}
+	if collected > 0 {
+		log.Printf("INFO: Collected %d kobo of refund debt from UserID %s", collected, userID)
+	}
+}
+
+// CreateRefund records a refund of a succeeded payment and claws it back from the user's
+// wallet, recording a debt for whatever is not available, in one database transaction.
+// A nil amount refunds everything not yet refunded.
+func (s *SupabaseService) CreateRefund(reference string, amount *int64, reason, requestedBy string) (*models.Refund, error) {
+	params := map[string]interface{}{
+		"p_reference":    reference,
+		"p_amount":       amount,
+		"p_reason":       nullIfEmpty(reason),
+		"p_requested_by": nullIfEmpty(requestedBy),
+		"p_description":  fmt.Sprintf("Refund of datacredit purchase via Paystack (Ref: %s)", reference),
+		"p_counterparty": ledgerCounterparties[models.OperationRefund].datacredit,
+	}
+
+	var created models.Refund
+	if err := s.callRPC("create_refund", params, &created); err != nil {
+		return nil, fmt.Errorf("error creating refund of %s: %w", reference, err)
+	}
+	return &created, nil
+}
+
+// MarkRefundProcessing records that Paystack accepted a pending refund. updated is false
+// when the refund is no longer pending, which happens when its webhook settled it first.
+func (s *SupabaseService) MarkRefundProcessing(id string, paystackRefundID int64) (refund *models.Refund, updated bool, err error) {
+	updateData := map[string]interface{}{
+		"status":     models.RefundProcessing,
+		"updated_at": time.Now(),
+	}
+	if paystackRefundID != 0 {
+		updateData["paystack_refund_id"] = paystackRefundID
+	}
+
+	var rows []models.Refund
+	_, err = s.Client.From("refunds").
+		Update(updateData, "", "").
+		Eq("id", id).
+		Eq("status", models.RefundPending).
+		ExecuteTo(&rows)
+	if err != nil {
+		return nil, false, fmt.Errorf("error marking refund %s processing: %w", id, err)
+	}
+	if len(rows) == 0 {
+		return nil, false, nil
+	}
+	return &rows[0], true, nil
+}
+
+// SettleRefund applies Paystack's outcome (RefundProcessed or RefundFailed) to a refund,
+// found by its ID, Paystack's refund ID, or else the payment reference and amount
+// (whichever are non-empty). A failed refund returns the clawback and any debt already
+// collected to the wallet and cancels the rest of the debt. changed is false when the
+// refund had already been settled.
+func (s *SupabaseService) SettleRefund(refundID string, paystackRefundID int64, reference string, amount int64, outcome, reason string) (refund *models.Refund, changed bool, err error) {
+	params := map[string]interface{}{
+		"p_refund_id":          nullIfEmpty(refundID),
+		"p_paystack_refund_id": nullIfZero(paystackRefundID),
+		"p_reference":          nullIfEmpty(reference),
+		"p_amount":             nullIfZero(amount),
+		"p_outcome":            outcome,
+		"p_reason":             nullIfEmpty(reason),
+		"p_description":        fmt.Sprintf("Return of failed refund to wallet (Paystack Ref: %s)", reference),
+		"p_counterparty":       ledgerCounterparties[models.OperationRefundReversal].datacredit,
+	}
+
+	var result struct {
+		Changed bool          `json:"changed"`
+		Refund  models.Refund `json:"refund"`
+	}
+	if err := s.callRPC("settle_refund", params, &result); err != nil {
+		return nil, false, fmt.Errorf("error settling refund %s (Paystack refund %d, reference %s): %w", refundID, paystackRefundID, reference, err)
+	}
+	return &result.Refund, result.Changed, nil
+}
+
+// CollectWalletDebts repays a user's open refund debts, oldest first, from their
+// available datacredit and returns the amount collected.
+func (s *SupabaseService) CollectWalletDebts(userID string) (int64, error) {
+	var collected int64
+	if err := s.callRPC("collect_wallet_debts", map[string]interface{}{"p_user_id": userID}, &collected); err != nil {
+		return 0, fmt.Errorf("error collecting wallet debts of user %s: %w", userID, err)
+	}
+	return collected, nil
+}
+
+// ListRefunds returns refunds, newest first, optionally filtered by payment reference and status.
+func (s *SupabaseService) ListRefunds(reference, status string, limit, offset int) ([]models.Refund, error) {
+	query := s.Client.From("refunds").
+		Select("*", "", false)
+	if reference != "" {
+		query = query.Eq("reference", reference)
+	}
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+
+	var refunds []models.Refund
+	_, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&refunds)
+	if err != nil {
+		return nil, fmt.Errorf("error listing refunds: %w", err)
+	}
+	return refunds, nil
+}
+
+// GetRefund fetches a refund by ID.
+func (s *SupabaseService) GetRefund(id string) (*models.Refund, error) {
+	var refunds []models.Refund
+	_, err := s.Client.From("refunds").
+		Select("*", "", false).
+		Eq("id", id).
+		ExecuteTo(&refunds)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching refund %s: %w", id, err)
+	}
+	if len(refunds) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrRefundNotFound, id)
+	}
+	return &refunds[0], nil
+}
+
+// nullIfZero sends a zero ID or amount to the database as NULL.
+func nullIfZero(n int64) *int64 {
+	if n == 0 {
+		return nil
+	}
+	return &n
+}
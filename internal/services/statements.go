@@ -0,0 +1,354 @@
+package services
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// statementBatchSize is how many transactions are fetched at a time while streaming a
+// statement, which bounds its memory use regardless of the length of the history.
+const statementBatchSize = 500
+
+// statementCurrencies are the currencies a statement covers, in output order.
+var statementCurrencies = []string{models.CurrencyDatacredit, models.CurrencyDatabyte}
+
+// WriteStatement streams a statement of the user's transactions in [from, to) to w in
+// the given format (models.StatementFormatCSV, JSONL or OFX). Each statement carries
+// the opening and closing balance of every currency, the running balance after each
+// transaction and the totals per operation. Balances are rebuilt from the transaction
+// history. Amounts are in kobo for datacredit and units for databytes, except in OFX,
+// where datacredit is in naira.
+func (s *SupabaseService) WriteStatement(w io.Writer, format, userID string, from, to time.Time) error {
+	opening, err := s.TransactionBalancesBefore(userID, from)
+	if err != nil {
+		return err
+	}
+	return s.WriteStatementFrom(w, format, userID, from, to, opening)
+}
+
+// WriteStatementFrom is WriteStatement with the opening balances already read, for
+// callers that must know the export can start before writing anything.
+func (s *SupabaseService) WriteStatementFrom(w io.Writer, format, userID string, from, to time.Time, opening map[string]int64) error {
+	var err error
+	out := bufio.NewWriter(w)
+	switch format {
+	case models.StatementFormatCSV:
+		err = s.writeCSVStatement(out, userID, from, to, opening)
+	case models.StatementFormatJSONL:
+		err = s.writeJSONLStatement(out, userID, from, to, opening)
+	case models.StatementFormatOFX:
+		err = s.writeOFXStatement(out, userID, from, to, opening)
+	default:
+		return fmt.Errorf("unknown statement format %q", format)
+	}
+	if err != nil {
+		return err
+	}
+	return out.Flush()
+}
+
+// TransactionBalancesBefore sums the user's transactions before a moment per currency,
+// which are the opening balances of a statement starting then.
+func (s *SupabaseService) TransactionBalancesBefore(userID string, before time.Time) (map[string]int64, error) {
+	params := map[string]interface{}{
+		"p_user_id": userID,
+		"p_before":  before.UTC().Format(time.RFC3339Nano),
+	}
+
+	var balances map[string]int64
+	if err := s.callRPC("transaction_balances_before", params, &balances); err != nil {
+		return nil, fmt.Errorf("error summing transactions of user %s before %s: %w", userID, before.Format(time.RFC3339), err)
+	}
+	return balances, nil
+}
+
+// StreamTransactions calls fn with each of the user's transactions in [from, to), oldest
+// first, optionally only those in one currency. Transactions are fetched in batches, so
+// only one batch is held in memory at a time.
+func (s *SupabaseService) StreamTransactions(userID, currency string, from, to time.Time, fn func(models.Transaction) error) error {
+	var afterID int64
+	for {
+		query := s.Client.From("transactions").
+			Select("*", "", false).
+			Eq("user_id", userID).
+			Gte("transaction_timestamp", from.UTC().Format(time.RFC3339Nano)).
+			Lt("transaction_timestamp", to.UTC().Format(time.RFC3339Nano)).
+			Gt("id", strconv.FormatInt(afterID, 10))
+		if currency != "" {
+			query = query.Eq("currency", currency)
+		}
+
+		var batch []models.Transaction
+		_, err := query.
+			Order("id", &postgrest.OrderOpts{Ascending: true}).
+			Limit(statementBatchSize, "").
+			ExecuteTo(&batch)
+		if err != nil {
+			return fmt.Errorf("error streaming transactions for user %s: %w", userID, err)
+		}
+		for _, tx := range batch {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		if len(batch) < statementBatchSize {
+			return nil
+		}
+		afterID = batch[len(batch)-1].ID
+	}
+}
+
+// statementTally keeps the running balance and per-operation totals of each currency
+// while a statement is streamed.
+type statementTally map[string]*models.StatementBalance
+
+func newStatementTally(opening map[string]int64) statementTally {
+	tally := statementTally{}
+	for _, currency := range statementCurrencies {
+		tally[currency] = &models.StatementBalance{
+			Currency:          currency,
+			Opening:           opening[currency],
+			Closing:           opening[currency],
+			TotalsByOperation: map[string]int64{},
+			CountsByOperation: map[string]int64{},
+		}
+	}
+	return tally
+}
+
+// add records a transaction and returns it with its running balance.
+func (t statementTally) add(tx models.Transaction) models.StatementLine {
+	balance, ok := t[tx.Currency]
+	if !ok {
+		balance = &models.StatementBalance{Currency: tx.Currency, TotalsByOperation: map[string]int64{}, CountsByOperation: map[string]int64{}}
+		t[tx.Currency] = balance
+	}
+	balance.Closing += tx.Amount
+	balance.TransactionCount++
+	balance.TotalsByOperation[tx.Operation] += tx.Amount
+	balance.CountsByOperation[tx.Operation]++
+	return models.StatementLine{Transaction: tx, RunningBalance: balance.Closing}
+}
+
+// balances returns the summary of every currency, in statement order.
+func (t statementTally) balances() []models.StatementBalance {
+	balances := make([]models.StatementBalance, 0, len(t))
+	for _, currency := range statementCurrencies {
+		balances = append(balances, *t[currency])
+	}
+	return balances
+}
+
+// sortedOperations returns the operations of a currency's totals in a stable order.
+func sortedOperations(balance models.StatementBalance) []string {
+	operations := make([]string, 0, len(balance.TotalsByOperation))
+	for operation := range balance.TotalsByOperation {
+		operations = append(operations, operation)
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// writeCSVStatement writes one CSV table whose record_type column tells opening balances,
+// transactions, operation totals and closing balances apart.
+func (s *SupabaseService) writeCSVStatement(w io.Writer, userID string, from, to time.Time, opening map[string]int64) error {
+	out := csv.NewWriter(w)
+	tally := newStatementTally(opening)
+
+	out.Write([]string{"record_type", "transaction_id", "timestamp", "currency", "operation", "amount", "running_balance", "count", "external_reference", "description"})
+	for _, currency := range statementCurrencies {
+		out.Write([]string{"opening", "", from.UTC().Format(time.RFC3339), currency, "", "", strconv.FormatInt(opening[currency], 10), "", "", ""})
+	}
+
+	err := s.StreamTransactions(userID, "", from, to, func(tx models.Transaction) error {
+		line := tally.add(tx)
+		out.Write([]string{
+			"transaction",
+			strconv.FormatInt(tx.ID, 10),
+			tx.TransactionTimestamp.UTC().Format(time.RFC3339),
+			tx.Currency,
+			tx.Operation,
+			strconv.FormatInt(tx.Amount, 10),
+			strconv.FormatInt(line.RunningBalance, 10),
+			"",
+			stringValue(tx.ExternalReferenceID),
+			stringValue(tx.Description),
+		})
+		return out.Error()
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, balance := range tally.balances() {
+		for _, operation := range sortedOperations(balance) {
+			out.Write([]string{"total", "", "", balance.Currency, operation,
+				strconv.FormatInt(balance.TotalsByOperation[operation], 10), "",
+				strconv.FormatInt(balance.CountsByOperation[operation], 10), "", ""})
+		}
+	}
+	for _, balance := range tally.balances() {
+		out.Write([]string{"closing", "", to.UTC().Format(time.RFC3339), balance.Currency, "", "",
+			strconv.FormatInt(balance.Closing, 10), strconv.FormatInt(balance.TransactionCount, 10), "", ""})
+	}
+
+	out.Flush()
+	return out.Error()
+}
+
+// writeJSONLStatement writes a "statement" header line with the opening balances, one
+// "transaction" line per transaction and a closing "summary" line.
+func (s *SupabaseService) writeJSONLStatement(w io.Writer, userID string, from, to time.Time, opening map[string]int64) error {
+	encoder := json.NewEncoder(w)
+	tally := newStatementTally(opening)
+
+	header := struct {
+		Type            string           `json:"type"`
+		UserID          string           `json:"user_id"`
+		From            time.Time        `json:"from"`
+		To              time.Time        `json:"to"`
+		GeneratedAt     time.Time        `json:"generated_at"`
+		OpeningBalances map[string]int64 `json:"opening_balances"`
+	}{"statement", userID, from.UTC(), to.UTC(), time.Now().UTC(), opening}
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+
+	err := s.StreamTransactions(userID, "", from, to, func(tx models.Transaction) error {
+		return encoder.Encode(struct {
+			Type string `json:"type"`
+			models.StatementLine
+		}{"transaction", tally.add(tx)})
+	})
+	if err != nil {
+		return err
+	}
+
+	return encoder.Encode(struct {
+		Type     string                    `json:"type"`
+		Balances []models.StatementBalance `json:"balances"`
+	}{"summary", tally.balances()})
+}
+
+// writeOFXStatement writes an OFX 2.2 document with one bank statement per currency.
+// OFX has no opening balance, so it and the operation totals go in each statement's
+// BALLIST; LEDGERBAL is the closing balance. Datacredit is in naira (NGN); databytes
+// are not money and use the ISO 4217 "no currency" code XXX.
+func (s *SupabaseService) writeOFXStatement(w io.Writer, userID string, from, to time.Time, opening map[string]int64) error {
+	now := time.Now()
+	fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\" standalone=\"no\"?>\n")
+	fmt.Fprintf(w, "<?OFX OFXHEADER=\"200\" VERSION=\"220\" SECURITY=\"NONE\" OLDFILEUID=\"NONE\" NEWFILEUID=\"NONE\"?>\n")
+	fmt.Fprintf(w, "<OFX>\n<SIGNONMSGSRSV1><SONRS><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS><DTSERVER>%s</DTSERVER><LANGUAGE>ENG</LANGUAGE></SONRS></SIGNONMSGSRSV1>\n", ofxTime(now))
+	fmt.Fprintf(w, "<BANKMSGSRSV1>\n")
+
+	tally := newStatementTally(opening)
+	for i, currency := range statementCurrencies {
+		curdef, balType := "NGN", "DOLLAR"
+		if currency == models.CurrencyDatabyte {
+			curdef, balType = "XXX", "NUMBER"
+		}
+
+		fmt.Fprintf(w, "<STMTTRNRS><TRNUID>%d</TRNUID><STATUS><CODE>0</CODE><SEVERITY>INFO</SEVERITY></STATUS>\n<STMTRS><CURDEF>%s</CURDEF>\n", i+1, curdef)
+		fmt.Fprintf(w, "<BANKACCTFROM><BANKID>DATAGRAM</BANKID><ACCTID>%s</ACCTID><ACCTTYPE>CHECKING</ACCTTYPE></BANKACCTFROM>\n", ofxEscape(userID+":"+currency))
+		fmt.Fprintf(w, "<BANKTRANLIST><DTSTART>%s</DTSTART><DTEND>%s</DTEND>\n", ofxTime(from), ofxTime(to))
+
+		err := s.StreamTransactions(userID, currency, from, to, func(tx models.Transaction) error {
+			tally.add(tx)
+			trnType := "CREDIT"
+			if tx.Amount < 0 {
+				trnType = "DEBIT"
+			}
+			_, err := fmt.Fprintf(w, "<STMTTRN><TRNTYPE>%s</TRNTYPE><DTPOSTED>%s</DTPOSTED><TRNAMT>%s</TRNAMT><FITID>%d</FITID><NAME>%s</NAME><MEMO>%s</MEMO></STMTTRN>\n",
+				trnType, ofxTime(tx.TransactionTimestamp), ofxAmount(currency, tx.Amount), tx.ID,
+				ofxEscape(truncate(tx.Operation, 32)), ofxEscape(truncate(ofxMemo(tx), 255)))
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		balance := *tally[currency]
+		fmt.Fprintf(w, "</BANKTRANLIST>\n<LEDGERBAL><BALAMT>%s</BALAMT><DTASOF>%s</DTASOF></LEDGERBAL>\n", ofxAmount(currency, balance.Closing), ofxTime(to))
+		fmt.Fprintf(w, "<BALLIST>\n<BAL><NAME>Opening balance</NAME><DESC>Balance before the first transaction</DESC><BALTYPE>%s</BALTYPE><VALUE>%s</VALUE><DTASOF>%s</DTASOF></BAL>\n",
+			balType, ofxAmount(currency, balance.Opening), ofxTime(from))
+		for _, operation := range sortedOperations(balance) {
+			fmt.Fprintf(w, "<BAL><NAME>%s</NAME><DESC>Total of %d %s transaction(s)</DESC><BALTYPE>%s</BALTYPE><VALUE>%s</VALUE></BAL>\n",
+				ofxEscape(truncate(operation, 32)), balance.CountsByOperation[operation], ofxEscape(operation), balType, ofxAmount(currency, balance.TotalsByOperation[operation]))
+		}
+		fmt.Fprintf(w, "</BALLIST>\n</STMTRS>\n</STMTTRNRS>\n")
+	}
+
+	_, err := fmt.Fprintf(w, "</BANKMSGSRSV1>\n</OFX>\n")
+	return err
+}
+
+// ofxTime formats a time as an OFX datetime in UTC.
+func ofxTime(t time.Time) string {
+	return t.UTC().Format("20060102150405.000") + "[0:GMT]"
+}
+
+// ofxAmount formats an amount in OFX: datacredit kobo as naira, databytes as units.
+func ofxAmount(currency string, amount int64) string {
+	if currency != models.CurrencyDatacredit {
+		return strconv.FormatInt(amount, 10)
+	}
+	sign := ""
+	if amount < 0 {
+		sign, amount = "-", -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// ofxMemo is a transaction's description, followed by its external reference if any.
+func ofxMemo(tx models.Transaction) string {
+	memo := stringValue(tx.Description)
+	if ref := stringValue(tx.ExternalReferenceID); ref != "" {
+		memo = strings.TrimSpace(memo + " (Ref: " + ref + ")")
+	}
+	return memo
+}
+
+// ofxEscape escapes text for an OFX 2 (XML) element.
+func ofxEscape(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch r {
+		case '&':
+			b.WriteString("&amp;")
+		case '<':
+			b.WriteString("&lt;")
+		case '>':
+			b.WriteString("&gt;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// truncate shortens text to at most n runes.
+func truncate(text string, n int) string {
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	return string(runes[:n])
+}
+
+// stringValue dereferences an optional string, treating nil as empty.
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
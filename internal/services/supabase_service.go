@@ -5,7 +5,6 @@ import (
 	// "encoding/json"
 	"fmt"
 	"log"
-	"math"
 	"time"
 
 	"github.com/supabase-community/supabase-go"
@@ -75,156 +74,121 @@ func (s *SupabaseService) GetOrCreateWallet(userID string) (*models.Wallet, erro
 	return &created[0], nil
 }
 
-func (s *SupabaseService) UpdateDatacreditBalance(userID string, amountKobo int64, operationDescription string, externalRef *string) (*models.Wallet, error) {
-	wallet, err := s.GetOrCreateWallet(userID)
+// callRPC invokes a Postgres function through PostgREST (POST /rest/v1/rpc/<name>)
+// and decodes its JSON result into out. supabase-go's own Rpc helper only returns the
+// raw body and swallows HTTP errors, so we go through the query builder instead,
+// which surfaces PostgREST errors as "(CODE) message".
+func (s *SupabaseService) callRPC(name string, params interface{}, out interface{}) error {
+	_, err := s.Client.From("rpc/"+name).
+		Insert(params, false, "", "", "").
+		ExecuteTo(out)
 	if err != nil {
-		return nil, fmt.Errorf("could not get/create wallet: %w", err)
+		return mapRPCError(err)
 	}
+	return nil
+}
 
-	newBalance := wallet.DatacreditBalance + amountKobo
-	if newBalance < 0 {
-		return nil, fmt.Errorf("insufficient datacredit balance")
+// applyWalletDelta atomically adds the given deltas to a user's wallet via the
+// apply_wallet_delta database function. Non-negative checks happen in the database,
+// so concurrent callers can never lose or double-apply an amount, and the same call
+// posts the balanced journal entry and writes the transaction records for the change.
+func (s *SupabaseService) applyWalletDelta(delta walletDelta) (*models.WalletChange, error) {
+	var change models.WalletChange
+	if err := s.callRPC("apply_wallet_delta", delta, &change); err != nil {
+		return nil, err
 	}
-
-	updateData := map[string]interface{}{
-		"datacredit_balance": newBalance,
+	for _, tx := range change.Transactions {
+		log.Printf("Transaction logged successfully for user %s, operation: %s, currency: %s", tx.UserID, tx.Operation, tx.Currency)
 	}
+	return &change, nil
+}
 
-	var updatedWallets []models.Wallet
-	_, err = s.Client.From("wallets").
-		Update(updateData, "", "").
-		Eq("user_id", userID).
-		ExecuteTo(&updatedWallets)
-	
+// UpdateDatacreditBalance adds amountKobo (negative to debit) to a user's datacredit
+// balance and records the matching transaction in the same database call. operation
+// decides which platform account the ledger posts against; metadata is stored on both
+// the transaction and the journal entry.
+func (s *SupabaseService) UpdateDatacreditBalance(userID string, amountKobo int64, operation string, operationDescription string, externalRef *string, metadata map[string]interface{}) (*models.Wallet, error) {
+	delta, err := newWalletDelta(userID, operation, amountKobo, 0)
 	if err != nil {
-		return nil, fmt.Errorf("error updating balance: %w", err)
+		return nil, err
 	}
+	delta.Description = operationDescription
+	delta.ExternalRef = externalRef
+	delta.Metadata = metadata
 
-	if len(updatedWallets) == 0 {
-		return nil, fmt.Errorf("no wallet updated")
+	change, err := s.applyWalletDelta(delta)
+	if err != nil {
+		return nil, fmt.Errorf("error updating datacredit balance for user %s: %w", userID, err)
 	}
-
-	// Log transaction (implementation omitted for brevity)
-	return &updatedWallets[0], nil
+	return &change.Wallet, nil
 }
 
 func (s *SupabaseService) UpdateDatabyteBalance(userID string, amountDatabyte int64, operationDescription string, externalRef *string) (*models.Wallet, error) {
-	wallet, err := s.GetOrCreateWallet(userID)
+	delta, err := newWalletDelta(userID, models.OperationDatabyteUpdate, 0, amountDatabyte)
 	if err != nil {
-		return nil, fmt.Errorf("could not get/create wallet for databyte update: %w", err)
-	}
-
-	balanceBefore := wallet.DatabyteBalance
-	newBalance := wallet.DatabyteBalance + amountDatabyte
-
-	if newBalance < 0 {
-		return nil, fmt.Errorf("insufficient databyte balance for user %s. Has: %d, Tried to change by: %d", userID, wallet.DatabyteBalance, amountDatabyte)
-	}
-
-	updateData := map[string]interface{}{
-		"databyte_balance": newBalance,
+		return nil, err
 	}
+	delta.Description = operationDescription
+	delta.ExternalRef = externalRef
 
-	var updatedWallets []models.Wallet
-	_, err = s.Client.From("wallets").
-		Update(updateData, "", "").
-		Eq("user_id", userID).
-		ExecuteTo(&updatedWallets)
+	change, err := s.applyWalletDelta(delta)
 	if err != nil {
 		return nil, fmt.Errorf("error updating databyte balance for user %s: %w", userID, err)
 	}
-
-	if len(updatedWallets) == 0 {
-		return nil, fmt.Errorf("user wallet %s not found for databyte update", userID)
-	}
-
-	transaction := models.Transaction{
-		UserID:              userID,
-		Amount:              amountDatabyte,
-		BalanceBefore:       &balanceBefore,
-		BalanceAfter:        &newBalance,
-		Operation:           "databyte_update",
-		Description:         &operationDescription,
-		ExternalReferenceID: externalRef,
-		TransactionTimestamp: time.Now(),
-	}
-	if err := s.LogTransaction(transaction); err != nil {
-		log.Printf("WARNING: Databyte balance updated for user %s, but failed to log transaction: %v", userID, err)
-	}
-	return &updatedWallets[0], nil
+	return &change.Wallet, nil
 }
 
-func (s *SupabaseService) PurchaseDatabytesWithDatacredit(userID string, databyteAmountToPurchase int64) (*models.Wallet, error) {
+// PurchaseDatabytesWithDatacredit prices a databyte purchase at the current rate and
+// volume tier and moves the datacredit and databytes in one call. It returns
+// ErrDatabyteRateChanged when expectedRateVersion is set and another version is in
+// effect, and ErrDatabyteCostTooHigh when the cost exceeds maxCostKobo.
+func (s *SupabaseService) PurchaseDatabytesWithDatacredit(userID string, databyteAmountToPurchase int64, expectedRateVersion, maxCostKobo *int64) (*models.Wallet, error) {
 	if databyteAmountToPurchase <= 0 {
 		return nil, fmt.Errorf("databyte amount to purchase must be positive")
 	}
 
-	exactKoboCostFloat := float64(databyteAmountToPurchase) / config.DATABYTES_PER_DATACREDIT_KOBO
-	actualKoboToDebit := int64(math.Ceil(exactKoboCostFloat))
-	if actualKoboToDebit <= 0 {
-		actualKoboToDebit = 1
-	}
-
-	wallet, err := s.GetOrCreateWallet(userID)
+	// Price the purchase at the rate version and volume tier in effect now.
+	rate, err := s.GetDatabyteRate(time.Now())
 	if err != nil {
 		return nil, err
 	}
-
-	if wallet.DatacreditBalance < actualKoboToDebit {
-		return nil, fmt.Errorf("insufficient datacredit balance. Required: %d kobo, Available: %d kobo", actualKoboToDebit, wallet.DatacreditBalance)
+	if expectedRateVersion != nil && *expectedRateVersion != rate.ID {
+		return nil, fmt.Errorf("%w: expected version %d, version %d is in effect", ErrDatabyteRateChanged, *expectedRateVersion, rate.ID)
 	}
-
-	datacreditBalanceBefore := wallet.DatacreditBalance
-	databyteBalanceBefore := wallet.DatabyteBalance
-
-	newDatacreditBalance := wallet.DatacreditBalance - actualKoboToDebit
-	newDatabyteBalance := wallet.DatabyteBalance + databyteAmountToPurchase
-
-	updateData := map[string]interface{}{
-		"datacredit_balance": newDatacreditBalance,
-		"databyte_balance":   newDatabyteBalance,
-	}
-
-	var updatedWallets []models.Wallet
-	_, err = s.Client.From("wallets").
-		Update(updateData, "", "").
-		Eq("user_id", userID).
-		ExecuteTo(&updatedWallets)
+	tier := rate.TierFor(databyteAmountToPurchase)
+	actualKoboToDebit, err := databyteCost(databyteAmountToPurchase, tier)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update wallet balances: %w", err)
+		return nil, err
 	}
-
-	if len(updatedWallets) == 0 {
-		return nil, fmt.Errorf("wallet not found during balance update")
+	if maxCostKobo != nil && actualKoboToDebit > *maxCostKobo {
+		return nil, fmt.Errorf("%w: %d kobo, at most %d kobo allowed", ErrDatabyteCostTooHigh, actualKoboToDebit, *maxCostKobo)
 	}
 
-	descDebit := fmt.Sprintf("Purchase of %d databytes", databyteAmountToPurchase)
-	if err := s.LogTransaction(models.Transaction{
-		UserID:               userID,
-		Amount:               -actualKoboToDebit,
-		BalanceBefore:        &datacreditBalanceBefore,
-		BalanceAfter:         &newDatacreditBalance,
-		Operation:            "datacredit_debit_for_databyte",
-		Description:          &descDebit,
-		TransactionTimestamp: time.Now(),
-	}); err != nil {
-		log.Printf("WARNING: Failed to log datacredit debit transaction for user %s: %v", userID, err)
+	// Debit datacredit and credit databytes in one atomic call so neither side can be
+	// applied without the other. The two transaction rows keep their historical operations.
+	delta, err := newWalletDelta(userID, models.OperationDatabytePurchase, -actualKoboToDebit, databyteAmountToPurchase)
+	if err != nil {
+		return nil, err
 	}
-
-	descCredit := fmt.Sprintf("Purchased with %d datacredit (kobo)", actualKoboToDebit)
-	if err := s.LogTransaction(models.Transaction{
-		UserID:               userID,
-		Amount:               databyteAmountToPurchase,
-		BalanceBefore:        &databyteBalanceBefore,
-		BalanceAfter:         &newDatabyteBalance,
-		Operation:            "databyte_credit_from_purchase",
-		Description:          &descCredit,
-		TransactionTimestamp: time.Now(),
-	}); err != nil {
-		log.Printf("WARNING: Failed to log databyte credit transaction for user %s: %v", userID, err)
+	debitOperation := models.OperationDatacreditDebitForDatabyte
+	creditOperation := models.OperationDatabyteCreditFromPurchase
+	delta.DatacreditOperation = &debitOperation
+	delta.DatabyteOperation = &creditOperation
+	delta.Description = fmt.Sprintf("Purchase of %d databytes for %d datacredit (kobo)", databyteAmountToPurchase, actualKoboToDebit)
+	delta.Metadata = map[string]interface{}{
+		"databyte_amount":          databyteAmountToPurchase,
+		"datacredit_cost_kobo":     actualKoboToDebit,
+		"databyte_price_kobo":      tier.PriceKobo,
+		"databyte_price_databytes": tier.PerDatabytes,
+		"databyte_rate_version":    rate.ID,
+		"databyte_rate_tier_min":   tier.MinDatabytes,
+	}
+
+	change, err := s.applyWalletDelta(delta)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update wallet balances: %w", err)
 	}
-
-	return &updatedWallets[0], nil
+	return &change.Wallet, nil
 }
 
 func (s *SupabaseService) LogTransaction(tx models.Transaction) error {
@@ -259,7 +223,7 @@ func (s *SupabaseService) GetUserProfile(userID string) (*models.Profile, error)
 	}
 
 	if len(profiles) == 0 {
-		return nil, fmt.Errorf("profile not found for user %s", userID)
+		return nil, fmt.Errorf("%w for user %s", ErrProfileNotFound, userID)
 	}
 	return &profiles[0], nil
 }
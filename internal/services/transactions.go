@@ -0,0 +1,83 @@
+package services
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// ListTransactions returns one page of a user's transaction history, newest first,
+// starting after cursor (empty for the first page). Transactions are ordered by ID, which
+// increases with every write, so pages stay stable while new transactions arrive. It
+// returns ErrInvalidCursor for a cursor it did not issue.
+func (s *SupabaseService) ListTransactions(filter models.TransactionFilter, cursor string, limit int) (*models.TransactionPage, error) {
+	query := s.Client.From("transactions").
+		Select("*", "", false).
+		Eq("user_id", filter.UserID)
+	if cursor != "" {
+		afterID, err := decodeTransactionCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Lt("id", strconv.FormatInt(afterID, 10))
+	}
+	if filter.Operation != "" {
+		query = query.Eq("operation", filter.Operation)
+	}
+	if filter.Currency != "" {
+		query = query.Eq("currency", filter.Currency)
+	}
+	if filter.ExternalReference != "" {
+		query = query.Eq("external_reference_id", filter.ExternalReference)
+	}
+	if filter.From != nil {
+		query = query.Gte("transaction_timestamp", filter.From.UTC().Format(time.RFC3339Nano))
+	}
+	if filter.To != nil {
+		query = query.Lt("transaction_timestamp", filter.To.UTC().Format(time.RFC3339Nano))
+	}
+
+	// One extra row tells us whether another page follows.
+	var transactions []models.Transaction
+	_, err := query.
+		Order("id", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit+1, "").
+		ExecuteTo(&transactions)
+	if err != nil {
+		return nil, fmt.Errorf("error listing transactions for user %s: %w", filter.UserID, err)
+	}
+
+	page := &models.TransactionPage{Data: transactions}
+	if page.Data == nil {
+		page.Data = []models.Transaction{}
+	}
+	if len(page.Data) > limit {
+		page.Data = page.Data[:limit]
+		page.HasMore = true
+		next := encodeTransactionCursor(page.Data[limit-1].ID)
+		page.NextCursor = &next
+	}
+	return page, nil
+}
+
+// encodeTransactionCursor makes the opaque cursor for the page after a transaction.
+func encodeTransactionCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("tx:" + strconv.FormatInt(id, 10)))
+}
+
+// decodeTransactionCursor returns the transaction ID a cursor points after.
+func decodeTransactionCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 4 || string(raw[:3]) != "tx:" {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw[3:]), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, ErrInvalidCursor
+	}
+	return id, nil
+}
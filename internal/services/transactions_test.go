@@ -0,0 +1,42 @@
+package services
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+)
+
+func TestDecodeTransactionCursor(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name    string
+		cursor  string
+		want    int64
+		wantErr bool
+	}{
+		{"round trip", encodeTransactionCursor(42), 42, false},
+		{"large id", encodeTransactionCursor(9007199254740993), 9007199254740993, false},
+		{"empty", "", 0, true},
+		{"not base64", "!!!", 0, true},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("tx:42")), 0, true},
+		{"wrong prefix", encode("id:42"), 0, true},
+		{"prefix only", encode("tx:"), 0, true},
+		{"not a number", encode("tx:abc"), 0, true},
+		{"zero", encode("tx:0"), 0, true},
+		{"negative", encode("tx:-5"), 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeTransactionCursor(tt.cursor)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidCursor) {
+					t.Fatalf("decodeTransactionCursor(%q) error = %v, want ErrInvalidCursor", tt.cursor, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("decodeTransactionCursor(%q) = %d, %v; want %d", tt.cursor, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/rpip/paystack-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// paystackTransferStatusOTP is the status Paystack gives a new transfer that must be
+// finalized with an OTP before it is sent.
+const paystackTransferStatusOTP = "otp"
+
+// FinalizeWithdrawalTransfer submits the OTP for a withdrawal awaiting one, sending its
+// transfer, and marks the withdrawal processing. It returns ErrWithdrawalStateConflict
+// when the withdrawal is not awaiting an OTP and ErrTransferOTPRejected when Paystack
+// rejects the OTP; the withdrawal then keeps waiting and the OTP can be retried. On a
+// network or Paystack server error it returns ErrTransferOutcomeUnknown: the transfer may
+// have been sent, so its webhook or the withdrawal sweeper settles the withdrawal.
+//
+// paystack.TransferService.Finalize sends its body as url.Values, which encode to JSON
+// arrays, so the request is sent directly.
+func (s *PaystackService) FinalizeWithdrawalTransfer(withdrawalID, otp string, supabaseService *SupabaseService) (*models.Withdrawal, error) {
+	withdrawal, transferCode, err := s.withdrawalAwaitingOTP(withdrawalID, supabaseService)
+	if err != nil {
+		return nil, err
+	}
+
+	finalizeReq := map[string]interface{}{
+		"transfer_code": transferCode,
+		"otp":           otp,
+	}
+	transferResponse := &paystack.Transfer{}
+	if err := s.Client.Call("POST", "/transfer/finalize_transfer", finalizeReq, transferResponse); err != nil {
+		if isPaystackRejection(err) {
+			return nil, fmt.Errorf("%w: %s", ErrTransferOTPRejected, paystackErrorMessage(err))
+		}
+		log.Printf("WARNING: Outcome of finalizing Paystack transfer %s unknown; withdrawal %s stays awaiting OTP with its hold", transferCode, withdrawal.ID)
+		return nil, fmt.Errorf("%w: transfer %s: %v", ErrTransferOutcomeUnknown, transferCode, err)
+	}
+	log.Printf("INFO: Paystack transfer %s for withdrawal %s finalized with OTP (status %s)", transferCode, withdrawal.ID, transferResponse.Status)
+
+	processing, updated, err := supabaseService.MarkWithdrawalOTPFinalized(withdrawal.ID)
+	if err != nil {
+		// Not fatal: the transfer was sent and its webhook settles the withdrawal.
+		log.Printf("WARNING: Failed to mark withdrawal %s processing after finalizing transfer %s: %v", withdrawal.ID, transferCode, err)
+		return withdrawal, nil
+	}
+	if !updated {
+		log.Printf("INFO: Withdrawal %s was settled by its webhook before its OTP finalization was recorded", withdrawal.ID)
+		return supabaseService.GetWithdrawalByID(withdrawal.ID)
+	}
+	return processing, nil
+}
+
+// ResendWithdrawalOTP asks Paystack to send a new OTP for a withdrawal awaiting one. It
+// returns ErrWithdrawalStateConflict when the withdrawal is not awaiting an OTP.
+//
+// paystack.TransferService.ResendOTP sends its body as url.Values, which encode to JSON
+// arrays, so the request is sent directly.
+func (s *PaystackService) ResendWithdrawalOTP(withdrawalID string, supabaseService *SupabaseService) (*models.Withdrawal, error) {
+	withdrawal, transferCode, err := s.withdrawalAwaitingOTP(withdrawalID, supabaseService)
+	if err != nil {
+		return nil, err
+	}
+
+	resendReq := map[string]interface{}{
+		"transfer_code": transferCode,
+		"reason":        "transfer",
+	}
+	if err := s.Client.Call("POST", "/transfer/resend_otp", resendReq, &paystack.Response{}); err != nil {
+		return nil, fmt.Errorf("failed to resend OTP for Paystack transfer %s: %w", transferCode, err)
+	}
+
+	log.Printf("INFO: OTP resent for Paystack transfer %s (withdrawal %s)", transferCode, withdrawal.ID)
+	return withdrawal, nil
+}
+
+// withdrawalAwaitingOTP fetches a withdrawal and checks that its transfer is waiting
+// for an OTP.
+func (s *PaystackService) withdrawalAwaitingOTP(withdrawalID string, supabaseService *SupabaseService) (*models.Withdrawal, string, error) {
+	withdrawal, err := supabaseService.GetWithdrawalByID(withdrawalID)
+	if err != nil {
+		return nil, "", err
+	}
+	if withdrawal.Status != models.WithdrawalAwaitingOTP || withdrawal.TransferCode == nil {
+		return nil, "", fmt.Errorf("%w: withdrawal %s is %s, not %s", ErrWithdrawalStateConflict, withdrawal.ID, withdrawal.Status, models.WithdrawalAwaitingOTP)
+	}
+	return withdrawal, *withdrawal.TransferCode, nil
+}
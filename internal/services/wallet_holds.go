@@ -0,0 +1,59 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// PlaceWalletHold reserves amountKobo of a user's available datacredit. It returns
+// ErrInsufficientDatacredit when less than that is available.
+func (s *SupabaseService) PlaceWalletHold(userID string, amountKobo int64, reason, reference string) (*models.WalletHold, error) {
+	params := map[string]interface{}{
+		"p_user_id":   userID,
+		"p_amount":    amountKobo,
+		"p_reason":    reason,
+		"p_reference": nullIfEmpty(reference),
+	}
+
+	var hold models.WalletHold
+	if err := s.callRPC("place_wallet_hold", params, &hold); err != nil {
+		return nil, fmt.Errorf("error placing %d kobo hold for user %s: %w", amountKobo, userID, err)
+	}
+	return &hold, nil
+}
+
+// ReleaseWalletHold makes a held amount available again. It returns
+// ErrWalletHoldNotActive if the hold was already captured or released.
+func (s *SupabaseService) ReleaseWalletHold(holdID int64) (*models.WalletHold, error) {
+	var hold models.WalletHold
+	if err := s.callRPC("release_wallet_hold", map[string]interface{}{"p_hold_id": holdID}, &hold); err != nil {
+		return nil, fmt.Errorf("error releasing wallet hold %d: %w", holdID, err)
+	}
+	return &hold, nil
+}
+
+// CaptureWalletHold debits a held amount from the wallet as operation, journaled against
+// the operation's ledger counterparty. It returns ErrWalletHoldNotActive if the hold was
+// already captured or released.
+func (s *SupabaseService) CaptureWalletHold(holdID int64, operation, description string, externalRef *string, metadata map[string]interface{}) (*models.WalletChange, error) {
+	counterparty, ok := ledgerCounterparties[operation]
+	if !ok || counterparty.datacredit == "" {
+		return nil, fmt.Errorf("no datacredit ledger counterparty configured for operation %q", operation)
+	}
+
+	params := map[string]interface{}{
+		"p_hold_id":      holdID,
+		"p_operation":    operation,
+		"p_description":  description,
+		"p_external_ref": externalRef,
+		"p_metadata":     metadata,
+		"p_counterparty": counterparty.datacredit,
+	}
+
+	var change models.WalletChange
+	if err := s.callRPC("capture_wallet_hold", params, &change); err != nil {
+		return nil, fmt.Errorf("error capturing wallet hold %d: %w", holdID, err)
+	}
+	return &change, nil
+}
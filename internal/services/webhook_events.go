@@ -0,0 +1,134 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// webhookClaimLease is how long a claimed event may stay 'processing' before another
+// delivery may claim it again. It is well above the time any handler takes.
+const webhookClaimLease = 5 * time.Minute
+
+// WebhookEventKey returns the key a Paystack event is deduplicated on: the
+// transaction reference for charges, the transfer code for transfers, falling back
+// to the event's data ID and finally to a hash of the raw body. Paystack sends
+// charge.dispute.remind for the same dispute until it is answered, so a reminder is
+// keyed on its dispute and a hash of its body: redeliveries of one reminder share a
+// key, later reminders do not.
+func WebhookEventKey(payload models.PaystackWebhookPayload, rawBody []byte) string {
+	key := webhookDataKey(payload.Data)
+	switch {
+	case key == "":
+		return webhookBodyHash(rawBody)
+	case payload.Event == "charge.dispute.remind":
+		return key + ":" + webhookBodyHash(rawBody)
+	}
+	return key
+}
+
+// webhookDataKey returns the first non-empty reference, transfer_code or id in an
+// event's data, or "" if it has none.
+func webhookDataKey(data interface{}) string {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, field := range []string{"reference", "transfer_code", "id"} {
+		switch v := fields[field].(type) {
+		case string:
+			if v != "" {
+				return v
+			}
+		case float64:
+			return fmt.Sprintf("%.0f", v)
+		}
+	}
+	return ""
+}
+
+func webhookBodyHash(rawBody []byte) string {
+	sum := sha256.Sum256(rawBody)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ClaimWebhookEvent records a webhook delivery and reports whether the caller should
+// process it. It returns false for deliveries of an event that is already processed
+// or currently being processed; events whose earlier attempt failed, or whose claim is
+// older than webhookClaimLease (the handler died), are claimed again.
+func (s *SupabaseService) ClaimWebhookEvent(eventType, eventKey string, rawPayload []byte) (*models.ProcessedWebhookEvent, bool, error) {
+	params := map[string]interface{}{
+		"p_event_type": eventType,
+		"p_event_key":  eventKey,
+		"p_payload":    json.RawMessage(rawPayload),
+		"p_lease":      fmt.Sprintf("%d seconds", int(webhookClaimLease.Seconds())),
+	}
+
+	var claim struct {
+		Claimed bool                         `json:"claimed"`
+		Event   models.ProcessedWebhookEvent `json:"event"`
+	}
+	if err := s.callRPC("claim_webhook_event", params, &claim); err != nil {
+		return nil, false, fmt.Errorf("error claiming webhook event %s/%s: %w", eventType, eventKey, err)
+	}
+	return &claim.Event, claim.Claimed, nil
+}
+
+// CompleteWebhookEvent stores the outcome of processing a claimed event. A failed
+// event can be claimed again by Paystack's next retry.
+func (s *SupabaseService) CompleteWebhookEvent(eventID int64, status string, result map[string]interface{}) error {
+	updateData := map[string]interface{}{
+		"status":       status,
+		"result":       result,
+		"processed_at": time.Now(),
+	}
+
+	var updated []models.ProcessedWebhookEvent
+	_, err := s.Client.From("processed_webhook_events").
+		Update(updateData, "", "").
+		Eq("id", fmt.Sprintf("%d", eventID)).
+		ExecuteTo(&updated)
+	if err != nil {
+		return fmt.Errorf("error completing webhook event %d: %w", eventID, err)
+	}
+	if len(updated) == 0 {
+		return fmt.Errorf("webhook event %d not found", eventID)
+	}
+	return nil
+}
+
+// ProcessEventOnce is the idempotent path shared by the Paystack webhook and any other
+// caller that applies a Paystack event (client verification, the payment intent sweeper).
+// It claims (eventType, eventKey) and runs process only when the claim succeeds, recording
+// process's result, or its error so a later retry can claim the event again.
+// processed is false when the event had already been handled; result is then the
+// recorded result of the first processing.
+func (s *SupabaseService) ProcessEventOnce(eventType, eventKey string, rawPayload []byte, process func() (map[string]interface{}, error)) (event *models.ProcessedWebhookEvent, processed bool, result map[string]interface{}, err error) {
+	event, claimed, err := s.ClaimWebhookEvent(eventType, eventKey, rawPayload)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	if !claimed {
+		return event, false, event.Result, nil
+	}
+
+	result, err = process()
+	if err != nil {
+		if cerr := s.CompleteWebhookEvent(event.ID, models.WebhookEventFailed, map[string]interface{}{"error": err.Error()}); cerr != nil {
+			log.Printf("WARNING: Failed to record failure of event %s/%s: %v", eventType, eventKey, cerr)
+		}
+		return event, false, nil, err
+	}
+
+	if cerr := s.CompleteWebhookEvent(event.ID, models.WebhookEventProcessed, result); cerr != nil {
+		// The event has been applied; a retry within the lease skips it, and one after it
+		// re-applies it, which the handlers treat as a no-op.
+		log.Printf("WARNING: Event %s/%s was applied but its result could not be recorded: %v", eventType, eventKey, cerr)
+	}
+	return event, true, result, nil
+}
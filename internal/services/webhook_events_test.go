@@ -0,0 +1,70 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+func TestWebhookEventKey(t *testing.T) {
+	rawBody := []byte(`{"event":"charge.success"}`)
+	tests := []struct {
+		name string
+		data interface{}
+		want string
+	}{
+		{"reference", map[string]interface{}{"reference": "ref_1", "transfer_code": "TRF_1", "id": float64(7)}, "ref_1"},
+		{"transfer code", map[string]interface{}{"reference": "", "transfer_code": "TRF_1", "id": float64(7)}, "TRF_1"},
+		{"numeric id", map[string]interface{}{"id": float64(1234567890)}, "1234567890"},
+		{"string id", map[string]interface{}{"id": "dsp_1"}, "dsp_1"},
+		{"no key fields", map[string]interface{}{"amount": float64(100)}, ""},
+		{"not an object", []interface{}{"x"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WebhookEventKey(models.PaystackWebhookPayload{Event: "charge.success", Data: tt.data}, rawBody)
+			if tt.want == "" {
+				if !strings.HasPrefix(got, "sha256:") || len(got) != len("sha256:")+64 {
+					t.Errorf("WebhookEventKey() = %q, want a sha256 hash of the body", got)
+				}
+				return
+			}
+			if got != tt.want {
+				t.Errorf("WebhookEventKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWebhookEventKeyHashesBody(t *testing.T) {
+	payload := models.PaystackWebhookPayload{Event: "charge.success"}
+	first := WebhookEventKey(payload, []byte(`{"a":1}`))
+	if again := WebhookEventKey(payload, []byte(`{"a":1}`)); again != first {
+		t.Errorf("same body hashed to %q and %q", first, again)
+	}
+	if other := WebhookEventKey(payload, []byte(`{"a":2}`)); other == first {
+		t.Errorf("different bodies both hashed to %q", first)
+	}
+}
+
+func TestWebhookEventKeyDisputeReminders(t *testing.T) {
+	dispute := map[string]interface{}{"id": float64(42), "status": "awaiting-merchant-feedback"}
+	remind := func(body string) string {
+		return WebhookEventKey(models.PaystackWebhookPayload{Event: "charge.dispute.remind", Data: dispute}, []byte(body))
+	}
+	first := remind(`{"event":"charge.dispute.remind","data":{"id":42,"updated_at":"2026-10-01T00:00:00Z"}}`)
+	if !strings.HasPrefix(first, "42:sha256:") {
+		t.Errorf("reminder key = %q, want the dispute ID followed by a body hash", first)
+	}
+	if again := remind(`{"event":"charge.dispute.remind","data":{"id":42,"updated_at":"2026-10-01T00:00:00Z"}}`); again != first {
+		t.Errorf("redelivered reminder keyed %q, want %q", again, first)
+	}
+	if later := remind(`{"event":"charge.dispute.remind","data":{"id":42,"updated_at":"2026-10-03T00:00:00Z"}}`); later == first {
+		t.Errorf("later reminder keyed %q, the same as the first", later)
+	}
+	create := WebhookEventKey(models.PaystackWebhookPayload{Event: "charge.dispute.create", Data: dispute}, []byte(`{}`))
+	if create != "42" {
+		t.Errorf("dispute create key = %q, want %q", create, "42")
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// withdrawalApprovalReason reports why a withdrawal needs admin approval before its
+// transfer is started, or "" if it does not: its amount is above
+// Cfg.WithdrawalApprovalThreshold, or the user is flagged for withdrawal review.
+func (s *PaystackService) withdrawalApprovalReason(userID string, amount int64, supabaseService *SupabaseService) (string, error) {
+	if threshold := s.Cfg.WithdrawalApprovalThreshold; threshold > 0 && amount > threshold {
+		return fmt.Sprintf("amount %d kobo is above the approval threshold of %d kobo", amount, threshold), nil
+	}
+
+	flag, err := supabaseService.GetWithdrawalReviewFlag(userID)
+	if err != nil {
+		return "", err
+	}
+	if flag != nil {
+		return "user is flagged for withdrawal review: " + flag.Reason, nil
+	}
+	return "", nil
+}
+
+// ApproveWithdrawal approves a withdrawal awaiting approval and starts its Paystack
+// transfer, or queues it in payout-batching mode. The admin must not be the user who requested the withdrawal
+// (ErrSelfApproval). Once approved, the withdrawal is handled like any other: if the
+// transfer cannot be started it fails or stays pending exactly as in InitiateWithdrawal.
+func (s *PaystackService) ApproveWithdrawal(withdrawalID, adminID string, supabaseService *SupabaseService) (*models.Withdrawal, error) {
+	approved, err := supabaseService.ApproveWithdrawal(withdrawalID, adminID)
+	if err != nil {
+		return nil, err
+	}
+	return s.queueOrStartTransfer(approved, supabaseService)
+}
+
+// ApproveWithdrawal moves a withdrawal from pending_approval to pending, recording the
+// approving admin. It returns ErrWithdrawalStateConflict when the withdrawal is not
+// awaiting approval and ErrSelfApproval when the admin requested it.
+func (s *SupabaseService) ApproveWithdrawal(withdrawalID, adminID string) (*models.Withdrawal, error) {
+	params := map[string]interface{}{
+		"p_withdrawal_id": withdrawalID,
+		"p_admin_id":      adminID,
+	}
+
+	var approved models.Withdrawal
+	if err := s.callRPC("approve_withdrawal", params, &approved); err != nil {
+		return nil, fmt.Errorf("error approving withdrawal %s: %w", withdrawalID, err)
+	}
+	return &approved, nil
+}
+
+// RejectWithdrawal rejects a withdrawal awaiting approval and releases its hold, in one
+// database transaction. It returns ErrWithdrawalStateConflict when the withdrawal is not
+// awaiting approval.
+func (s *SupabaseService) RejectWithdrawal(withdrawalID, adminID, reason string) (*models.Withdrawal, error) {
+	params := map[string]interface{}{
+		"p_withdrawal_id": withdrawalID,
+		"p_admin_id":      adminID,
+		"p_reason":        reason,
+	}
+
+	var rejected models.Withdrawal
+	if err := s.callRPC("reject_withdrawal", params, &rejected); err != nil {
+		return nil, fmt.Errorf("error rejecting withdrawal %s: %w", withdrawalID, err)
+	}
+	return &rejected, nil
+}
+
+// ListWithdrawalsByStatus returns all users' withdrawals in a status, oldest first, for
+// the admin review queue.
+func (s *SupabaseService) ListWithdrawalsByStatus(status string, limit, offset int) ([]models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Select("*", "", false).
+		Eq("status", status).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %s withdrawals: %w", status, err)
+	}
+	return withdrawals, nil
+}
+
+// GetWithdrawalReviewFlag returns the user's withdrawal review flag, or nil if the user
+// is not flagged.
+func (s *SupabaseService) GetWithdrawalReviewFlag(userID string) (*models.WithdrawalReviewFlag, error) {
+	var flags []models.WithdrawalReviewFlag
+	_, err := s.Client.From("withdrawal_review_flags").
+		Select("*", "", false).
+		Eq("user_id", userID).
+		ExecuteTo(&flags)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawal review flag for user %s: %w", userID, err)
+	}
+	if len(flags) == 0 {
+		return nil, nil
+	}
+	return &flags[0], nil
+}
+
+// FlagUserForWithdrawalReview makes all of a user's future withdrawals need admin
+// approval. Flagging an already flagged user replaces the reason.
+func (s *SupabaseService) FlagUserForWithdrawalReview(userID, reason, adminID string) (*models.WithdrawalReviewFlag, error) {
+	flagData := map[string]interface{}{
+		"user_id":    userID,
+		"reason":     reason,
+		"flagged_by": adminID,
+	}
+
+	var flags []models.WithdrawalReviewFlag
+	_, err := s.Client.From("withdrawal_review_flags").
+		Upsert(flagData, "user_id", "", "").
+		ExecuteTo(&flags)
+	if err != nil {
+		return nil, fmt.Errorf("error flagging user %s for withdrawal review: %w", userID, err)
+	}
+	if len(flags) == 0 {
+		return nil, fmt.Errorf("no withdrawal review flag returned for user %s", userID)
+	}
+	return &flags[0], nil
+}
+
+// UnflagUserForWithdrawalReview removes a user's withdrawal review flag. It returns
+// ErrWithdrawalReviewFlagNotFound when the user is not flagged.
+func (s *SupabaseService) UnflagUserForWithdrawalReview(userID string) error {
+	var deleted []models.WithdrawalReviewFlag
+	_, err := s.Client.From("withdrawal_review_flags").
+		Delete("", "").
+		Eq("user_id", userID).
+		ExecuteTo(&deleted)
+	if err != nil {
+		return fmt.Errorf("error removing withdrawal review flag for user %s: %w", userID, err)
+	}
+	if len(deleted) == 0 {
+		return fmt.Errorf("%w: %s", ErrWithdrawalReviewFlagNotFound, userID)
+	}
+	return nil
+}
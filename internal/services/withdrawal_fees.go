@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// paystackTransferFeeTiers is Paystack's NGN transfer fee schedule: the fee for amounts
+// up to each bound, in kobo. Amounts above the last bound pay paystackTransferFeeTop.
+var paystackTransferFeeTiers = []struct{ upTo, fee int64 }{
+	{upTo: 500000, fee: 1000},  // Up to ₦5,000: ₦10
+	{upTo: 5000000, fee: 2500}, // Up to ₦50,000: ₦25
+}
+
+const paystackTransferFeeTop = 5000 // Above ₦50,000: ₦50
+
+// WithdrawalFee returns the fee charged on a withdrawal of amount kobo under the
+// configured fee mode.
+func (s *PaystackService) WithdrawalFee(amount int64) int64 {
+	switch s.Cfg.WithdrawalFeeMode {
+	case config.WithdrawalFeeModeFlat:
+		return s.Cfg.WithdrawalFeeFlat
+	case config.WithdrawalFeeModePercent:
+		// Round up so fractional kobo are never given away. A fee too large for an int64
+		// saturates; the cap or checkWithdrawalAmount then deals with it.
+		fee, ok := mulDivCeil(amount, s.Cfg.WithdrawalFeePercentBps, 10000)
+		if !ok {
+			fee = math.MaxInt64
+		}
+		if s.Cfg.WithdrawalFeeCap > 0 && fee > s.Cfg.WithdrawalFeeCap {
+			fee = s.Cfg.WithdrawalFeeCap
+		}
+		return fee
+	case config.WithdrawalFeeModePaystack:
+		for _, tier := range paystackTransferFeeTiers {
+			if amount <= tier.upTo {
+				return tier.fee
+			}
+		}
+		return paystackTransferFeeTop
+	default:
+		return 0
+	}
+}
+
+// checkWithdrawalAmount enforces the per-transaction limits and that the amount covers
+// its fee. The daily limit is enforced by create_withdrawal.
+func (s *PaystackService) checkWithdrawalAmount(amount, fee int64) error {
+	if s.Cfg.WithdrawalMinAmount > 0 && amount < s.Cfg.WithdrawalMinAmount {
+		return fmt.Errorf("%w of %d kobo", ErrWithdrawalBelowMinimum, s.Cfg.WithdrawalMinAmount)
+	}
+	if s.Cfg.WithdrawalMaxAmount > 0 && amount > s.Cfg.WithdrawalMaxAmount {
+		return fmt.Errorf("%w of %d kobo", ErrWithdrawalAboveMaximum, s.Cfg.WithdrawalMaxAmount)
+	}
+	if amount <= fee {
+		return fmt.Errorf("%w of %d kobo", ErrWithdrawalBelowFee, fee)
+	}
+	return nil
+}
+
+// QuoteWithdrawal returns the fee and net payout in NGN for withdrawing amount kobo to
+// the payout account recipientID (or the user's default), with the user's limits. It
+// returns the same limit and payout account errors InitiateWithdrawal would, including
+// ErrPayoutCurrency for an account not in NGN, except that the daily limit is only
+// reported, not enforced.
+func (s *PaystackService) QuoteWithdrawal(userID, recipientID string, amount int64, supabaseService *SupabaseService) (*models.WithdrawalQuote, error) {
+	fee := s.WithdrawalFee(amount)
+	if err := s.checkWithdrawalAmount(amount, fee); err != nil {
+		return nil, err
+	}
+
+	payoutAccount, err := supabaseService.GetPayoutAccountForWithdrawal(userID, recipientID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPayoutCurrency(payoutAccount.Currency); err != nil {
+		return nil, err
+	}
+
+	withdrawnToday, err := supabaseService.GetWithdrawalDailyTotal(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WithdrawalQuote{
+		Amount:         amount,
+		Fee:            fee,
+		NetAmount:      amount - fee,
+		Currency:       payoutCurrency,
+		FeeMode:        s.Cfg.WithdrawalFeeMode,
+		MinAmount:      s.Cfg.WithdrawalMinAmount,
+		MaxAmount:      s.Cfg.WithdrawalMaxAmount,
+		DailyLimit:     s.Cfg.WithdrawalDailyLimit,
+		WithdrawnToday: withdrawnToday,
+	}, nil
+}
+
+// GetWithdrawalDailyTotal returns how much a user has withdrawn today (Lagos time),
+// including withdrawals still in flight.
+func (s *SupabaseService) GetWithdrawalDailyTotal(userID string) (int64, error) {
+	var total int64
+	if err := s.callRPC("withdrawal_daily_total", map[string]interface{}{"p_user_id": userID}, &total); err != nil {
+		return 0, fmt.Errorf("error fetching today's withdrawals for user %s: %w", userID, err)
+	}
+	return total, nil
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+)
+
+func TestWithdrawalFee(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    config.Config
+		amount int64
+		want   int64
+	}{
+		{"none", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModeNone}, 100000, 0},
+		{"unknown mode", config.Config{WithdrawalFeeMode: "bogus"}, 100000, 0},
+		{"flat", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModeFlat, WithdrawalFeeFlat: 5000}, 100000, 5000},
+		{"percent exact", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 150}, 100000, 1500},
+		{"percent rounds up", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 150}, 100001, 1501},
+		{"percent rounds up a fraction of a kobo", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 1}, 1, 1},
+		{"percent below cap", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 150, WithdrawalFeeCap: 2000}, 100000, 1500},
+		{"percent capped", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 150, WithdrawalFeeCap: 2000}, 1000000, 2000},
+		{"percent without overflow", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 150}, math.MaxInt64 / 100, 1383505805528217},
+		{"percent overflow capped", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 20000, WithdrawalFeeCap: 5000}, math.MaxInt64, 5000},
+		{"percent overflow saturates", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePercent, WithdrawalFeePercentBps: 20000}, math.MaxInt64, math.MaxInt64},
+		{"paystack lowest tier", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePaystack}, 100000, 1000},
+		{"paystack lowest tier bound", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePaystack}, 500000, 1000},
+		{"paystack middle tier", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePaystack}, 500001, 2500},
+		{"paystack middle tier bound", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePaystack}, 5000000, 2500},
+		{"paystack top", config.Config{WithdrawalFeeMode: config.WithdrawalFeeModePaystack}, 5000001, 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			s := &PaystackService{Cfg: &cfg}
+			if got := s.WithdrawalFee(tt.amount); got != tt.want {
+				t.Errorf("WithdrawalFee(%d) = %d, want %d", tt.amount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckWithdrawalAmount(t *testing.T) {
+	limits := config.Config{WithdrawalMinAmount: 10000, WithdrawalMaxAmount: 1000000}
+	tests := []struct {
+		name    string
+		cfg     config.Config
+		amount  int64
+		fee     int64
+		wantErr error
+	}{
+		{"no limits", config.Config{}, 1, 0, nil},
+		{"within limits", limits, 50000, 1000, nil},
+		{"at minimum", limits, 10000, 0, nil},
+		{"below minimum", limits, 9999, 0, ErrWithdrawalBelowMinimum},
+		{"at maximum", limits, 1000000, 0, nil},
+		{"above maximum", limits, 1000001, 0, ErrWithdrawalAboveMaximum},
+		{"equal to fee", config.Config{}, 1000, 1000, ErrWithdrawalBelowFee},
+		{"below fee", config.Config{}, 999, 1000, ErrWithdrawalBelowFee},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			s := &PaystackService{Cfg: &cfg}
+			err := s.checkWithdrawalAmount(tt.amount, tt.fee)
+			if tt.wantErr == nil && err != nil {
+				t.Fatalf("checkWithdrawalAmount(%d, %d) = %v, want nil", tt.amount, tt.fee, err)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Fatalf("checkWithdrawalAmount(%d, %d) = %v, want %v", tt.amount, tt.fee, err, tt.wantErr)
+			}
+		})
+	}
+}
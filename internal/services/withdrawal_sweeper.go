@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/tedobanks/datagram_payment_processor/internal/config"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// WithdrawalSweeper periodically verifies pending, awaiting OTP and processing withdrawals
+// that have not been updated for a while with Paystack, and settles them as Paystack
+// reports. It closes out transfers whose start or OTP finalization timed out or failed on
+// Paystack's side, and transfers whose webhook never arrived.
+type WithdrawalSweeper struct {
+	PaystackService *PaystackService
+	SupabaseService *SupabaseService
+	Interval        time.Duration
+	StaleAfter      time.Duration
+	AbandonAfter    time.Duration
+}
+
+// NewWithdrawalSweeper creates a sweeper using the intervals from cfg.
+func NewWithdrawalSweeper(ps *PaystackService, ss *SupabaseService, cfg *config.Config) *WithdrawalSweeper {
+	return &WithdrawalSweeper{
+		PaystackService: ps,
+		SupabaseService: ss,
+		Interval:        cfg.WithdrawalSweepInterval,
+		StaleAfter:      cfg.WithdrawalStaleAfter,
+		AbandonAfter:    cfg.WithdrawalAbandonAfter,
+	}
+}
+
+// Run sweeps every Interval until ctx is cancelled. A zero Interval disables the sweeper.
+func (w *WithdrawalSweeper) Run(ctx context.Context) {
+	if w.Interval <= 0 {
+		log.Println("INFO: Withdrawal sweeper disabled.")
+		return
+	}
+	log.Printf("INFO: Withdrawal sweeper running every %s (stale after %s, abandon after %s)", w.Interval, w.StaleAfter, w.AbandonAfter)
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.SweepOnce()
+		}
+	}
+}
+
+// SweepOnce verifies one batch of unsettled withdrawals and returns how many changed state.
+func (w *WithdrawalSweeper) SweepOnce() int {
+	now := time.Now()
+	withdrawals, err := w.SupabaseService.ListUnsettledWithdrawals(now.Add(-w.StaleAfter), sweepBatchSize)
+	if err != nil {
+		log.Printf("ERROR: Withdrawal sweep failed to list withdrawals: %v", err)
+		return 0
+	}
+
+	changed := 0
+	for i := range withdrawals {
+		if w.sweepWithdrawal(&withdrawals[i], now) {
+			changed++
+		}
+	}
+	if len(withdrawals) > 0 {
+		log.Printf("INFO: Withdrawal sweep checked %d withdrawal(s), %d changed state", len(withdrawals), changed)
+	}
+	return changed
+}
+
+// sweepWithdrawal verifies a single withdrawal's transfer with Paystack and applies what
+// Paystack reports.
+func (w *WithdrawalSweeper) sweepWithdrawal(withdrawal *models.Withdrawal, now time.Time) bool {
+	transfer, err := w.PaystackService.verifyTransfer(withdrawal.Reference)
+	if err != nil {
+		if !isPaystackNotFound(err) {
+			log.Printf("WARNING: Sweeper could not verify transfer of withdrawal %s: %v", withdrawal.ID, err)
+			w.markVerified(withdrawal)
+			return false
+		}
+		if withdrawal.Status == models.WithdrawalPending && now.Sub(withdrawal.UpdatedAt) >= w.AbandonAfter {
+			// Transfers are created with our reference, so Paystack would have it by now
+			// if the request that started it had reached Paystack.
+			log.Printf("INFO: Paystack has no transfer for withdrawal %s; failing it and releasing its hold", withdrawal.ID)
+			w.PaystackService.failWithdrawal(withdrawal, "transfer never reached Paystack", w.SupabaseService)
+			return true
+		}
+		if withdrawal.Status == models.WithdrawalProcessing {
+			log.Printf("ERROR: Withdrawal %s is processing but Paystack has no transfer with reference %s; settle it manually", withdrawal.ID, withdrawal.Reference)
+		}
+		w.markVerified(withdrawal)
+		return false
+	}
+
+	switch transfer.Status {
+	case TransferOutcomeSuccess, TransferOutcomeFailed, TransferOutcomeReversed:
+		// Same idempotent path as the transfer webhooks.
+		_, applied, err := w.PaystackService.ApplyTransferEvent("transfer."+transfer.Status, *transfer, w.SupabaseService)
+		if err != nil {
+			log.Printf("ERROR: Sweeper failed to settle withdrawal %s as %s: %v", withdrawal.ID, transfer.Status, err)
+			return false
+		}
+		return applied
+	}
+
+	switch {
+	case withdrawal.Status == models.WithdrawalPending && transfer.TransferCode != "":
+		// Paystack accepted the transfer but we never heard back: record it as started.
+		started := w.PaystackService.recordTransferStarted(withdrawal, transfer.TransferCode, transfer.ID, transfer.Status, w.SupabaseService)
+		if started.Status != models.WithdrawalPending {
+			log.Printf("INFO: Withdrawal %s moved from pending to %s (Paystack status %s)", withdrawal.ID, started.Status, transfer.Status)
+			return true
+		}
+	case withdrawal.Status == models.WithdrawalAwaitingOTP && transfer.Status != paystackTransferStatusOTP:
+		// The OTP was accepted but the finalize response never reached us.
+		if _, updated, err := w.SupabaseService.MarkWithdrawalOTPFinalized(withdrawal.ID); err != nil {
+			log.Printf("ERROR: Sweeper failed to mark withdrawal %s processing: %v", withdrawal.ID, err)
+		} else if updated {
+			log.Printf("INFO: Withdrawal %s moved from awaiting_otp to processing (Paystack status %s)", withdrawal.ID, transfer.Status)
+			return true
+		}
+	}
+	w.markVerified(withdrawal)
+	return false
+}
+
+// markVerified records that a withdrawal was checked without changing its status, so the
+// next sweep does not pick it up again until it is stale once more.
+func (w *WithdrawalSweeper) markVerified(withdrawal *models.Withdrawal) {
+	if err := w.SupabaseService.MarkWithdrawalTransferVerified(withdrawal.ID); err != nil {
+		log.Printf("WARNING: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package services
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/supabase-community/postgrest-go"
+	"github.com/tedobanks/datagram_payment_processor/internal/models"
+)
+
+// Transfer outcomes passed to SettleWithdrawal, named after Paystack's transfer.* events.
+const (
+	TransferOutcomeSuccess  = "success"
+	TransferOutcomeFailed   = "failed"
+	TransferOutcomeReversed = "reversed"
+)
+
+// NewTransferReference generates a unique Paystack transfer reference. Sending our own
+// reference makes a retried transfer request idempotent on Paystack's side and lets
+// transfer webhooks be matched to the withdrawal before its transfer code is stored.
+func NewTransferReference() (string, error) {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("error generating transfer reference: %w", err)
+	}
+	return "DGW_" + hex.EncodeToString(buf), nil
+}
+
+// CreateWithdrawal stores a new withdrawal and places a hold on the user's wallet for its
+// amount, in one database transaction. The withdrawal is pending, or pending_approval when
+// it has an ApprovalReason. It returns ErrInsufficientDatacredit when the user's available
+// balance is too low and ErrWithdrawalDailyLimit when it would take the user's withdrawals
+// today past dailyLimit kobo (0 means no limit).
+func (s *SupabaseService) CreateWithdrawal(withdrawal models.Withdrawal, dailyLimit int64) (*models.Withdrawal, error) {
+	params := map[string]interface{}{
+		"p_user_id":           withdrawal.UserID,
+		"p_amount":            withdrawal.Amount,
+		"p_fee":               withdrawal.Fee,
+		"p_currency":          withdrawal.Currency,
+		"p_reference":         withdrawal.Reference,
+		"p_recipient_code":    withdrawal.RecipientCode,
+		"p_payout_account_id": withdrawal.PayoutAccountID,
+		"p_approval_reason":   withdrawal.ApprovalReason,
+		"p_daily_limit":       dailyLimit,
+	}
+
+	var created models.Withdrawal
+	if err := s.callRPC("create_withdrawal", params, &created); err != nil {
+		return nil, fmt.Errorf("error creating withdrawal %s: %w", withdrawal.Reference, err)
+	}
+	return &created, nil
+}
+
+// UpdateWithdrawal applies a partial update to a withdrawal.
+func (s *SupabaseService) UpdateWithdrawal(id string, updateData map[string]interface{}) (*models.Withdrawal, error) {
+	updateData["updated_at"] = time.Now()
+
+	var updated []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Update(updateData, "", "").
+		Eq("id", id).
+		ExecuteTo(&updated)
+	if err != nil {
+		return nil, fmt.Errorf("error updating withdrawal %s: %w", id, err)
+	}
+	if len(updated) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrWithdrawalNotFound, id)
+	}
+	return &updated[0], nil
+}
+
+// MarkWithdrawalProcessing records that Paystack accepted a pending withdrawal's transfer.
+// updated is false when the withdrawal is no longer pending, which happens when the
+// transfer's webhook settled it before this was called.
+func (s *SupabaseService) MarkWithdrawalProcessing(id, transferCode string, transferID int64) (withdrawal *models.Withdrawal, updated bool, err error) {
+	return s.transitionWithdrawal(id, models.WithdrawalPending, transferStartedUpdate(models.WithdrawalProcessing, transferCode, transferID))
+}
+
+// MarkWithdrawalAwaitingOTP records that Paystack is holding a pending withdrawal's
+// transfer until it is finalized with an OTP. updated is false when the withdrawal is
+// no longer pending.
+func (s *SupabaseService) MarkWithdrawalAwaitingOTP(id, transferCode string, transferID int64) (withdrawal *models.Withdrawal, updated bool, err error) {
+	return s.transitionWithdrawal(id, models.WithdrawalPending, transferStartedUpdate(models.WithdrawalAwaitingOTP, transferCode, transferID))
+}
+
+// QueueWithdrawal moves a pending withdrawal to the payout batch queue. updated is false
+// when the withdrawal is no longer pending.
+func (s *SupabaseService) QueueWithdrawal(id string) (withdrawal *models.Withdrawal, updated bool, err error) {
+	return s.transitionWithdrawal(id, models.WithdrawalPending, map[string]interface{}{
+		"status": models.WithdrawalQueued,
+	})
+}
+
+// transferStartedUpdate is the update recording a transfer Paystack accepted. Bulk
+// transfer results carry no transfer ID, so a zero transferID is not stored.
+func transferStartedUpdate(status, transferCode string, transferID int64) map[string]interface{} {
+	updateData := map[string]interface{}{
+		"status":        status,
+		"transfer_code": transferCode,
+	}
+	if transferID != 0 {
+		updateData["paystack_transfer_id"] = transferID
+	}
+	return updateData
+}
+
+// MarkWithdrawalOTPFinalized records that a withdrawal's transfer was finalized with its
+// OTP. updated is false when the withdrawal is no longer awaiting its OTP, which happens
+// when the transfer's webhook settled it first.
+func (s *SupabaseService) MarkWithdrawalOTPFinalized(id string) (withdrawal *models.Withdrawal, updated bool, err error) {
+	return s.transitionWithdrawal(id, models.WithdrawalAwaitingOTP, map[string]interface{}{
+		"status": models.WithdrawalProcessing,
+	})
+}
+
+// transitionWithdrawal applies updateData to a withdrawal only if it is still in
+// fromStatus. updated is false when it is not.
+func (s *SupabaseService) transitionWithdrawal(id, fromStatus string, updateData map[string]interface{}) (withdrawal *models.Withdrawal, updated bool, err error) {
+	updateData["updated_at"] = time.Now()
+
+	var rows []models.Withdrawal
+	_, err = s.Client.From("withdrawals").
+		Update(updateData, "", "").
+		Eq("id", id).
+		Eq("status", fromStatus).
+		ExecuteTo(&rows)
+	if err != nil {
+		return nil, false, fmt.Errorf("error moving withdrawal %s from %s to %v: %w", id, fromStatus, updateData["status"], err)
+	}
+	if len(rows) == 0 {
+		return nil, false, nil
+	}
+	return &rows[0], true, nil
+}
+
+// SettleWithdrawal applies a transfer outcome to a withdrawal, found by its ID, our
+// reference or Paystack's transfer code (whichever are non-empty). Success captures the
+// withdrawal's hold, debiting the wallet; failure releases the hold. A reversal after
+// success re-credits the wallet with a compensating withdrawal_reversal transaction.
+// changed is false when the outcome had already been applied.
+func (s *SupabaseService) SettleWithdrawal(withdrawalID, reference, transferCode, outcome, reason string) (withdrawal *models.Withdrawal, changed bool, err error) {
+	description := fmt.Sprintf("Datacredit withdrawal to bank (Paystack Transfer Code: %s)", transferCode)
+	if outcome != TransferOutcomeSuccess {
+		description = fmt.Sprintf("Return of %s withdrawal to wallet (Paystack Transfer Code: %s)", outcome, transferCode)
+	}
+
+	params := map[string]interface{}{
+		"p_withdrawal_id": nullIfEmpty(withdrawalID),
+		"p_reference":     nullIfEmpty(reference),
+		"p_transfer_code": nullIfEmpty(transferCode),
+		"p_outcome":       outcome,
+		"p_reason":        nullIfEmpty(reason),
+		"p_description":   description,
+		"p_counterparty":  ledgerCounterparties[models.OperationWithdrawal].datacredit,
+	}
+
+	var result struct {
+		Changed    bool              `json:"changed"`
+		Withdrawal models.Withdrawal `json:"withdrawal"`
+	}
+	if err := s.callRPC("settle_withdrawal", params, &result); err != nil {
+		return nil, false, fmt.Errorf("error settling withdrawal for transfer %s (reference %s): %w", transferCode, reference, err)
+	}
+	return &result.Withdrawal, result.Changed, nil
+}
+
+// ListWithdrawals returns a user's withdrawals, newest first, optionally filtered by status.
+func (s *SupabaseService) ListWithdrawals(userID, status string, limit, offset int) ([]models.Withdrawal, error) {
+	query := s.Client.From("withdrawals").
+		Select("*", "", false).
+		Eq("user_id", userID)
+	if status != "" {
+		query = query.Eq("status", status)
+	}
+
+	var withdrawals []models.Withdrawal
+	_, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Range(offset, offset+limit-1, "").
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error listing withdrawals for user %s: %w", userID, err)
+	}
+	return withdrawals, nil
+}
+
+// GetWithdrawal fetches one of a user's withdrawals with its status history. Withdrawals
+// belonging to other users are reported as ErrWithdrawalNotFound.
+func (s *SupabaseService) GetWithdrawal(userID, id string) (*models.WithdrawalDetail, error) {
+	var withdrawals []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Select("*", "", false).
+		Eq("id", id).
+		Eq("user_id", userID).
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawal %s: %w", id, err)
+	}
+	if len(withdrawals) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrWithdrawalNotFound, id)
+	}
+
+	var history []models.WithdrawalStatusEvent
+	_, err = s.Client.From("withdrawal_status_events").
+		Select("*", "", false).
+		Eq("withdrawal_id", id).
+		Order("id", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&history)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching status history of withdrawal %s: %w", id, err)
+	}
+	if history == nil {
+		history = []models.WithdrawalStatusEvent{}
+	}
+
+	return &models.WithdrawalDetail{Withdrawal: withdrawals[0], History: history}, nil
+}
+
+// GetWithdrawalByID fetches any user's withdrawal, for admin operations.
+func (s *SupabaseService) GetWithdrawalByID(id string) (*models.Withdrawal, error) {
+	var withdrawals []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Select("*", "", false).
+		Eq("id", id).
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching withdrawal %s: %w", id, err)
+	}
+	if len(withdrawals) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrWithdrawalNotFound, id)
+	}
+	return &withdrawals[0], nil
+}
+
+// ListUnsettledWithdrawals returns pending, awaiting OTP and processing withdrawals not
+// updated since staleBefore whose transfer has not been verified with Paystack since
+// then, oldest first.
+func (s *SupabaseService) ListUnsettledWithdrawals(staleBefore time.Time, limit int) ([]models.Withdrawal, error) {
+	cutoff := staleBefore.UTC().Format(time.RFC3339)
+
+	var withdrawals []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Select("*", "", false).
+		In("status", []string{models.WithdrawalPending, models.WithdrawalAwaitingOTP, models.WithdrawalProcessing}).
+		Lt("updated_at", cutoff).
+		Or("transfer_verified_at.is.null,transfer_verified_at.lt."+cutoff, "").
+		Order("updated_at", &postgrest.OrderOpts{Ascending: true}).
+		Limit(limit, "").
+		ExecuteTo(&withdrawals)
+	if err != nil {
+		return nil, fmt.Errorf("error listing unsettled withdrawals: %w", err)
+	}
+	return withdrawals, nil
+}
+
+// MarkWithdrawalTransferVerified records that a withdrawal's transfer was checked with
+// Paystack without changing the withdrawal.
+func (s *SupabaseService) MarkWithdrawalTransferVerified(id string) error {
+	var updated []models.Withdrawal
+	_, err := s.Client.From("withdrawals").
+		Update(map[string]interface{}{"transfer_verified_at": time.Now()}, "", "").
+		Eq("id", id).
+		ExecuteTo(&updated)
+	if err != nil {
+		return fmt.Errorf("error recording verification of withdrawal %s: %w", id, err)
+	}
+	return nil
+}
+
+// nullIfEmpty sends an empty string to the database as NULL.
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -2,6 +2,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	// "os" // For signal handling
@@ -66,10 +67,27 @@ func main() {
 
 	// Initialize other services here if you add more (e.g., a dedicated UserService).
 
+	// Start background jobs.
+	// The sweeper verifies stale payment intents with Paystack and closes abandoned checkouts.
+	intentSweeper := services.NewPaymentIntentSweeper(paystackService, supabaseService, cfg)
+	go intentSweeper.Run(context.Background())
+	// The withdrawal sweeper settles withdrawals whose transfer outcome Paystack never reported.
+	withdrawalSweeper := services.NewWithdrawalSweeper(paystackService, supabaseService, cfg)
+	go withdrawalSweeper.Run(context.Background())
+	// The payout batcher sends queued withdrawals as Paystack bulk transfers (batching mode only).
+	payoutBatcher := services.NewPayoutBatcher(paystackService, supabaseService, cfg)
+	go payoutBatcher.Run(context.Background())
+	// The reconciler compares recent Paystack charges and transfers with our records (scheduled mode only).
+	reconciler := services.NewReconciler(paystackService, supabaseService, cfg)
+	go reconciler.Run(context.Background())
+	// The ledger checker looks for wallets that drifted from their history and failed ledger invariants.
+	ledgerChecker := services.NewLedgerChecker(supabaseService, cfg)
+	go ledgerChecker.Run(context.Background())
+
 	// 3. Initialize HTTP Handlers
 	// Handlers take services as dependencies and process HTTP requests.
 	paymentHandler := handlers.NewPaymentHandler(paystackService, supabaseService)
-	// userHandler := handlers.NewUserHandler(supabaseService) // Example if you create UserHandler
+	userHandler := handlers.NewUserHandler(supabaseService)
 
 	// 4. Setup Gin Router
 	// The router defines API endpoints and maps them to handlers.
@@ -77,7 +95,7 @@ func main() {
 	gin.SetMode(cfg.GinMode)
 	
 	// Pass all initialized handlers to the router setup function.
-	appRouter := router.SetupRouter(paymentHandler /*, userHandler */)
+	appRouter := router.SetupRouter(paymentHandler, userHandler)
 
 	// 5. Start HTTP Server
 	serverAddr := fmt.Sprintf(":%s", cfg.Port)
@@ -0,0 +1,71 @@
+-- Atomic wallet mutations.
+--
+-- Every balance change goes through apply_wallet_delta so that the read, the
+-- non-negative check and the write happen under one wallet row lock. Concurrent
+-- callers for the same user serialise on that lock instead of overwriting each
+-- other's read-modify-write from the Go side.
+
+create unique index if not exists wallets_user_id_key on public.wallets (user_id);
+
+alter table public.wallets
+    add constraint wallets_datacredit_balance_nonnegative check (datacredit_balance >= 0),
+    add constraint wallets_databyte_balance_nonnegative check (databyte_balance >= 0);
+
+-- apply_wallet_delta adds the given (possibly negative) deltas to a user's
+-- wallet, creating the wallet on first use. It raises DG001 / DG002 when the
+-- resulting datacredit / databyte balance would be negative, which rolls the
+-- whole call back. The result carries the updated wallet and the balances the
+-- deltas were applied to.
+create or replace function public.apply_wallet_delta(
+    p_user_id          uuid,
+    p_datacredit_delta bigint default 0,
+    p_databyte_delta   bigint default 0
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet public.wallets%rowtype;
+begin
+    -- Make sure the row exists so there is always a row to lock.
+    insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+    values (p_user_id, 0, 0)
+    on conflict (user_id) do nothing;
+
+    -- Lock the wallet and check the new balances before writing them, so an
+    -- overdraft raises DG001 / DG002 rather than tripping the CHECK constraints.
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if v_wallet.datacredit_balance + p_datacredit_delta < 0 then
+        raise exception 'insufficient datacredit balance for user %. Has: % kobo, Tried to change by: % kobo',
+            p_user_id, v_wallet.datacredit_balance, p_datacredit_delta
+            using errcode = 'DG001';
+    end if;
+    if v_wallet.databyte_balance + p_databyte_delta < 0 then
+        raise exception 'insufficient databyte balance for user %. Has: %, Tried to change by: %',
+            p_user_id, v_wallet.databyte_balance, p_databyte_delta
+            using errcode = 'DG002';
+    end if;
+
+    update public.wallets
+       set datacredit_balance = datacredit_balance + p_datacredit_delta,
+           databyte_balance   = databyte_balance + p_databyte_delta,
+           updated_at         = now()
+     where user_id = p_user_id
+    returning * into v_wallet;
+
+    return jsonb_build_object(
+        'wallet', to_jsonb(v_wallet),
+        'datacredit_balance_before', v_wallet.datacredit_balance - p_datacredit_delta,
+        'databyte_balance_before', v_wallet.databyte_balance - p_databyte_delta
+    );
+end;
+$$;
+
+-- Only the backend (service role) may move money.
+revoke execute on function public.apply_wallet_delta(uuid, bigint, bigint) from public, anon, authenticated;
+grant execute on function public.apply_wallet_delta(uuid, bigint, bigint) to service_role;
@@ -0,0 +1,312 @@
+-- Double-entry ledger behind the wallets table.
+--
+-- Every wallet change posts exactly one journal entry whose postings sum to
+-- zero per currency. Account balances follow the owner's point of view: a
+-- positive balance on a user account is datacredit/databyte we owe that user,
+-- and the platform accounts carry the matching negative side.
+--
+--   platform:paystack_clearing  money received from / paid out through Paystack
+--   platform:revenue            datacredit spent on databytes
+--   platform:fees               withdrawal and other fees charged to users
+--   platform:databyte_issuance  databytes minted for users
+--   platform:opening_balances   balances that existed before the ledger
+
+create table if not exists public.ledger_accounts (
+    id            bigserial primary key,
+    code          text        not null unique,
+    owner_user_id uuid        references auth.users (id),
+    currency      text        not null check (currency in ('datacredit', 'databyte')),
+    kind          text        not null check (kind in ('user', 'platform')),
+    balance       bigint      not null default 0,
+    created_at    timestamptz not null default now(),
+    updated_at    timestamptz not null default now(),
+    unique (owner_user_id, currency)
+);
+
+create table if not exists public.journal_entries (
+    id                    bigserial primary key,
+    operation             text        not null,
+    description           text,
+    external_reference_id text,
+    metadata              jsonb       not null default '{}'::jsonb,
+    created_at            timestamptz not null default now()
+);
+
+create index if not exists journal_entries_external_reference_id_idx
+    on public.journal_entries (external_reference_id);
+
+create table if not exists public.postings (
+    id         bigserial primary key,
+    entry_id   bigint      not null references public.journal_entries (id),
+    account_id bigint      not null references public.ledger_accounts (id),
+    currency   text        not null check (currency in ('datacredit', 'databyte')),
+    amount     bigint      not null check (amount <> 0),
+    created_at timestamptz not null default now()
+);
+
+create index if not exists postings_entry_id_idx on public.postings (entry_id);
+create index if not exists postings_account_id_idx on public.postings (account_id);
+
+-- The ledger is append-only and written exclusively by the functions below.
+alter table public.ledger_accounts enable row level security;
+alter table public.journal_entries enable row level security;
+alter table public.postings enable row level security;
+
+insert into public.ledger_accounts (code, currency, kind) values
+    ('platform:paystack_clearing', 'datacredit', 'platform'),
+    ('platform:revenue', 'datacredit', 'platform'),
+    ('platform:fees', 'datacredit', 'platform'),
+    ('platform:databyte_issuance', 'databyte', 'platform'),
+    ('platform:opening_balances', 'datacredit', 'platform')
+on conflict (code) do nothing;
+
+-- Databyte opening balances need their own account since accounts are single-currency.
+insert into public.ledger_accounts (code, currency, kind)
+values ('platform:opening_balances:databyte', 'databyte', 'platform')
+on conflict (code) do nothing;
+
+-- check_journal_entry_balanced runs at commit time and rejects any entry whose
+-- postings do not sum to zero in every currency.
+create or replace function public.check_journal_entry_balanced()
+returns trigger
+language plpgsql
+as $$
+begin
+    if exists (
+        select 1
+          from public.postings
+         where entry_id = new.entry_id
+         group by currency
+        having sum(amount) <> 0
+    ) then
+        raise exception 'journal entry % is not balanced', new.entry_id
+            using errcode = 'DG003';
+    end if;
+    return null;
+end;
+$$;
+
+drop trigger if exists postings_balanced on public.postings;
+create constraint trigger postings_balanced
+    after insert on public.postings
+    deferrable initially deferred
+    for each row execute function public.check_journal_entry_balanced();
+
+-- user_ledger_account returns (creating on first use) a user's account for a currency.
+create or replace function public.user_ledger_account(p_user_id uuid, p_currency text)
+returns bigint
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_id bigint;
+begin
+    insert into public.ledger_accounts (code, owner_user_id, currency, kind)
+    values ('user:' || p_user_id || ':' || p_currency, p_user_id, p_currency, 'user')
+    on conflict (code) do nothing;
+
+    select id into v_id
+      from public.ledger_accounts
+     where code = 'user:' || p_user_id || ':' || p_currency;
+    return v_id;
+end;
+$$;
+
+-- post_journal_entry writes one entry and its postings and moves the account
+-- balances. p_postings is a JSON array of {"account": code, "amount": n};
+-- zero amounts are skipped. Balance is enforced by postings_balanced.
+create or replace function public.post_journal_entry(
+    p_operation    text,
+    p_description  text,
+    p_external_ref text,
+    p_metadata     jsonb,
+    p_postings     jsonb
+) returns bigint
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_entry_id bigint;
+    v_posting  jsonb;
+    v_account  public.ledger_accounts%rowtype;
+    v_amount   bigint;
+begin
+    insert into public.journal_entries (operation, description, external_reference_id, metadata)
+    values (p_operation, p_description, p_external_ref, coalesce(p_metadata, '{}'::jsonb))
+    returning id into v_entry_id;
+
+    for v_posting in select * from jsonb_array_elements(p_postings) loop
+        v_amount := (v_posting ->> 'amount')::bigint;
+        continue when v_amount = 0;
+
+        update public.ledger_accounts
+           set balance = balance + v_amount,
+               updated_at = now()
+         where code = v_posting ->> 'account'
+        returning * into v_account;
+
+        if not found then
+            raise exception 'unknown ledger account %', v_posting ->> 'account'
+                using errcode = 'DG004';
+        end if;
+
+        insert into public.postings (entry_id, account_id, currency, amount)
+        values (v_entry_id, v_account.id, v_account.currency, v_amount);
+    end loop;
+
+    return v_entry_id;
+end;
+$$;
+
+-- Bring existing wallets into the ledger with one opening entry each.
+do $$
+declare
+    v_wallet public.wallets%rowtype;
+begin
+    for v_wallet in
+        select w.*
+          from public.wallets w
+         where not exists (
+                select 1 from public.ledger_accounts a where a.owner_user_id = w.user_id
+               )
+    loop
+        perform public.user_ledger_account(v_wallet.user_id, 'datacredit');
+        perform public.user_ledger_account(v_wallet.user_id, 'databyte');
+        perform public.post_journal_entry(
+            'opening_balance',
+            'Wallet balance carried over into the ledger',
+            null,
+            jsonb_build_object('user_id', v_wallet.user_id),
+            jsonb_build_array(
+                jsonb_build_object('account', 'user:' || v_wallet.user_id || ':datacredit', 'amount', v_wallet.datacredit_balance),
+                jsonb_build_object('account', 'platform:opening_balances', 'amount', -v_wallet.datacredit_balance),
+                jsonb_build_object('account', 'user:' || v_wallet.user_id || ':databyte', 'amount', v_wallet.databyte_balance),
+                jsonb_build_object('account', 'platform:opening_balances:databyte', 'amount', -v_wallet.databyte_balance)
+            )
+        );
+    end loop;
+end;
+$$;
+
+-- apply_wallet_delta now posts a balanced journal entry for every change,
+-- against the counterparty accounts chosen by the caller, and refuses to
+-- commit if the wallet and its ledger accounts disagree afterwards (DG005).
+drop function if exists public.apply_wallet_delta(uuid, bigint, bigint);
+
+create or replace function public.apply_wallet_delta(
+    p_user_id                 uuid,
+    p_datacredit_delta        bigint default 0,
+    p_databyte_delta          bigint default 0,
+    p_operation               text default 'adjustment',
+    p_description             text default null,
+    p_external_ref            text default null,
+    p_metadata                jsonb default '{}'::jsonb,
+    p_datacredit_counterparty text default null,
+    p_databyte_counterparty   text default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet          public.wallets%rowtype;
+    v_entry_id        bigint;
+    v_ledger_credit   bigint;
+    v_ledger_databyte bigint;
+begin
+    if p_datacredit_delta <> 0 and p_datacredit_counterparty is null then
+        raise exception 'datacredit counterparty account is required for operation %', p_operation
+            using errcode = 'DG004';
+    end if;
+    if p_databyte_delta <> 0 and p_databyte_counterparty is null then
+        raise exception 'databyte counterparty account is required for operation %', p_operation
+            using errcode = 'DG004';
+    end if;
+
+    -- Make sure the row exists so there is always a row to lock.
+    insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+    values (p_user_id, 0, 0)
+    on conflict (user_id) do nothing;
+
+    -- Lock the wallet and check the new balances before writing them, so an
+    -- overdraft raises DG001 / DG002 rather than tripping the CHECK constraints.
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if v_wallet.datacredit_balance + p_datacredit_delta < 0 then
+        raise exception 'insufficient datacredit balance for user %. Has: % kobo, Tried to change by: % kobo',
+            p_user_id, v_wallet.datacredit_balance, p_datacredit_delta
+            using errcode = 'DG001';
+    end if;
+    if v_wallet.databyte_balance + p_databyte_delta < 0 then
+        raise exception 'insufficient databyte balance for user %. Has: %, Tried to change by: %',
+            p_user_id, v_wallet.databyte_balance, p_databyte_delta
+            using errcode = 'DG002';
+    end if;
+
+    update public.wallets
+       set datacredit_balance = datacredit_balance + p_datacredit_delta,
+           databyte_balance   = databyte_balance + p_databyte_delta,
+           updated_at         = now()
+     where user_id = p_user_id
+    returning * into v_wallet;
+
+    perform public.user_ledger_account(p_user_id, 'datacredit');
+    perform public.user_ledger_account(p_user_id, 'databyte');
+
+    v_entry_id := public.post_journal_entry(
+        p_operation,
+        p_description,
+        p_external_ref,
+        coalesce(p_metadata, '{}'::jsonb) || jsonb_build_object('user_id', p_user_id),
+        jsonb_build_array(
+            jsonb_build_object('account', 'user:' || p_user_id || ':datacredit', 'amount', p_datacredit_delta),
+            jsonb_build_object('account', coalesce(p_datacredit_counterparty, ''), 'amount', -p_datacredit_delta),
+            jsonb_build_object('account', 'user:' || p_user_id || ':databyte', 'amount', p_databyte_delta),
+            jsonb_build_object('account', coalesce(p_databyte_counterparty, ''), 'amount', -p_databyte_delta)
+        )
+    );
+
+    select balance into v_ledger_credit
+      from public.ledger_accounts where owner_user_id = p_user_id and currency = 'datacredit';
+    select balance into v_ledger_databyte
+      from public.ledger_accounts where owner_user_id = p_user_id and currency = 'databyte';
+
+    if v_ledger_credit <> v_wallet.datacredit_balance or v_ledger_databyte <> v_wallet.databyte_balance then
+        raise exception 'wallet for user % is out of balance with the ledger (wallet %/%, ledger %/%)',
+            p_user_id, v_wallet.datacredit_balance, v_wallet.databyte_balance, v_ledger_credit, v_ledger_databyte
+            using errcode = 'DG005';
+    end if;
+
+    return jsonb_build_object(
+        'wallet', to_jsonb(v_wallet),
+        'datacredit_balance_before', v_wallet.datacredit_balance - p_datacredit_delta,
+        'databyte_balance_before', v_wallet.databyte_balance - p_databyte_delta,
+        'journal_entry_id', v_entry_id
+    );
+end;
+$$;
+
+-- ledger_wallet_drift lists wallets whose balances disagree with their ledger accounts.
+create or replace view public.ledger_wallet_drift as
+select w.user_id,
+       w.datacredit_balance               as wallet_datacredit_balance,
+       coalesce(dc.balance, 0)            as ledger_datacredit_balance,
+       w.databyte_balance                 as wallet_databyte_balance,
+       coalesce(db.balance, 0)            as ledger_databyte_balance
+  from public.wallets w
+  left join public.ledger_accounts dc on dc.owner_user_id = w.user_id and dc.currency = 'datacredit'
+  left join public.ledger_accounts db on db.owner_user_id = w.user_id and db.currency = 'databyte'
+ where w.datacredit_balance <> coalesce(dc.balance, 0)
+    or w.databyte_balance <> coalesce(db.balance, 0);
+
+revoke all on public.ledger_wallet_drift from anon, authenticated;
+
+revoke execute on function public.user_ledger_account(uuid, text) from public, anon, authenticated;
+revoke execute on function public.post_journal_entry(text, text, text, jsonb, jsonb) from public, anon, authenticated;
+revoke execute on function public.apply_wallet_delta(uuid, bigint, bigint, text, text, text, jsonb, text, text) from public, anon, authenticated;
+grant execute on function public.apply_wallet_delta(uuid, bigint, bigint, text, text, text, jsonb, text, text) to service_role;
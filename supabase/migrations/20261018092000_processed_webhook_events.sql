@@ -0,0 +1,78 @@
+-- Idempotent Paystack webhook processing.
+--
+-- Paystack retries deliveries until it gets a 2xx, so the same charge.success
+-- can arrive several times. Each event is claimed here, keyed on its type and
+-- Paystack reference (or transfer code), before it is applied. Later deliveries
+-- of a claimed event are acknowledged without being applied again, and the
+-- first processing result is kept for audit. A claim is a lease: if the
+-- process dies mid-handler, the event stays 'processing' only until the lease
+-- runs out, and the next delivery claims it again.
+
+create table if not exists public.processed_webhook_events (
+    id                bigserial primary key,
+    event_type        text        not null,
+    event_key         text        not null,
+    status            text        not null default 'processing'
+                                  check (status in ('processing', 'processed', 'failed')),
+    attempts          integer     not null default 1,
+    duplicate_count   integer     not null default 0,
+    payload           jsonb,
+    result            jsonb,
+    first_received_at timestamptz not null default now(),
+    last_received_at  timestamptz not null default now(),
+    processed_at      timestamptz,
+    claimed_at        timestamptz not null default now(),
+    unique (event_type, event_key)
+);
+
+alter table public.processed_webhook_events enable row level security;
+
+-- claim_webhook_event returns {"claimed": bool, "event": row}. A new event,
+-- one whose previous attempt failed, or one still 'processing' under a claim
+-- older than p_lease (its handler crashed) is claimed for processing; anything
+-- else is a duplicate delivery and only has its duplicate counter bumped. The
+-- handlers check the state they change, so re-applying an event whose handler
+-- got as far as committing before it died is a no-op.
+create or replace function public.claim_webhook_event(
+    p_event_type text,
+    p_event_key  text,
+    p_payload    jsonb,
+    p_lease      interval default interval '5 minutes'
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_event public.processed_webhook_events%rowtype;
+begin
+    insert into public.processed_webhook_events (event_type, event_key, payload)
+    values (p_event_type, p_event_key, p_payload)
+    on conflict (event_type, event_key) do update
+        set status           = 'processing',
+            attempts         = processed_webhook_events.attempts + 1,
+            payload          = excluded.payload,
+            last_received_at = now(),
+            claimed_at       = now()
+      where processed_webhook_events.status = 'failed'
+         or (processed_webhook_events.status = 'processing'
+             and processed_webhook_events.claimed_at < now() - p_lease)
+    returning * into v_event;
+
+    if found then
+        return jsonb_build_object('claimed', true, 'event', to_jsonb(v_event));
+    end if;
+
+    update public.processed_webhook_events
+       set duplicate_count  = duplicate_count + 1,
+           last_received_at = now()
+     where event_type = p_event_type
+       and event_key = p_event_key
+    returning * into v_event;
+
+    return jsonb_build_object('claimed', false, 'event', to_jsonb(v_event));
+end;
+$$;
+
+revoke execute on function public.claim_webhook_event(text, text, jsonb, interval) from public, anon, authenticated;
+grant execute on function public.claim_webhook_event(text, text, jsonb, interval) to service_role;
@@ -0,0 +1,153 @@
+-- Complete transaction logging for wallet changes.
+--
+-- apply_wallet_delta now writes the 'transactions' rows for the change itself,
+-- inside the same database transaction as the balance update and the journal
+-- entry, so a balance can no longer change without its history (or vice versa).
+-- One row is written per currency that actually changed.
+
+alter table public.transactions
+    add column if not exists currency text check (currency in ('datacredit', 'databyte')),
+    add column if not exists journal_entry_id bigint references public.journal_entries (id);
+
+update public.transactions
+   set currency = case
+                      when operation in ('databyte_update', 'databyte_credit_from_purchase') then 'databyte'
+                      else 'datacredit'
+                  end
+ where currency is null;
+
+alter table public.transactions alter column currency set not null;
+
+create index if not exists transactions_user_id_idx on public.transactions (user_id, id desc);
+create index if not exists transactions_external_reference_id_idx on public.transactions (external_reference_id);
+
+drop function if exists public.apply_wallet_delta(uuid, bigint, bigint, text, text, text, jsonb, text, text);
+
+create or replace function public.apply_wallet_delta(
+    p_user_id                 uuid,
+    p_datacredit_delta        bigint default 0,
+    p_databyte_delta          bigint default 0,
+    p_operation               text default 'adjustment',
+    p_description             text default null,
+    p_external_ref            text default null,
+    p_metadata                jsonb default '{}'::jsonb,
+    p_datacredit_counterparty text default null,
+    p_databyte_counterparty   text default null,
+    p_datacredit_operation    text default null,
+    p_databyte_operation      text default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet          public.wallets%rowtype;
+    v_entry_id        bigint;
+    v_ledger_credit   bigint;
+    v_ledger_databyte bigint;
+    v_metadata        jsonb := coalesce(p_metadata, '{}'::jsonb);
+    v_transactions    jsonb := '[]'::jsonb;
+    v_tx              public.transactions%rowtype;
+begin
+    if p_datacredit_delta <> 0 and p_datacredit_counterparty is null then
+        raise exception 'datacredit counterparty account is required for operation %', p_operation
+            using errcode = 'DG004';
+    end if;
+    if p_databyte_delta <> 0 and p_databyte_counterparty is null then
+        raise exception 'databyte counterparty account is required for operation %', p_operation
+            using errcode = 'DG004';
+    end if;
+
+    -- Make sure the row exists so there is always a row to lock.
+    insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+    values (p_user_id, 0, 0)
+    on conflict (user_id) do nothing;
+
+    -- Lock the wallet and check the new balances before writing them, so an
+    -- overdraft raises DG001 / DG002 rather than tripping the CHECK constraints.
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if v_wallet.datacredit_balance + p_datacredit_delta < 0 then
+        raise exception 'insufficient datacredit balance for user %. Has: % kobo, Tried to change by: % kobo',
+            p_user_id, v_wallet.datacredit_balance, p_datacredit_delta
+            using errcode = 'DG001';
+    end if;
+    if v_wallet.databyte_balance + p_databyte_delta < 0 then
+        raise exception 'insufficient databyte balance for user %. Has: %, Tried to change by: %',
+            p_user_id, v_wallet.databyte_balance, p_databyte_delta
+            using errcode = 'DG002';
+    end if;
+
+    update public.wallets
+       set datacredit_balance = datacredit_balance + p_datacredit_delta,
+           databyte_balance   = databyte_balance + p_databyte_delta,
+           updated_at         = now()
+     where user_id = p_user_id
+    returning * into v_wallet;
+
+    perform public.user_ledger_account(p_user_id, 'datacredit');
+    perform public.user_ledger_account(p_user_id, 'databyte');
+
+    v_entry_id := public.post_journal_entry(
+        p_operation,
+        p_description,
+        p_external_ref,
+        v_metadata || jsonb_build_object('user_id', p_user_id),
+        jsonb_build_array(
+            jsonb_build_object('account', 'user:' || p_user_id || ':datacredit', 'amount', p_datacredit_delta),
+            jsonb_build_object('account', coalesce(p_datacredit_counterparty, ''), 'amount', -p_datacredit_delta),
+            jsonb_build_object('account', 'user:' || p_user_id || ':databyte', 'amount', p_databyte_delta),
+            jsonb_build_object('account', coalesce(p_databyte_counterparty, ''), 'amount', -p_databyte_delta)
+        )
+    );
+
+    select balance into v_ledger_credit
+      from public.ledger_accounts where owner_user_id = p_user_id and currency = 'datacredit';
+    select balance into v_ledger_databyte
+      from public.ledger_accounts where owner_user_id = p_user_id and currency = 'databyte';
+
+    if v_ledger_credit <> v_wallet.datacredit_balance or v_ledger_databyte <> v_wallet.databyte_balance then
+        raise exception 'wallet for user % is out of balance with the ledger (wallet %/%, ledger %/%)',
+            p_user_id, v_wallet.datacredit_balance, v_wallet.databyte_balance, v_ledger_credit, v_ledger_databyte
+            using errcode = 'DG005';
+    end if;
+
+    if p_datacredit_delta <> 0 then
+        insert into public.transactions (
+            user_id, amount, balance_before, balance_after, operation, currency, description,
+            external_reference_id, metadata, journal_entry_id, transaction_timestamp
+        ) values (
+            p_user_id, p_datacredit_delta, v_wallet.datacredit_balance - p_datacredit_delta, v_wallet.datacredit_balance,
+            coalesce(p_datacredit_operation, p_operation), 'datacredit', p_description,
+            p_external_ref, v_metadata, v_entry_id, now()
+        ) returning * into v_tx;
+        v_transactions := v_transactions || to_jsonb(v_tx);
+    end if;
+
+    if p_databyte_delta <> 0 then
+        insert into public.transactions (
+            user_id, amount, balance_before, balance_after, operation, currency, description,
+            external_reference_id, metadata, journal_entry_id, transaction_timestamp
+        ) values (
+            p_user_id, p_databyte_delta, v_wallet.databyte_balance - p_databyte_delta, v_wallet.databyte_balance,
+            coalesce(p_databyte_operation, p_operation), 'databyte', p_description,
+            p_external_ref, v_metadata, v_entry_id, now()
+        ) returning * into v_tx;
+        v_transactions := v_transactions || to_jsonb(v_tx);
+    end if;
+
+    return jsonb_build_object(
+        'wallet', to_jsonb(v_wallet),
+        'datacredit_balance_before', v_wallet.datacredit_balance - p_datacredit_delta,
+        'databyte_balance_before', v_wallet.databyte_balance - p_databyte_delta,
+        'journal_entry_id', v_entry_id,
+        'transactions', v_transactions
+    );
+end;
+$$;
+
+revoke execute on function public.apply_wallet_delta(uuid, bigint, bigint, text, text, text, jsonb, text, text, text, text) from public, anon, authenticated;
+grant execute on function public.apply_wallet_delta(uuid, bigint, bigint, text, text, text, jsonb, text, text, text, text) to service_role;
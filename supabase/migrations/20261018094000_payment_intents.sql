@@ -0,0 +1,211 @@
+-- Payment intents and the payment review queue.
+--
+-- Every Paystack initialization is persisted as a payment intent carrying the
+-- reference we generated, the user, and the amount and currency we expect.
+-- charge.success webhooks are matched against their intent; anything that does
+-- not line up (unknown reference, amount, currency or user mismatch, or an
+-- intent that had already failed) is parked in payment_reviews for an admin to
+-- credit or dismiss (resolve_payment_review) instead of being credited.
+
+create table if not exists public.payment_intents (
+    id                      uuid        primary key default gen_random_uuid(),
+    reference               text        not null unique,
+    user_id                 uuid        not null references auth.users (id),
+    email                   text        not null,
+    amount                  bigint      not null check (amount > 0), -- kobo
+    currency                text        not null default 'NGN',
+    purpose                 text        not null default 'datacredit_purchase',
+    status                  text        not null default 'initialized'
+                                        check (status in ('initialized', 'succeeded', 'failed', 'under_review')),
+    access_code             text,
+    authorization_url       text,
+    paystack_transaction_id bigint,
+    paid_amount             bigint,
+    paid_at                 timestamptz,
+    failure_reason          text,
+    created_at              timestamptz not null default now(),
+    updated_at              timestamptz not null default now()
+);
+
+create index if not exists payment_intents_user_id_idx on public.payment_intents (user_id, created_at desc);
+create index if not exists payment_intents_status_idx on public.payment_intents (status, created_at);
+
+create table if not exists public.payment_reviews (
+    id                bigserial   primary key,
+    reference         text        not null,
+    intent_id         uuid        references public.payment_intents (id),
+    user_id           uuid,
+    reason            text        not null
+                                  check (reason in ('unknown_reference', 'amount_mismatch', 'currency_mismatch', 'user_mismatch', 'intent_failed')),
+    expected_amount   bigint,
+    received_amount   bigint,
+    expected_currency text,
+    received_currency text,
+    payload           jsonb,
+    status            text        not null default 'open' check (status in ('open', 'resolved')),
+    resolution        text        check (resolution in ('credited', 'dismissed')),
+    resolution_note   text,
+    resolved_by       uuid,
+    created_at        timestamptz not null default now(),
+    resolved_at       timestamptz
+);
+
+create index if not exists payment_reviews_status_idx on public.payment_reviews (status, created_at);
+
+alter table public.payment_intents enable row level security;
+alter table public.payment_reviews enable row level security;
+
+-- credit_payment_intent credits the intent's user with the intent's amount (or
+-- p_amount) and marks it succeeded, all in one transaction. The intent row is
+-- locked first, so concurrent callers (webhook retries, client verification)
+-- credit it at most once; later callers get {"credited": false}. Only intents
+-- that may still succeed are credited: an intent under review is credited only
+-- by resolving its review (p_from_review), and one that failed never is; both
+-- raise DG016.
+create or replace function public.credit_payment_intent(
+    p_reference      text,
+    p_description    text,
+    p_metadata       jsonb,
+    p_counterparty   text,
+    p_paid_amount    bigint,
+    p_transaction_id bigint,
+    p_amount         bigint  default null,
+    p_from_review    boolean default false
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_intent public.payment_intents%rowtype;
+    v_change jsonb;
+begin
+    select * into v_intent
+      from public.payment_intents
+     where reference = p_reference
+       for update;
+
+    if not found then
+        raise exception 'payment intent % not found', p_reference using errcode = 'DG006';
+    end if;
+
+    if v_intent.status = 'succeeded' then
+        return jsonb_build_object('credited', false, 'intent', to_jsonb(v_intent));
+    end if;
+
+    if v_intent.status = 'failed' or (v_intent.status = 'under_review' and not p_from_review) then
+        raise exception 'payment intent % is % and cannot be credited', p_reference, v_intent.status
+            using errcode = 'DG016';
+    end if;
+
+    v_change := public.apply_wallet_delta(
+        p_user_id                 => v_intent.user_id,
+        p_datacredit_delta        => coalesce(p_amount, v_intent.amount),
+        p_operation               => 'credit_purchase',
+        p_description             => p_description,
+        p_external_ref            => v_intent.reference,
+        p_metadata                => coalesce(p_metadata, '{}'::jsonb) || jsonb_build_object('payment_intent_id', v_intent.id),
+        p_datacredit_counterparty => p_counterparty
+    );
+
+    update public.payment_intents
+       set status                  = 'succeeded',
+           paid_amount             = p_paid_amount,
+           paystack_transaction_id = p_transaction_id,
+           paid_at                 = now(),
+           updated_at              = now()
+     where id = v_intent.id
+    returning * into v_intent;
+
+    return jsonb_build_object('credited', true, 'intent', to_jsonb(v_intent), 'change', v_change);
+end;
+$$;
+
+-- resolve_payment_review closes an open review. 'credit' credits the intent the
+-- charge belongs to with the amount Paystack actually received; charges with no
+-- intent or in another currency cannot be credited (DG019). 'dismiss' leaves the
+-- wallet alone and fails the intent if it was waiting on the review. Raises
+-- DG017 for an unknown review and DG018 for one already resolved.
+create or replace function public.resolve_payment_review(
+    p_review_id    bigint,
+    p_action       text,
+    p_admin_id     uuid,
+    p_note         text,
+    p_description  text,
+    p_counterparty text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_review public.payment_reviews%rowtype;
+    v_intent public.payment_intents%rowtype;
+    v_credit jsonb;
+begin
+    if p_action not in ('credit', 'dismiss') then
+        raise exception 'unknown payment review action %', p_action;
+    end if;
+
+    select * into v_review
+      from public.payment_reviews
+     where id = p_review_id
+       for update;
+
+    if not found then
+        raise exception 'payment review % not found', p_review_id using errcode = 'DG017';
+    end if;
+    if v_review.status <> 'open' then
+        raise exception 'payment review % is already resolved', p_review_id using errcode = 'DG018';
+    end if;
+
+    if p_action = 'credit' then
+        if v_review.intent_id is null or v_review.received_amount is null
+           or v_review.reason = 'currency_mismatch' then
+            raise exception 'payment review % (%) cannot be credited to a wallet', p_review_id, v_review.reason
+                using errcode = 'DG019';
+        end if;
+
+        select * into v_intent from public.payment_intents where id = v_review.intent_id;
+        v_credit := public.credit_payment_intent(
+            p_reference      => v_intent.reference,
+            p_description    => p_description,
+            p_metadata       => jsonb_build_object('payment_review_id', v_review.id, 'resolved_by', p_admin_id, 'note', p_note),
+            p_counterparty   => p_counterparty,
+            p_paid_amount    => v_review.received_amount,
+            p_transaction_id => (v_review.payload ->> 'paystack_transaction_id')::bigint,
+            p_amount         => v_review.received_amount,
+            p_from_review    => true
+        );
+        v_intent := jsonb_populate_record(null::public.payment_intents, v_credit -> 'intent');
+    elsif v_review.intent_id is not null then
+        update public.payment_intents
+           set status         = 'failed',
+               failure_reason = coalesce(p_note, 'Dismissed on review'),
+               updated_at     = now()
+         where id = v_review.intent_id
+           and status = 'under_review'
+        returning * into v_intent;
+    end if;
+
+    update public.payment_reviews
+       set status          = 'resolved',
+           resolution      = case when p_action = 'credit' then 'credited' else 'dismissed' end,
+           resolution_note = p_note,
+           resolved_by     = p_admin_id,
+           resolved_at     = now()
+     where id = v_review.id
+    returning * into v_review;
+
+    return jsonb_build_object(
+        'review', to_jsonb(v_review),
+        'intent', case when v_intent.id is not null then to_jsonb(v_intent) end,
+        'credited', coalesce((v_credit ->> 'credited')::boolean, false)
+    );
+end;
+$$;
+
+revoke execute on function public.credit_payment_intent(text, text, jsonb, text, bigint, bigint, bigint, boolean) from public, anon, authenticated;
+grant execute on function public.credit_payment_intent(text, text, jsonb, text, bigint, bigint, bigint, boolean) to service_role;
+revoke execute on function public.resolve_payment_review(bigint, text, uuid, text, text, text) from public, anon, authenticated;
+grant execute on function public.resolve_payment_review(bigint, text, uuid, text, text, text) to service_role;
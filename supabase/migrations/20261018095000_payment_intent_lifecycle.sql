@@ -0,0 +1,21 @@
+-- Payment intent lifecycle.
+--
+--   initialized -> pending -> succeeded | failed | abandoned | expired
+--
+-- plus under_review for charges that did not match their intent. Transitions are
+-- enforced by the backend (models.CanTransitionPaymentIntent) with a guarded
+-- UPDATE on the current status. The expiry sweeper verifies stale intents with
+-- Paystack and records when it last did so in last_verified_at.
+
+alter table public.payment_intents drop constraint if exists payment_intents_status_check;
+alter table public.payment_intents
+    add constraint payment_intents_status_check
+    check (status in ('initialized', 'pending', 'succeeded', 'failed', 'abandoned', 'expired', 'under_review'));
+
+alter table public.payment_intents
+    add column if not exists last_verified_at timestamptz,
+    add column if not exists paystack_status  text;
+
+create index if not exists payment_intents_open_idx
+    on public.payment_intents (created_at)
+    where status in ('initialized', 'pending');
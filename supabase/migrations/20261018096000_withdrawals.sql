@@ -0,0 +1,177 @@
+-- Withdrawals and transfer settlement.
+--
+-- Every withdrawal is persisted before Paystack is called, keyed by the transfer
+-- reference we generate, and moves through
+--
+--   pending -> processing -> completed
+--                         \-> failed | reversed
+--
+-- pending:    created, Paystack not called yet or the call failed (no debit).
+-- processing: Paystack accepted the transfer and the wallet has been debited.
+-- completed:  transfer.success received. A completed transfer can still be reversed.
+-- failed / reversed: the debit has been returned with a compensating
+--             'withdrawal_reversal' credit.
+
+create table if not exists public.withdrawals (
+    id                        uuid        primary key default gen_random_uuid(),
+    user_id                   uuid        not null references auth.users (id),
+    amount                    bigint      not null check (amount > 0), -- kobo
+    currency                  text        not null default 'NGN',
+    reference                 text        not null unique,
+    recipient_code            text,
+    transfer_code             text        unique,
+    paystack_transfer_id      bigint,
+    status                    text        not null default 'pending'
+                                          check (status in ('pending', 'processing', 'completed', 'failed', 'reversed')),
+    failure_reason            text,
+    debit_journal_entry_id    bigint      references public.journal_entries (id),
+    reversal_journal_entry_id bigint      references public.journal_entries (id),
+    created_at                timestamptz not null default now(),
+    updated_at                timestamptz not null default now()
+);
+
+create index if not exists withdrawals_user_id_idx on public.withdrawals (user_id, created_at desc);
+create index if not exists withdrawals_status_idx on public.withdrawals (status, created_at);
+
+alter table public.withdrawals enable row level security;
+
+-- debit_withdrawal debits the wallet for a pending withdrawal whose transfer Paystack
+-- has accepted and marks it processing, in one transaction.
+create or replace function public.debit_withdrawal(
+    p_withdrawal_id   uuid,
+    p_transfer_code   text,
+    p_transfer_id     bigint,
+    p_description     text,
+    p_metadata        jsonb,
+    p_counterparty    text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+    v_change     jsonb;
+begin
+    select * into v_withdrawal
+      from public.withdrawals
+     where id = p_withdrawal_id
+       for update;
+
+    if not found then
+        raise exception 'withdrawal % not found', p_withdrawal_id using errcode = 'DG007';
+    end if;
+    if v_withdrawal.status <> 'pending' then
+        raise exception 'withdrawal % is already %', p_withdrawal_id, v_withdrawal.status using errcode = 'DG008';
+    end if;
+
+    v_change := public.apply_wallet_delta(
+        p_user_id                 => v_withdrawal.user_id,
+        p_datacredit_delta        => -v_withdrawal.amount,
+        p_operation               => 'withdrawal',
+        p_description             => p_description,
+        p_external_ref            => p_transfer_code,
+        p_metadata                => coalesce(p_metadata, '{}'::jsonb) || jsonb_build_object('withdrawal_id', v_withdrawal.id),
+        p_datacredit_counterparty => p_counterparty
+    );
+
+    update public.withdrawals
+       set status                 = 'processing',
+           transfer_code          = p_transfer_code,
+           paystack_transfer_id   = p_transfer_id,
+           debit_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+           updated_at             = now()
+     where id = v_withdrawal.id
+    returning * into v_withdrawal;
+
+    return jsonb_build_object('withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+end;
+$$;
+
+revoke execute on function public.debit_withdrawal(uuid, text, bigint, text, jsonb, text) from public, anon, authenticated;
+grant execute on function public.debit_withdrawal(uuid, text, bigint, text, jsonb, text) to service_role;
+
+-- settle_withdrawal applies a transfer outcome ('success', 'failed' or 'reversed')
+-- reported by Paystack. The withdrawal is found by our reference or Paystack's
+-- transfer code and locked, so repeated or concurrent deliveries settle it once:
+-- callers get {"changed": false} when the outcome had already been applied.
+-- A withdrawal that is still pending has not been debited yet; DG008 is raised so
+-- the webhook is retried once the debit has been recorded.
+create or replace function public.settle_withdrawal(
+    p_reference     text,
+    p_transfer_code text,
+    p_outcome       text,
+    p_reason        text,
+    p_description   text,
+    p_counterparty  text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+    v_change     jsonb;
+begin
+    if p_outcome not in ('success', 'failed', 'reversed') then
+        raise exception 'unknown transfer outcome %', p_outcome;
+    end if;
+
+    select * into v_withdrawal
+      from public.withdrawals
+     where (p_reference is not null and reference = p_reference)
+        or (p_transfer_code is not null and transfer_code = p_transfer_code)
+     limit 1
+       for update;
+
+    if not found then
+        raise exception 'withdrawal for transfer % (reference %) not found', p_transfer_code, p_reference
+            using errcode = 'DG007';
+    end if;
+    if v_withdrawal.status = 'pending' then
+        raise exception 'withdrawal % has not been debited yet', v_withdrawal.id using errcode = 'DG008';
+    end if;
+
+    if p_outcome = 'success' then
+        if v_withdrawal.status <> 'processing' then
+            return jsonb_build_object('changed', false, 'withdrawal', to_jsonb(v_withdrawal));
+        end if;
+
+        update public.withdrawals
+           set status     = 'completed',
+               updated_at = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    -- failed or reversed: return the debit unless that has already happened.
+    if v_withdrawal.status not in ('processing', 'completed') then
+        return jsonb_build_object('changed', false, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    v_change := public.apply_wallet_delta(
+        p_user_id                 => v_withdrawal.user_id,
+        p_datacredit_delta        => v_withdrawal.amount,
+        p_operation               => 'withdrawal_reversal',
+        p_description             => p_description,
+        p_external_ref            => v_withdrawal.transfer_code,
+        p_metadata                => jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_outcome', p_outcome, 'reason', p_reason),
+        p_datacredit_counterparty => p_counterparty
+    );
+
+    update public.withdrawals
+       set status                    = p_outcome,
+           failure_reason            = p_reason,
+           reversal_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+           updated_at                = now()
+     where id = v_withdrawal.id
+    returning * into v_withdrawal;
+
+    return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+end;
+$$;
+
+revoke execute on function public.settle_withdrawal(text, text, text, text, text, text) from public, anon, authenticated;
+grant execute on function public.settle_withdrawal(text, text, text, text, text, text) to service_role;
@@ -0,0 +1,118 @@
+-- Payout bank accounts.
+--
+-- A payout account is a bank account the user has added for withdrawals. The
+-- account name is resolved through Paystack and a Paystack transfer recipient is
+-- created when the account is added; withdrawals pay out to its recipient_code.
+-- Each user has at most one default account, used when a withdrawal does not
+-- name one. Withdrawals are NGN kobo, so only NGN accounts are accepted.
+
+create table if not exists public.payout_accounts (
+    id             uuid        primary key default gen_random_uuid(),
+    user_id        uuid        not null references auth.users (id),
+    bank_code      text        not null,
+    bank_name      text,
+    account_number text        not null,
+    account_name   text        not null,
+    currency       text        not null default 'NGN' check (currency = 'NGN'),
+    recipient_code text        not null,
+    is_default     boolean     not null default false,
+    created_at     timestamptz not null default now(),
+    updated_at     timestamptz not null default now(),
+    unique (user_id, bank_code, account_number)
+);
+
+create index if not exists payout_accounts_user_id_idx on public.payout_accounts (user_id, created_at desc);
+create unique index if not exists payout_accounts_one_default_idx
+    on public.payout_accounts (user_id)
+    where is_default;
+
+alter table public.payout_accounts enable row level security;
+
+alter table public.withdrawals
+    add column if not exists payout_account_id uuid references public.payout_accounts (id) on delete set null;
+
+-- set_default_payout_account makes one of a user's payout accounts the default
+-- and clears the flag on the others, in one transaction.
+create or replace function public.set_default_payout_account(
+    p_user_id    uuid,
+    p_account_id uuid
+) returns public.payout_accounts
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_account public.payout_accounts%rowtype;
+begin
+    perform 1 from public.payout_accounts where user_id = p_user_id for update;
+
+    if not exists (select 1 from public.payout_accounts where id = p_account_id and user_id = p_user_id) then
+        raise exception 'payout account % not found for user %', p_account_id, p_user_id using errcode = 'DG009';
+    end if;
+
+    update public.payout_accounts
+       set is_default = false,
+           updated_at = now()
+     where user_id = p_user_id
+       and is_default
+       and id <> p_account_id;
+
+    update public.payout_accounts
+       set is_default = true,
+           updated_at = now()
+     where id = p_account_id
+    returning * into v_account;
+
+    return v_account;
+end;
+$$;
+
+revoke execute on function public.set_default_payout_account(uuid, uuid) from public, anon, authenticated;
+grant execute on function public.set_default_payout_account(uuid, uuid) to service_role;
+
+-- delete_payout_account deletes one of a user's payout accounts. It raises DG009
+-- if the user has no such account and DG020 while a withdrawal that has not
+-- reached a final state still pays out to it, since deleting the account also
+-- deactivates the Paystack recipient that withdrawal's transfer goes to. Locking
+-- the account row serialises this with create_withdrawal, whose foreign key
+-- check on payout_account_id needs a share lock on the same row.
+create or replace function public.delete_payout_account(
+    p_user_id    uuid,
+    p_account_id uuid
+) returns public.payout_accounts
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_account public.payout_accounts%rowtype;
+    v_pending bigint;
+begin
+    select * into v_account
+      from public.payout_accounts
+     where id = p_account_id
+       and user_id = p_user_id
+       for update;
+
+    if not found then
+        raise exception 'payout account % not found for user %', p_account_id, p_user_id using errcode = 'DG009';
+    end if;
+
+    select count(*) into v_pending
+      from public.withdrawals
+     where (payout_account_id = v_account.id or recipient_code = v_account.recipient_code)
+       and status in ('pending_approval', 'queued', 'pending', 'awaiting_otp', 'processing');
+
+    if v_pending > 0 then
+        raise exception 'payout account % has % withdrawal(s) in progress', p_account_id, v_pending using errcode = 'DG020';
+    end if;
+
+    delete from public.payout_accounts
+     where id = v_account.id;
+
+    return v_account;
+end;
+$$;
+
+revoke execute on function public.delete_payout_account(uuid, uuid) from public, anon, authenticated;
+grant execute on function public.delete_payout_account(uuid, uuid) to service_role;
@@ -0,0 +1,459 @@
+-- Wallet holds.
+--
+-- A hold reserves part of a user's datacredit balance without moving it, so a
+-- withdrawal can set the money aside before Paystack is called and only debit it
+-- once the transfer succeeds. The wallet tracks the total held amount:
+--
+--   available datacredit = datacredit_balance - held_datacredit
+--
+-- Holds do not post journal entries: the money is still the user's until the
+-- hold is captured, which debits it through apply_wallet_delta. A released hold
+-- simply makes the amount available again.
+
+alter table public.wallets
+    add column if not exists held_datacredit bigint not null default 0;
+
+alter table public.wallets
+    add constraint wallets_held_datacredit_within_balance
+    check (held_datacredit >= 0 and held_datacredit <= datacredit_balance);
+
+create table if not exists public.wallet_holds (
+    id          bigserial   primary key,
+    user_id     uuid        not null references auth.users (id),
+    amount      bigint      not null check (amount > 0), -- kobo
+    reason      text        not null,
+    reference   text,
+    status      text        not null default 'active' check (status in ('active', 'captured', 'released')),
+    created_at  timestamptz not null default now(),
+    settled_at  timestamptz
+);
+
+create index if not exists wallet_holds_user_id_idx on public.wallet_holds (user_id, created_at desc);
+create index if not exists wallet_holds_active_idx on public.wallet_holds (user_id) where status = 'active';
+
+alter table public.wallet_holds enable row level security;
+
+alter table public.withdrawals
+    add column if not exists hold_id bigint references public.wallet_holds (id);
+
+-- When the withdrawal sweeper last verified the transfer of a pending or processing
+-- withdrawal with Paystack, for transfers whose outcome was never reported.
+alter table public.withdrawals
+    add column if not exists transfer_verified_at timestamptz;
+
+create index if not exists withdrawals_unsettled_idx
+    on public.withdrawals (updated_at)
+    where status in ('pending', 'processing');
+
+-- apply_wallet_delta now refuses debits that would dip into held funds. The wallet
+-- row is locked and checked before it is updated, so DG001 / DG002 are raised
+-- rather than the table's check constraints.
+create or replace function public.apply_wallet_delta(
+    p_user_id                 uuid,
+    p_datacredit_delta        bigint default 0,
+    p_databyte_delta          bigint default 0,
+    p_operation               text default 'adjustment',
+    p_description             text default null,
+    p_external_ref            text default null,
+    p_metadata                jsonb default '{}'::jsonb,
+    p_datacredit_counterparty text default null,
+    p_databyte_counterparty   text default null,
+    p_datacredit_operation    text default null,
+    p_databyte_operation      text default null
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet          public.wallets%rowtype;
+    v_entry_id        bigint;
+    v_ledger_credit   bigint;
+    v_ledger_databyte bigint;
+    v_metadata        jsonb := coalesce(p_metadata, '{}'::jsonb);
+    v_transactions    jsonb := '[]'::jsonb;
+    v_tx              public.transactions%rowtype;
+begin
+    if p_datacredit_delta <> 0 and p_datacredit_counterparty is null then
+        raise exception 'datacredit counterparty account is required for operation %', p_operation
+            using errcode = 'DG004';
+    end if;
+    if p_databyte_delta <> 0 and p_databyte_counterparty is null then
+        raise exception 'databyte counterparty account is required for operation %', p_operation
+            using errcode = 'DG004';
+    end if;
+
+    -- Make sure the row exists so there is always a row to lock.
+    insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+    values (p_user_id, 0, 0)
+    on conflict (user_id) do nothing;
+
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if p_datacredit_delta < 0 and v_wallet.datacredit_balance - v_wallet.held_datacredit + p_datacredit_delta < 0 then
+        raise exception 'insufficient datacredit balance for user %. Available: % kobo (% held), Tried to change by: % kobo',
+            p_user_id, v_wallet.datacredit_balance - v_wallet.held_datacredit, v_wallet.held_datacredit, p_datacredit_delta
+            using errcode = 'DG001';
+    end if;
+    if p_databyte_delta < 0 and v_wallet.databyte_balance + p_databyte_delta < 0 then
+        raise exception 'insufficient databyte balance for user %. Has: %, Tried to change by: %',
+            p_user_id, v_wallet.databyte_balance, p_databyte_delta
+            using errcode = 'DG002';
+    end if;
+
+    update public.wallets
+       set datacredit_balance = datacredit_balance + p_datacredit_delta,
+           databyte_balance   = databyte_balance + p_databyte_delta,
+           updated_at         = now()
+     where user_id = p_user_id
+    returning * into v_wallet;
+
+    perform public.user_ledger_account(p_user_id, 'datacredit');
+    perform public.user_ledger_account(p_user_id, 'databyte');
+
+    v_entry_id := public.post_journal_entry(
+        p_operation,
+        p_description,
+        p_external_ref,
+        v_metadata || jsonb_build_object('user_id', p_user_id),
+        jsonb_build_array(
+            jsonb_build_object('account', 'user:' || p_user_id || ':datacredit', 'amount', p_datacredit_delta),
+            jsonb_build_object('account', coalesce(p_datacredit_counterparty, ''), 'amount', -p_datacredit_delta),
+            jsonb_build_object('account', 'user:' || p_user_id || ':databyte', 'amount', p_databyte_delta),
+            jsonb_build_object('account', coalesce(p_databyte_counterparty, ''), 'amount', -p_databyte_delta)
+        )
+    );
+
+    select balance into v_ledger_credit
+      from public.ledger_accounts where owner_user_id = p_user_id and currency = 'datacredit';
+    select balance into v_ledger_databyte
+      from public.ledger_accounts where owner_user_id = p_user_id and currency = 'databyte';
+
+    if v_ledger_credit <> v_wallet.datacredit_balance or v_ledger_databyte <> v_wallet.databyte_balance then
+        raise exception 'wallet for user % is out of balance with the ledger (wallet %/%, ledger %/%)',
+            p_user_id, v_wallet.datacredit_balance, v_wallet.databyte_balance, v_ledger_credit, v_ledger_databyte
+            using errcode = 'DG005';
+    end if;
+
+    if p_datacredit_delta <> 0 then
+        insert into public.transactions (
+            user_id, amount, balance_before, balance_after, operation, currency, description,
+            external_reference_id, metadata, journal_entry_id, transaction_timestamp
+        ) values (
+            p_user_id, p_datacredit_delta, v_wallet.datacredit_balance - p_datacredit_delta, v_wallet.datacredit_balance,
+            coalesce(p_datacredit_operation, p_operation), 'datacredit', p_description,
+            p_external_ref, v_metadata, v_entry_id, now()
+        ) returning * into v_tx;
+        v_transactions := v_transactions || to_jsonb(v_tx);
+    end if;
+
+    if p_databyte_delta <> 0 then
+        insert into public.transactions (
+            user_id, amount, balance_before, balance_after, operation, currency, description,
+            external_reference_id, metadata, journal_entry_id, transaction_timestamp
+        ) values (
+            p_user_id, p_databyte_delta, v_wallet.databyte_balance - p_databyte_delta, v_wallet.databyte_balance,
+            coalesce(p_databyte_operation, p_operation), 'databyte', p_description,
+            p_external_ref, v_metadata, v_entry_id, now()
+        ) returning * into v_tx;
+        v_transactions := v_transactions || to_jsonb(v_tx);
+    end if;
+
+    return jsonb_build_object(
+        'wallet', to_jsonb(v_wallet),
+        'datacredit_balance_before', v_wallet.datacredit_balance - p_datacredit_delta,
+        'databyte_balance_before', v_wallet.databyte_balance - p_databyte_delta,
+        'journal_entry_id', v_entry_id,
+        'transactions', v_transactions
+    );
+end;
+$$;
+
+-- place_wallet_hold reserves p_amount of a user's available datacredit. It raises
+-- DG001 when less than p_amount is available.
+create or replace function public.place_wallet_hold(
+    p_user_id   uuid,
+    p_amount    bigint,
+    p_reason    text,
+    p_reference text
+) returns public.wallet_holds
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet public.wallets%rowtype;
+    v_hold   public.wallet_holds%rowtype;
+begin
+    insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+    values (p_user_id, 0, 0)
+    on conflict (user_id) do nothing;
+
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if v_wallet.datacredit_balance - v_wallet.held_datacredit < p_amount then
+        raise exception 'insufficient datacredit balance for user %. Available: % kobo (% held), Wants to hold: % kobo',
+            p_user_id, v_wallet.datacredit_balance - v_wallet.held_datacredit, v_wallet.held_datacredit, p_amount
+            using errcode = 'DG001';
+    end if;
+
+    update public.wallets
+       set held_datacredit = held_datacredit + p_amount,
+           updated_at      = now()
+     where user_id = p_user_id;
+
+    insert into public.wallet_holds (user_id, amount, reason, reference)
+    values (p_user_id, p_amount, p_reason, p_reference)
+    returning * into v_hold;
+
+    return v_hold;
+end;
+$$;
+
+-- release_wallet_hold makes a held amount available again. Releasing a hold that
+-- is no longer active raises DG010.
+create or replace function public.release_wallet_hold(
+    p_hold_id bigint
+) returns public.wallet_holds
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_hold public.wallet_holds%rowtype;
+begin
+    select * into v_hold
+      from public.wallet_holds
+     where id = p_hold_id
+       for update;
+
+    if not found or v_hold.status <> 'active' then
+        raise exception 'wallet hold % is not active', p_hold_id using errcode = 'DG010';
+    end if;
+
+    update public.wallets
+       set held_datacredit = held_datacredit - v_hold.amount,
+           updated_at      = now()
+     where user_id = v_hold.user_id;
+
+    update public.wallet_holds
+       set status     = 'released',
+           settled_at = now()
+     where id = p_hold_id
+    returning * into v_hold;
+
+    return v_hold;
+end;
+$$;
+
+-- capture_wallet_hold turns a hold into a real debit: the held amount is released
+-- and immediately debited through apply_wallet_delta, so the available balance
+-- does not change and the debit is journaled like any other wallet change.
+-- Capturing a hold that is no longer active raises DG010.
+create or replace function public.capture_wallet_hold(
+    p_hold_id      bigint,
+    p_operation    text,
+    p_description  text,
+    p_external_ref text,
+    p_metadata     jsonb,
+    p_counterparty text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_hold public.wallet_holds%rowtype;
+begin
+    v_hold := public.release_wallet_hold(p_hold_id);
+
+    update public.wallet_holds
+       set status = 'captured'
+     where id = p_hold_id;
+
+    return public.apply_wallet_delta(
+        p_user_id                 => v_hold.user_id,
+        p_datacredit_delta        => -v_hold.amount,
+        p_operation               => p_operation,
+        p_description             => p_description,
+        p_external_ref            => p_external_ref,
+        p_metadata                => coalesce(p_metadata, '{}'::jsonb) || jsonb_build_object('wallet_hold_id', v_hold.id),
+        p_datacredit_counterparty => p_counterparty
+    );
+end;
+$$;
+
+revoke execute on function public.place_wallet_hold(uuid, bigint, text, text) from public, anon, authenticated;
+grant execute on function public.place_wallet_hold(uuid, bigint, text, text) to service_role;
+revoke execute on function public.release_wallet_hold(bigint) from public, anon, authenticated;
+grant execute on function public.release_wallet_hold(bigint) to service_role;
+revoke execute on function public.capture_wallet_hold(bigint, text, text, text, jsonb, text) from public, anon, authenticated;
+grant execute on function public.capture_wallet_hold(bigint, text, text, text, jsonb, text) to service_role;
+
+-- Withdrawals now reserve their amount when they are created and only debit it
+-- when the transfer succeeds:
+--
+--   pending / processing --transfer.success--> completed   (hold captured)
+--   pending / processing --transfer.failed---> failed      (hold released)
+--   completed            --transfer.reversed-> reversed    (compensating credit)
+--
+-- debit_withdrawal is replaced by create_withdrawal and the hold. Withdrawals
+-- already debited under the old flow are still settled correctly.
+
+drop function if exists public.debit_withdrawal(uuid, text, bigint, text, jsonb, text);
+
+-- create_withdrawal stores a pending withdrawal and places a hold for its amount in
+-- one transaction. It raises DG001 when the user's available balance is too low.
+create or replace function public.create_withdrawal(
+    p_user_id           uuid,
+    p_amount            bigint,
+    p_currency          text,
+    p_reference         text,
+    p_recipient_code    text,
+    p_payout_account_id uuid
+) returns public.withdrawals
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_hold       public.wallet_holds%rowtype;
+    v_withdrawal public.withdrawals%rowtype;
+begin
+    v_hold := public.place_wallet_hold(p_user_id, p_amount, 'withdrawal', p_reference);
+
+    insert into public.withdrawals (user_id, amount, currency, reference, recipient_code, payout_account_id, hold_id)
+    values (p_user_id, p_amount, coalesce(p_currency, 'NGN'), p_reference, p_recipient_code, p_payout_account_id, v_hold.id)
+    returning * into v_withdrawal;
+
+    return v_withdrawal;
+end;
+$$;
+
+revoke execute on function public.create_withdrawal(uuid, bigint, text, text, text, uuid) from public, anon, authenticated;
+grant execute on function public.create_withdrawal(uuid, bigint, text, text, text, uuid) to service_role;
+
+drop function if exists public.settle_withdrawal(text, text, text, text, text, text);
+
+-- settle_withdrawal applies a transfer outcome ('success', 'failed' or 'reversed')
+-- to a withdrawal found by id, our reference or Paystack's transfer code. The row
+-- is locked, so repeated or concurrent deliveries settle it once: callers get
+-- {"changed": false} when the outcome had already been applied.
+create or replace function public.settle_withdrawal(
+    p_withdrawal_id uuid,
+    p_reference     text,
+    p_transfer_code text,
+    p_outcome       text,
+    p_reason        text,
+    p_description   text,
+    p_counterparty  text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+    v_change     jsonb;
+begin
+    if p_outcome not in ('success', 'failed', 'reversed') then
+        raise exception 'unknown transfer outcome %', p_outcome;
+    end if;
+
+    select * into v_withdrawal
+      from public.withdrawals
+     where (p_withdrawal_id is not null and id = p_withdrawal_id)
+        or (p_reference is not null and reference = p_reference)
+        or (p_transfer_code is not null and transfer_code = p_transfer_code)
+     limit 1
+       for update;
+
+    if not found then
+        raise exception 'withdrawal for transfer % (reference %) not found', p_transfer_code, p_reference
+            using errcode = 'DG007';
+    end if;
+
+    if v_withdrawal.status in ('pending', 'processing') and v_withdrawal.hold_id is not null then
+        if p_outcome = 'success' then
+            v_change := public.capture_wallet_hold(
+                v_withdrawal.hold_id,
+                'withdrawal',
+                p_description,
+                coalesce(p_transfer_code, v_withdrawal.transfer_code, v_withdrawal.reference),
+                jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_reference', v_withdrawal.reference),
+                p_counterparty
+            );
+
+            update public.withdrawals
+               set status                 = 'completed',
+                   transfer_code          = coalesce(transfer_code, p_transfer_code),
+                   debit_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+                   updated_at             = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        else
+            perform public.release_wallet_hold(v_withdrawal.hold_id);
+
+            update public.withdrawals
+               set status         = p_outcome,
+                   failure_reason = p_reason,
+                   updated_at     = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        end if;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    -- Withdrawals created before wallet holds were debited when their transfer
+    -- started, so they are already 'processing' with no hold to capture.
+    if v_withdrawal.status = 'processing' and p_outcome = 'success' then
+        update public.withdrawals
+           set status     = 'completed',
+               updated_at = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    if v_withdrawal.status in ('processing', 'completed') and p_outcome <> 'success' then
+        -- The money was already debited: return it with a compensating credit.
+        v_change := public.apply_wallet_delta(
+            p_user_id                 => v_withdrawal.user_id,
+            p_datacredit_delta        => v_withdrawal.amount,
+            p_operation               => 'withdrawal_reversal',
+            p_description             => p_description,
+            p_external_ref            => v_withdrawal.transfer_code,
+            p_metadata                => jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_outcome', p_outcome, 'reason', p_reason),
+            p_datacredit_counterparty => p_counterparty
+        );
+
+        update public.withdrawals
+           set status                    = case when status = 'completed' then 'reversed' else p_outcome end,
+               failure_reason            = p_reason,
+               reversal_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+               updated_at                = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    if v_withdrawal.status = 'pending' then
+        raise exception 'withdrawal % has no hold and was never debited; settle it manually', v_withdrawal.id
+            using errcode = 'DG008';
+    end if;
+
+    return jsonb_build_object('changed', false, 'withdrawal', to_jsonb(v_withdrawal));
+end;
+$$;
+
+revoke execute on function public.settle_withdrawal(uuid, text, text, text, text, text, text) from public, anon, authenticated;
+grant execute on function public.settle_withdrawal(uuid, text, text, text, text, text, text) to service_role;
@@ -0,0 +1,89 @@
+-- Withdrawal history.
+--
+-- Withdrawals record the fee charged on them and when they entered each status,
+-- and every status change is appended to withdrawal_status_events with the
+-- failure reason at the time. Both are maintained by triggers, so every code
+-- path that moves a withdrawal (the API, webhooks, settle_withdrawal) is covered.
+
+alter table public.withdrawals
+    add column if not exists fee           bigint      not null default 0 check (fee >= 0), -- kobo
+    add column if not exists processing_at timestamptz,
+    add column if not exists completed_at  timestamptz,
+    add column if not exists failed_at     timestamptz,
+    add column if not exists reversed_at   timestamptz;
+
+create table if not exists public.withdrawal_status_events (
+    id             bigserial   primary key,
+    withdrawal_id  uuid        not null references public.withdrawals (id) on delete cascade,
+    from_status    text,
+    to_status      text        not null,
+    failure_reason text,
+    created_at     timestamptz not null default now()
+);
+
+create index if not exists withdrawal_status_events_withdrawal_id_idx
+    on public.withdrawal_status_events (withdrawal_id, id);
+
+alter table public.withdrawal_status_events enable row level security;
+
+-- stamp_withdrawal_status sets the timestamp column for the status a withdrawal
+-- is entering.
+create or replace function public.stamp_withdrawal_status()
+returns trigger
+language plpgsql
+as $$
+begin
+    if tg_op = 'INSERT' or new.status is distinct from old.status then
+        case new.status
+            when 'processing' then new.processing_at := coalesce(new.processing_at, now());
+            when 'completed'  then new.completed_at  := coalesce(new.completed_at, now());
+            when 'failed'     then new.failed_at     := coalesce(new.failed_at, now());
+            when 'reversed'   then new.reversed_at   := coalesce(new.reversed_at, now());
+            else null;
+        end case;
+    end if;
+    return new;
+end;
+$$;
+
+-- record_withdrawal_status_event appends a withdrawal's status changes to
+-- withdrawal_status_events.
+create or replace function public.record_withdrawal_status_event()
+returns trigger
+language plpgsql
+security definer
+set search_path = public
+as $$
+begin
+    if tg_op = 'INSERT' then
+        insert into public.withdrawal_status_events (withdrawal_id, from_status, to_status, failure_reason)
+        values (new.id, null, new.status, new.failure_reason);
+    elsif new.status is distinct from old.status then
+        insert into public.withdrawal_status_events (withdrawal_id, from_status, to_status, failure_reason)
+        values (new.id, old.status, new.status, new.failure_reason);
+    end if;
+    return null;
+end;
+$$;
+
+drop trigger if exists withdrawals_stamp_status on public.withdrawals;
+create trigger withdrawals_stamp_status
+    before insert or update of status on public.withdrawals
+    for each row execute function public.stamp_withdrawal_status();
+
+drop trigger if exists withdrawals_record_status_event on public.withdrawals;
+create trigger withdrawals_record_status_event
+    after insert or update of status on public.withdrawals
+    for each row execute function public.record_withdrawal_status_event();
+
+-- Backfill what we can for withdrawals created before this migration.
+update public.withdrawals
+   set processing_at = case when status in ('processing', 'completed', 'reversed') then coalesce(processing_at, created_at) end,
+       completed_at  = case when status = 'completed' then coalesce(completed_at, updated_at) end,
+       failed_at     = case when status = 'failed' then coalesce(failed_at, updated_at) end,
+       reversed_at   = case when status = 'reversed' then coalesce(reversed_at, updated_at) end;
+
+insert into public.withdrawal_status_events (withdrawal_id, from_status, to_status, failure_reason, created_at)
+select w.id, null, w.status, w.failure_reason, w.updated_at
+  from public.withdrawals w
+ where not exists (select 1 from public.withdrawal_status_events e where e.withdrawal_id = w.id);
@@ -0,0 +1,157 @@
+-- Maker-checker approval for withdrawals.
+--
+-- Withdrawals above the configured threshold, or from users flagged for review,
+-- wait in 'pending_approval' with their funds held until an admin acts:
+--
+--   pending_approval --approve--> pending -> (transfer as usual)
+--                    \--reject--> rejected   (hold released)
+--
+-- The approving admin must not be the user who requested the withdrawal.
+
+alter table public.withdrawals drop constraint if exists withdrawals_status_check;
+alter table public.withdrawals
+    add constraint withdrawals_status_check
+    check (status in ('pending_approval', 'pending', 'processing', 'completed', 'failed', 'reversed', 'rejected'));
+
+alter table public.withdrawals
+    add column if not exists approval_reason text,
+    add column if not exists approved_by     uuid,
+    add column if not exists approved_at     timestamptz,
+    add column if not exists rejected_by     uuid,
+    add column if not exists rejected_at     timestamptz;
+
+create index if not exists withdrawals_pending_approval_idx
+    on public.withdrawals (created_at)
+    where status = 'pending_approval';
+
+-- Users whose withdrawals always need approval.
+create table if not exists public.withdrawal_review_flags (
+    user_id    uuid        primary key references auth.users (id),
+    reason     text        not null,
+    flagged_by uuid,
+    created_at timestamptz not null default now()
+);
+
+alter table public.withdrawal_review_flags enable row level security;
+
+drop function if exists public.create_withdrawal(uuid, bigint, text, text, text, uuid);
+
+-- create_withdrawal stores a withdrawal and places a hold for its amount in one
+-- transaction. With an approval reason the withdrawal starts in pending_approval
+-- instead of pending, so it is never visible as ready for transfer.
+create or replace function public.create_withdrawal(
+    p_user_id           uuid,
+    p_amount            bigint,
+    p_currency          text,
+    p_reference         text,
+    p_recipient_code    text,
+    p_payout_account_id uuid,
+    p_approval_reason   text
+) returns public.withdrawals
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_hold       public.wallet_holds%rowtype;
+    v_withdrawal public.withdrawals%rowtype;
+begin
+    v_hold := public.place_wallet_hold(p_user_id, p_amount, 'withdrawal', p_reference);
+
+    insert into public.withdrawals (user_id, amount, currency, reference, recipient_code, payout_account_id, hold_id, status, approval_reason)
+    values (p_user_id, p_amount, coalesce(p_currency, 'NGN'), p_reference, p_recipient_code, p_payout_account_id, v_hold.id,
+            case when p_approval_reason is null then 'pending' else 'pending_approval' end, p_approval_reason)
+    returning * into v_withdrawal;
+
+    return v_withdrawal;
+end;
+$$;
+
+-- approve_withdrawal moves a withdrawal out of pending_approval so its transfer can
+-- be started. It raises DG008 if the withdrawal is not awaiting approval and DG011
+-- if the admin is the user who requested it.
+create or replace function public.approve_withdrawal(
+    p_withdrawal_id uuid,
+    p_admin_id      uuid
+) returns public.withdrawals
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+begin
+    select * into v_withdrawal
+      from public.withdrawals
+     where id = p_withdrawal_id
+       for update;
+
+    if not found then
+        raise exception 'withdrawal % not found', p_withdrawal_id using errcode = 'DG007';
+    end if;
+    if v_withdrawal.status <> 'pending_approval' then
+        raise exception 'withdrawal % is %, not pending_approval', p_withdrawal_id, v_withdrawal.status using errcode = 'DG008';
+    end if;
+    if v_withdrawal.user_id = p_admin_id then
+        raise exception 'withdrawal % must be approved by an admin other than its requester', p_withdrawal_id using errcode = 'DG011';
+    end if;
+
+    update public.withdrawals
+       set status      = 'pending',
+           approved_by = p_admin_id,
+           approved_at = now(),
+           updated_at  = now()
+     where id = p_withdrawal_id
+    returning * into v_withdrawal;
+
+    return v_withdrawal;
+end;
+$$;
+
+-- reject_withdrawal rejects a withdrawal awaiting approval and releases its hold,
+-- in one transaction. It raises DG008 if the withdrawal is not awaiting approval.
+create or replace function public.reject_withdrawal(
+    p_withdrawal_id uuid,
+    p_admin_id      uuid,
+    p_reason        text
+) returns public.withdrawals
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+begin
+    select * into v_withdrawal
+      from public.withdrawals
+     where id = p_withdrawal_id
+       for update;
+
+    if not found then
+        raise exception 'withdrawal % not found', p_withdrawal_id using errcode = 'DG007';
+    end if;
+    if v_withdrawal.status <> 'pending_approval' then
+        raise exception 'withdrawal % is %, not pending_approval', p_withdrawal_id, v_withdrawal.status using errcode = 'DG008';
+    end if;
+
+    perform public.release_wallet_hold(v_withdrawal.hold_id);
+
+    update public.withdrawals
+       set status         = 'rejected',
+           failure_reason = p_reason,
+           rejected_by    = p_admin_id,
+           rejected_at    = now(),
+           updated_at     = now()
+     where id = p_withdrawal_id
+    returning * into v_withdrawal;
+
+    return v_withdrawal;
+end;
+$$;
+
+revoke execute on function public.create_withdrawal(uuid, bigint, text, text, text, uuid, text) from public, anon, authenticated;
+grant execute on function public.create_withdrawal(uuid, bigint, text, text, text, uuid, text) to service_role;
+revoke execute on function public.approve_withdrawal(uuid, uuid) from public, anon, authenticated;
+grant execute on function public.approve_withdrawal(uuid, uuid) to service_role;
+revoke execute on function public.reject_withdrawal(uuid, uuid, text) from public, anon, authenticated;
+grant execute on function public.reject_withdrawal(uuid, uuid, text) to service_role;
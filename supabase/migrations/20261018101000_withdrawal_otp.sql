@@ -0,0 +1,131 @@
+-- Transfer OTP finalization.
+--
+-- When OTP is enabled on the Paystack integration, a new transfer is not sent until
+-- it is finalized with the OTP Paystack texts to the business. Such withdrawals wait
+-- in 'awaiting_otp', still holding their funds:
+--
+--   pending -> awaiting_otp --finalize--> processing -> completed / failed / reversed
+--
+-- A transfer can fail or be reversed while awaiting its OTP, so settle_withdrawal
+-- treats 'awaiting_otp' like 'pending' and 'processing'.
+
+alter table public.withdrawals drop constraint if exists withdrawals_status_check;
+alter table public.withdrawals
+    add constraint withdrawals_status_check
+    check (status in ('pending_approval', 'pending', 'awaiting_otp', 'processing', 'completed', 'failed', 'reversed', 'rejected'));
+
+-- The withdrawal sweeper also verifies transfers left awaiting their OTP.
+drop index if exists public.withdrawals_unsettled_idx;
+create index if not exists withdrawals_unsettled_idx
+    on public.withdrawals (updated_at)
+    where status in ('pending', 'awaiting_otp', 'processing');
+
+create or replace function public.settle_withdrawal(
+    p_withdrawal_id uuid,
+    p_reference     text,
+    p_transfer_code text,
+    p_outcome       text,
+    p_reason        text,
+    p_description   text,
+    p_counterparty  text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+    v_change     jsonb;
+begin
+    if p_outcome not in ('success', 'failed', 'reversed') then
+        raise exception 'unknown transfer outcome %', p_outcome;
+    end if;
+
+    select * into v_withdrawal
+      from public.withdrawals
+     where (p_withdrawal_id is not null and id = p_withdrawal_id)
+        or (p_reference is not null and reference = p_reference)
+        or (p_transfer_code is not null and transfer_code = p_transfer_code)
+     limit 1
+       for update;
+
+    if not found then
+        raise exception 'withdrawal for transfer % (reference %) not found', p_transfer_code, p_reference
+            using errcode = 'DG007';
+    end if;
+
+    if v_withdrawal.status in ('pending', 'awaiting_otp', 'processing') and v_withdrawal.hold_id is not null then
+        if p_outcome = 'success' then
+            v_change := public.capture_wallet_hold(
+                v_withdrawal.hold_id,
+                'withdrawal',
+                p_description,
+                coalesce(p_transfer_code, v_withdrawal.transfer_code, v_withdrawal.reference),
+                jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_reference', v_withdrawal.reference),
+                p_counterparty
+            );
+
+            update public.withdrawals
+               set status                 = 'completed',
+                   transfer_code          = coalesce(transfer_code, p_transfer_code),
+                   debit_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+                   updated_at             = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        else
+            perform public.release_wallet_hold(v_withdrawal.hold_id);
+
+            update public.withdrawals
+               set status         = p_outcome,
+                   failure_reason = p_reason,
+                   updated_at     = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        end if;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    -- Withdrawals created before wallet holds were debited when their transfer
+    -- started, so they are already 'processing' with no hold to capture.
+    if v_withdrawal.status = 'processing' and p_outcome = 'success' then
+        update public.withdrawals
+           set status     = 'completed',
+               updated_at = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    if v_withdrawal.status in ('processing', 'completed') and p_outcome <> 'success' then
+        -- The money was already debited: return it with a compensating credit.
+        v_change := public.apply_wallet_delta(
+            p_user_id                 => v_withdrawal.user_id,
+            p_datacredit_delta        => v_withdrawal.amount,
+            p_operation               => 'withdrawal_reversal',
+            p_description             => p_description,
+            p_external_ref            => v_withdrawal.transfer_code,
+            p_metadata                => jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_outcome', p_outcome, 'reason', p_reason),
+            p_datacredit_counterparty => p_counterparty
+        );
+
+        update public.withdrawals
+           set status                    = case when status = 'completed' then 'reversed' else p_outcome end,
+               failure_reason            = p_reason,
+               reversal_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+               updated_at                = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    if v_withdrawal.status = 'pending' then
+        raise exception 'withdrawal % has no hold and was never debited; settle it manually', v_withdrawal.id
+            using errcode = 'DG008';
+    end if;
+
+    return jsonb_build_object('changed', false, 'withdrawal', to_jsonb(v_withdrawal));
+end;
+$$;
@@ -0,0 +1,117 @@
+-- Batched payouts.
+--
+-- In payout-batching mode withdrawals ready for transfer wait in 'queued' (still
+-- holding their funds) instead of each starting its own transfer. A scheduled job
+-- claims them into a payout_batches row and sends the batch through Paystack's bulk
+-- transfer API; claimed withdrawals move back to 'pending' and continue exactly as
+-- single transfers do:
+--
+--   queued --claim_payout_batch--> pending -> processing -> completed / failed / reversed
+--
+-- Every batch records what was sent and what Paystack answered, so a payout run
+-- can be audited as a unit.
+
+alter table public.withdrawals drop constraint if exists withdrawals_status_check;
+alter table public.withdrawals
+    add constraint withdrawals_status_check
+    check (status in ('pending_approval', 'queued', 'pending', 'awaiting_otp', 'processing', 'completed', 'failed', 'reversed', 'rejected'));
+
+create table if not exists public.payout_batches (
+    id                uuid        primary key default gen_random_uuid(),
+    currency          text        not null,
+    status            text        not null default 'sending'
+                                  check (status in ('sending', 'sent', 'rejected', 'unconfirmed')),
+    item_count        integer     not null default 0,
+    total_amount      bigint      not null default 0, -- kobo sent, after fees
+    fee_total         bigint      not null default 0, -- kobo kept as withdrawal fees
+    failure_reason    text,
+    paystack_response jsonb,
+    created_at        timestamptz not null default now(),
+    sent_at           timestamptz,
+    updated_at        timestamptz not null default now()
+);
+
+create index if not exists payout_batches_created_at_idx on public.payout_batches (created_at desc);
+
+alter table public.payout_batches enable row level security;
+
+alter table public.withdrawals
+    add column if not exists payout_batch_id uuid references public.payout_batches (id);
+
+create index if not exists withdrawals_payout_batch_id_idx on public.withdrawals (payout_batch_id);
+create index if not exists withdrawals_queued_idx
+    on public.withdrawals (currency, created_at)
+    where status = 'queued';
+
+-- claim_payout_batch creates a batch of up to p_limit queued withdrawals in the
+-- currency of the oldest one and moves them to 'pending'. Rows are claimed with
+-- skip locked, so concurrent runs never put a withdrawal in two batches. The
+-- batch total is what the bulk transfer sends, each amount net of its fee, with
+-- the fees kept in fee_total. Returns {"batch": ..., "withdrawals": [...]}, or
+-- null when nothing is queued.
+create or replace function public.claim_payout_batch(
+    p_limit integer
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_currency    text;
+    v_batch       public.payout_batches%rowtype;
+    v_withdrawals jsonb;
+begin
+    select currency into v_currency
+      from public.withdrawals
+     where status = 'queued'
+     order by created_at
+     limit 1;
+
+    if not found then
+        return null;
+    end if;
+
+    insert into public.payout_batches (currency)
+    values (v_currency)
+    returning * into v_batch;
+
+    with claimed as (
+        select id
+          from public.withdrawals
+         where status = 'queued'
+           and currency = v_currency
+         order by created_at
+         limit p_limit
+           for update skip locked
+    ), moved as (
+        update public.withdrawals w
+           set status          = 'pending',
+               payout_batch_id = v_batch.id,
+               updated_at      = now()
+          from claimed
+         where w.id = claimed.id
+        returning w.*
+    )
+    select coalesce(jsonb_agg(to_jsonb(moved) order by moved.created_at), '[]'::jsonb)
+      into v_withdrawals
+      from moved;
+
+    if jsonb_array_length(v_withdrawals) = 0 then
+        delete from public.payout_batches where id = v_batch.id;
+        return null;
+    end if;
+
+    update public.payout_batches
+       set item_count   = jsonb_array_length(v_withdrawals),
+           total_amount = (select sum((w ->> 'amount')::bigint - (w ->> 'fee')::bigint) from jsonb_array_elements(v_withdrawals) w),
+           fee_total    = (select sum((w ->> 'fee')::bigint) from jsonb_array_elements(v_withdrawals) w),
+           updated_at   = now()
+     where id = v_batch.id
+    returning * into v_batch;
+
+    return jsonb_build_object('batch', to_jsonb(v_batch), 'withdrawals', v_withdrawals);
+end;
+$$;
+
+revoke execute on function public.claim_payout_batch(integer) from public, anon, authenticated;
+grant execute on function public.claim_payout_batch(integer) to service_role;
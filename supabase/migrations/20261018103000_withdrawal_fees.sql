@@ -0,0 +1,224 @@
+-- Withdrawal fees and limits.
+--
+-- A withdrawal's amount is what leaves the wallet; its fee is kept by the platform
+-- and the rest (amount - fee) is transferred to the user's bank. When the transfer
+-- succeeds the wallet is debited the full amount against Paystack clearing, and a
+-- second journal entry moves the fee from clearing to platform:fees, recorded on
+-- the withdrawal as fee_journal_entry_id. A reversal after completion returns the
+-- full amount and reverses the fee entry.
+--
+-- create_withdrawal also enforces the daily withdrawal limit. It runs after the
+-- wallet row is locked by the hold, so concurrent withdrawals cannot both pass.
+
+alter table public.withdrawals
+    add column if not exists fee_journal_entry_id bigint references public.journal_entries (id);
+
+alter table public.withdrawals drop constraint if exists withdrawals_fee_below_amount;
+alter table public.withdrawals
+    add constraint withdrawals_fee_below_amount check (fee < amount);
+
+-- withdrawal_daily_total is how much a user has withdrawn today (Lagos time),
+-- counting withdrawals that are still in flight but not ones that returned the funds.
+create or replace function public.withdrawal_daily_total(
+    p_user_id uuid
+) returns bigint
+language sql
+stable
+security definer
+set search_path = public
+as $$
+    select coalesce(sum(amount), 0)::bigint
+      from public.withdrawals
+     where user_id = p_user_id
+       and status not in ('failed', 'reversed', 'rejected')
+       and created_at >= date_trunc('day', now() at time zone 'Africa/Lagos') at time zone 'Africa/Lagos';
+$$;
+
+drop function if exists public.create_withdrawal(uuid, bigint, text, text, text, uuid, text);
+
+-- create_withdrawal stores a withdrawal with its fee and places a hold for its
+-- amount in one transaction. It raises DG001 when the user's available balance is
+-- too low and DG012 when the withdrawal would take the user past p_daily_limit
+-- (0 means no limit).
+create or replace function public.create_withdrawal(
+    p_user_id           uuid,
+    p_amount            bigint,
+    p_fee               bigint,
+    p_currency          text,
+    p_reference         text,
+    p_recipient_code    text,
+    p_payout_account_id uuid,
+    p_approval_reason   text,
+    p_daily_limit       bigint
+) returns public.withdrawals
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_hold       public.wallet_holds%rowtype;
+    v_withdrawal public.withdrawals%rowtype;
+    v_today      bigint;
+begin
+    v_hold := public.place_wallet_hold(p_user_id, p_amount, 'withdrawal', p_reference);
+
+    if coalesce(p_daily_limit, 0) > 0 then
+        v_today := public.withdrawal_daily_total(p_user_id);
+        if v_today + p_amount > p_daily_limit then
+            raise exception 'withdrawal of % would exceed the daily limit of % (% already withdrawn today)', p_amount, p_daily_limit, v_today
+                using errcode = 'DG012';
+        end if;
+    end if;
+
+    insert into public.withdrawals (user_id, amount, fee, currency, reference, recipient_code, payout_account_id, hold_id, status, approval_reason)
+    values (p_user_id, p_amount, p_fee, coalesce(p_currency, 'NGN'), p_reference, p_recipient_code, p_payout_account_id, v_hold.id,
+            case when p_approval_reason is null then 'pending' else 'pending_approval' end, p_approval_reason)
+    returning * into v_withdrawal;
+
+    return v_withdrawal;
+end;
+$$;
+
+create or replace function public.settle_withdrawal(
+    p_withdrawal_id uuid,
+    p_reference     text,
+    p_transfer_code text,
+    p_outcome       text,
+    p_reason        text,
+    p_description   text,
+    p_counterparty  text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+    v_change     jsonb;
+    v_fee_entry  bigint;
+begin
+    if p_outcome not in ('success', 'failed', 'reversed') then
+        raise exception 'unknown transfer outcome %', p_outcome;
+    end if;
+
+    select * into v_withdrawal
+      from public.withdrawals
+     where (p_withdrawal_id is not null and id = p_withdrawal_id)
+        or (p_reference is not null and reference = p_reference)
+        or (p_transfer_code is not null and transfer_code = p_transfer_code)
+     limit 1
+       for update;
+
+    if not found then
+        raise exception 'withdrawal for transfer % (reference %) not found', p_transfer_code, p_reference
+            using errcode = 'DG007';
+    end if;
+
+    if v_withdrawal.status in ('pending', 'awaiting_otp', 'processing') and v_withdrawal.hold_id is not null then
+        if p_outcome = 'success' then
+            v_change := public.capture_wallet_hold(
+                v_withdrawal.hold_id,
+                'withdrawal',
+                p_description,
+                coalesce(p_transfer_code, v_withdrawal.transfer_code, v_withdrawal.reference),
+                jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_reference', v_withdrawal.reference, 'fee', v_withdrawal.fee),
+                p_counterparty
+            );
+
+            if v_withdrawal.fee > 0 then
+                v_fee_entry := public.post_journal_entry(
+                    'withdrawal_fee',
+                    'Withdrawal fee',
+                    coalesce(p_transfer_code, v_withdrawal.transfer_code, v_withdrawal.reference),
+                    jsonb_build_object('withdrawal_id', v_withdrawal.id, 'user_id', v_withdrawal.user_id),
+                    jsonb_build_array(
+                        jsonb_build_object('account', p_counterparty, 'amount', -v_withdrawal.fee),
+                        jsonb_build_object('account', 'platform:fees', 'amount', v_withdrawal.fee)
+                    )
+                );
+            end if;
+
+            update public.withdrawals
+               set status                 = 'completed',
+                   transfer_code          = coalesce(transfer_code, p_transfer_code),
+                   debit_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+                   fee_journal_entry_id   = v_fee_entry,
+                   updated_at             = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        else
+            perform public.release_wallet_hold(v_withdrawal.hold_id);
+
+            update public.withdrawals
+               set status         = p_outcome,
+                   failure_reason = p_reason,
+                   updated_at     = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        end if;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    -- Withdrawals created before wallet holds were debited when their transfer
+    -- started, so they are already 'processing' with no hold to capture.
+    if v_withdrawal.status = 'processing' and p_outcome = 'success' then
+        update public.withdrawals
+           set status     = 'completed',
+               updated_at = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    if v_withdrawal.status in ('processing', 'completed') and p_outcome <> 'success' then
+        -- The money was already debited: return it with a compensating credit, and
+        -- give back the fee if one was taken.
+        v_change := public.apply_wallet_delta(
+            p_user_id                 => v_withdrawal.user_id,
+            p_datacredit_delta        => v_withdrawal.amount,
+            p_operation               => 'withdrawal_reversal',
+            p_description             => p_description,
+            p_external_ref            => v_withdrawal.transfer_code,
+            p_metadata                => jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_outcome', p_outcome, 'reason', p_reason),
+            p_datacredit_counterparty => p_counterparty
+        );
+
+        if v_withdrawal.fee_journal_entry_id is not null then
+            perform public.post_journal_entry(
+                'withdrawal_fee_reversal',
+                'Withdrawal fee returned with reversed transfer',
+                v_withdrawal.transfer_code,
+                jsonb_build_object('withdrawal_id', v_withdrawal.id, 'user_id', v_withdrawal.user_id, 'fee_journal_entry_id', v_withdrawal.fee_journal_entry_id),
+                jsonb_build_array(
+                    jsonb_build_object('account', 'platform:fees', 'amount', -v_withdrawal.fee),
+                    jsonb_build_object('account', p_counterparty, 'amount', v_withdrawal.fee)
+                )
+            );
+        end if;
+
+        update public.withdrawals
+           set status                    = case when status = 'completed' then 'reversed' else p_outcome end,
+               failure_reason            = p_reason,
+               reversal_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+               updated_at                = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    if v_withdrawal.status = 'pending' then
+        raise exception 'withdrawal % has no hold and was never debited; settle it manually', v_withdrawal.id
+            using errcode = 'DG008';
+    end if;
+
+    return jsonb_build_object('changed', false, 'withdrawal', to_jsonb(v_withdrawal));
+end;
+$$;
+
+revoke execute on function public.withdrawal_daily_total(uuid) from public, anon, authenticated;
+grant execute on function public.withdrawal_daily_total(uuid) to service_role;
+revoke execute on function public.create_withdrawal(uuid, bigint, bigint, text, text, text, uuid, text, bigint) from public, anon, authenticated;
+grant execute on function public.create_withdrawal(uuid, bigint, bigint, text, text, text, uuid, text, bigint) to service_role;
@@ -0,0 +1,364 @@
+-- Refunds of datacredit purchases.
+--
+-- Support issues a full or partial refund of a succeeded payment intent through
+-- Paystack's refund API. The refunded datacredit is clawed back from the wallet
+-- when the refund is created, so it cannot be spent while Paystack processes it.
+-- Whatever the user has already spent (or has held) becomes a wallet debt,
+-- recorded against platform:receivables and collected from later purchases:
+--
+--   pending --Paystack accepts--> processing --refund.processed--> processed
+--         \--Paystack rejects--\            \--refund.failed----> failed
+--                               `-------------------------------> failed
+--
+-- A failed refund returns the clawback and any debt already collected to the
+-- wallet and cancels the rest of the debt.
+
+insert into public.ledger_accounts (code, currency, kind)
+values ('platform:receivables', 'datacredit', 'platform')
+on conflict (code) do nothing;
+
+create table if not exists public.refunds (
+    id                         uuid        primary key default gen_random_uuid(),
+    payment_intent_id          uuid        not null references public.payment_intents (id),
+    user_id                    uuid        not null references auth.users (id),
+    reference                  text        not null, -- Paystack transaction reference of the refunded payment
+    amount                     bigint      not null check (amount > 0), -- kobo
+    currency                   text        not null default 'NGN',
+    clawback_amount            bigint      not null default 0 check (clawback_amount >= 0), -- Debited from the wallet
+    debt_amount                bigint      not null default 0 check (debt_amount >= 0),     -- Recorded as a wallet debt
+    status                     text        not null default 'pending'
+                                           check (status in ('pending', 'processing', 'processed', 'failed')),
+    reason                     text,
+    requested_by               uuid,
+    paystack_refund_id         bigint      unique,
+    failure_reason             text,
+    clawback_journal_entry_id  bigint      references public.journal_entries (id),
+    reversal_journal_entry_id  bigint      references public.journal_entries (id),
+    created_at                 timestamptz not null default now(),
+    processed_at               timestamptz,
+    failed_at                  timestamptz,
+    updated_at                 timestamptz not null default now(),
+    check (clawback_amount + debt_amount = amount)
+);
+
+create index if not exists refunds_payment_intent_id_idx on public.refunds (payment_intent_id);
+create index if not exists refunds_reference_idx on public.refunds (reference, created_at desc);
+create index if not exists refunds_user_id_idx on public.refunds (user_id, created_at desc);
+
+alter table public.refunds enable row level security;
+
+create table if not exists public.wallet_debts (
+    id          bigserial   primary key,
+    user_id     uuid        not null references auth.users (id),
+    refund_id   uuid        references public.refunds (id),
+    amount      bigint      not null check (amount > 0),       -- kobo
+    outstanding bigint      not null check (outstanding >= 0), -- kobo still owed
+    status      text        not null default 'open' check (status in ('open', 'settled', 'cancelled')),
+    created_at  timestamptz not null default now(),
+    settled_at  timestamptz,
+    updated_at  timestamptz not null default now(),
+    check (outstanding <= amount)
+);
+
+create index if not exists wallet_debts_open_idx
+    on public.wallet_debts (user_id, created_at)
+    where status = 'open';
+
+alter table public.wallet_debts enable row level security;
+
+-- create_refund records a refund of a succeeded payment intent and claws it back
+-- from the wallet, in one transaction. p_amount null refunds everything not yet
+-- refunded. It raises DG006 if the intent does not exist and DG013 if it is not
+-- refundable or the amount exceeds what is left to refund.
+create or replace function public.create_refund(
+    p_reference    text,
+    p_amount       bigint,
+    p_reason       text,
+    p_requested_by uuid,
+    p_description  text,
+    p_counterparty text
+) returns public.refunds
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_intent    public.payment_intents%rowtype;
+    v_wallet    public.wallets%rowtype;
+    v_refund    public.refunds%rowtype;
+    v_refunded  bigint;
+    v_remaining bigint;
+    v_amount    bigint;
+    v_clawback  bigint;
+    v_change    jsonb;
+begin
+    select * into v_intent
+      from public.payment_intents
+     where reference = p_reference
+       for update;
+
+    if not found then
+        raise exception 'payment intent % not found', p_reference using errcode = 'DG006';
+    end if;
+    if v_intent.status <> 'succeeded' then
+        raise exception 'payment % is %, only succeeded payments can be refunded', p_reference, v_intent.status
+            using errcode = 'DG013';
+    end if;
+
+    select coalesce(sum(amount), 0) into v_refunded
+      from public.refunds
+     where payment_intent_id = v_intent.id
+       and status <> 'failed';
+
+    v_remaining := v_intent.amount - v_refunded;
+    v_amount := coalesce(p_amount, v_remaining);
+    if v_amount <= 0 or v_amount > v_remaining then
+        raise exception 'cannot refund % of payment %: % of % is left to refund', v_amount, p_reference, v_remaining, v_intent.amount
+            using errcode = 'DG013';
+    end if;
+
+    -- Claw back what the wallet can cover; the rest becomes a debt.
+    insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+    values (v_intent.user_id, 0, 0)
+    on conflict (user_id) do nothing;
+
+    select * into v_wallet
+      from public.wallets
+     where user_id = v_intent.user_id
+       for update;
+
+    v_clawback := least(v_amount, greatest(v_wallet.datacredit_balance - v_wallet.held_datacredit, 0));
+
+    insert into public.refunds (payment_intent_id, user_id, reference, amount, currency, clawback_amount, debt_amount, reason, requested_by)
+    values (v_intent.id, v_intent.user_id, v_intent.reference, v_amount, v_intent.currency, v_clawback, v_amount - v_clawback, p_reason, p_requested_by)
+    returning * into v_refund;
+
+    if v_clawback > 0 then
+        v_change := public.apply_wallet_delta(
+            p_user_id                 => v_intent.user_id,
+            p_datacredit_delta        => -v_clawback,
+            p_operation               => 'refund',
+            p_description             => p_description,
+            p_external_ref            => v_intent.reference,
+            p_metadata                => jsonb_build_object('refund_id', v_refund.id, 'payment_intent_id', v_intent.id),
+            p_datacredit_counterparty => p_counterparty
+        );
+
+        update public.refunds
+           set clawback_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint
+         where id = v_refund.id
+        returning * into v_refund;
+    end if;
+
+    if v_refund.debt_amount > 0 then
+        insert into public.wallet_debts (user_id, refund_id, amount, outstanding)
+        values (v_intent.user_id, v_refund.id, v_refund.debt_amount, v_refund.debt_amount);
+
+        perform public.post_journal_entry(
+            'refund_debt',
+            'Refunded datacredit already spent, owed by the user',
+            v_intent.reference,
+            jsonb_build_object('refund_id', v_refund.id, 'user_id', v_intent.user_id),
+            jsonb_build_array(
+                jsonb_build_object('account', 'platform:receivables', 'amount', -v_refund.debt_amount),
+                jsonb_build_object('account', p_counterparty, 'amount', v_refund.debt_amount)
+            )
+        );
+    end if;
+
+    return v_refund;
+end;
+$$;
+
+-- settle_refund applies Paystack's outcome ('processed' or 'failed') to a refund,
+-- found by id, Paystack refund id, or else the oldest unsettled refund of the
+-- payment reference for that amount. A failed refund returns the clawback and any
+-- collected debt to the wallet and cancels the outstanding debt. Callers get
+-- {"changed": false} when the refund was already settled.
+create or replace function public.settle_refund(
+    p_refund_id          uuid,
+    p_paystack_refund_id bigint,
+    p_reference          text,
+    p_amount             bigint,
+    p_outcome            text,
+    p_reason             text,
+    p_description        text,
+    p_counterparty       text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_refund      public.refunds%rowtype;
+    v_debt        public.wallet_debts%rowtype;
+    v_collected   bigint := 0;
+    v_outstanding bigint := 0;
+    v_change      jsonb;
+begin
+    if p_outcome not in ('processed', 'failed') then
+        raise exception 'unknown refund outcome %', p_outcome;
+    end if;
+
+    select * into v_refund
+      from public.refunds
+     where (p_refund_id is not null and id = p_refund_id)
+        or (p_paystack_refund_id is not null and paystack_refund_id = p_paystack_refund_id)
+     limit 1
+       for update;
+
+    if not found and p_reference is not null then
+        select * into v_refund
+          from public.refunds
+         where reference = p_reference
+           and (p_amount is null or amount = p_amount)
+           and status in ('pending', 'processing')
+         order by created_at
+         limit 1
+           for update;
+    end if;
+
+    if not found then
+        raise exception 'refund % (Paystack refund %, reference %) not found', p_refund_id, p_paystack_refund_id, p_reference
+            using errcode = 'DG014';
+    end if;
+
+    if v_refund.status in ('processed', 'failed') then
+        return jsonb_build_object('changed', false, 'refund', to_jsonb(v_refund));
+    end if;
+
+    if p_outcome = 'processed' then
+        update public.refunds
+           set status             = 'processed',
+               paystack_refund_id = coalesce(paystack_refund_id, p_paystack_refund_id),
+               processed_at       = now(),
+               updated_at         = now()
+         where id = v_refund.id
+        returning * into v_refund;
+
+        return jsonb_build_object('changed', true, 'refund', to_jsonb(v_refund));
+    end if;
+
+    select * into v_debt
+      from public.wallet_debts
+     where refund_id = v_refund.id
+       for update;
+
+    if found then
+        v_outstanding := v_debt.outstanding;
+        v_collected := v_debt.amount - v_debt.outstanding;
+
+        update public.wallet_debts
+           set outstanding = 0,
+               status      = 'cancelled',
+               updated_at  = now()
+         where id = v_debt.id;
+    end if;
+
+    if v_refund.clawback_amount + v_collected > 0 then
+        v_change := public.apply_wallet_delta(
+            p_user_id                 => v_refund.user_id,
+            p_datacredit_delta        => v_refund.clawback_amount + v_collected,
+            p_operation               => 'refund_reversal',
+            p_description             => p_description,
+            p_external_ref            => v_refund.reference,
+            p_metadata                => jsonb_build_object('refund_id', v_refund.id, 'reason', p_reason),
+            p_datacredit_counterparty => p_counterparty
+        );
+    end if;
+
+    if v_outstanding > 0 then
+        perform public.post_journal_entry(
+            'refund_reversal',
+            'Debt of failed refund cancelled',
+            v_refund.reference,
+            jsonb_build_object('refund_id', v_refund.id, 'user_id', v_refund.user_id),
+            jsonb_build_array(
+                jsonb_build_object('account', 'platform:receivables', 'amount', v_outstanding),
+                jsonb_build_object('account', p_counterparty, 'amount', -v_outstanding)
+            )
+        );
+    end if;
+
+    update public.refunds
+       set status                    = 'failed',
+           paystack_refund_id        = coalesce(paystack_refund_id, p_paystack_refund_id),
+           failure_reason            = p_reason,
+           reversal_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+           failed_at                 = now(),
+           updated_at                = now()
+     where id = v_refund.id
+    returning * into v_refund;
+
+    return jsonb_build_object('changed', true, 'refund', to_jsonb(v_refund), 'change', v_change);
+end;
+$$;
+
+-- collect_wallet_debts repays a user's open debts, oldest first, from their
+-- available datacredit and returns the amount collected.
+create or replace function public.collect_wallet_debts(
+    p_user_id uuid
+) returns bigint
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet    public.wallets%rowtype;
+    v_debt      public.wallet_debts%rowtype;
+    v_available bigint;
+    v_take      bigint;
+    v_total     bigint := 0;
+begin
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if not found then
+        return 0;
+    end if;
+    v_available := v_wallet.datacredit_balance - v_wallet.held_datacredit;
+
+    for v_debt in
+        select *
+          from public.wallet_debts
+         where user_id = p_user_id
+           and status = 'open'
+         order by created_at
+           for update
+    loop
+        exit when v_available <= 0;
+        v_take := least(v_debt.outstanding, v_available);
+
+        perform public.apply_wallet_delta(
+            p_user_id                 => p_user_id,
+            p_datacredit_delta        => -v_take,
+            p_operation               => 'debt_collection',
+            p_description             => 'Repayment of datacredit owed for a refund',
+            p_external_ref            => v_debt.refund_id::text,
+            p_metadata                => jsonb_build_object('wallet_debt_id', v_debt.id),
+            p_datacredit_counterparty => 'platform:receivables'
+        );
+
+        update public.wallet_debts
+           set outstanding = outstanding - v_take,
+               status      = case when outstanding - v_take = 0 then 'settled' else status end,
+               settled_at  = case when outstanding - v_take = 0 then now() end,
+               updated_at  = now()
+         where id = v_debt.id;
+
+        v_available := v_available - v_take;
+        v_total := v_total + v_take;
+    end loop;
+
+    return v_total;
+end;
+$$;
+
+revoke execute on function public.create_refund(text, bigint, text, uuid, text, text) from public, anon, authenticated;
+grant execute on function public.create_refund(text, bigint, text, uuid, text, text) to service_role;
+revoke execute on function public.settle_refund(uuid, bigint, text, bigint, text, text, text, text) from public, anon, authenticated;
+grant execute on function public.settle_refund(uuid, bigint, text, bigint, text, text, text, text) to service_role;
+revoke execute on function public.collect_wallet_debts(uuid) from public, anon, authenticated;
+grant execute on function public.collect_wallet_debts(uuid) to service_role;
@@ -0,0 +1,287 @@
+-- Chargebacks and disputes.
+--
+-- When a cardholder disputes a datacredit purchase, Paystack sends
+-- charge.dispute.create and the disputed amount is frozen in the user's wallet
+-- with a wallet hold, so it cannot be spent or withdrawn while the dispute is
+-- open. Admins answer the dispute through Paystack (evidence or acceptance) before
+-- its due date; charge.dispute.remind refreshes it. On resolution:
+--
+--   open / evidence_submitted --declined (merchant won)--> won   (hold released)
+--                             \--accepted (merchant lost)--> lost (debited)
+--
+-- A lost dispute is debited from the wallet as a 'chargeback'; whatever the user
+-- has already spent is recorded as a wallet debt, like a refund's.
+
+create table if not exists public.disputes (
+    id                     uuid        primary key default gen_random_uuid(),
+    paystack_dispute_id    bigint      not null unique,
+    payment_intent_id      uuid        references public.payment_intents (id),
+    user_id                uuid        references auth.users (id), -- Null when the reference matches no payment intent
+    reference              text        not null, -- Paystack transaction reference of the disputed payment
+    amount                 bigint      not null check (amount >= 0), -- kobo in dispute
+    currency               text        not null default 'NGN',
+    category               text,
+    status                 text        not null default 'open'
+                                       check (status in ('open', 'evidence_submitted', 'won', 'lost')),
+    paystack_status        text,
+    resolution             text,
+    hold_id                bigint      references public.wallet_holds (id),
+    held_amount            bigint      not null default 0 check (held_amount >= 0), -- kobo frozen in the wallet
+    debited_amount         bigint      not null default 0 check (debited_amount >= 0),
+    debt_amount            bigint      not null default 0 check (debt_amount >= 0),
+    debit_journal_entry_id bigint      references public.journal_entries (id),
+    due_at                 timestamptz,
+    last_reminded_at       timestamptz,
+    evidence_submitted_by  uuid,
+    evidence_submitted_at  timestamptz,
+    accepted_by            uuid,
+    resolved_at            timestamptz,
+    created_at             timestamptz not null default now(),
+    updated_at             timestamptz not null default now()
+);
+
+create index if not exists disputes_status_due_at_idx on public.disputes (status, due_at);
+create index if not exists disputes_reference_idx on public.disputes (reference);
+create index if not exists disputes_user_id_idx on public.disputes (user_id, created_at desc);
+
+alter table public.disputes enable row level security;
+
+alter table public.wallet_debts
+    add column if not exists dispute_id uuid references public.disputes (id);
+
+-- open_dispute records a dispute raised on a payment and freezes as much of the
+-- disputed amount as the user has available. Deliveries of a dispute already
+-- recorded only refresh its Paystack status and due date, and return
+-- {"changed": false}.
+create or replace function public.open_dispute(
+    p_paystack_dispute_id bigint,
+    p_reference           text,
+    p_amount              bigint,
+    p_currency            text,
+    p_category            text,
+    p_paystack_status     text,
+    p_due_at              timestamptz
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_dispute public.disputes%rowtype;
+    v_intent  public.payment_intents%rowtype;
+    v_wallet  public.wallets%rowtype;
+    v_hold    public.wallet_holds%rowtype;
+    v_held    bigint := 0;
+begin
+    select * into v_dispute
+      from public.disputes
+     where paystack_dispute_id = p_paystack_dispute_id
+       for update;
+
+    if found then
+        update public.disputes
+           set paystack_status = coalesce(p_paystack_status, paystack_status),
+               due_at          = coalesce(p_due_at, due_at),
+               updated_at      = now()
+         where id = v_dispute.id
+        returning * into v_dispute;
+
+        return jsonb_build_object('changed', false, 'dispute', to_jsonb(v_dispute));
+    end if;
+
+    select * into v_intent
+      from public.payment_intents
+     where reference = p_reference;
+
+    if found and v_intent.status = 'succeeded' then
+        insert into public.wallets (user_id, datacredit_balance, databyte_balance)
+        values (v_intent.user_id, 0, 0)
+        on conflict (user_id) do nothing;
+
+        select * into v_wallet
+          from public.wallets
+         where user_id = v_intent.user_id
+           for update;
+
+        v_held := least(p_amount, greatest(v_wallet.datacredit_balance - v_wallet.held_datacredit, 0));
+        if v_held > 0 then
+            v_hold := public.place_wallet_hold(v_intent.user_id, v_held, 'dispute', p_reference);
+        end if;
+    end if;
+
+    insert into public.disputes (paystack_dispute_id, payment_intent_id, user_id, reference, amount, currency, category,
+                                 paystack_status, hold_id, held_amount, due_at)
+    values (p_paystack_dispute_id, v_intent.id, v_intent.user_id, p_reference, p_amount, coalesce(p_currency, 'NGN'), p_category,
+            p_paystack_status, v_hold.id, v_held, p_due_at)
+    returning * into v_dispute;
+
+    return jsonb_build_object('changed', true, 'dispute', to_jsonb(v_dispute));
+end;
+$$;
+
+-- resolve_dispute applies a dispute's resolution: 'won' releases its hold, 'lost'
+-- releases it and debits p_amount (the dispute's amount if null) from the wallet
+-- as a chargeback, recording a debt for whatever is not available. It raises DG015
+-- if the dispute does not exist; callers get {"changed": false} when it was
+-- already resolved.
+create or replace function public.resolve_dispute(
+    p_paystack_dispute_id bigint,
+    p_outcome             text,
+    p_resolution          text,
+    p_amount              bigint,
+    p_description         text,
+    p_counterparty        text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_dispute public.disputes%rowtype;
+    v_wallet  public.wallets%rowtype;
+    v_amount  bigint;
+    v_debit   bigint := 0;
+    v_debt    bigint := 0;
+    v_change  jsonb;
+begin
+    if p_outcome not in ('won', 'lost') then
+        raise exception 'unknown dispute outcome %', p_outcome;
+    end if;
+
+    select * into v_dispute
+      from public.disputes
+     where paystack_dispute_id = p_paystack_dispute_id
+       for update;
+
+    if not found then
+        raise exception 'dispute % not found', p_paystack_dispute_id using errcode = 'DG015';
+    end if;
+    if v_dispute.status in ('won', 'lost') then
+        return jsonb_build_object('changed', false, 'dispute', to_jsonb(v_dispute));
+    end if;
+
+    if v_dispute.hold_id is not null then
+        perform public.release_wallet_hold(v_dispute.hold_id);
+    end if;
+
+    v_amount := coalesce(p_amount, v_dispute.amount);
+    if p_outcome = 'lost' and v_amount > 0 and v_dispute.user_id is not null then
+        select * into v_wallet
+          from public.wallets
+         where user_id = v_dispute.user_id
+           for update;
+
+        v_debit := least(v_amount, greatest(v_wallet.datacredit_balance - v_wallet.held_datacredit, 0));
+        v_debt := v_amount - v_debit;
+
+        if v_debit > 0 then
+            v_change := public.apply_wallet_delta(
+                p_user_id                 => v_dispute.user_id,
+                p_datacredit_delta        => -v_debit,
+                p_operation               => 'chargeback',
+                p_description             => p_description,
+                p_external_ref            => v_dispute.reference,
+                p_metadata                => jsonb_build_object('dispute_id', v_dispute.id, 'paystack_dispute_id', v_dispute.paystack_dispute_id),
+                p_datacredit_counterparty => p_counterparty
+            );
+        end if;
+
+        if v_debt > 0 then
+            insert into public.wallet_debts (user_id, dispute_id, amount, outstanding)
+            values (v_dispute.user_id, v_dispute.id, v_debt, v_debt);
+
+            perform public.post_journal_entry(
+                'chargeback_debt',
+                'Charged-back datacredit already spent, owed by the user',
+                v_dispute.reference,
+                jsonb_build_object('dispute_id', v_dispute.id, 'user_id', v_dispute.user_id),
+                jsonb_build_array(
+                    jsonb_build_object('account', 'platform:receivables', 'amount', -v_debt),
+                    jsonb_build_object('account', p_counterparty, 'amount', v_debt)
+                )
+            );
+        end if;
+    end if;
+
+    update public.disputes
+       set status                 = p_outcome,
+           resolution             = p_resolution,
+           debited_amount         = v_debit,
+           debt_amount            = v_debt,
+           debit_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+           resolved_at            = now(),
+           updated_at             = now()
+     where id = v_dispute.id
+    returning * into v_dispute;
+
+    return jsonb_build_object('changed', true, 'dispute', to_jsonb(v_dispute), 'change', v_change);
+end;
+$$;
+
+-- collect_wallet_debts now also collects chargeback debts, which have no refund.
+create or replace function public.collect_wallet_debts(
+    p_user_id uuid
+) returns bigint
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_wallet    public.wallets%rowtype;
+    v_debt      public.wallet_debts%rowtype;
+    v_available bigint;
+    v_take      bigint;
+    v_total     bigint := 0;
+begin
+    select * into v_wallet
+      from public.wallets
+     where user_id = p_user_id
+       for update;
+
+    if not found then
+        return 0;
+    end if;
+    v_available := v_wallet.datacredit_balance - v_wallet.held_datacredit;
+
+    for v_debt in
+        select *
+          from public.wallet_debts
+         where user_id = p_user_id
+           and status = 'open'
+         order by created_at
+           for update
+    loop
+        exit when v_available <= 0;
+        v_take := least(v_debt.outstanding, v_available);
+
+        perform public.apply_wallet_delta(
+            p_user_id                 => p_user_id,
+            p_datacredit_delta        => -v_take,
+            p_operation               => 'debt_collection',
+            p_description             => case when v_debt.dispute_id is not null
+                                              then 'Repayment of datacredit owed for a chargeback'
+                                              else 'Repayment of datacredit owed for a refund' end,
+            p_external_ref            => coalesce(v_debt.refund_id, v_debt.dispute_id)::text,
+            p_metadata                => jsonb_build_object('wallet_debt_id', v_debt.id),
+            p_datacredit_counterparty => 'platform:receivables'
+        );
+
+        update public.wallet_debts
+           set outstanding = outstanding - v_take,
+               status      = case when outstanding - v_take = 0 then 'settled' else status end,
+               settled_at  = case when outstanding - v_take = 0 then now() end,
+               updated_at  = now()
+         where id = v_debt.id;
+
+        v_available := v_available - v_take;
+        v_total := v_total + v_take;
+    end loop;
+
+    return v_total;
+end;
+$$;
+
+revoke execute on function public.open_dispute(bigint, text, bigint, text, text, text, timestamptz) from public, anon, authenticated;
+grant execute on function public.open_dispute(bigint, text, bigint, text, text, text, timestamptz) to service_role;
+revoke execute on function public.resolve_dispute(bigint, text, text, bigint, text, text) from public, anon, authenticated;
+grant execute on function public.resolve_dispute(bigint, text, text, bigint, text, text) to service_role;
@@ -0,0 +1,162 @@
+-- Ledger invariant checks.
+--
+-- Wallet balances are checked three ways: against their ledger accounts (as
+-- ledger_wallet_drift does), against the sum of their 'transactions' history,
+-- and, for held datacredit, against their active holds. Rows written before
+-- apply_wallet_delta logged its own transactions are the usual source of
+-- history drift. check_ledger_invariants adds the platform-wide checks.
+
+-- wallet_balance_drift lists users whose wallet, transaction history, ledger
+-- accounts and active holds disagree.
+create or replace view public.wallet_balance_drift as
+with tx as (
+    select user_id,
+           coalesce(sum(amount) filter (where currency = 'datacredit'), 0) as datacredit,
+           coalesce(sum(amount) filter (where currency = 'databyte'), 0)   as databyte
+      from public.transactions
+     group by user_id
+), ledger as (
+    select owner_user_id as user_id,
+           coalesce(sum(balance) filter (where currency = 'datacredit'), 0) as datacredit,
+           coalesce(sum(balance) filter (where currency = 'databyte'), 0)   as databyte
+      from public.ledger_accounts
+     where kind = 'user'
+     group by owner_user_id
+), holds as (
+    select user_id, sum(amount) as datacredit
+      from public.wallet_holds
+     where status = 'active'
+     group by user_id
+), users as (
+    select user_id from public.wallets
+    union
+    select user_id from tx
+    union
+    select user_id from ledger
+), balances as (
+    select u.user_id,
+           coalesce(w.datacredit_balance, 0) as wallet_datacredit_balance,
+           coalesce(tx.datacredit, 0)        as transactions_datacredit_balance,
+           coalesce(ledger.datacredit, 0)    as ledger_datacredit_balance,
+           coalesce(w.databyte_balance, 0)   as wallet_databyte_balance,
+           coalesce(tx.databyte, 0)          as transactions_databyte_balance,
+           coalesce(ledger.databyte, 0)      as ledger_databyte_balance,
+           coalesce(w.held_datacredit, 0)    as held_datacredit,
+           coalesce(holds.datacredit, 0)     as active_holds_datacredit
+      from users u
+      left join public.wallets w on w.user_id = u.user_id
+      left join tx on tx.user_id = u.user_id
+      left join ledger on ledger.user_id = u.user_id
+      left join holds on holds.user_id = u.user_id
+)
+select *
+  from balances
+ where wallet_datacredit_balance <> transactions_datacredit_balance
+    or wallet_datacredit_balance <> ledger_datacredit_balance
+    or wallet_databyte_balance <> transactions_databyte_balance
+    or wallet_databyte_balance <> ledger_databyte_balance
+    or held_datacredit <> active_holds_datacredit;
+
+revoke all on public.wallet_balance_drift from anon, authenticated;
+
+-- transaction_totals sums the transaction history per currency and operation:
+-- deposits, withdrawals, spending and the rest, which together must add up to
+-- the balances outstanding in wallets.
+create or replace view public.transaction_totals as
+select currency,
+       operation,
+       sum(amount) as total,
+       count(*)    as count
+  from public.transactions
+ group by currency, operation;
+
+revoke all on public.transaction_totals from anon, authenticated;
+
+-- check_ledger_invariants returns one row per platform-wide invariant and
+-- currency; an invariant holds when expected equals actual.
+create or replace function public.check_ledger_invariants()
+returns table (
+    name        text,
+    currency    text,
+    description text,
+    expected    bigint,
+    actual      bigint
+)
+language plpgsql
+stable
+security definer
+set search_path = public
+as $$
+declare
+    v_currency    text;
+    v_outstanding bigint;
+begin
+    foreach v_currency in array array['datacredit', 'databyte'] loop
+        name := 'ledger_zero_sum';
+        currency := v_currency;
+        description := 'Ledger account balances sum to zero';
+        expected := 0;
+        select coalesce(sum(a.balance), 0) into actual
+          from public.ledger_accounts a
+         where a.currency = v_currency;
+        return next;
+
+        name := 'ledger_balances_match_postings';
+        description := 'Total difference between ledger account balances and the sum of their postings';
+        expected := 0;
+        select coalesce(sum(abs(a.balance - coalesce(p.total, 0))), 0) into actual
+          from public.ledger_accounts a
+          left join (
+                select account_id, sum(amount) as total
+                  from public.postings
+                 group by account_id
+               ) p on p.account_id = a.id
+         where a.currency = v_currency;
+        return next;
+
+        select coalesce(sum(case when v_currency = 'datacredit' then w.datacredit_balance else w.databyte_balance end), 0)
+          into v_outstanding
+          from public.wallets w;
+
+        name := 'outstanding_matches_transactions';
+        description := 'Wallet balances outstanding equal deposits minus withdrawals and all other transactions';
+        select coalesce(sum(t.amount), 0) into expected
+          from public.transactions t
+         where t.currency = v_currency;
+        actual := v_outstanding;
+        return next;
+
+        name := 'outstanding_matches_ledger';
+        description := 'Wallet balances outstanding equal the balances of user ledger accounts';
+        select coalesce(sum(a.balance), 0) into expected
+          from public.ledger_accounts a
+         where a.kind = 'user' and a.currency = v_currency;
+        actual := v_outstanding;
+        return next;
+    end loop;
+
+    name := 'held_matches_active_holds';
+    currency := 'datacredit';
+    description := 'Held datacredit in wallets equals the active wallet holds';
+    select coalesce(sum(h.amount), 0) into expected
+      from public.wallet_holds h
+     where h.status = 'active';
+    select coalesce(sum(w.held_datacredit), 0) into actual
+      from public.wallets w;
+    return next;
+
+    name := 'deposits_match_payment_intents';
+    description := 'Credit purchases logged for payment intents equal the amounts of succeeded intents';
+    select coalesce(sum(i.amount), 0) into expected
+      from public.payment_intents i
+     where i.status = 'succeeded';
+    select coalesce(sum(t.amount), 0) into actual
+      from public.transactions t
+      join public.payment_intents i on i.reference = t.external_reference_id
+     where t.operation = 'credit_purchase' and t.currency = 'datacredit';
+    return next;
+end;
+$$;
+
+revoke execute on function public.check_ledger_invariants() from public, anon, authenticated;
+grant execute on function public.check_ledger_invariants() to service_role;
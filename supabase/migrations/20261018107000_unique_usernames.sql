@@ -0,0 +1,36 @@
+-- Usernames are unique regardless of case. The profile endpoint checks this
+-- before updating; the index closes the race between two users claiming the
+-- same username at once (the update then fails with 23505).
+--
+-- Usernames that already clash regardless of case are resolved first: the
+-- earliest profile keeps its username and the others get a suffix from their
+-- user ID (still within the 30 characters the API allows), which they can change.
+
+do $$
+declare
+    v_profile record;
+begin
+    for v_profile in
+        select id, username, new_username
+          from (
+              select id,
+                     username,
+                     left(username, 21) || '_' || left(replace(id::text, '-', ''), 8) as new_username,
+                     row_number() over (partition by lower(username) order by created_at, id) as rank
+                from public.profiles
+               where username is not null
+          ) ranked
+         where rank > 1
+    loop
+        raise notice 'renaming duplicate username % of profile % to %', v_profile.username, v_profile.id, v_profile.new_username;
+
+        update public.profiles
+           set username = v_profile.new_username
+         where id = v_profile.id;
+    end loop;
+end;
+$$;
+
+create unique index if not exists profiles_username_lower_key
+    on public.profiles (lower(username))
+ where username is not null;
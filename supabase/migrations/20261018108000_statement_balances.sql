@@ -0,0 +1,23 @@
+-- transaction_balances_before sums a user's transaction history before a
+-- moment, per currency: the opening balances of a statement starting then.
+
+create or replace function public.transaction_balances_before(
+    p_user_id uuid,
+    p_before  timestamptz
+) returns jsonb
+language sql
+stable
+security definer
+set search_path = public
+as $$
+    select jsonb_build_object(
+        'datacredit', coalesce(sum(amount) filter (where currency = 'datacredit'), 0),
+        'databyte',   coalesce(sum(amount) filter (where currency = 'databyte'), 0)
+    )
+      from public.transactions
+     where user_id = p_user_id
+       and transaction_timestamp < p_before;
+$$;
+
+revoke execute on function public.transaction_balances_before(uuid, timestamptz) from public, anon, authenticated;
+grant execute on function public.transaction_balances_before(uuid, timestamptz) to service_role;
@@ -0,0 +1,51 @@
+-- transaction_balances_as_of reconstructs a user's balances at a moment from
+-- the transaction log. For each currency it returns the sum of the amounts
+-- of every transaction up to and including p_as_of, how many there were, and
+-- the last of them, whose balance_after is the recorded balance at the time.
+
+create or replace function public.transaction_balances_as_of(
+    p_user_id uuid,
+    p_as_of   timestamptz
+) returns jsonb
+language plpgsql
+stable
+security definer
+set search_path = public
+as $$
+declare
+    v_currency text;
+    v_sum      bigint;
+    v_count    bigint;
+    v_last     public.transactions%rowtype;
+    v_result   jsonb := '[]'::jsonb;
+begin
+    foreach v_currency in array array['datacredit', 'databyte'] loop
+        select coalesce(sum(amount), 0), count(*)
+          into v_sum, v_count
+          from public.transactions
+         where user_id = p_user_id
+           and currency = v_currency
+           and transaction_timestamp <= p_as_of;
+
+        select * into v_last
+          from public.transactions
+         where user_id = p_user_id
+           and currency = v_currency
+           and transaction_timestamp <= p_as_of
+         order by transaction_timestamp desc, id desc
+         limit 1;
+
+        v_result := v_result || jsonb_build_object(
+            'currency', v_currency,
+            'summed_balance', v_sum,
+            'transaction_count', v_count,
+            'last_transaction', case when found then to_jsonb(v_last) end
+        );
+    end loop;
+
+    return v_result;
+end;
+$$;
+
+revoke execute on function public.transaction_balances_as_of(uuid, timestamptz) from public, anon, authenticated;
+grant execute on function public.transaction_balances_as_of(uuid, timestamptz) to service_role;
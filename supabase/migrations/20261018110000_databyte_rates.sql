@@ -0,0 +1,34 @@
+-- Versioned, volume-tiered databyte pricing.
+--
+-- Each row is one version of the datacredit -> databyte rate, in effect from
+-- effective_from until the next version's effective_from. tiers is a JSON
+-- array of {"min_databytes": n, "price_kobo": p, "per_databytes": d},
+-- ascending by min_databytes and starting at 1: a purchase of N databytes is
+-- priced at p kobo per d databytes of the highest tier whose
+-- min_databytes <= N, rounded up to whole kobo. Versions are never edited; a
+-- scheduled version that has not taken effect yet can be cancelled.
+
+create table if not exists public.databyte_rates (
+    id             bigserial   primary key,
+    effective_from timestamptz not null,
+    tiers          jsonb       not null check (jsonb_typeof(tiers) = 'array' and jsonb_array_length(tiers) > 0),
+    note           text,
+    created_by     uuid,
+    cancelled_by   uuid,
+    cancelled_at   timestamptz,
+    created_at     timestamptz not null default now()
+);
+
+create unique index if not exists databyte_rates_effective_from_key
+    on public.databyte_rates (effective_from)
+ where cancelled_at is null;
+
+alter table public.databyte_rates enable row level security;
+
+-- The rate that was compiled in as DATABYTES_PER_DATACREDIT_KOBO, in effect
+-- since before any purchase.
+insert into public.databyte_rates (effective_from, tiers, note)
+select 'epoch'::timestamptz,
+       '[{"min_databytes": 1, "price_kobo": 1, "per_databytes": 100}]'::jsonb,
+       'Initial rate (formerly DATABYTES_PER_DATACREDIT_KOBO)'
+ where not exists (select 1 from public.databyte_rates);
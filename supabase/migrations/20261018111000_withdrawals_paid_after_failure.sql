@@ -0,0 +1,171 @@
+-- Transfers that succeed after their withdrawal failed.
+--
+-- Paystack can report a transfer as successful after its withdrawal was failed
+-- and its hold released. The user was then paid without being debited, so
+-- settle_withdrawal flags the withdrawal (paid_after_failure_at) for an admin
+-- to recover the funds instead of ignoring the event. The flag is set once;
+-- redeliveries leave it as it is.
+
+alter table public.withdrawals
+    add column if not exists paid_after_failure_at            timestamptz,
+    add column if not exists paid_after_failure_transfer_code text;
+
+create index if not exists withdrawals_paid_after_failure_idx
+    on public.withdrawals (paid_after_failure_at)
+    where paid_after_failure_at is not null;
+
+create or replace function public.settle_withdrawal(
+    p_withdrawal_id uuid,
+    p_reference     text,
+    p_transfer_code text,
+    p_outcome       text,
+    p_reason        text,
+    p_description   text,
+    p_counterparty  text
+) returns jsonb
+language plpgsql
+security definer
+set search_path = public
+as $$
+declare
+    v_withdrawal public.withdrawals%rowtype;
+    v_change     jsonb;
+    v_fee_entry  bigint;
+begin
+    if p_outcome not in ('success', 'failed', 'reversed') then
+        raise exception 'unknown transfer outcome %', p_outcome;
+    end if;
+
+    select * into v_withdrawal
+      from public.withdrawals
+     where (p_withdrawal_id is not null and id = p_withdrawal_id)
+        or (p_reference is not null and reference = p_reference)
+        or (p_transfer_code is not null and transfer_code = p_transfer_code)
+     limit 1
+       for update;
+
+    if not found then
+        raise exception 'withdrawal for transfer % (reference %) not found', p_transfer_code, p_reference
+            using errcode = 'DG007';
+    end if;
+
+    if v_withdrawal.status in ('pending', 'awaiting_otp', 'processing') and v_withdrawal.hold_id is not null then
+        if p_outcome = 'success' then
+            v_change := public.capture_wallet_hold(
+                v_withdrawal.hold_id,
+                'withdrawal',
+                p_description,
+                coalesce(p_transfer_code, v_withdrawal.transfer_code, v_withdrawal.reference),
+                jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_reference', v_withdrawal.reference, 'fee', v_withdrawal.fee),
+                p_counterparty
+            );
+
+            if v_withdrawal.fee > 0 then
+                v_fee_entry := public.post_journal_entry(
+                    'withdrawal_fee',
+                    'Withdrawal fee',
+                    coalesce(p_transfer_code, v_withdrawal.transfer_code, v_withdrawal.reference),
+                    jsonb_build_object('withdrawal_id', v_withdrawal.id, 'user_id', v_withdrawal.user_id),
+                    jsonb_build_array(
+                        jsonb_build_object('account', p_counterparty, 'amount', -v_withdrawal.fee),
+                        jsonb_build_object('account', 'platform:fees', 'amount', v_withdrawal.fee)
+                    )
+                );
+            end if;
+
+            update public.withdrawals
+               set status                 = 'completed',
+                   transfer_code          = coalesce(transfer_code, p_transfer_code),
+                   debit_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+                   fee_journal_entry_id   = v_fee_entry,
+                   updated_at             = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        else
+            perform public.release_wallet_hold(v_withdrawal.hold_id);
+
+            update public.withdrawals
+               set status         = p_outcome,
+                   failure_reason = p_reason,
+                   updated_at     = now()
+             where id = v_withdrawal.id
+            returning * into v_withdrawal;
+        end if;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    -- Withdrawals created before wallet holds were debited when their transfer
+    -- started, so they are already 'processing' with no hold to capture.
+    if v_withdrawal.status = 'processing' and p_outcome = 'success' then
+        update public.withdrawals
+           set status     = 'completed',
+               updated_at = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    if v_withdrawal.status in ('processing', 'completed') and p_outcome <> 'success' then
+        -- The money was already debited: return it with a compensating credit, and
+        -- give back the fee if one was taken.
+        v_change := public.apply_wallet_delta(
+            p_user_id                 => v_withdrawal.user_id,
+            p_datacredit_delta        => v_withdrawal.amount,
+            p_operation               => 'withdrawal_reversal',
+            p_description             => p_description,
+            p_external_ref            => v_withdrawal.transfer_code,
+            p_metadata                => jsonb_build_object('withdrawal_id', v_withdrawal.id, 'transfer_outcome', p_outcome, 'reason', p_reason),
+            p_datacredit_counterparty => p_counterparty
+        );
+
+        if v_withdrawal.fee_journal_entry_id is not null then
+            perform public.post_journal_entry(
+                'withdrawal_fee_reversal',
+                'Withdrawal fee returned with reversed transfer',
+                v_withdrawal.transfer_code,
+                jsonb_build_object('withdrawal_id', v_withdrawal.id, 'user_id', v_withdrawal.user_id, 'fee_journal_entry_id', v_withdrawal.fee_journal_entry_id),
+                jsonb_build_array(
+                    jsonb_build_object('account', 'platform:fees', 'amount', -v_withdrawal.fee),
+                    jsonb_build_object('account', p_counterparty, 'amount', v_withdrawal.fee)
+                )
+            );
+        end if;
+
+        update public.withdrawals
+           set status                    = case when status = 'completed' then 'reversed' else p_outcome end,
+               failure_reason            = p_reason,
+               reversal_journal_entry_id = (v_change ->> 'journal_entry_id')::bigint,
+               updated_at                = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', true, 'withdrawal', to_jsonb(v_withdrawal), 'change', v_change);
+    end if;
+
+    if v_withdrawal.status = 'pending' then
+        raise exception 'withdrawal % has no hold and was never debited; settle it manually', v_withdrawal.id
+            using errcode = 'DG008';
+    end if;
+
+    -- Paystack paid out a withdrawal whose hold was already released: the user was
+    -- paid without being debited. Flag it for an admin to recover rather than
+    -- failing the event, which Paystack would only redeliver.
+    if v_withdrawal.status in ('failed', 'rejected') and p_outcome = 'success' then
+        update public.withdrawals
+           set paid_after_failure_at            = coalesce(paid_after_failure_at, now()),
+               paid_after_failure_transfer_code = coalesce(paid_after_failure_transfer_code, p_transfer_code, transfer_code),
+               updated_at                       = now()
+         where id = v_withdrawal.id
+        returning * into v_withdrawal;
+
+        return jsonb_build_object('changed', false, 'paid_after_failure', true, 'withdrawal', to_jsonb(v_withdrawal));
+    end if;
+
+    return jsonb_build_object('changed', false, 'withdrawal', to_jsonb(v_withdrawal));
+end;
+$$;
+
+revoke execute on function public.settle_withdrawal(uuid, text, text, text, text, text, text) from public, anon, authenticated;
+grant execute on function public.settle_withdrawal(uuid, text, text, text, text, text, text) to service_role;